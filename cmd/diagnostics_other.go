@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// dumpOnSIGUSR1 is a no-op where SIGUSR1 does not exist.
+func dumpOnSIGUSR1(string) {}
@@ -0,0 +1,32 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/guttosm/b3pulse/internal/diag"
+	"github.com/guttosm/b3pulse/internal/logger"
+)
+
+// dumpOnSIGUSR1 writes a goroutine dump and heap profile (see diag.Dump) to
+// dir, or to stderr when dir is empty, each time the process receives SIGUSR1.
+// The process keeps running.
+func dumpOnSIGUSR1(dir string) {
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+
+	go func() {
+		for range usr1 {
+			paths, err := diag.Dump(dir, os.Stderr, time.Now())
+			if err != nil {
+				logger.L().Error().Err(err).Msg("diagnostics dump failed")
+				continue
+			}
+			logger.L().Info().Strs("files", paths).Msg("SIGUSR1 received, diagnostics dumped")
+		}
+	}()
+}
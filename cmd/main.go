@@ -24,6 +24,7 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
@@ -33,31 +34,66 @@ import (
 	"github.com/guttosm/b3pulse/config"
 	_ "github.com/guttosm/b3pulse/docs" // swagger docs
 	"github.com/guttosm/b3pulse/internal/app"
+	"github.com/guttosm/b3pulse/internal/calendar"
 	"github.com/guttosm/b3pulse/internal/ingestion"
+	"github.com/guttosm/b3pulse/internal/instrument"
 	"github.com/guttosm/b3pulse/internal/logger"
 )
 
-// startServer initializes and starts the HTTP server in a separate goroutine.
+// newServer builds the HTTP server from the server configuration.
+//
+// HTTP/1.1 is always served. With cfg.HTTP2, HTTP/2 is added over TLS when a
+// certificate is configured, or as cleartext h2c otherwise.
 //
 // Parameters:
 //   - router (http.Handler): The HTTP router (Gin Engine) configured with all routes.
 //   - port (string): The port where the server will listen for incoming requests.
+//   - cfg (config.ServerConfig): Timeouts, header limit and protocol settings.
 //
 // Returns:
-//   - *http.Server: The initialized HTTP server instance.
-func startServer(router http.Handler, port string) *http.Server {
-	server := &http.Server{
+//   - *http.Server: The configured, not yet listening, server.
+func newServer(router http.Handler, port string, cfg config.ServerConfig) *http.Server {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	if cfg.TLSCertFile != "" {
+		protocols.SetHTTP2(cfg.HTTP2)
+	} else {
+		protocols.SetUnencryptedHTTP2(cfg.HTTP2)
+	}
+
+	return &http.Server{
 		Addr:              ":" + port,
 		Handler:           router,
-		ReadTimeout:       15 * time.Second,
-		ReadHeaderTimeout: 10 * time.Second,
-		WriteTimeout:      30 * time.Second,
-		IdleTimeout:       60 * time.Second,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		Protocols:         &protocols,
 	}
+}
+
+// startServer initializes and starts the HTTP server in a separate goroutine.
+//
+// Parameters:
+//   - router (http.Handler): The HTTP router (Gin Engine) configured with all routes.
+//   - port (string): The port where the server will listen for incoming requests.
+//   - cfg (config.ServerConfig): Server tuning; see newServer.
+//
+// Returns:
+//   - *http.Server: The initialized HTTP server instance.
+func startServer(router http.Handler, port string, cfg config.ServerConfig) *http.Server {
+	server := newServer(router, port, cfg)
 
 	go func() {
-		logger.L().Info().Str("port", port).Msg("server starting")
-		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.L().Info().Str("port", port).Bool("http2", cfg.HTTP2).Bool("tls", cfg.TLSCertFile != "").Msg("server starting")
+		var err error
+		if cfg.TLSCertFile != "" {
+			err = server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.L().Fatal().Err(err).Msg("server failed to start")
 		}
 	}()
@@ -85,42 +121,118 @@ func gracefulShutdown(ctx context.Context, server *http.Server, cleanup func())
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.L().Fatal().Err(err).Msg("server forced to shutdown")
 	}
+	if err := ingestion.ShutdownWorkerPool(shutdownCtx); err != nil {
+		logger.L().Error().Err(err).Msg("ingestion worker pool did not drain before shutdown")
+	}
 
 	cleanup()
 	logger.L().Info().Msg("server exited gracefully")
 }
 
+// reloadOnSIGHUP re-applies the hot-reloadable configuration (see app.ReloadConfig)
+// each time the process receives SIGHUP. A failed reload keeps the current settings.
+func reloadOnSIGHUP() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		for range hup {
+			logger.L().Info().Msg("SIGHUP received, reloading configuration")
+			if err := app.ReloadConfig(); err != nil {
+				logger.L().Error().Err(err).Msg("configuration reload failed, keeping current settings")
+			}
+		}
+	}()
+}
+
+// Values of the --output flag.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
 // main is the entry point of the b3pulse application.
 //
 // Modes (selected via --mode flag):
 //   - ingest: Processes the last 7 business days of .txt files from ./data/input/.
-//   - api:    Starts the REST API to expose aggregated trade data.
+//   - api:    Starts the REST API to expose aggregated trade data. SIGHUP reloads
+//     the log level, rate limit and request timeout.
+//   - ingest-then-api: Runs ingest, then api in the same process. A failed
+//     ingestion exits non-zero without serving, so one container can load the
+//     latest data and then serve it.
+//   - check:  Pre-flight for ingest: lists, for each of the last --days business days,
+//     whether its file is in --dir, with size and modification time, and exits non-zero
+//     if any is missing. Files are only stat'ed; the database is not used.
+//
+// In all modes SIGUSR1 dumps goroutines and the heap to DEBUG_DUMP_DIR (or stderr).
 //
 // Flags:
-//   - --mode: Execution mode ("ingest" or "api"). Default: "ingest".
+//   - --mode: Execution mode ("ingest", "api" or "ingest-then-api"). Default: "ingest".
 //   - --dir:  Directory containing .txt input files. Default: "./data/input".
 //   - --port: Port for the API server. Defaults to value from config (SERVER_PORT).
+//   - --allow-missing: Skip business days without an input file instead of aborting the ingestion.
+//   - --continue-on-error: Process every day even if some fail; report which days succeeded and failed.
+//   - --analyze: Run ANALYZE on trades after a successful ingestion. Defaults to INGEST_ANALYZE_AFTER.
+//   - --resume: Checkpoint each committed batch and continue files that failed part-way
+//     in an earlier --resume run after their last committed line.
+//   - --confirm-delete: Let --force delete a day's trades even when ingestion_log has no
+//     entry for it (by default such a day fails, so a mistyped date cannot wipe data).
+//   - --instruments: Path to B3's instruments reference file, loaded before the trades
+//     so /api/v1/aggregate?enrich=true can add the company name and ISIN.
+//   - --output: "text" (default) or "json". With json, ingest prints one JSON
+//     summary (ingestion.RunSummary) to stdout at the end and logs go to stderr;
+//     check prints its report (ingestion.CheckReport) as JSON instead of a table.
 func main() {
 	ctx := context.Background()
 
 	// Load configuration from environment or .env file
 	config.LoadConfig()
 
-	// Initialize JSON logger
-	logger.Init()
-
 	// Parse CLI flags (override config defaults if provided)
-	mode := flag.String("mode", "ingest", "Mode: ingest or api")
+	mode := flag.String("mode", "ingest", "Mode: ingest, api, ingest-then-api (serve only after a successful ingestion), or check (report missing input files)")
 	dir := flag.String("dir", "./data/input", "Directory with .txt files")
 	days := flag.Int("days", 7, "Number of last business days to ingest (1-7)")
 	parallel := flag.Int("parallel", 0, "How many files to process concurrently (0=auto up to CPU, max 7)")
 	force := flag.Bool("force", false, "Reprocess days even if already ingested (deletes existing trades for that day)")
-	port := flag.String("port", config.AppConfig.Server.Port, "Port for API mode")
+	allowMissing := flag.Bool("allow-missing", false, "Skip business days whose file is absent instead of failing (still fails if all are missing)")
+	continueOnError := flag.Bool("continue-on-error", false, "Keep ingesting other days when one file fails and report all failures at the end (default: fail fast)")
+	analyze := flag.Bool("analyze", config.AppConfig.Ingest.AnalyzeAfter, "Run ANALYZE on trades after a successful ingestion to refresh planner statistics")
+	resume := flag.Bool("resume", false, "Checkpoint every committed batch and resume files interrupted in an earlier --resume run instead of restarting them")
+	confirmDelete := flag.Bool("confirm-delete", false, "With --force, also delete existing trades of days that have no ingestion_log entry")
+	instruments := flag.String("instruments", "", "Optional B3 instruments reference file (TckrSymb;ISIN;CrpnNm columns) to load for ?enrich=true")
+	port := flag.String("port", config.AppConfig.Server.Port, "Port for API mode (also ingest-then-api)")
+	output := flag.String("output", outputText, "Ingest result format: text, or json (summary on stdout, logs on stderr)")
 	flag.Parse()
 
-	switch *mode {
-	case "ingest":
-		// Ingestion mode: process .txt files and persist trades
+	// Initialize JSON logger; keep stdout clean for the JSON summary
+	if *output == outputJSON {
+		logger.InitTo(os.Stderr)
+	} else {
+		logger.Init()
+	}
+	if *output != outputText && *output != outputJSON {
+		logger.L().Fatal().Str("output", *output).Msg("unknown output format")
+	}
+
+	// Merge configured closures into the business-day calendar (fail fast on bad entries)
+	if err := calendar.SetExtraHolidays(config.AppConfig.Calendar.ExtraHolidays); err != nil {
+		logger.L().Fatal().Err(err).Msg("invalid EXTRA_HOLIDAYS")
+	}
+
+	// Canonicalize aliased instrument codes at ingest and query time (fail fast on bad entries)
+	if err := instrument.SetAliases(config.AppConfig.Instrument.Aliases); err != nil {
+		logger.L().Fatal().Err(err).Msg("invalid INSTRUMENT_ALIASES")
+	}
+	if aliases := instrument.Aliases(); len(aliases) > 0 {
+		logger.L().Info().Strs("instrument_aliases", aliases).Msg("instrument aliases loaded")
+	}
+
+	// On-demand goroutine/heap dumps for stuck processes (Unix only)
+	dumpOnSIGUSR1(config.AppConfig.Debug.DumpDir)
+
+	// runIngestion processes the last *days business days of *dir and, with
+	// --output json, prints the run summary to stdout.
+	runIngestion := func() error {
 		logger.L().Info().Msg("running ingestion")
 		if *days < 1 {
 			*days = 1
@@ -132,17 +244,44 @@ func main() {
 		// Direct DB connection for ingestion
 		db, err := app.InitPostgres(config.AppConfig)
 		if err != nil {
-			logger.L().Fatal().Err(err).Msg("db connect error")
+			if *output == outputJSON {
+				_ = ingestion.RunSummary{Error: err.Error()}.WriteJSON(os.Stdout)
+			}
+			return fmt.Errorf("db connect error: %w", err)
 		}
 		defer func() { _ = db.Close() }()
 
-		if err := ingestion.ProcessDirectory(ctx, *dir, db, *days, *parallel, *force); err != nil {
-			logger.L().Fatal().Err(err).Msg("ingestion failed")
+		if *instruments != "" {
+			if _, err := ingestion.LoadInstruments(ctx, *instruments, db); err != nil {
+				if *output == outputJSON {
+					_ = ingestion.RunSummary{Error: err.Error()}.WriteJSON(os.Stdout)
+				}
+				return fmt.Errorf("load instruments: %w", err)
+			}
+		}
+
+		summary, err := ingestion.ProcessDirectoryWithSummary(ctx, *dir, db, *days, *parallel, ingestion.ProcessOptions{
+			Force:           *force,
+			AllowMissing:    *allowMissing,
+			ContinueOnError: *continueOnError,
+			Analyze:         *analyze,
+			Resume:          *resume,
+			ConfirmDelete:   *confirmDelete,
+		})
+		if *output == outputJSON {
+			if werr := summary.WriteJSON(os.Stdout); werr != nil {
+				logger.L().Error().Err(werr).Msg("write ingestion summary failed")
+			}
+		}
+		if err != nil {
+			return err
 		}
 		logger.L().Info().Msg("ingestion completed successfully")
+		return nil
+	}
 
-	case "api":
-		// API mode: start the HTTP server
+	// serveAPI starts the HTTP server and blocks until SIGINT/SIGTERM.
+	serveAPI := func() {
 		logger.L().Info().Msg("starting API server")
 
 		router, cleanup, err := app.InitializeApp()
@@ -150,8 +289,46 @@ func main() {
 			logger.L().Fatal().Err(err).Msg("app init error")
 		}
 
-		server := startServer(router, *port)
+		server := startServer(router, *port, config.AppConfig.Server)
+		reloadOnSIGHUP()
 		gracefulShutdown(ctx, server, cleanup)
+	}
+
+	switch *mode {
+	case "ingest":
+		// Ingestion mode: process .txt files and persist trades
+		if err := runIngestion(); err != nil {
+			logger.L().Fatal().Err(err).Msg("ingestion failed")
+		}
+
+	case "api":
+		// API mode: start the HTTP server
+		serveAPI()
+
+	case "ingest-then-api":
+		// Load the latest data, then serve it; never serve after a failed ingestion
+		if err := runIngestion(); err != nil {
+			logger.L().Fatal().Err(err).Msg("ingestion failed, not starting API server")
+		}
+		serveAPI()
+
+	case "check":
+		// Pre-flight: are all input files there? No parsing, no database
+		report, err := ingestion.CheckDirectory(*dir, *days)
+		if err != nil {
+			logger.L().Fatal().Err(err).Msg("check failed")
+		}
+		if *output == outputJSON {
+			err = report.WriteJSON(os.Stdout)
+		} else {
+			err = report.WriteText(os.Stdout)
+		}
+		if err != nil {
+			logger.L().Fatal().Err(err).Msg("write check report failed")
+		}
+		if !report.Complete {
+			logger.L().Fatal().Int("missing", report.Missing).Str("dir", *dir).Msg("input files missing")
+		}
 
 	default:
 		logger.L().Fatal().Str("mode", *mode).Msg("unknown mode")
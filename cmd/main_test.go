@@ -2,19 +2,29 @@ package main
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"os"
 	"syscall"
 	"testing"
 	"time"
+
+	"github.com/guttosm/b3pulse/config"
 )
 
 type dummyHandler struct{}
 
+// protoHandler echoes the negotiated protocol.
+type protoHandler struct{}
+
+func (protoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte(r.Proto))
+}
+
 func (d dummyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
 
 func TestStartServerAndShutdown(t *testing.T) {
-	srv := startServer(dummyHandler{}, "0") // random port
+	srv := startServer(dummyHandler{}, "0", config.ServerConfig{}) // random port
 	if srv == nil {
 		t.Fatalf("expected server")
 	}
@@ -41,7 +51,7 @@ func TestStartServerAndShutdown(t *testing.T) {
 
 func TestGracefulShutdown_SignalPath(t *testing.T) {
 	// Use a server that responds immediately
-	srv := startServer(dummyHandler{}, "0")
+	srv := startServer(dummyHandler{}, "0", config.ServerConfig{})
 
 	cleaned := make(chan struct{}, 1)
 	go func() {
@@ -63,3 +73,69 @@ func TestGracefulShutdown_SignalPath(t *testing.T) {
 		t.Fatalf("cleanup not called after SIGTERM")
 	}
 }
+
+func TestNewServer_AppliesConfig(t *testing.T) {
+	cfg := config.ServerConfig{
+		ReadTimeout:       1 * time.Second,
+		ReadHeaderTimeout: 2 * time.Second,
+		WriteTimeout:      3 * time.Second,
+		IdleTimeout:       4 * time.Second,
+		MaxHeaderBytes:    4096,
+	}
+	srv := newServer(dummyHandler{}, "9999", cfg)
+	if srv.Addr != ":9999" || srv.ReadTimeout != cfg.ReadTimeout || srv.ReadHeaderTimeout != cfg.ReadHeaderTimeout ||
+		srv.WriteTimeout != cfg.WriteTimeout || srv.IdleTimeout != cfg.IdleTimeout || srv.MaxHeaderBytes != cfg.MaxHeaderBytes {
+		t.Fatalf("server does not reflect config: %+v", srv)
+	}
+	if !srv.Protocols.HTTP1() || srv.Protocols.UnencryptedHTTP2() || srv.Protocols.HTTP2() {
+		t.Fatalf("expected HTTP/1 only by default, got %v", srv.Protocols)
+	}
+
+	cfg.HTTP2 = true
+	if p := newServer(dummyHandler{}, "0", cfg).Protocols; !p.HTTP1() || !p.UnencryptedHTTP2() {
+		t.Fatalf("expected h2c alongside HTTP/1, got %v", p)
+	}
+	cfg.TLSCertFile, cfg.TLSKeyFile = "cert.pem", "key.pem"
+	if p := newServer(dummyHandler{}, "0", cfg).Protocols; !p.HTTP1() || !p.HTTP2() || p.UnencryptedHTTP2() {
+		t.Fatalf("expected HTTP/2 over TLS, got %v", p)
+	}
+}
+
+func TestNewServer_H2C(t *testing.T) {
+	cases := []struct {
+		name  string
+		http2 bool
+		want  string
+	}{
+		{name: "disabled falls back to HTTP/1.1", http2: false, want: "HTTP/1.1"},
+		{name: "enabled serves h2c", http2: true, want: "HTTP/2.0"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newServer(protoHandler{}, "0", config.ServerConfig{HTTP2: tc.http2})
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listen: %v", err)
+			}
+			go func() { _ = srv.Serve(ln) }()
+			t.Cleanup(func() { _ = srv.Close() })
+
+			client := &http.Client{Timeout: 2 * time.Second}
+			if tc.http2 {
+				// h2c with prior knowledge: the client must not offer HTTP/1.1.
+				var protocols http.Protocols
+				protocols.SetUnencryptedHTTP2(true)
+				client.Transport = &http.Transport{Protocols: &protocols}
+			}
+
+			resp, err := client.Get("http://" + ln.Addr().String())
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			if resp.Proto != tc.want {
+				t.Fatalf("proto=%s, want %s", resp.Proto, tc.want)
+			}
+		})
+	}
+}
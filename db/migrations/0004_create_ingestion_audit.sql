@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- One row per ProcessDirectory run (success or failure), for compliance/audit purposes
+CREATE TABLE IF NOT EXISTS ingestion_audit (
+    run_id          UUID PRIMARY KEY,
+    mode            TEXT NOT NULL,
+    requested_dates DATE[] NOT NULL DEFAULT '{}',
+    files_processed INTEGER NOT NULL DEFAULT 0,
+    total_rows      BIGINT NOT NULL DEFAULT 0,
+    errors          TEXT[] NOT NULL DEFAULT '{}',
+    success         BOOLEAN NOT NULL,
+    force           BOOLEAN NOT NULL DEFAULT FALSE,
+    started_at      TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    finished_at     TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_ingestion_audit_started_at
+    ON ingestion_audit (started_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS ingestion_audit;
+-- +goose StatementEnd
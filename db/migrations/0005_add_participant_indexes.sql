@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Indexes backing participant (broker) activity queries, which filter on
+-- either side of the trade within a date range
+CREATE INDEX IF NOT EXISTS idx_trades_buyer_date
+    ON trades (buyer_participant_code, trade_date);
+
+CREATE INDEX IF NOT EXISTS idx_trades_seller_date
+    ON trades (seller_participant_code, trade_date);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_trades_seller_date;
+DROP INDEX IF EXISTS idx_trades_buyer_date;
+-- +goose StatementEnd
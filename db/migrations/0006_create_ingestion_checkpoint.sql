@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Progress of resumable (--resume) ingestions, committed with each trades batch
+CREATE TABLE IF NOT EXISTS ingestion_checkpoint (
+    file_date   DATE PRIMARY KEY,
+    filename    TEXT NOT NULL,
+    line_number BIGINT NOT NULL,
+    row_count   BIGINT NOT NULL DEFAULT 0,
+    updated_at  TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS ingestion_checkpoint;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Serves GetLatestPrice (latest trade of a ticker) with a single index probe;
+-- the ordering must match the query's ORDER BY for the planner to use it
+CREATE INDEX IF NOT EXISTS idx_trades_instr_latest
+    ON trades (instrument_code, trade_date DESC NULLS LAST, closing_time DESC NULLS LAST);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_trades_instr_latest;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Optional reference data from B3's instruments file (see ingestion.LoadInstruments);
+-- aggregates join it with ?enrich=true and behave as before while it is empty
+CREATE TABLE IF NOT EXISTS instruments (
+    instrument_code TEXT PRIMARY KEY,
+    isin            TEXT NOT NULL DEFAULT '',
+    name            TEXT NOT NULL DEFAULT '',
+    updated_at      TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS instruments;
+-- +goose StatementEnd
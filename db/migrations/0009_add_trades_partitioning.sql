@@ -0,0 +1,79 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Opt-in monthly range partitioning of trades on trade_date. Nothing changes
+-- until an operator runs SELECT partition_trades_by_month(); afterwards, with
+-- INGEST_PARTITION_MONTHLY=true, the ingester calls ensure_trades_partition
+-- for each day before loading it.
+
+-- ensure_trades_partition creates trades_YYYY_MM, the partition holding d's
+-- month, unless it exists. Callers are serialized by an advisory lock so
+-- files of the same month loaded in parallel do not race on CREATE TABLE.
+CREATE OR REPLACE FUNCTION ensure_trades_partition(d DATE)
+RETURNS VOID AS $$
+DECLARE
+    month_start DATE := date_trunc('month', d)::DATE;
+    part_name   TEXT := 'trades_' || to_char(month_start, 'YYYY_MM');
+BEGIN
+    IF to_regclass(part_name) IS NOT NULL THEN
+        RETURN;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = 'trades'::regclass) THEN
+        RAISE EXCEPTION 'trades is not partitioned; run SELECT partition_trades_by_month() first';
+    END IF;
+
+    PERFORM pg_advisory_xact_lock(hashtext('ensure_trades_partition'));
+    EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF trades FOR VALUES FROM (%L) TO (%L)',
+                   part_name, month_start, (month_start + INTERVAL '1 month')::DATE);
+END;
+$$ LANGUAGE plpgsql;
+
+-- partition_trades_by_month rebuilds trades as a table partitioned by month,
+-- with one partition per month that has trades and a default partition for
+-- rows without a trade_date. It copies every row under an exclusive lock, so
+-- run it in a maintenance window. Running it again is a no-op.
+--
+-- The partitioned table has no primary key: one would have to include the
+-- nullable trade_date. id keeps its default and gets a plain index; the other
+-- indexes are recreated under their names.
+CREATE OR REPLACE FUNCTION partition_trades_by_month()
+RETURNS VOID AS $$
+DECLARE
+    idx RECORD;
+    m   DATE;
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = 'trades'::regclass) THEN
+        RETURN;
+    END IF;
+
+    LOCK TABLE trades IN ACCESS EXCLUSIVE MODE;
+    ALTER TABLE trades RENAME TO trades_unpartitioned;
+    CREATE TABLE trades (LIKE trades_unpartitioned INCLUDING DEFAULTS) PARTITION BY RANGE (trade_date);
+
+    FOR idx IN
+        SELECT indexname, indexdef FROM pg_indexes
+        WHERE schemaname = current_schema() AND tablename = 'trades_unpartitioned' AND indexname <> 'trades_pkey'
+    LOOP
+        EXECUTE format('ALTER INDEX %I RENAME TO %I', idx.indexname, left(idx.indexname, 59) || '_old');
+        EXECUTE replace(idx.indexdef, '.trades_unpartitioned ', '.trades ');
+    END LOOP;
+    CREATE INDEX IF NOT EXISTS idx_trades_id ON trades (id);
+
+    FOR m IN
+        SELECT DISTINCT date_trunc('month', trade_date)::DATE FROM trades_unpartitioned WHERE trade_date IS NOT NULL
+    LOOP
+        PERFORM ensure_trades_partition(m);
+    END LOOP;
+    CREATE TABLE IF NOT EXISTS trades_default PARTITION OF trades DEFAULT;
+
+    INSERT INTO trades SELECT * FROM trades_unpartitioned;
+    DROP TABLE trades_unpartitioned;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- A table already partitioned stays partitioned; only the functions go.
+DROP FUNCTION IF EXISTS partition_trades_by_month();
+DROP FUNCTION IF EXISTS ensure_trades_partition(DATE);
+-- +goose StatementEnd
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/internal/middleware"
+)
+
+// AdminHandler provides operational endpoints under /admin.
+//
+// Responsibilities:
+//   - /admin/stats: Per-route request counts and latency percentiles.
+type AdminHandler struct {
+	stats *middleware.LatencyStats
+}
+
+// NewAdminHandler constructs an AdminHandler reporting from the given stats collector.
+func NewAdminHandler(stats *middleware.LatencyStats) *AdminHandler {
+	return &AdminHandler{stats: stats}
+}
+
+// Register mounts the admin endpoints on the provided group, which is expected
+// to be protected by middleware.AdminAuth.
+//
+// Routes:
+//   - GET /stats: Returns request counts and p50/p90/p99 latency per route.
+func (h *AdminHandler) Register(g *gin.RouterGroup) {
+	g.GET("/stats", h.GetStats)
+}
+
+// GetStats handles GET /admin/stats requests.
+//
+// GetStats godoc
+// @Summary      Request latency stats
+// @Description  Returns request counts and p50/p90/p99 latency (ms) per route since startup
+// @Tags         admin
+// @Produce      json
+// @Param        X-Admin-Key  header    string  false  "Admin API key (required when ADMIN_API_KEY is set)"
+// @Success      200          {object}  map[string][]middleware.RouteStats
+// @Failure      401          {object}  dto.ErrorResponse  "Unauthorized"
+// @Router       /admin/stats [get]
+func (h *AdminHandler) GetStats(c *gin.Context) {
+	middleware.RespondJSON(c, http.StatusOK, gin.H{"routes": h.stats.Snapshot()})
+}
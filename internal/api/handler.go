@@ -1,16 +1,83 @@
 package api
 
 import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
-	"time"
-
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/config"
+	"github.com/guttosm/b3pulse/internal/calendar"
 	"github.com/guttosm/b3pulse/internal/domain/dto"
+	"github.com/guttosm/b3pulse/internal/domain/models"
+	"github.com/guttosm/b3pulse/internal/instrument"
+	"github.com/guttosm/b3pulse/internal/middleware"
 	"github.com/guttosm/b3pulse/internal/service"
+	"github.com/guttosm/b3pulse/internal/storage"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// cacheControlImmutable is used for ranges ending before the latest ingested day, whose
+	// aggregates no longer change.
+	cacheControlImmutable = "public, max-age=86400, immutable"
+	// cacheControlRecent is used for everything else: open-ended ranges, ranges touching today
+	// or days not ingested yet, and empty results.
+	cacheControlRecent = "public, max-age=30"
+
+	// dataAsOfHeader tells clients how current an aggregate's data is.
+	dataAsOfHeader = "X-Data-As-Of"
+
+	// exportFlushRows is how many CSV (or NDJSON) rows are buffered between flushes to the client.
+	exportFlushRows = 1000
+
+	// maxWindowDays bounds the "window=Nd" query param (about one trading year).
+	maxWindowDays = 250
+
+	// maxGapsRangeDays bounds the calendar span checked by GetGaps.
+	maxGapsRangeDays = 366
+
+	// maxSMARangeDays bounds the calendar span of a GetSMA series (about five years).
+	maxSMARangeDays = 5 * 366
+
+	// defaultCrossLimit and maxCrossLimit bound the page size of GetCrossTrades.
+	defaultCrossLimit = 50
+	maxCrossLimit     = 500
 )
 
+// exportHeader lists the CSV columns written by ExportTrades, in order.
+var exportHeader = []string{
+	"reference_date", "instrument_code", "update_action", "trade_price", "trade_quantity",
+	"closing_time", "trade_identifier_code", "session_type", "trade_date",
+	"buyer_participant_code", "seller_participant_code",
+}
+
+// nowFunc is an indirection for the current time; tests override it.
+var nowFunc = time.Now
+
+// appLocation returns the timezone whose calendar days the API works in
+// (APP_TIMEZONE); UTC when unset.
+func appLocation() *time.Location {
+	if loc := config.AppConfig.Calendar.Location; loc != nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// today returns the current calendar day in appLocation as midnight UTC, the
+// same representation as dates parsed from query params.
+func today() time.Time {
+	now := nowFunc().In(appLocation())
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
 // Handler provides HTTP handlers for trade aggregation endpoints.
 //
 // Responsibilities:
@@ -18,6 +85,7 @@ import (
 //   - Interact with the repository layer for data access
 //   - Translate repository results into response DTOs
 //   - Return structured JSON responses with appropriate HTTP status codes
+//     (via middleware.RespondJSON/RespondError, which apply API_ENVELOPE)
 type Handler struct {
 	svc service.AggregateService
 }
@@ -25,7 +93,7 @@ type Handler struct {
 // NewHandler constructs a new Handler instance.
 //
 // Parameters:
-//   - repo (storage.TradesRepository): Repository dependency used for querying trade data.
+//   - svc (service.AggregateService): Service used for querying trade data.
 //
 // Returns:
 //   - *Handler: A handler ready to be registered with the router.
@@ -38,11 +106,33 @@ func NewHandler(svc service.AggregateService) *Handler {
 // Query Parameters:
 //   - ticker (string, required): Stock ticker symbol (e.g., "PETR4").
 //   - data_inicio (string, optional): Minimum trade date in YYYY-MM-DD format.
+//   - data_fim (string, optional): Maximum trade date (inclusive) in YYYY-MM-DD format.
+//   - window (string, optional): Last N business days ending yesterday, e.g. "5d".
+//     Mutually exclusive with data_inicio/data_fim.
+//   - min_qty (int, optional): Only trades of at least this quantity count towards
+//     max_range_value; volumes and trade_count are unaffected.
+//   - date_field (string, optional): "trade_date" (default) or "reference_date";
+//     the date column the range and daily volumes use.
+//   - end_inclusive (bool, optional): "false" treats data_fim as an exclusive upper
+//     bound (trade_date < data_fim); default true (trade_date <= data_fim).
+//   - explain (bool, optional): Include the query plan as query_plan; requires DEBUG_EXPLAIN.
+//   - format (string, optional): "json" (default) or "prometheus" for the figures as
+//     gauges in Prometheus text exposition format, e.g. for a pushgateway. Errors stay JSON.
+//   - enrich (bool, optional): Add name and isin from the instruments reference file
+//     (ingest --instruments); omitted when the file was not loaded or lacks the ticker.
 //
 // Responses:
-//   - 200 OK: Returns AggregateResponse containing max price and max daily volume.
-//   - 400 Bad Request: Missing or invalid query parameters.
-//   - 404 Not Found: No trades found for the given ticker/date range.
+//   - 200 OK: Returns AggregateResponse containing max price and max daily volume,
+//     plus the resolved range_start/range_end (omitted for an open bound).
+//     If the ticker only traded outside the range, figures are zero and has_data_outside_range=true.
+//     Cache-Control is immutable for ranges ending before today, short-lived otherwise
+//     (no-store with explain). X-Data-As-Of carries the latest day with trades in the
+//     range (YYYY-MM-DD), omitted when there is none.
+//   - 400 Bad Request: Missing or invalid query parameters (including explain with
+//     format=prometheus), or (code NO_TRADING_DAYS_IN_RANGE) a range with no business day,
+//     e.g. a weekend.
+//   - 403 Forbidden: explain=true while DEBUG_EXPLAIN is off.
+//   - 404 Not Found: The ticker has never traded.
 //   - 500 Internal Server Error: Failure in repository or database layer.
 //
 // GetAggregate godoc
@@ -50,62 +140,1397 @@ func NewHandler(svc service.AggregateService) *Handler {
 // @Description  Returns max price and max daily volume for the given ticker since an optional start date
 // @Tags         aggregate
 // @Accept       json
-// @Produce      json
+// @Produce      json,plain
 // @Param        ticker       query     string  true   "Stock ticker" example(PETR4)
 // @Param        data_inicio  query     string  false  "Start date in YYYY-MM-DD" example(2024-09-01)
+// @Param        data_fim     query     string  false  "End date (inclusive) in YYYY-MM-DD" example(2024-09-30)
+// @Param        window       query     string  false  "Last N business days ending yesterday; excludes data_inicio/data_fim" example(5d)
+// @Param        min_qty      query     int     false  "Minimum trade quantity for the max price" example(100)
+// @Param        date_field   query     string  false  "Date column for the range" Enums(trade_date, reference_date) default(trade_date)
+// @Param        end_inclusive query    bool    false  "Whether data_fim is included in the range" default(true)
+// @Param        explain      query     bool    false  "Include the EXPLAIN ANALYZE plan (requires DEBUG_EXPLAIN)"
+// @Param        format       query     string  false  "Response format" Enums(json, prometheus) default(json)
+// @Param        enrich       query     bool    false  "Add name and isin from the instruments reference file" default(false)
 // @Success      200          {object}  dto.AggregateResponse  "Success"
+// @Header       200          {string}  X-Data-As-Of           "Latest day with trades in the range (YYYY-MM-DD)"
 // @Failure      400          {object}  dto.ErrorResponse      "Bad Request"
-// @Failure      404          {object}  dto.ErrorResponse      "Not Found"
+// @Failure      403          {object}  dto.ErrorResponse      "Explain disabled"
+// @Failure      404          {object}  dto.ErrorResponse      "Unknown ticker"
 // @Failure      500          {object}  dto.ErrorResponse      "Internal Error"
 // @Router       /api/v1/aggregate [get]
 func (h *Handler) GetAggregate(c *gin.Context) {
 	// ─── Validate "ticker" param ──────────────────────────────
-	ticker := strings.ToUpper(strings.TrimSpace(c.Query("ticker")))
+	ticker := queryTicker(c, "ticker")
 	if ticker == "" {
-		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("ticker is required", nil))
+		middleware.RespondError(c, http.StatusBadRequest, "ticker is required", nil)
 		return
 	}
 
-	// ─── Parse optional "data_inicio" param ───────────────────
-	var startDate *time.Time
-	var endDate *time.Time
-	if s := c.Query("data_inicio"); s != "" {
-		parsed, err := time.Parse("2006-01-02", s)
+	// ─── Parse optional "data_inicio"/"data_fim" params ───────
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	// ─── Parse optional "min_qty" param ───────────────────────
+	var opts storage.AggregateOptions
+	if raw := c.Query("min_qty"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 1 {
+			middleware.RespondError(c, http.StatusBadRequest, "min_qty must be a positive integer", nil)
+			return
+		}
+		opts.MinQty = &n
+	}
+
+	// ─── Parse optional "date_field" param ────────────────────
+	opts.DateField = c.Query("date_field")
+	if !storage.ValidDateField(opts.DateField) {
+		middleware.RespondError(c, http.StatusBadRequest, "date_field must be trade_date or reference_date", nil)
+		return
+	}
+
+	// ─── Parse optional "end_inclusive" param ─────────────────
+	if raw := c.Query("end_inclusive"); raw != "" {
+		v, err := strconv.ParseBool(raw)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid data_inicio format, expected YYYY-MM-DD", err))
+			middleware.RespondError(c, http.StatusBadRequest, "end_inclusive must be true or false", nil)
 			return
 		}
-		startDate = &parsed
-		// When provided, include data where trade_date >= data_inicio (no upper bound)
-	} else {
-		// Default: last 7 ingested days, ending yesterday
-		today := time.Now().UTC()
-		yday := today.AddDate(0, 0, -1)
-		start := yday.AddDate(0, 0, -6)
-		// normalize to date-only (strip time)
-		start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
-		yday = time.Date(yday.Year(), yday.Month(), yday.Day(), 0, 0, 0, 0, time.UTC)
-		startDate = &start
-		endDate = &yday
+		opts.EndExclusive = !v
 	}
 
-	// ─── Query service (with request context) ─────────────────
-	agg, err := h.svc.GetAggregate(c.Request.Context(), ticker, startDate, endDate)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to fetch aggregates", err))
+	// ─── Reject ranges without a single trading day ───────────
+	// Otherwise they yield the same 404 as an unknown ticker.
+	if startDate != nil && endDate != nil {
+		last := *endDate
+		if opts.EndExclusive {
+			last = last.AddDate(0, 0, -1)
+		}
+		// HasBusinessDay stops at the first one found: the range is not capped.
+		if !calendar.HasBusinessDay(*startDate, last) {
+			middleware.RespondErrorCode(c, http.StatusBadRequest, dto.CodeNoTradingDays,
+				"the date range contains no trading days (only weekends/holidays)", nil)
+			return
+		}
+	}
+
+	// ─── Parse optional "format" param ────────────────────────
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != formatPrometheus {
+		middleware.RespondError(c, http.StatusBadRequest, "format must be json or prometheus", nil)
 		return
 	}
-	if agg == nil {
-		c.JSON(http.StatusNotFound, dto.NewErrorResponse("no data found", nil))
+
+	// ─── Parse optional "enrich" param ────────────────────────
+	enrich := false
+	if raw := c.Query("enrich"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			middleware.RespondError(c, http.StatusBadRequest, "enrich must be true or false", nil)
+			return
+		}
+		enrich = v
+	}
+
+	// ─── Optional query plan, only where explicitly enabled ───
+	explain := c.Query("explain") == "true"
+	if explain && !config.AppConfig.Debug.Explain {
+		middleware.RespondError(c, http.StatusForbidden, "explain is disabled", nil)
+		return
+	}
+	if explain && format == formatPrometheus {
+		middleware.RespondError(c, http.StatusBadRequest, "explain is only available with format=json", nil)
+		return
+	}
+
+	// ─── Query service (with request context) ─────────────────
+	ctx := withLogFields(c, startDate, endDate, ticker)
+	agg, err := h.svc.GetAggregate(ctx, ticker, startDate, endDate, opts)
+	switch {
+	case errors.Is(err, service.ErrNoData):
+		middleware.RespondError(c, http.StatusNotFound, "unknown ticker", nil)
+		return
+	case err != nil:
+		middleware.RespondError(c, http.StatusInternalServerError, "failed to fetch aggregates", err)
 		return
 	}
 
 	// ─── Build and return response DTO ────────────────────────
 	resp := dto.AggregateResponse{
-		Ticker:         agg.Ticker,
-		MaxRangeValue:  agg.MaxRangeValue,
-		MaxDailyVolume: agg.MaxDailyVolume,
+		Ticker:              agg.Ticker,
+		MaxRangeValue:       roundPrice(agg.MaxRangeValue),
+		MaxDailyVolume:      agg.MaxDailyVolume,
+		MinDailyVolume:      agg.MinDailyVolume,
+		TradeCount:          agg.TradeCount,
+		TradingDays:         agg.TradingDays,
+		HasDataOutsideRange: agg.HasDataOutsideRange,
+		RangeStart:          formatDate(startDate),
+		RangeEnd:            formatDate(endDate),
+		DateField:           opts.DateField,
+	}
+	if opts.MinQty != nil {
+		resp.MinQty = *opts.MinQty
+	}
+	if enrich {
+		// Reference data is optional: a missing instrument or lookup failure
+		// leaves the fields out rather than failing the aggregate.
+		in, err := h.svc.GetInstrument(ctx, ticker)
+		switch {
+		case err == nil:
+			resp.Name, resp.ISIN = in.Name, in.ISIN
+		case !errors.Is(err, service.ErrNoData):
+			middleware.Log(c).Warn().Err(err).Msg("instrument lookup failed")
+		}
+	}
+	if !agg.LatestDate.IsZero() {
+		c.Header(dataAsOfHeader, agg.LatestDate.Format("2006-01-02"))
+	}
+
+	if explain {
+		plan, err := h.svc.ExplainAggregate(ctx, ticker, startDate, endDate, opts)
+		if err != nil {
+			middleware.RespondError(c, http.StatusInternalServerError, "failed to explain aggregate query", err)
+			return
+		}
+		resp.QueryPlan = plan
+		c.Header("Cache-Control", "no-store")
+		middleware.RespondJSON(c, http.StatusOK, resp)
+		return
+	}
+
+	c.Header("Cache-Control", h.cacheControlFor(c, endDate, agg.TradeCount == 0 || agg.HasDataOutsideRange))
+	if format == formatPrometheus {
+		c.Data(http.StatusOK, prometheusContentType, renderAggregatePrometheus(resp))
+		return
+	}
+	middleware.RespondJSON(c, http.StatusOK, resp)
+}
+
+// GetAggregateBySession handles GET /api/v1/aggregate/by-session requests.
+//
+// Query Parameters: same as GetAggregate (ticker, data_inicio, data_fim, window).
+//
+// Responses:
+//   - 200 OK: Returns SessionAggregateResponse with one aggregate per session type;
+//     sessions is empty when the ticker has no trades in the period.
+//   - 400 Bad Request: Missing or invalid query parameters.
+//   - 500 Internal Server Error: Failure in repository or database layer.
+//
+// GetAggregateBySession godoc
+// @Summary      Get aggregates by trading session
+// @Description  Returns the aggregate figures split by session type (TipoSessaoPregao), e.g. regular vs after-market
+// @Tags         aggregate
+// @Produce      json
+// @Param        ticker       query     string  true   "Stock ticker" example(PETR4)
+// @Param        data_inicio  query     string  false  "Start date in YYYY-MM-DD" example(2024-09-01)
+// @Param        data_fim     query     string  false  "End date (inclusive) in YYYY-MM-DD" example(2024-09-30)
+// @Param        window       query     string  false  "Last N business days ending yesterday; excludes data_inicio/data_fim" example(5d)
+// @Success      200          {object}  dto.SessionAggregateResponse  "Success"
+// @Failure      400          {object}  dto.ErrorResponse             "Bad Request"
+// @Failure      500          {object}  dto.ErrorResponse             "Internal Error"
+// @Router       /api/v1/aggregate/by-session [get]
+func (h *Handler) GetAggregateBySession(c *gin.Context) {
+	ticker := queryTicker(c, "ticker")
+	if ticker == "" {
+		middleware.RespondError(c, http.StatusBadRequest, "ticker is required", nil)
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.svc.GetAggregateBySession(withLogFields(c, startDate, endDate, ticker), ticker, startDate, endDate)
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "failed to fetch session aggregates", err)
+		return
+	}
+
+	resp := dto.SessionAggregateResponse{
+		Ticker:     ticker,
+		Sessions:   make(map[string]*dto.AggregateResponse, len(sessions)),
+		RangeStart: formatDate(startDate),
+		RangeEnd:   formatDate(endDate),
+	}
+	for session, agg := range sessions {
+		resp.Sessions[session] = toAggregateResponse(agg)
+	}
+
+	c.Header("Cache-Control", h.cacheControlFor(c, endDate, len(sessions) == 0))
+	middleware.RespondJSON(c, http.StatusOK, resp)
+}
+
+// GetSpread handles GET /api/v1/spread requests.
+//
+// Query Parameters: same as GetAggregateBySession (ticker, data_inicio, data_fim, window).
+//
+// Responses:
+//   - 200 OK: Returns SpreadResponse with the max and min trade price and the
+//     spread between them, absolute and as a percentage of the min price.
+//   - 400 Bad Request: Missing or invalid query parameters.
+//   - 404 Not Found: The ticker has no trades in the period.
+//   - 500 Internal Server Error: Failure in repository or database layer.
+//
+// GetSpread godoc
+// @Summary      Get the high-low price spread
+// @Description  Returns the max and min trade price of a ticker in the period and the spread between them, for volatility screening
+// @Tags         aggregate
+// @Produce      json
+// @Param        ticker       query     string  true   "Stock ticker" example(PETR4)
+// @Param        data_inicio  query     string  false  "Start date in YYYY-MM-DD" example(2024-09-01)
+// @Param        data_fim     query     string  false  "End date (inclusive) in YYYY-MM-DD" example(2024-09-30)
+// @Param        window       query     string  false  "Last N business days ending yesterday; excludes data_inicio/data_fim" example(5d)
+// @Success      200          {object}  dto.SpreadResponse  "Success"
+// @Failure      400          {object}  dto.ErrorResponse   "Bad Request"
+// @Failure      404          {object}  dto.ErrorResponse   "Not Found"
+// @Failure      500          {object}  dto.ErrorResponse   "Internal Error"
+// @Router       /api/v1/spread [get]
+func (h *Handler) GetSpread(c *gin.Context) {
+	ticker := queryTicker(c, "ticker")
+	if ticker == "" {
+		middleware.RespondError(c, http.StatusBadRequest, "ticker is required", nil)
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	spread, err := h.svc.GetPriceSpread(withLogFields(c, startDate, endDate, ticker), ticker, startDate, endDate)
+	switch {
+	case errors.Is(err, service.ErrNoData):
+		middleware.RespondError(c, http.StatusNotFound, "no data found", nil)
+		return
+	case err != nil:
+		middleware.RespondError(c, http.StatusInternalServerError, "failed to fetch price spread", err)
+		return
+	}
+
+	c.Header("Cache-Control", h.cacheControlFor(c, endDate, false))
+	middleware.RespondJSON(c, http.StatusOK, dto.SpreadResponse{
+		Ticker:     spread.Ticker,
+		MaxPrice:   roundPrice(spread.MaxPrice),
+		MinPrice:   roundPrice(spread.MinPrice),
+		SpreadAbs:  roundPrice(spread.SpreadAbs),
+		SpreadPct:  spread.SpreadPct,
+		RangeStart: formatDate(startDate),
+		RangeEnd:   formatDate(endDate),
+	})
+}
+
+// GetNotional handles GET /api/v1/notional requests.
+//
+// Query Parameters: same as GetAggregateBySession (ticker, data_inicio, data_fim, window).
+//
+// Responses:
+//   - 200 OK: Returns NotionalResponse with the financial value traded in the
+//     period (sum of price * quantity, in R$) and the number of trades behind it.
+//   - 400 Bad Request: Missing or invalid query parameters.
+//   - 404 Not Found: The ticker has no trades in the period.
+//   - 500 Internal Server Error: Failure in repository or database layer.
+//
+// GetNotional godoc
+// @Summary      Get the notional traded
+// @Description  Returns the financial value (sum of price * quantity) traded in a ticker over the period
+// @Tags         aggregate
+// @Produce      json
+// @Param        ticker       query     string  true   "Stock ticker" example(PETR4)
+// @Param        data_inicio  query     string  false  "Start date in YYYY-MM-DD" example(2024-09-01)
+// @Param        data_fim     query     string  false  "End date (inclusive) in YYYY-MM-DD" example(2024-09-30)
+// @Param        window       query     string  false  "Last N business days ending yesterday; excludes data_inicio/data_fim" example(5d)
+// @Success      200          {object}  dto.NotionalResponse  "Success"
+// @Failure      400          {object}  dto.ErrorResponse     "Bad Request"
+// @Failure      404          {object}  dto.ErrorResponse     "Not Found"
+// @Failure      500          {object}  dto.ErrorResponse     "Internal Error"
+// @Router       /api/v1/notional [get]
+func (h *Handler) GetNotional(c *gin.Context) {
+	ticker := queryTicker(c, "ticker")
+	if ticker == "" {
+		middleware.RespondError(c, http.StatusBadRequest, "ticker is required", nil)
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	n, err := h.svc.GetNotional(withLogFields(c, startDate, endDate, ticker), ticker, startDate, endDate)
+	switch {
+	case errors.Is(err, service.ErrNoData):
+		middleware.RespondError(c, http.StatusNotFound, "no data found", nil)
+		return
+	case err != nil:
+		middleware.RespondError(c, http.StatusInternalServerError, "failed to fetch notional", err)
+		return
+	}
+
+	c.Header("Cache-Control", h.cacheControlFor(c, endDate, false))
+	middleware.RespondJSON(c, http.StatusOK, dto.NotionalResponse{
+		Ticker:         n.Ticker,
+		NotionalTraded: roundPrice(n.Notional),
+		TradeCount:     n.TradeCount,
+		RangeStart:     formatDate(startDate),
+		RangeEnd:       formatDate(endDate),
+	})
+}
+
+// GetLatestPrice handles GET /api/v1/latest requests.
+//
+// Query Parameters:
+//   - ticker (string, required): Stock ticker symbol (e.g., "PETR4").
+//
+// Responses:
+//   - 200 OK: Returns LatestPriceResponse with the price, quantity, date and time
+//     of the ticker's most recent trade. Cache-Control is short-lived.
+//   - 400 Bad Request: Missing ticker.
+//   - 404 Not Found: The ticker has no trades.
+//   - 500 Internal Server Error: Failure in repository or database layer.
+//
+// GetLatestPrice godoc
+// @Summary      Get the latest trade price
+// @Description  Returns the most recent trade (latest trade date, then closing time) of a ticker, for price tapes
+// @Tags         aggregate
+// @Produce      json
+// @Param        ticker  query     string  true  "Stock ticker" example(PETR4)
+// @Success      200     {object}  dto.LatestPriceResponse  "Success"
+// @Failure      400     {object}  dto.ErrorResponse        "Bad Request"
+// @Failure      404     {object}  dto.ErrorResponse        "Not Found"
+// @Failure      500     {object}  dto.ErrorResponse        "Internal Error"
+// @Router       /api/v1/latest [get]
+func (h *Handler) GetLatestPrice(c *gin.Context) {
+	ticker := queryTicker(c, "ticker")
+	if ticker == "" {
+		middleware.RespondError(c, http.StatusBadRequest, "ticker is required", nil)
+		return
+	}
+
+	latest, err := h.svc.GetLatestPrice(withLogFields(c, nil, nil, ticker), ticker)
+	switch {
+	case errors.Is(err, service.ErrNoData):
+		middleware.RespondError(c, http.StatusNotFound, "no data found", nil)
+		return
+	case err != nil:
+		middleware.RespondError(c, http.StatusInternalServerError, "failed to fetch latest price", err)
+		return
+	}
+
+	resp := dto.LatestPriceResponse{
+		Ticker:   latest.Ticker,
+		Price:    roundPrice(latest.Price),
+		Quantity: latest.Quantity,
+	}
+	if !latest.TradeDate.IsZero() {
+		resp.TradeDate = latest.TradeDate.Format("2006-01-02")
+	}
+	if !latest.ClosingTime.IsZero() {
+		resp.ClosingTime = latest.ClosingTime.Format("15:04:05")
+	}
+
+	c.Header("Cache-Control", cacheControlRecent)
+	middleware.RespondJSON(c, http.StatusOK, resp)
+}
+
+// GetDailyAggregate handles GET /api/v1/aggregate/daily requests.
+//
+// Query Parameters:
+//   - ticker (string, required): Stock ticker symbol (e.g., "PETR4").
+//   - data (string, required): Trade date in YYYY-MM-DD format.
+//
+// Responses:
+//   - 200 OK: Returns DailyAggregateResponse with max price, total volume and trade count.
+//   - 400 Bad Request: Missing or invalid query parameters.
+//   - 404 Not Found: The ticker did not trade on the given date.
+//   - 500 Internal Server Error: Failure in repository or database layer.
+//
+// GetDailyAggregate godoc
+// @Summary      Get aggregate for a single day
+// @Description  Returns max price, total volume and trade count for the given ticker on a single trade date
+// @Tags         aggregate
+// @Produce      json
+// @Param        ticker  query     string  true  "Stock ticker" example(PETR4)
+// @Param        data    query     string  true  "Trade date in YYYY-MM-DD" example(2025-09-18)
+// @Success      200     {object}  dto.DailyAggregateResponse  "Success"
+// @Failure      400     {object}  dto.ErrorResponse           "Bad Request"
+// @Failure      404     {object}  dto.ErrorResponse           "Not Found"
+// @Failure      500     {object}  dto.ErrorResponse           "Internal Error"
+// @Router       /api/v1/aggregate/daily [get]
+func (h *Handler) GetDailyAggregate(c *gin.Context) {
+	ticker := queryTicker(c, "ticker")
+	if ticker == "" {
+		middleware.RespondError(c, http.StatusBadRequest, "ticker is required", nil)
+		return
+	}
+
+	s := c.Query("data")
+	if s == "" {
+		middleware.RespondError(c, http.StatusBadRequest, "data is required", nil)
+		return
+	}
+	date, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "invalid data format, expected YYYY-MM-DD", err)
+		return
+	}
+
+	daily, err := h.svc.GetDailyAggregate(withLogFields(c, &date, &date, ticker), ticker, date)
+	switch {
+	case errors.Is(err, service.ErrNoData):
+		middleware.RespondError(c, http.StatusNotFound, "no data found", nil)
+		return
+	case err != nil:
+		middleware.RespondError(c, http.StatusInternalServerError, "failed to fetch daily aggregate", err)
+		return
+	}
+
+	middleware.RespondJSON(c, http.StatusOK, dto.DailyAggregateResponse{
+		Ticker:      daily.Ticker,
+		Date:        daily.Date.Format("2006-01-02"),
+		MaxPrice:    roundPrice(daily.MaxPrice),
+		TotalVolume: daily.TotalVolume,
+		TradeCount:  daily.TradeCount,
+	})
+}
+
+// GetCalendar handles GET /api/v1/calendar requests.
+//
+// Query Parameters:
+//   - ticker (string, required): Stock ticker symbol (e.g., "PETR4").
+//   - year (int, required): Calendar year, e.g. 2025.
+//   - month (int, required): Calendar month, 1-12.
+//
+// Responses:
+//   - 200 OK: Returns CalendarResponse with one entry per business day of the
+//     month up to today, zero-filled for days without trades.
+//   - 400 Bad Request: Missing or invalid query parameters.
+//   - 500 Internal Server Error: Failure in repository or database layer.
+//
+// GetCalendar godoc
+// @Summary      Month calendar of daily figures
+// @Description  Returns per-day total volume and max price for every trading day of a month (zero-filled), for heatmaps
+// @Tags         aggregate
+// @Produce      json
+// @Param        ticker  query     string  true  "Stock ticker" example(PETR4)
+// @Param        year    query     int     true  "Year" example(2025)
+// @Param        month   query     int     true  "Month (1-12)" example(9)
+// @Success      200     {object}  dto.CalendarResponse  "Success"
+// @Failure      400     {object}  dto.ErrorResponse     "Bad Request"
+// @Failure      500     {object}  dto.ErrorResponse     "Internal Error"
+// @Router       /api/v1/calendar [get]
+func (h *Handler) GetCalendar(c *gin.Context) {
+	ticker := queryTicker(c, "ticker")
+	if ticker == "" {
+		middleware.RespondError(c, http.StatusBadRequest, "ticker is required", nil)
+		return
+	}
+	year, err := strconv.Atoi(c.Query("year"))
+	if err != nil || year < 1900 || year > 9999 {
+		middleware.RespondError(c, http.StatusBadRequest, "year must be an integer between 1900 and 9999", nil)
+		return
+	}
+	month, err := strconv.Atoi(c.Query("month"))
+	if err != nil || month < 1 || month > 12 {
+		middleware.RespondError(c, http.StatusBadRequest, "month must be an integer between 1 and 12", nil)
+		return
+	}
+
+	// Days after today cannot have trades yet; don't report them as zero.
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, -1)
+	if today := today(); end.After(today) {
+		end = today
+	}
+
+	resp := dto.CalendarResponse{Ticker: ticker, Year: year, Month: month, Days: []dto.CalendarDay{}}
+	if !end.Before(start) {
+		days, err := h.svc.GetCalendar(withLogFields(c, &start, &end, ticker), ticker, start, end)
+		if err != nil {
+			middleware.RespondError(c, http.StatusInternalServerError, "failed to fetch calendar", err)
+			return
+		}
+		for _, d := range days {
+			resp.Days = append(resp.Days, dto.CalendarDay{
+				Date:     d.Date.Format("2006-01-02"),
+				Volume:   d.TotalVolume,
+				MaxPrice: roundPrice(d.MaxPrice),
+			})
+		}
+	}
+
+	c.Header("Cache-Control", h.cacheControlFor(c, &end, len(resp.Days) == 0))
+	middleware.RespondJSON(c, http.StatusOK, resp)
+}
+
+// GetSMA handles GET /api/v1/sma requests.
+//
+// Query Parameters:
+//   - ticker (string, required): Stock ticker symbol (e.g., "PETR4").
+//   - window (int, required): Number of trading days averaged, 1..250.
+//   - data_inicio (string, optional): First date in YYYY-MM-DD format; default
+//     six days before data_fim.
+//   - data_fim (string, optional): Last date (inclusive) in YYYY-MM-DD format;
+//     default yesterday. The range may span at most 1830 days.
+//
+// Unlike the other endpoints, window is a number of days to average, not a
+// date range ("5d").
+//
+// Responses:
+//   - 200 OK: Returns SMAResponse with, for each day with trades in the range,
+//     the close and the mean close of the last window trading days (see
+//     AggregateService.GetSMA). sma is null until window days are available,
+//     counting stored days before data_inicio. Days is empty without trades.
+//   - 400 Bad Request: Missing or invalid query parameters.
+//   - 500 Internal Server Error: Failure in repository or database layer.
+//
+// GetSMA godoc
+// @Summary      Simple moving average of the daily close
+// @Description  Returns per trading day the close and its simple moving average over the last window trading days
+// @Tags         aggregate
+// @Produce      json
+// @Param        ticker       query     string  true   "Stock ticker" example(PETR4)
+// @Param        window       query     int     true   "Trading days averaged (1-250)" example(20)
+// @Param        data_inicio  query     string  false  "Start date in YYYY-MM-DD" example(2025-09-01)
+// @Param        data_fim     query     string  false  "End date (inclusive) in YYYY-MM-DD" example(2025-09-30)
+// @Success      200          {object}  dto.SMAResponse    "Success"
+// @Failure      400          {object}  dto.ErrorResponse  "Bad Request"
+// @Failure      500          {object}  dto.ErrorResponse  "Internal Error"
+// @Router       /api/v1/sma [get]
+func (h *Handler) GetSMA(c *gin.Context) {
+	ticker := queryTicker(c, "ticker")
+	if ticker == "" {
+		middleware.RespondError(c, http.StatusBadRequest, "ticker is required", nil)
+		return
+	}
+	window, err := strconv.Atoi(c.Query("window"))
+	if err != nil || window < 1 || window > maxWindowDays {
+		middleware.RespondError(c, http.StatusBadRequest, fmt.Sprintf("window must be an integer between 1 and %d", maxWindowDays), nil)
+		return
+	}
+
+	startDate, endDate, ok := parseDates(c)
+	if !ok {
+		return
+	}
+	if endDate == nil {
+		yday := today().AddDate(0, 0, -1)
+		endDate = &yday
+	}
+	if startDate == nil {
+		start := endDate.AddDate(0, 0, -6)
+		startDate = &start
+	}
+	if startDate.After(*endDate) {
+		middleware.RespondError(c, http.StatusBadRequest, "data_inicio must not be after data_fim (default: yesterday)", nil)
+		return
+	}
+	if endDate.Sub(*startDate) >= maxSMARangeDays*24*time.Hour {
+		middleware.RespondError(c, http.StatusBadRequest, fmt.Sprintf("range must span at most %d days", maxSMARangeDays), nil)
+		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	points, err := h.svc.GetSMA(withLogFields(c, startDate, endDate, ticker), ticker, *startDate, *endDate, window)
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "failed to compute moving average", err)
+		return
+	}
+
+	resp := dto.SMAResponse{
+		Ticker:     ticker,
+		Window:     window,
+		RangeStart: startDate.Format("2006-01-02"),
+		RangeEnd:   endDate.Format("2006-01-02"),
+		Days:       make([]dto.SMADay, 0, len(points)),
+	}
+	for _, p := range points {
+		day := dto.SMADay{Date: p.Date.Format("2006-01-02"), Close: roundPrice(p.Close)}
+		if p.SMA != nil {
+			sma := roundPrice(*p.SMA)
+			day.SMA = &sma
+		}
+		resp.Days = append(resp.Days, day)
+	}
+
+	c.Header("Cache-Control", h.cacheControlFor(c, endDate, len(resp.Days) == 0))
+	middleware.RespondJSON(c, http.StatusOK, resp)
+}
+
+// Compare handles GET /api/v1/compare requests.
+//
+// Query Parameters:
+//   - ticker_a (string, required): First stock ticker symbol (e.g., "PETR4").
+//   - ticker_b (string, required): Second stock ticker symbol (e.g., "VALE3").
+//   - data_inicio (string, optional): Minimum trade date in YYYY-MM-DD format.
+//   - data_fim (string, optional): Maximum trade date (inclusive) in YYYY-MM-DD format.
+//
+// Responses:
+//   - 200 OK: Returns CompareResponse with both aggregates and their ratios.
+//     If only one ticker has data, partial=true and missing_tickers names the other.
+//   - 400 Bad Request: Missing or invalid query parameters.
+//   - 404 Not Found: Neither ticker has trades in the date range.
+//   - 500 Internal Server Error: Failure in repository or database layer.
+//
+// Compare godoc
+// @Summary      Compare two tickers
+// @Description  Returns the aggregates of two tickers over the same period plus max price and volume ratios (a over b)
+// @Tags         aggregate
+// @Produce      json
+// @Param        ticker_a     query     string  true   "First stock ticker" example(PETR4)
+// @Param        ticker_b     query     string  true   "Second stock ticker" example(VALE3)
+// @Param        data_inicio  query     string  false  "Start date in YYYY-MM-DD" example(2024-09-01)
+// @Param        data_fim     query     string  false  "End date (inclusive) in YYYY-MM-DD" example(2024-09-30)
+// @Success      200          {object}  dto.CompareResponse  "Success"
+// @Failure      400          {object}  dto.ErrorResponse    "Bad Request"
+// @Failure      404          {object}  dto.ErrorResponse    "Not Found"
+// @Failure      500          {object}  dto.ErrorResponse    "Internal Error"
+// @Router       /api/v1/compare [get]
+func (h *Handler) Compare(c *gin.Context) {
+	tickerA := queryTicker(c, "ticker_a")
+	tickerB := queryTicker(c, "ticker_b")
+	if tickerA == "" || tickerB == "" {
+		middleware.RespondError(c, http.StatusBadRequest, "ticker_a and ticker_b are required", nil)
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	cmp, err := h.svc.Compare(withLogFields(c, startDate, endDate, tickerA, tickerB), tickerA, tickerB, startDate, endDate)
+	switch {
+	case errors.Is(err, service.ErrNoData):
+		middleware.RespondError(c, http.StatusNotFound, "no data found", nil)
+		return
+	case err != nil:
+		middleware.RespondError(c, http.StatusInternalServerError, "failed to compare aggregates", err)
+		return
+	}
+
+	c.Header("Cache-Control", h.cacheControlFor(c, endDate, cmp.Partial()))
+	middleware.RespondJSON(c, http.StatusOK, dto.CompareResponse{
+		TickerA:        cmp.TickerA,
+		TickerB:        cmp.TickerB,
+		A:              toAggregateResponse(cmp.A),
+		B:              toAggregateResponse(cmp.B),
+		MaxPriceRatio:  cmp.MaxPriceRatio,
+		VolumeRatio:    cmp.VolumeRatio,
+		Partial:        cmp.Partial(),
+		MissingTickers: cmp.MissingTickers(),
+	})
+}
+
+// GetParticipantActivity handles GET /api/v1/participant requests.
+//
+// Query Parameters:
+//   - code (string, required): Participant (broker) code as stored in the buyer/seller columns.
+//   - data_inicio (string, optional): Minimum trade date in YYYY-MM-DD format.
+//   - data_fim (string, optional): Maximum trade date (inclusive) in YYYY-MM-DD format.
+//
+// Responses:
+//   - 200 OK: Returns ParticipantResponse with buy/sell volume per ticker, busiest first.
+//     At most storage.MaxParticipantTickers tickers are returned; truncated=true signals more exist.
+//   - 400 Bad Request: Missing or invalid query parameters.
+//   - 404 Not Found: The participant has no trades in the date range.
+//   - 500 Internal Server Error: Failure in repository or database layer.
+//
+// GetParticipantActivity godoc
+// @Summary      Get participant activity
+// @Description  Returns, per ticker, the volume a participant traded as buyer and as seller
+// @Tags         participant
+// @Produce      json
+// @Param        code         query     string  true   "Participant code" example(3)
+// @Param        data_inicio  query     string  false  "Start date in YYYY-MM-DD" example(2024-09-01)
+// @Param        data_fim     query     string  false  "End date (inclusive) in YYYY-MM-DD" example(2024-09-30)
+// @Success      200          {object}  dto.ParticipantResponse  "Success"
+// @Failure      400          {object}  dto.ErrorResponse        "Bad Request"
+// @Failure      404          {object}  dto.ErrorResponse        "Not Found"
+// @Failure      500          {object}  dto.ErrorResponse        "Internal Error"
+// @Router       /api/v1/participant [get]
+func (h *Handler) GetParticipantActivity(c *gin.Context) {
+	code := strings.TrimSpace(c.Query("code"))
+	if code == "" {
+		middleware.RespondError(c, http.StatusBadRequest, "code is required", nil)
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	summary, err := h.svc.GetParticipantActivity(withParticipantLogFields(c, startDate, endDate, "participant", code), code, startDate, endDate)
+	switch {
+	case errors.Is(err, service.ErrNoData):
+		middleware.RespondError(c, http.StatusNotFound, "no data found", nil)
+		return
+	case err != nil:
+		middleware.RespondError(c, http.StatusInternalServerError, "failed to fetch participant activity", err)
+		return
+	}
+
+	resp := dto.ParticipantResponse{
+		Code:      summary.Code,
+		Tickers:   make([]dto.ParticipantActivityResponse, 0, len(summary.Tickers)),
+		Truncated: summary.Truncated,
+	}
+	for _, a := range summary.Tickers {
+		resp.Tickers = append(resp.Tickers, dto.ParticipantActivityResponse{
+			Ticker:     a.Ticker,
+			BuyVolume:  a.BuyVolume,
+			SellVolume: a.SellVolume,
+		})
+	}
+
+	c.Header("Cache-Control", h.cacheControlFor(c, endDate, len(resp.Tickers) == 0))
+	middleware.RespondJSON(c, http.StatusOK, resp)
+}
+
+// GetCrossTrades handles GET /api/v1/cross requests.
+//
+// Query Parameters:
+//   - buyer (string, optional): Buyer participant code.
+//   - seller (string, optional): Seller participant code; at least one of buyer/seller is required.
+//   - data_inicio, data_fim, window: as for GetAggregate.
+//   - limit (int, optional): Page size, 1..maxCrossLimit (default defaultCrossLimit).
+//   - offset (int, optional): Tickers to skip (default 0).
+//
+// Responses:
+//   - 200 OK: Returns CrossResponse with volume and trade count per ticker, busiest
+//     first; has_more=true means the next page starts at offset+limit.
+//   - 400 Bad Request: Missing or invalid query parameters.
+//   - 404 Not Found: The pair has no trades in the date range.
+//   - 500 Internal Server Error: Failure in repository or database layer.
+//
+// GetCrossTrades godoc
+// @Summary      Get trades between a buyer and a seller
+// @Description  Returns, per ticker, the volume and trade count where the given buyer bought from the given seller
+// @Tags         participant
+// @Produce      json
+// @Param        buyer        query     string  false  "Buyer participant code" example(3)
+// @Param        seller       query     string  false  "Seller participant code" example(72)
+// @Param        data_inicio  query     string  false  "Start date in YYYY-MM-DD" example(2024-09-01)
+// @Param        data_fim     query     string  false  "End date (inclusive) in YYYY-MM-DD" example(2024-09-30)
+// @Param        window       query     string  false  "Last N business days ending yesterday; excludes data_inicio/data_fim" example(5d)
+// @Param        limit        query     int     false  "Page size (max 500)" default(50)
+// @Param        offset       query     int     false  "Tickers to skip" default(0)
+// @Success      200          {object}  dto.CrossResponse  "Success"
+// @Failure      400          {object}  dto.ErrorResponse  "Bad Request"
+// @Failure      404          {object}  dto.ErrorResponse  "Not Found"
+// @Failure      500          {object}  dto.ErrorResponse  "Internal Error"
+// @Router       /api/v1/cross [get]
+func (h *Handler) GetCrossTrades(c *gin.Context) {
+	buyer := strings.TrimSpace(c.Query("buyer"))
+	seller := strings.TrimSpace(c.Query("seller"))
+	if buyer == "" && seller == "" {
+		middleware.RespondError(c, http.StatusBadRequest, "buyer or seller is required", nil)
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	limit, offset := defaultCrossLimit, 0
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxCrossLimit {
+			middleware.RespondError(c, http.StatusBadRequest, fmt.Sprintf("limit must be an integer between 1 and %d", maxCrossLimit), nil)
+			return
+		}
+		limit = n
+	}
+	if raw := c.Query("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			middleware.RespondError(c, http.StatusBadRequest, "offset must be a non-negative integer", nil)
+			return
+		}
+		offset = n
+	}
+
+	cross, err := h.svc.GetCrossTrades(withParticipantLogFields(c, startDate, endDate, "buyer", buyer, "seller", seller), buyer, seller, startDate, endDate, limit, offset)
+	switch {
+	case errors.Is(err, service.ErrNoData):
+		middleware.RespondError(c, http.StatusNotFound, "no data found", nil)
+		return
+	case err != nil:
+		middleware.RespondError(c, http.StatusInternalServerError, "failed to fetch cross trades", err)
+		return
+	}
+
+	resp := dto.CrossResponse{
+		Buyer:   cross.Buyer,
+		Seller:  cross.Seller,
+		Tickers: make([]dto.CrossActivityResponse, 0, len(cross.Tickers)),
+		Limit:   limit,
+		Offset:  offset,
+		HasMore: cross.HasMore,
+	}
+	for _, a := range cross.Tickers {
+		resp.Tickers = append(resp.Tickers, dto.CrossActivityResponse{
+			Ticker:     a.Ticker,
+			Volume:     a.Volume,
+			TradeCount: a.TradeCount,
+		})
+	}
+
+	c.Header("Cache-Control", h.cacheControlFor(c, endDate, len(resp.Tickers) == 0))
+	middleware.RespondJSON(c, http.StatusOK, resp)
+}
+
+// GetFreshness handles GET /api/v1/freshness requests.
+//
+// It reports the most recent ingested business day and whether it is at least
+// the latest business day (on or before today in APP_TIMEZONE) that data is expected for.
+//
+// Responses:
+//   - 200 OK: Returns FreshnessResponse; latest_* fields are null when nothing was ingested.
+//   - 500 Internal Server Error: Failure in repository or database layer.
+//
+// GetFreshness godoc
+// @Summary      Data freshness
+// @Description  Returns the latest ingested business day and whether data is up to date
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  dto.FreshnessResponse  "Success"
+// @Failure      500  {object}  dto.ErrorResponse      "Internal Error"
+// @Router       /api/v1/freshness [get]
+func (h *Handler) GetFreshness(c *gin.Context) {
+	latest, err := h.svc.GetLatestIngestion(c.Request.Context())
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "failed to fetch latest ingestion", err)
+		return
+	}
+
+	expected := calendar.LatestBusinessDay(today())
+	resp := dto.FreshnessResponse{ExpectedDate: expected.Format("2006-01-02")}
+	if latest != nil {
+		date := latest.FileDate.Format("2006-01-02")
+		ingestedAt := latest.IngestedAt.UTC().Format(time.RFC3339)
+		rows := latest.RowCount
+		resp.LatestDate = &date
+		resp.IngestedAt = &ingestedAt
+		resp.RowCount = &rows
+		// Compare calendar dates (YYYY-MM-DD sorts chronologically).
+		resp.IsCurrent = date >= resp.ExpectedDate
+	}
+
+	c.Header("Cache-Control", "no-store")
+	middleware.RespondJSON(c, http.StatusOK, resp)
+}
+
+// GetGaps handles GET /api/v1/gaps requests.
+//
+// It lists the business days in the range with no ingestion_log entry, so
+// monitoring can detect and backfill holes.
+//
+// Query Parameters: data_inicio, data_fim and window as for GetAggregate.
+// data_fim defaults to yesterday; data_fim alone is rejected. The range may
+// span at most maxGapsRangeDays calendar days.
+//
+// Responses:
+//   - 200 OK: Returns GapsResponse; missing_dates is empty when nothing is missing.
+//   - 400 Bad Request: Missing or invalid query parameters, or a range that is too long.
+//   - 500 Internal Server Error: Failure in repository or database layer.
+//
+// GetGaps godoc
+// @Summary      Ingestion gaps
+// @Description  Returns the business days in a date range that were never ingested
+// @Tags         health
+// @Produce      json
+// @Param        data_inicio  query     string  false  "Start date in YYYY-MM-DD" example(2025-09-01)
+// @Param        data_fim     query     string  false  "End date (inclusive) in YYYY-MM-DD; defaults to yesterday" example(2025-09-30)
+// @Param        window       query     string  false  "Last N business days ending yesterday; excludes data_inicio/data_fim" example(20d)
+// @Success      200          {object}  dto.GapsResponse   "Success"
+// @Failure      400          {object}  dto.ErrorResponse  "Bad Request"
+// @Failure      500          {object}  dto.ErrorResponse  "Internal Error"
+// @Router       /api/v1/gaps [get]
+func (h *Handler) GetGaps(c *gin.Context) {
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	if startDate == nil {
+		middleware.RespondError(c, http.StatusBadRequest, "data_inicio is required", nil)
+		return
+	}
+	if endDate == nil {
+		yday := today().AddDate(0, 0, -1)
+		endDate = &yday
+	}
+	if endDate.Before(*startDate) {
+		middleware.RespondError(c, http.StatusBadRequest, "data_inicio must not be after data_fim (default: yesterday)", nil)
+		return
+	}
+	if endDate.Sub(*startDate) >= maxGapsRangeDays*24*time.Hour {
+		middleware.RespondError(c, http.StatusBadRequest, fmt.Sprintf("range must span at most %d days", maxGapsRangeDays), nil)
+		return
+	}
+
+	missing, err := h.svc.FindMissingIngestionDates(withLogFields(c, startDate, endDate), *startDate, *endDate)
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "failed to find ingestion gaps", err)
+		return
+	}
+
+	resp := dto.GapsResponse{
+		RangeStart:   startDate.Format("2006-01-02"),
+		RangeEnd:     endDate.Format("2006-01-02"),
+		MissingDates: make([]string, 0, len(missing)),
+		Count:        len(missing),
+	}
+	for _, d := range missing {
+		resp.MissingDates = append(resp.MissingDates, d.Format("2006-01-02"))
+	}
+
+	// A backfill can close a gap at any time.
+	c.Header("Cache-Control", "no-store")
+	middleware.RespondJSON(c, http.StatusOK, resp)
+}
+
+// ExportTrades handles GET /api/v1/trades/export requests.
+//
+// Trades are streamed from a database cursor straight into the response as CSV,
+// flushed every exportFlushRows rows, so memory stays flat regardless of size.
+// Aborting the download cancels the request context and stops the DB scan.
+//
+// Query Parameters:
+//   - data (string, required): Trade date in YYYY-MM-DD format.
+//   - ticker (string, optional): Restrict the export to one stock ticker; all tickers when omitted.
+//
+// Responses:
+//   - 200 OK: text/csv attachment with a header row (header only when there are no trades).
+//   - 400 Bad Request: Missing or invalid query parameters.
+//   - 500 Internal Server Error: The query failed before any row was sent.
+//     Failures after streaming started truncate the body; the status cannot change.
+//
+// ExportTrades godoc
+// @Summary      Export trades as CSV
+// @Description  Streams every trade of a date (optionally one ticker) as a CSV attachment
+// @Tags         trades
+// @Produce      text/csv
+// @Param        data    query     string  true   "Trade date in YYYY-MM-DD" example(2025-09-18)
+// @Param        ticker  query     string  false  "Stock ticker" example(PETR4)
+// @Success      200     {file}    file               "CSV file"
+// @Failure      400     {object}  dto.ErrorResponse  "Bad Request"
+// @Failure      500     {object}  dto.ErrorResponse  "Internal Error"
+// @Router       /api/v1/trades/export [get]
+func (h *Handler) ExportTrades(c *gin.Context) {
+	ticker := queryTicker(c, "ticker")
+	date, ok := queryTradeDate(c)
+	if !ok {
+		return
+	}
+	s := date.Format("2006-01-02")
+
+	// Headers are only committed once the first row (or the empty result) is
+	// known, so a failing query can still be reported as a 500.
+	w := csv.NewWriter(c.Writer)
+	started := false
+	start := func() error {
+		started = true
+		name := "trades_" + s + ".csv"
+		if ticker != "" {
+			name = "trades_" + ticker + "_" + s + ".csv"
+		}
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		c.Status(http.StatusOK)
+		return w.Write(exportHeader)
+	}
+
+	rows := 0
+	err := h.svc.StreamTrades(withLogFields(c, &date, &date, ticker), ticker, date, func(t models.Trade) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := w.Write(tradeRecord(t)); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			w.Flush()
+			c.Writer.Flush()
+			return w.Error()
+		}
+		return nil
+	})
+	if err != nil && !started {
+		middleware.RespondError(c, http.StatusInternalServerError, "failed to export trades", err)
+		return
+	}
+	if err != nil {
+		w.Flush()
+		middleware.Log(c).Warn().Err(err).Int("rows", rows).Msg("trade export aborted")
+		return
+	}
+	if !started {
+		if err := start(); err != nil {
+			return
+		}
+	}
+	w.Flush()
+}
+
+// StreamTradesNDJSON handles GET /api/v1/trades/stream requests.
+//
+// It is the JSON-lines counterpart of ExportTrades: trades are streamed from a
+// database cursor as one dto.TradeLine object per line, flushed every
+// exportFlushRows rows, so consumers can process them incrementally. Aborting
+// the request cancels the request context and stops the DB scan.
+//
+// Query Parameters: data (required) and ticker (optional), as for ExportTrades.
+//
+// Responses:
+//   - 200 OK: application/x-ndjson body; empty when there are no trades.
+//   - 400 Bad Request: Missing or invalid query parameters.
+//   - 500 Internal Server Error: The query failed before any row was sent.
+//     Failures after streaming started truncate the body; the status cannot change.
+//
+// StreamTradesNDJSON godoc
+// @Summary      Stream trades as NDJSON
+// @Description  Streams every trade of a date (optionally one ticker), one JSON object per line
+// @Tags         trades
+// @Produce      application/x-ndjson
+// @Param        data    query     string  true   "Trade date in YYYY-MM-DD" example(2025-09-18)
+// @Param        ticker  query     string  false  "Stock ticker" example(PETR4)
+// @Success      200     {object}  dto.TradeLine      "One object per line"
+// @Failure      400     {object}  dto.ErrorResponse  "Bad Request"
+// @Failure      500     {object}  dto.ErrorResponse  "Internal Error"
+// @Router       /api/v1/trades/stream [get]
+func (h *Handler) StreamTradesNDJSON(c *gin.Context) {
+	ticker := queryTicker(c, "ticker")
+	date, ok := queryTradeDate(c)
+	if !ok {
+		return
+	}
+
+	// As in ExportTrades, headers are committed with the first row so a
+	// failing query can still be reported as a 500.
+	enc := json.NewEncoder(c.Writer) // Encode terminates each object with '\n'
+	started := false
+	start := func() {
+		started = true
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+	}
+
+	rows := 0
+	err := h.svc.StreamTrades(withLogFields(c, &date, &date, ticker), ticker, date, func(t models.Trade) error {
+		if !started {
+			start()
+		}
+		if err := enc.Encode(tradeLine(t)); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil && !started {
+		middleware.RespondError(c, http.StatusInternalServerError, "failed to stream trades", err)
+		return
+	}
+	if err != nil {
+		middleware.Log(c).Warn().Err(err).Int("rows", rows).Msg("trade stream aborted")
+		return
+	}
+	if !started {
+		start()
+	}
+	c.Writer.Flush()
+}
+
+// queryTradeDate parses the required "data" query param (YYYY-MM-DD) shared by
+// the trade export endpoints, writing a 400 and returning ok=false when it is
+// missing or invalid.
+func queryTradeDate(c *gin.Context) (date time.Time, ok bool) {
+	s := c.Query("data")
+	if s == "" {
+		middleware.RespondError(c, http.StatusBadRequest, "data is required", nil)
+		return time.Time{}, false
+	}
+	date, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "invalid data format, expected YYYY-MM-DD", err)
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// tradeLine converts a trade to its NDJSON representation; it carries the same
+// values as tradeRecord. Exported trades are raw rows, so prices are not rounded.
+func tradeLine(t models.Trade) dto.TradeLine {
+	return dto.TradeLine{
+		ReferenceDate:         dateOrEmpty(t.ReferenceDate),
+		InstrumentCode:        t.InstrumentCode,
+		UpdateAction:          t.UpdateAction,
+		TradePrice:            priceOrNil(t),
+		TradeQuantity:         quantityOrNil(t),
+		ClosingTime:           clockOrEmpty(t.ClosingTime),
+		TradeIdentifierCode:   t.TradeIdentifierCode,
+		SessionType:           t.SessionType,
+		TradeDate:             dateOrEmpty(t.TradeDate),
+		BuyerParticipantCode:  t.BuyerParticipantCode,
+		SellerParticipantCode: t.SellerParticipantCode,
+	}
+}
+
+// tradeRecord formats a trade as one CSV row matching exportHeader.
+func tradeRecord(t models.Trade) []string {
+	return []string{
+		dateOrEmpty(t.ReferenceDate),
+		t.InstrumentCode,
+		t.UpdateAction,
+		priceOrEmpty(t),
+		quantityOrEmpty(t),
+		clockOrEmpty(t.ClosingTime),
+		t.TradeIdentifierCode,
+		t.SessionType,
+		dateOrEmpty(t.TradeDate),
+		t.BuyerParticipantCode,
+		t.SellerParticipantCode,
+	}
+}
+
+// roundPrice rounds a price to PRICE_DECIMALS places for the response, so
+// float artifacts such as 10.500000000000001 serialize as 10.5. Stored values
+// keep their precision; 0 returns p unchanged.
+func roundPrice(p float64) float64 {
+	d := config.AppConfig.Server.PriceDecimals
+	if d <= 0 {
+		return p
+	}
+	scale := math.Pow10(d)
+	return math.Round(p*scale) / scale
+}
+
+// dateOrEmpty formats d as YYYY-MM-DD, or "" for the zero time.
+func dateOrEmpty(d time.Time) string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format("2006-01-02")
+}
+
+// priceOrNil returns the trade price, or nil when it is missing.
+func priceOrNil(t models.Trade) *float64 {
+	if t.IsPriceMissing() {
+		return nil
+	}
+	return &t.TradePrice
+}
+
+// quantityOrNil returns the trade quantity, or nil when it is missing.
+func quantityOrNil(t models.Trade) *int64 {
+	if t.IsQuantityMissing() {
+		return nil
+	}
+	return &t.TradeQuantity
+}
+
+// priceOrEmpty formats the trade price, or "" when it is missing.
+func priceOrEmpty(t models.Trade) string {
+	if t.IsPriceMissing() {
+		return ""
+	}
+	return strconv.FormatFloat(t.TradePrice, 'f', -1, 64)
+}
+
+// quantityOrEmpty formats the trade quantity, or "" when it is missing.
+func quantityOrEmpty(t models.Trade) string {
+	if t.IsQuantityMissing() {
+		return ""
+	}
+	return strconv.FormatInt(t.TradeQuantity, 10)
+}
+
+// clockOrEmpty formats t as HH:MM:SS, or "" for the zero time.
+func clockOrEmpty(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("15:04:05")
+}
+
+// queryTicker reads a ticker query param, upper-cased and resolved through
+// INSTRUMENT_ALIASES so it matches the codes stored at ingest time.
+func queryTicker(c *gin.Context, key string) string {
+	return instrument.Canonical(strings.ToUpper(strings.TrimSpace(c.Query(key))))
+}
+
+// withLogFields adds the ticker(s) and the resolved range to the request logger
+// (see middleware.ContextLogger) and returns the request context carrying it,
+// so the service layer logs with the same fields.
+func withLogFields(c *gin.Context, start, end *time.Time, tickers ...string) context.Context {
+	lc := middleware.Log(c).With()
+	if len(tickers) == 1 {
+		lc = lc.Str("ticker", tickers[0])
+	} else {
+		lc = lc.Strs("tickers", tickers)
+	}
+	return withRangeLogger(c, lc, start, end)
+}
+
+// withParticipantLogFields is withLogFields for the participant endpoints: it
+// logs the participant codes, given as role/code pairs (e.g. "buyer", "123"),
+// instead of tickers. Empty codes are left out.
+func withParticipantLogFields(c *gin.Context, start, end *time.Time, roleCodes ...string) context.Context {
+	lc := middleware.Log(c).With()
+	for i := 0; i+1 < len(roleCodes); i += 2 {
+		if roleCodes[i+1] != "" {
+			lc = lc.Str(roleCodes[i], roleCodes[i+1])
+		}
+	}
+	return withRangeLogger(c, lc, start, end)
+}
+
+// withRangeLogger adds the resolved range to lc, installs the result as the
+// request logger and returns the request context carrying it.
+func withRangeLogger(c *gin.Context, lc zerolog.Context, start, end *time.Time) context.Context {
+	if s := formatDate(start); s != "" {
+		lc = lc.Str("range_start", s)
+	}
+	if s := formatDate(end); s != "" {
+		lc = lc.Str("range_end", s)
+	}
+	l := lc.Logger()
+	middleware.SetLogger(c, &l)
+	return c.Request.Context()
+}
+
+// parseWindow parses a "window" value of the form "Nd" (N business days, 1..maxWindowDays).
+func parseWindow(s string) (int, error) {
+	digits, ok := strings.CutSuffix(s, "d")
+	n, err := strconv.Atoi(digits)
+	if !ok || err != nil || n < 1 || n > maxWindowDays {
+		return 0, fmt.Errorf("invalid window %q, expected Nd with 1 <= N <= %d (e.g. 5d)", s, maxWindowDays)
+	}
+	return n, nil
+}
+
+// formatDate renders an optional date as YYYY-MM-DD, or "" when nil.
+func formatDate(d *time.Time) string {
+	if d == nil {
+		return ""
+	}
+	return d.Format("2006-01-02")
+}
+
+// toAggregateResponse maps an aggregate to its response DTO, preserving nil.
+func toAggregateResponse(agg *models.Aggregate) *dto.AggregateResponse {
+	if agg == nil {
+		return nil
+	}
+	return &dto.AggregateResponse{
+		Ticker:         agg.Ticker,
+		MaxRangeValue:  roundPrice(agg.MaxRangeValue),
+		MaxDailyVolume: agg.MaxDailyVolume,
+		MinDailyVolume: agg.MinDailyVolume,
+		TradeCount:     agg.TradeCount,
+		TradingDays:    agg.TradingDays,
+	}
+}
+
+// parseDateRange reads the optional "data_inicio"/"data_fim" or "window" query params.
+//
+// Behavior:
+//   - data_inicio only: trade_date >= data_inicio (no upper bound).
+//   - data_fim only: trade_date <= data_fim (no lower bound).
+//   - window=Nd: the last N business days (see calendar) ending yesterday;
+//     cannot be combined with data_inicio/data_fim.
+//   - None: defaults to the last 7 days, ending yesterday.
+//
+// Dates are calendar days in APP_TIMEZONE (see appLocation), so "yesterday"
+// does not jump ahead at UTC midnight (21:00 in São Paulo). They are returned
+// as midnight UTC of that day, which compares correctly with the DATE columns.
+//
+// On invalid input it writes a 400 response and returns ok=false.
+func parseDateRange(c *gin.Context) (startDate *time.Time, endDate *time.Time, ok bool) {
+	if w := c.Query("window"); w != "" {
+		if c.Query("data_inicio") != "" || c.Query("data_fim") != "" {
+			middleware.RespondError(c, http.StatusBadRequest, "window cannot be combined with data_inicio/data_fim", nil)
+			return nil, nil, false
+		}
+		n, err := parseWindow(w)
+		if err != nil {
+			middleware.RespondError(c, http.StatusBadRequest, err.Error(), nil)
+			return nil, nil, false
+		}
+		yday := today().AddDate(0, 0, -1)
+		days := calendar.LastNBusinessDays(n, yday) // most recent first
+		start, end := days[len(days)-1], days[0]
+		return &start, &end, true
+	}
+
+	startDate, endDate, ok = parseDates(c)
+	if !ok {
+		return nil, nil, false
+	}
+	if startDate == nil && endDate == nil {
+		// Default: last 7 ingested days, ending yesterday
+		yday := today().AddDate(0, 0, -1)
+		start := yday.AddDate(0, 0, -6)
+		startDate = &start
+		endDate = &yday
+	}
+	return startDate, endDate, true
+}
+
+// parseDates reads the optional "data_inicio"/"data_fim" query params as
+// given, nil when absent, and rejects data_fim before data_inicio.
+//
+// On invalid input it writes a 400 response and returns ok=false.
+func parseDates(c *gin.Context) (startDate *time.Time, endDate *time.Time, ok bool) {
+	if s := c.Query("data_inicio"); s != "" {
+		parsed, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			middleware.RespondError(c, http.StatusBadRequest, "invalid data_inicio format, expected YYYY-MM-DD", err)
+			return nil, nil, false
+		}
+		startDate = &parsed
+	}
+	if s := c.Query("data_fim"); s != "" {
+		parsed, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			middleware.RespondError(c, http.StatusBadRequest, "invalid data_fim format, expected YYYY-MM-DD", err)
+			return nil, nil, false
+		}
+		if startDate != nil && parsed.Before(*startDate) {
+			middleware.RespondError(c, http.StatusBadRequest, "data_fim must not be before data_inicio", nil)
+			return nil, nil, false
+		}
+		endDate = &parsed
+	}
+	return startDate, endDate, true
+}
+
+// cacheControlFor returns the Cache-Control value for a result over a range
+// ending at end; empty tells that the result has no trades (or is partial).
+//
+// Only a non-empty result whose (inclusive) end date is before today and before
+// the latest day in ingestion_log is cached aggressively: until the nightly
+// load of a day, a range covering it is partial or empty, and caches would
+// keep that answer for a day. Everything else gets a short max-age, including
+// when the latest ingestion cannot be read.
+func (h *Handler) cacheControlFor(c *gin.Context, end *time.Time, empty bool) string {
+	if end == nil || empty || !end.Before(today()) {
+		return cacheControlRecent
+	}
+	latest, err := h.svc.GetLatestIngestion(c.Request.Context())
+	if err != nil {
+		middleware.Log(c).Warn().Err(err).Msg("latest ingestion lookup failed")
+		return cacheControlRecent
+	}
+	// Compared as YYYY-MM-DD: file_date and query dates may differ in location.
+	if latest == nil || end.Format("2006-01-02") >= latest.FileDate.Format("2006-01-02") {
+		return cacheControlRecent
+	}
+	return cacheControlImmutable
 }
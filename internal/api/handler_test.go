@@ -1,28 +1,135 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/config"
+	"github.com/guttosm/b3pulse/internal/domain/dto"
 	"github.com/guttosm/b3pulse/internal/domain/models"
+	"github.com/guttosm/b3pulse/internal/instrument"
+	"github.com/guttosm/b3pulse/internal/logger"
 	"github.com/guttosm/b3pulse/internal/service"
+	"github.com/guttosm/b3pulse/internal/storage"
 )
 
 type mockAggService struct {
-	resp *models.Aggregate
-	err  error
+	resp        *models.Aggregate
+	daily       *models.DailyAggregate
+	cmp         *models.Comparison
+	participant *models.ParticipantSummary
+	latest      *models.IngestionLogEntry
+	trades      []models.Trade
+	sessions    map[string]*models.Aggregate
+	plan        json.RawMessage
+	calendar    []models.DailyAggregate
+	gotRange    [2]time.Time // range received by GetCalendar/FindMissingIngestionDates
+	missing     []time.Time
+	spread      *models.PriceSpread
+	notional    *models.Notional
+	latestPrice *models.LatestPrice
+	sma         []models.MovingAveragePoint
+	gotWindow   int                // window received by GetSMA
+	instrument  *models.Instrument // nil means service.ErrNoData
+	instrErr    error              // returned by GetInstrument
+	cross       *models.CrossSummary
+	gotPage     [2]int // limit, offset received by GetCrossTrades
+	err         error
+	gotTickers  []string                  // tickers received by GetAggregate/Compare
+	gotOpts     *storage.AggregateOptions // options received by GetAggregate
 }
 
-func (m *mockAggService) GetAggregate(_ context.Context, _ string, _ *time.Time, _ *time.Time) (*models.Aggregate, error) {
+func (m *mockAggService) GetAggregate(_ context.Context, ticker string, _ *time.Time, _ *time.Time, opts storage.AggregateOptions) (*models.Aggregate, error) {
+	m.gotTickers = append(m.gotTickers, ticker)
+	m.gotOpts = &opts
 	return m.resp, m.err
 }
 
+func (m *mockAggService) GetDailyAggregate(_ context.Context, _ string, _ time.Time) (*models.DailyAggregate, error) {
+	return m.daily, m.err
+}
+
+func (m *mockAggService) GetAggregateBySession(_ context.Context, _ string, _ *time.Time, _ *time.Time) (map[string]*models.Aggregate, error) {
+	return m.sessions, m.err
+}
+
+func (m *mockAggService) Compare(_ context.Context, a, b string, _ *time.Time, _ *time.Time) (*models.Comparison, error) {
+	m.gotTickers = append(m.gotTickers, a, b)
+	return m.cmp, m.err
+}
+
+func (m *mockAggService) GetParticipantActivity(_ context.Context, _ string, _ *time.Time, _ *time.Time) (*models.ParticipantSummary, error) {
+	return m.participant, m.err
+}
+
+func (m *mockAggService) GetLatestIngestion(_ context.Context) (*models.IngestionLogEntry, error) {
+	return m.latest, m.err
+}
+
+func (m *mockAggService) StreamTrades(_ context.Context, _ string, _ time.Time, fn func(models.Trade) error) error {
+	for _, t := range m.trades {
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return m.err
+}
+
+func (m *mockAggService) GetCalendar(_ context.Context, _ string, start time.Time, end time.Time) ([]models.DailyAggregate, error) {
+	m.gotRange = [2]time.Time{start, end}
+	return m.calendar, m.err
+}
+
+func (m *mockAggService) GetSMA(_ context.Context, _ string, start time.Time, end time.Time, window int) ([]models.MovingAveragePoint, error) {
+	m.gotRange, m.gotWindow = [2]time.Time{start, end}, window
+	return m.sma, m.err
+}
+
+func (m *mockAggService) FindMissingIngestionDates(_ context.Context, start time.Time, end time.Time) ([]time.Time, error) {
+	m.gotRange = [2]time.Time{start, end}
+	return m.missing, m.err
+}
+
+func (m *mockAggService) GetPriceSpread(_ context.Context, _ string, _ *time.Time, _ *time.Time) (*models.PriceSpread, error) {
+	return m.spread, m.err
+}
+
+func (m *mockAggService) GetNotional(_ context.Context, _ string, _ *time.Time, _ *time.Time) (*models.Notional, error) {
+	return m.notional, m.err
+}
+
+func (m *mockAggService) GetInstrument(_ context.Context, _ string) (*models.Instrument, error) {
+	if m.instrErr != nil {
+		return nil, m.instrErr
+	}
+	if m.instrument == nil {
+		return nil, service.ErrNoData
+	}
+	return m.instrument, nil
+}
+
+func (m *mockAggService) GetLatestPrice(_ context.Context, ticker string) (*models.LatestPrice, error) {
+	m.gotTickers = append(m.gotTickers, ticker)
+	return m.latestPrice, m.err
+}
+
+func (m *mockAggService) GetCrossTrades(_ context.Context, _ string, _ string, _ *time.Time, _ *time.Time, limit int, offset int) (*models.CrossSummary, error) {
+	m.gotPage = [2]int{limit, offset}
+	return m.cross, m.err
+}
+
+func (m *mockAggService) ExplainAggregate(_ context.Context, _ string, _ *time.Time, _ *time.Time, _ storage.AggregateOptions) (json.RawMessage, error) {
+	return m.plan, m.err
+}
+
 var _ service.AggregateService = (*mockAggService)(nil)
 
 func setupRouterWithMock(s service.AggregateService) *gin.Engine {
@@ -31,6 +138,21 @@ func setupRouterWithMock(s service.AggregateService) *gin.Engine {
 	r := gin.New()
 	v1 := r.Group("/api/v1")
 	v1.GET("/aggregate", h.GetAggregate)
+	v1.GET("/aggregate/daily", h.GetDailyAggregate)
+	v1.GET("/aggregate/by-session", h.GetAggregateBySession)
+	v1.GET("/aggregate/schema", h.GetAggregateSchema)
+	v1.GET("/calendar", h.GetCalendar)
+	v1.GET("/compare", h.Compare)
+	v1.GET("/participant", h.GetParticipantActivity)
+	v1.GET("/cross", h.GetCrossTrades)
+	v1.GET("/spread", h.GetSpread)
+	v1.GET("/notional", h.GetNotional)
+	v1.GET("/sma", h.GetSMA)
+	v1.GET("/latest", h.GetLatestPrice)
+	v1.GET("/freshness", h.GetFreshness)
+	v1.GET("/gaps", h.GetGaps)
+	v1.GET("/trades/export", h.ExportTrades)
+	v1.GET("/trades/stream", h.StreamTradesNDJSON)
 	return r
 }
 
@@ -54,9 +176,33 @@ func TestGetAggregate_TableDriven(t *testing.T) {
 			query:  "/api/v1/aggregate?ticker=PETR4&data_inicio=2025/09/01",
 			status: http.StatusBadRequest,
 		},
+		{
+			name:   "invalid end date format",
+			svc:    &mockAggService{},
+			query:  "/api/v1/aggregate?ticker=PETR4&data_fim=2025/09/01",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "end before start",
+			svc:    &mockAggService{},
+			query:  "/api/v1/aggregate?ticker=PETR4&data_inicio=2025-09-10&data_fim=2025-09-01",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid min_qty",
+			svc:    &mockAggService{},
+			query:  "/api/v1/aggregate?ticker=PETR4&min_qty=abc",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "zero min_qty",
+			svc:    &mockAggService{},
+			query:  "/api/v1/aggregate?ticker=PETR4&min_qty=0",
+			status: http.StatusBadRequest,
+		},
 		{
 			name:   "not found",
-			svc:    &mockAggService{resp: nil, err: nil},
+			svc:    &mockAggService{err: service.ErrNoData},
 			query:  "/api/v1/aggregate?ticker=VALE3",
 			status: http.StatusNotFound,
 		},
@@ -68,7 +214,7 @@ func TestGetAggregate_TableDriven(t *testing.T) {
 		},
 		{
 			name:   "success",
-			svc:    &mockAggService{resp: &models.Aggregate{Ticker: "PETR4", MaxRangeValue: 10.5, MaxDailyVolume: 123}},
+			svc:    &mockAggService{resp: &models.Aggregate{Ticker: "PETR4", MaxRangeValue: 10.5, MaxDailyVolume: 123, MinDailyVolume: 7, TradeCount: 42, TradingDays: 5}},
 			query:  "/api/v1/aggregate?ticker=petr4&data_inicio=2025-09-01",
 			status: http.StatusOK,
 			assert: func(t *testing.T, body []byte) {
@@ -76,7 +222,22 @@ func TestGetAggregate_TableDriven(t *testing.T) {
 				if err := json.Unmarshal(body, &out); err != nil {
 					t.Fatalf("invalid json: %v", err)
 				}
-				if out.Ticker != "PETR4" || out.MaxRangeValue != 10.5 || out.MaxDailyVolume != 123 {
+				if out.Ticker != "PETR4" || out.MaxRangeValue != 10.5 || out.MaxDailyVolume != 123 || out.MinDailyVolume != 7 || out.TradeCount != 42 || out.TradingDays != 5 || out.HasDataOutsideRange {
+					t.Fatalf("unexpected body: %+v", out)
+				}
+			},
+		},
+		{
+			name:   "empty range for known ticker",
+			svc:    &mockAggService{resp: &models.Aggregate{Ticker: "PETR4", HasDataOutsideRange: true}},
+			query:  "/api/v1/aggregate?ticker=PETR4&data_inicio=2030-01-01",
+			status: http.StatusOK,
+			assert: func(t *testing.T, body []byte) {
+				var out dto.AggregateResponse
+				if err := json.Unmarshal(body, &out); err != nil {
+					t.Fatalf("invalid json: %v", err)
+				}
+				if !out.HasDataOutsideRange || out.MaxRangeValue != 0 || out.MaxDailyVolume != 0 {
 					t.Fatalf("unexpected body: %+v", out)
 				}
 			},
@@ -98,3 +259,1285 @@ func TestGetAggregate_TableDriven(t *testing.T) {
 		})
 	}
 }
+
+func TestGetAggregate_MinQty(t *testing.T) {
+	svc := &mockAggService{resp: &models.Aggregate{Ticker: "PETR4", MaxRangeValue: 30.1}}
+	r := setupRouterWithMock(svc)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate?ticker=PETR4&min_qty=100", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if svc.gotOpts.MinQty == nil || *svc.gotOpts.MinQty != 100 {
+		t.Fatalf("expected min_qty 100 passed to service, got %v", svc.gotOpts.MinQty)
+	}
+	var out dto.AggregateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || out.MinQty != 100 {
+		t.Fatalf("expected min_qty echoed, got %s (err=%v)", w.Body.String(), err)
+	}
+
+	// Absent: no floor, and the field is omitted.
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate?ticker=PETR4", nil))
+	if svc.gotOpts.MinQty != nil || strings.Contains(w.Body.String(), "min_qty") {
+		t.Fatalf("expected no min_qty, got %v body=%s", svc.gotOpts.MinQty, w.Body.String())
+	}
+}
+
+func TestGetAggregate_EndInclusive(t *testing.T) {
+	cases := []struct {
+		query  string
+		status int
+		want   bool // EndExclusive passed to the service
+	}{
+		{query: "", status: http.StatusOK, want: false},
+		{query: "&end_inclusive=true", status: http.StatusOK, want: false},
+		{query: "&end_inclusive=false", status: http.StatusOK, want: true},
+		{query: "&end_inclusive=maybe", status: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			svc := &mockAggService{resp: &models.Aggregate{Ticker: "PETR4"}}
+			r := setupRouterWithMock(svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate?ticker=PETR4&data_inicio=2025-09-01&data_fim=2025-09-12"+tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				if svc.gotOpts != nil {
+					t.Fatalf("service should not be called on a bad end_inclusive")
+				}
+				return
+			}
+			if svc.gotOpts == nil || svc.gotOpts.EndExclusive != tc.want {
+				t.Fatalf("service got %+v, want EndExclusive=%v", svc.gotOpts, tc.want)
+			}
+		})
+	}
+}
+
+func TestGetAggregate_NoTradingDays(t *testing.T) {
+	cases := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{name: "saturday to sunday", query: "&data_inicio=2025-09-13&data_fim=2025-09-14", status: http.StatusBadRequest},
+		{name: "weekend with exclusive monday", query: "&data_inicio=2025-09-13&data_fim=2025-09-15&end_inclusive=false", status: http.StatusBadRequest},
+		{name: "weekend with inclusive monday", query: "&data_inicio=2025-09-13&data_fim=2025-09-15", status: http.StatusOK},
+		{name: "open end", query: "&data_inicio=2025-09-13", status: http.StatusOK},
+		{name: "huge range", query: "&data_inicio=0001-01-01&data_fim=9999-12-31", status: http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &mockAggService{resp: &models.Aggregate{Ticker: "PETR4"}}
+			r := setupRouterWithMock(svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate?ticker=PETR4"+tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.status == http.StatusOK {
+				return
+			}
+			var body dto.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != dto.CodeNoTradingDays {
+				t.Fatalf("expected code %s, got %s (%v)", dto.CodeNoTradingDays, w.Body.String(), err)
+			}
+			if svc.gotOpts != nil {
+				t.Fatalf("service should not be called for a range without trading days")
+			}
+		})
+	}
+}
+
+func TestGetAggregate_DateField(t *testing.T) {
+	svc := &mockAggService{resp: &models.Aggregate{Ticker: "PETR4", MaxRangeValue: 30.1}}
+	r := setupRouterWithMock(svc)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate?ticker=PETR4&date_field=reference_date", nil))
+	if w.Code != http.StatusOK || svc.gotOpts.DateField != "reference_date" {
+		t.Fatalf("expected 200 with reference_date, got %d field=%q", w.Code, svc.gotOpts.DateField)
+	}
+	var out dto.AggregateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || out.DateField != "reference_date" {
+		t.Fatalf("expected date_field echoed, got %s (err=%v)", w.Body.String(), err)
+	}
+
+	// Absent: default column, and the field is omitted.
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate?ticker=PETR4", nil))
+	if svc.gotOpts.DateField != "" || strings.Contains(w.Body.String(), "date_field") {
+		t.Fatalf("expected no date_field, got %q body=%s", svc.gotOpts.DateField, w.Body.String())
+	}
+
+	// Not in the allowlist.
+	svc.gotTickers = nil
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate?ticker=PETR4&date_field=closing_time", nil))
+	if w.Code != http.StatusBadRequest || len(svc.gotTickers) != 0 {
+		t.Fatalf("expected 400 without calling the service, got %d", w.Code)
+	}
+}
+
+func TestGetAggregate_Window(t *testing.T) {
+	prev := nowFunc
+	t.Cleanup(func() { nowFunc = prev })
+	// Tue 2025-09-23: yesterday is Mon 09-22; 09-07 (Independence Day) falls on a Sunday.
+	nowFunc = func() time.Time { return time.Date(2025, 9, 23, 15, 0, 0, 0, time.UTC) }
+
+	cases := []struct {
+		name      string
+		query     string
+		status    int
+		wantStart string
+		wantEnd   string
+	}{
+		{name: "1d is yesterday", query: "window=1d", status: http.StatusOK, wantStart: "2025-09-22", wantEnd: "2025-09-22"},
+		{name: "5d skips weekend", query: "window=5d", status: http.StatusOK, wantStart: "2025-09-16", wantEnd: "2025-09-22"},
+		{name: "20d", query: "window=20d", status: http.StatusOK, wantStart: "2025-08-26", wantEnd: "2025-09-22"},
+		{name: "explicit range echoed", query: "data_inicio=2025-09-01&data_fim=2025-09-05", status: http.StatusOK, wantStart: "2025-09-01", wantEnd: "2025-09-05"},
+		{name: "open end omitted", query: "data_inicio=2025-09-01", status: http.StatusOK, wantStart: "2025-09-01"},
+		{name: "window with data_inicio", query: "window=5d&data_inicio=2025-09-01", status: http.StatusBadRequest},
+		{name: "window with data_fim", query: "window=5d&data_fim=2025-09-01", status: http.StatusBadRequest},
+		{name: "missing unit", query: "window=5", status: http.StatusBadRequest},
+		{name: "zero", query: "window=0d", status: http.StatusBadRequest},
+		{name: "too large", query: "window=251d", status: http.StatusBadRequest},
+		{name: "weeks unsupported", query: "window=2w", status: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(&mockAggService{resp: &models.Aggregate{Ticker: "PETR4"}})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate?ticker=PETR4&"+tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d body=%s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			var out dto.AggregateResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			if out.RangeStart != tc.wantStart || out.RangeEnd != tc.wantEnd {
+				t.Fatalf("range=%s..%s, want %s..%s", out.RangeStart, out.RangeEnd, tc.wantStart, tc.wantEnd)
+			}
+		})
+	}
+}
+
+func TestQueryTicker_Aliases(t *testing.T) {
+	if err := instrument.SetAliases([]string{"PETR4F=PETR4"}); err != nil {
+		t.Fatalf("SetAliases: %v", err)
+	}
+	t.Cleanup(func() { _ = instrument.SetAliases(nil) })
+
+	svc := &mockAggService{
+		resp: &models.Aggregate{Ticker: "PETR4"},
+		cmp:  &models.Comparison{A: &models.Aggregate{Ticker: "PETR4"}, B: &models.Aggregate{Ticker: "VALE3"}},
+	}
+	r := setupRouterWithMock(svc)
+	for _, path := range []string{
+		"/api/v1/aggregate?ticker=petr4f&data_inicio=2025-09-01",
+		"/api/v1/compare?ticker_a=PETR4F&ticker_b=vale3&data_inicio=2025-09-01",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d body=%s", path, w.Code, w.Body.String())
+		}
+	}
+	if got := strings.Join(svc.gotTickers, ","); got != "PETR4,PETR4,VALE3" {
+		t.Fatalf("service received tickers %s, want aliases resolved to PETR4", got)
+	}
+}
+
+func TestWithLogFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/compare", nil)
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	l := logger.FromContext(withLogFields(c, &start, nil, "PETR4", "VALE3")).Output(&buf)
+	l.Info().Msg("x")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("decode log line: %v (%s)", err, buf.String())
+	}
+	if line["range_start"] != "2025-09-01" || line["range_end"] != nil {
+		t.Fatalf("unexpected range fields: %v", line)
+	}
+	if tk, _ := line["tickers"].([]any); len(tk) != 2 || tk[0] != "PETR4" || tk[1] != "VALE3" {
+		t.Fatalf("unexpected tickers field: %v", line["tickers"])
+	}
+
+	// Participant endpoints log the codes by role; an empty one is left out.
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/cross", nil)
+	end := time.Date(2025, 9, 5, 0, 0, 0, 0, time.UTC)
+	buf.Reset()
+	l = logger.FromContext(withParticipantLogFields(c, &start, &end, "buyer", "308", "seller", "")).Output(&buf)
+	l.Info().Msg("x")
+	line = nil
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("decode log line: %v (%s)", err, buf.String())
+	}
+	if line["buyer"] != "308" || line["range_start"] != "2025-09-01" || line["range_end"] != "2025-09-05" {
+		t.Fatalf("unexpected participant fields: %v", line)
+	}
+	if _, ok := line["seller"]; ok || line["tickers"] != nil {
+		t.Fatalf("unexpected fields: %v", line)
+	}
+}
+
+func TestGetAggregate_Explain(t *testing.T) {
+	old := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = old })
+
+	plan := `[{"Plan":{"Node Type":"Result"}}]`
+	cases := []struct {
+		name     string
+		enabled  bool
+		query    string
+		wantCode int
+		wantPlan bool
+	}{
+		{name: "disabled", query: "&explain=true", wantCode: http.StatusForbidden},
+		{name: "enabled", enabled: true, query: "&explain=true", wantCode: http.StatusOK, wantPlan: true},
+		{name: "enabled but not requested", enabled: true, wantCode: http.StatusOK},
+		{name: "not requested while disabled", wantCode: http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config.AppConfig.Debug.Explain = tc.enabled
+			svc := &mockAggService{resp: &models.Aggregate{Ticker: "PETR4"}, plan: json.RawMessage(plan)}
+			w := httptest.NewRecorder()
+			setupRouterWithMock(svc).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate?ticker=PETR4"+tc.query, nil))
+			if w.Code != tc.wantCode {
+				t.Fatalf("expected %d, got %d body=%s", tc.wantCode, w.Code, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got := string(body["query_plan"]); (got == plan) != tc.wantPlan || (!tc.wantPlan && got != "") {
+				t.Fatalf("query_plan=%q, wantPlan=%v", got, tc.wantPlan)
+			}
+			if tc.wantPlan && w.Header().Get("Cache-Control") != "no-store" {
+				t.Fatalf("explain responses must not be cached, got %q", w.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestAppTimezone_DayBoundary(t *testing.T) {
+	prevNow, prevCfg := nowFunc, config.AppConfig
+	t.Cleanup(func() { nowFunc, config.AppConfig = prevNow, prevCfg })
+	// Thursday 22:30 in São Paulo, already Friday in UTC.
+	nowFunc = func() time.Time { return time.Date(2025, 9, 19, 1, 30, 0, 0, time.UTC) }
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+
+	cases := []struct {
+		name      string
+		loc       *time.Location
+		query     string
+		wantStart string
+		wantEnd   string
+		wantCache string
+	}{
+		{name: "default range, UTC", loc: time.UTC, query: "", wantStart: "2025-09-12", wantEnd: "2025-09-18", wantCache: cacheControlImmutable},
+		{name: "default range, Sao Paulo", loc: saoPaulo, query: "", wantStart: "2025-09-11", wantEnd: "2025-09-17", wantCache: cacheControlImmutable},
+		{name: "window, UTC", loc: time.UTC, query: "&window=1d", wantStart: "2025-09-18", wantEnd: "2025-09-18", wantCache: cacheControlImmutable},
+		{name: "window, Sao Paulo", loc: saoPaulo, query: "&window=1d", wantStart: "2025-09-17", wantEnd: "2025-09-17", wantCache: cacheControlImmutable},
+		{name: "range ending on local today, UTC", loc: time.UTC, query: "&data_inicio=2025-09-18&data_fim=2025-09-18", wantStart: "2025-09-18", wantEnd: "2025-09-18", wantCache: cacheControlImmutable},
+		{name: "range ending on local today, Sao Paulo", loc: saoPaulo, query: "&data_inicio=2025-09-18&data_fim=2025-09-18", wantStart: "2025-09-18", wantEnd: "2025-09-18", wantCache: cacheControlRecent},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config.AppConfig.Calendar.Location = tc.loc
+			r := setupRouterWithMock(&mockAggService{
+				resp:   &models.Aggregate{Ticker: "PETR4", TradeCount: 1},
+				latest: &models.IngestionLogEntry{FileDate: time.Date(2025, 9, 19, 0, 0, 0, 0, time.UTC)},
+			})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate?ticker=PETR4"+tc.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+			}
+			var body dto.AggregateResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("json: %v", err)
+			}
+			if body.RangeStart != tc.wantStart || body.RangeEnd != tc.wantEnd {
+				t.Fatalf("range %s..%s, want %s..%s", body.RangeStart, body.RangeEnd, tc.wantStart, tc.wantEnd)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tc.wantCache {
+				t.Fatalf("Cache-Control: want %q got %q", tc.wantCache, got)
+			}
+		})
+	}
+}
+
+func TestGetAggregate_CacheControl(t *testing.T) {
+	fixedNow := time.Date(2025, 9, 20, 15, 0, 0, 0, time.UTC)
+	old := nowFunc
+	nowFunc = func() time.Time { return fixedNow }
+	t.Cleanup(func() { nowFunc = old })
+
+	ingested := func(day int) *models.IngestionLogEntry {
+		return &models.IngestionLogEntry{FileDate: time.Date(2025, 9, day, 0, 0, 0, 0, time.UTC)}
+	}
+	agg := &models.Aggregate{Ticker: "PETR4", MaxRangeValue: 1, MaxDailyVolume: 1, TradeCount: 3}
+	cases := []struct {
+		name   string
+		query  string
+		resp   *models.Aggregate
+		latest *models.IngestionLogEntry
+		want   string
+	}{
+		{name: "range before latest ingested day", query: "/api/v1/aggregate?ticker=PETR4&data_inicio=2025-09-01&data_fim=2025-09-18", latest: ingested(19), want: cacheControlImmutable},
+		{name: "range ending on latest ingested day", query: "/api/v1/aggregate?ticker=PETR4&data_inicio=2025-09-01&data_fim=2025-09-19", latest: ingested(19), want: cacheControlRecent},
+		{name: "range ends yesterday, not yet ingested", query: "/api/v1/aggregate?ticker=PETR4&data_inicio=2025-09-01&data_fim=2025-09-19", latest: ingested(18), want: cacheControlRecent},
+		{name: "nothing ingested", query: "/api/v1/aggregate?ticker=PETR4&data_inicio=2025-09-01&data_fim=2025-09-18", want: cacheControlRecent},
+		{name: "range touching today", query: "/api/v1/aggregate?ticker=PETR4&data_inicio=2025-09-01&data_fim=2025-09-20", latest: ingested(19), want: cacheControlRecent},
+		{name: "open-ended range", query: "/api/v1/aggregate?ticker=PETR4&data_inicio=2025-09-01", latest: ingested(19), want: cacheControlRecent},
+		{name: "default window ends yesterday", query: "/api/v1/aggregate?ticker=PETR4", latest: ingested(19), want: cacheControlRecent},
+		{name: "no trades in range", query: "/api/v1/aggregate?ticker=PETR4&data_inicio=2025-09-01&data_fim=2025-09-18",
+			resp: &models.Aggregate{Ticker: "PETR4", HasDataOutsideRange: true}, latest: ingested(19), want: cacheControlRecent},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := tc.resp
+			if resp == nil {
+				resp = agg
+			}
+			r := setupRouterWithMock(&mockAggService{resp: resp, latest: tc.latest})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", w.Code)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tc.want {
+				t.Fatalf("Cache-Control: want %q got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGetAggregate_DataAsOf(t *testing.T) {
+	latest := time.Date(2025, 9, 19, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		svc    *mockAggService
+		code   int
+		header string
+	}{
+		{name: "ok", svc: &mockAggService{resp: &models.Aggregate{Ticker: "PETR4", TradeCount: 3, LatestDate: latest}}, code: http.StatusOK, header: "2025-09-19"},
+		{name: "no trades in range", svc: &mockAggService{resp: &models.Aggregate{Ticker: "PETR4", HasDataOutsideRange: true}}, code: http.StatusOK},
+		{name: "unknown ticker", svc: &mockAggService{err: service.ErrNoData}, code: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(tc.svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate?ticker=PETR4", nil))
+			if w.Code != tc.code {
+				t.Fatalf("expected %d, got %d", tc.code, w.Code)
+			}
+			got, present := w.Header()[dataAsOfHeader]
+			if tc.header == "" && present {
+				t.Fatalf("expected no %s header, got %q", dataAsOfHeader, got)
+			}
+			if tc.header != "" {
+				if _, err := time.Parse("2006-01-02", w.Header().Get(dataAsOfHeader)); err != nil || w.Header().Get(dataAsOfHeader) != tc.header {
+					t.Fatalf("%s: want %q, got %q", dataAsOfHeader, tc.header, w.Header().Get(dataAsOfHeader))
+				}
+			}
+		})
+	}
+}
+
+func TestGetAggregate_PriceDecimals(t *testing.T) {
+	old := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = old })
+
+	cases := []struct {
+		name     string
+		decimals int
+		price    float64
+		want     string
+	}{
+		{name: "float artifact", decimals: 4, price: 10.500000000000001, want: "10.5"},
+		{name: "rounds half up", decimals: 2, price: 20.125, want: "20.13"},
+		{name: "keeps fewer places", decimals: 4, price: 18.2, want: "18.2"},
+		{name: "unrounded", decimals: 0, price: 10.500000000000001, want: "10.500000000000002"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config.AppConfig.Server.PriceDecimals = tc.decimals
+			svc := &mockAggService{resp: &models.Aggregate{Ticker: "PETR4", MaxRangeValue: tc.price}}
+			w := httptest.NewRecorder()
+			setupRouterWithMock(svc).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate?ticker=PETR4", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", w.Code)
+			}
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			got := string(body["max_range_value"])
+			if got != tc.want {
+				t.Fatalf("max_range_value: want %s, got %s", tc.want, got)
+			}
+			if _, frac, ok := strings.Cut(got, "."); ok && tc.decimals > 0 && len(frac) > tc.decimals {
+				t.Fatalf("max_range_value %s has more than %d decimals", got, tc.decimals)
+			}
+		})
+	}
+}
+
+func TestGetAggregate_Enrich(t *testing.T) {
+	petr := &models.Instrument{Code: "PETR4", ISIN: "BRPETRACNPR6", Name: "PETROBRAS"}
+	cases := []struct {
+		name     string
+		query    string
+		svc      *mockAggService
+		status   int
+		wantName string
+		wantISIN string
+	}{
+		{name: "enriched", query: "&enrich=true", svc: &mockAggService{instrument: petr}, status: http.StatusOK, wantName: "PETROBRAS", wantISIN: "BRPETRACNPR6"},
+		{name: "not requested", query: "", svc: &mockAggService{instrument: petr}, status: http.StatusOK},
+		{name: "disabled", query: "&enrich=false", svc: &mockAggService{instrument: petr}, status: http.StatusOK},
+		{name: "not loaded", query: "&enrich=true", svc: &mockAggService{}, status: http.StatusOK},
+		{name: "lookup failure is not fatal", query: "&enrich=true", svc: &mockAggService{instrErr: errors.New("db down")}, status: http.StatusOK},
+		{name: "invalid", query: "&enrich=maybe", svc: &mockAggService{instrument: petr}, status: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.svc.resp = &models.Aggregate{Ticker: "PETR4", MaxRangeValue: 20.5}
+			w := httptest.NewRecorder()
+			setupRouterWithMock(tc.svc).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate?ticker=PETR4"+tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if tc.wantName == "" {
+				if _, ok := body["name"]; ok {
+					t.Fatalf("name should be omitted: %v", body)
+				}
+				if _, ok := body["isin"]; ok {
+					t.Fatalf("isin should be omitted: %v", body)
+				}
+				return
+			}
+			if body["name"] != tc.wantName || body["isin"] != tc.wantISIN {
+				t.Fatalf("want name=%q isin=%q, got %v", tc.wantName, tc.wantISIN, body)
+			}
+		})
+	}
+}
+
+func TestGetAggregateBySession_TableDriven(t *testing.T) {
+	cases := []struct {
+		name   string
+		query  string
+		svc    *mockAggService
+		status int
+		want   map[string]int64 // session -> trade_count
+	}{
+		{name: "missing ticker", query: "", svc: &mockAggService{}, status: http.StatusBadRequest},
+		{name: "invalid date", query: "ticker=PETR4&data_inicio=bad", svc: &mockAggService{}, status: http.StatusBadRequest},
+		{name: "service error", query: "ticker=PETR4&data_inicio=2025-09-01", svc: &mockAggService{err: errors.New("db")}, status: http.StatusInternalServerError},
+		{name: "no data is empty 200", query: "ticker=PETR4&data_inicio=2025-09-01", svc: &mockAggService{sessions: map[string]*models.Aggregate{}}, status: http.StatusOK, want: map[string]int64{}},
+		{name: "per session", query: "ticker=PETR4&data_inicio=2025-09-01", svc: &mockAggService{sessions: map[string]*models.Aggregate{
+			"1": {Ticker: "PETR4", MaxRangeValue: 10.5, TradeCount: 40},
+			"6": {Ticker: "PETR4", MaxRangeValue: 10.1, TradeCount: 2},
+		}}, status: http.StatusOK, want: map[string]int64{"1": 40, "6": 2}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(tc.svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate/by-session?"+tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d body=%s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			var out dto.SessionAggregateResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			if out.Ticker != "PETR4" || out.Sessions == nil || len(out.Sessions) != len(tc.want) || out.RangeStart != "2025-09-01" {
+				t.Fatalf("unexpected body: %s", w.Body.String())
+			}
+			for session, count := range tc.want {
+				if got := out.Sessions[session]; got == nil || got.TradeCount != count {
+					t.Fatalf("session %s: got %+v, want trade_count=%d", session, got, count)
+				}
+			}
+		})
+	}
+}
+
+func TestGetDailyAggregate_TableDriven(t *testing.T) {
+	day := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		svc    *mockAggService
+		query  string
+		status int
+	}{
+		{name: "missing ticker", svc: &mockAggService{}, query: "/api/v1/aggregate/daily?data=2025-09-18", status: http.StatusBadRequest},
+		{name: "missing date", svc: &mockAggService{}, query: "/api/v1/aggregate/daily?ticker=PETR4", status: http.StatusBadRequest},
+		{name: "invalid date", svc: &mockAggService{}, query: "/api/v1/aggregate/daily?ticker=PETR4&data=18/09/2025", status: http.StatusBadRequest},
+		{name: "not traded", svc: &mockAggService{err: service.ErrNoData}, query: "/api/v1/aggregate/daily?ticker=PETR4&data=2025-09-18", status: http.StatusNotFound},
+		{name: "internal error", svc: &mockAggService{err: errors.New("db down")}, query: "/api/v1/aggregate/daily?ticker=PETR4&data=2025-09-18", status: http.StatusInternalServerError},
+		{
+			name:   "success",
+			svc:    &mockAggService{daily: &models.DailyAggregate{Ticker: "PETR4", Date: day, MaxPrice: 10.5, TotalVolume: 300, TradeCount: 7}},
+			query:  "/api/v1/aggregate/daily?ticker=petr4&data=2025-09-18",
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(tc.svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, w.Code)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			var out dto.DailyAggregateResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			if out.Ticker != "PETR4" || out.Date != "2025-09-18" || out.MaxPrice != 10.5 || out.TotalVolume != 300 || out.TradeCount != 7 {
+				t.Fatalf("unexpected body: %+v", out)
+			}
+		})
+	}
+}
+
+func TestGetSMA_TableDriven(t *testing.T) {
+	prev, oldCfg := nowFunc, config.AppConfig
+	t.Cleanup(func() { nowFunc, config.AppConfig = prev, oldCfg })
+	config.AppConfig.Server.PriceDecimals = 4
+	nowFunc = func() time.Time { return time.Date(2025, 9, 23, 15, 0, 0, 0, time.UTC) }
+
+	avg := 19.856666
+	points := []models.MovingAveragePoint{
+		{Date: time.Date(2025, 9, 11, 0, 0, 0, 0, time.UTC), Close: 20.1},
+		{Date: time.Date(2025, 9, 12, 0, 0, 0, 0, time.UTC), Close: 19.5, SMA: &avg},
+	}
+	cases := []struct {
+		name      string
+		query     string
+		svc       *mockAggService
+		status    int
+		wantRange string // "start end" passed to the service
+	}{
+		{name: "missing ticker", query: "window=20", svc: &mockAggService{}, status: http.StatusBadRequest},
+		{name: "missing window", query: "ticker=PETR4", svc: &mockAggService{}, status: http.StatusBadRequest},
+		{name: "window zero", query: "ticker=PETR4&window=0", svc: &mockAggService{}, status: http.StatusBadRequest},
+		{name: "window too large", query: "ticker=PETR4&window=251", svc: &mockAggService{}, status: http.StatusBadRequest},
+		{name: "window as date range", query: "ticker=PETR4&window=5d", svc: &mockAggService{}, status: http.StatusBadRequest},
+		{name: "range too long", query: "ticker=PETR4&window=20&data_inicio=2015-01-01&data_fim=2025-01-01", svc: &mockAggService{}, status: http.StatusBadRequest},
+		{name: "start after default end", query: "ticker=PETR4&window=20&data_inicio=2025-09-23", svc: &mockAggService{}, status: http.StatusBadRequest},
+		{name: "internal error", query: "ticker=PETR4&window=20", svc: &mockAggService{err: errors.New("db down")}, status: http.StatusInternalServerError},
+		{name: "defaults to last week", query: "ticker=PETR4&window=2", svc: &mockAggService{sma: points}, status: http.StatusOK, wantRange: "2025-09-16 2025-09-22"},
+		{name: "explicit range", query: "ticker=petr4&window=2&data_inicio=2025-09-01&data_fim=2025-09-12", svc: &mockAggService{sma: points}, status: http.StatusOK, wantRange: "2025-09-01 2025-09-12"},
+		{name: "no trades", query: "ticker=PETR4&window=2", svc: &mockAggService{}, status: http.StatusOK, wantRange: "2025-09-16 2025-09-22"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			setupRouterWithMock(tc.svc).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/sma?"+tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			if got := tc.svc.gotRange[0].Format("2006-01-02") + " " + tc.svc.gotRange[1].Format("2006-01-02"); got != tc.wantRange || tc.svc.gotWindow != 2 {
+				t.Fatalf("service got range %s window %d", got, tc.svc.gotWindow)
+			}
+			var out dto.SMAResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			if out.Ticker != "PETR4" || out.Window != 2 || out.Days == nil || len(out.Days) != len(tc.svc.sma) {
+				t.Fatalf("unexpected body: %s", w.Body.String())
+			}
+			if len(out.Days) > 0 {
+				if out.Days[0].Date != "2025-09-11" || out.Days[0].SMA != nil {
+					t.Fatalf("leading day should have a null sma: %s", w.Body.String())
+				}
+				if out.Days[1].SMA == nil || *out.Days[1].SMA != 19.8567 {
+					t.Fatalf("expected sma rounded to 19.8567: %s", w.Body.String())
+				}
+			}
+			if !strings.Contains(w.Body.String(), `"sma":null`) && len(out.Days) > 0 {
+				t.Fatalf("a missing sma must be serialized as null: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetCalendar_TableDriven(t *testing.T) {
+	prev := nowFunc
+	t.Cleanup(func() { nowFunc = prev })
+	nowFunc = func() time.Time { return time.Date(2025, 9, 23, 15, 0, 0, 0, time.UTC) }
+
+	day := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name      string
+		svc       *mockAggService
+		query     string
+		status    int
+		days      int
+		wantEnd   string // end of the range passed to the service
+		wantCache string
+	}{
+		{name: "missing ticker", svc: &mockAggService{}, query: "/api/v1/calendar?year=2025&month=8", status: http.StatusBadRequest},
+		{name: "invalid year", svc: &mockAggService{}, query: "/api/v1/calendar?ticker=PETR4&year=25a&month=8", status: http.StatusBadRequest},
+		{name: "month out of range", svc: &mockAggService{}, query: "/api/v1/calendar?ticker=PETR4&year=2025&month=13", status: http.StatusBadRequest},
+		{name: "internal error", svc: &mockAggService{err: errors.New("db down")}, query: "/api/v1/calendar?ticker=PETR4&year=2025&month=8", status: http.StatusInternalServerError},
+		{
+			name: "past month",
+			svc: &mockAggService{
+				calendar: []models.DailyAggregate{{Ticker: "PETR4", Date: day, MaxPrice: 30.5, TotalVolume: 500}, {Ticker: "PETR4", Date: day.AddDate(0, 0, 3)}},
+				latest:   &models.IngestionLogEntry{FileDate: time.Date(2025, 9, 22, 0, 0, 0, 0, time.UTC)},
+			},
+			query:     "/api/v1/calendar?ticker=petr4&year=2025&month=8",
+			status:    http.StatusOK,
+			days:      2,
+			wantEnd:   "2025-08-31",
+			wantCache: cacheControlImmutable,
+		},
+		{name: "current month capped at today", svc: &mockAggService{}, query: "/api/v1/calendar?ticker=PETR4&year=2025&month=9", status: http.StatusOK, wantEnd: "2025-09-23", wantCache: cacheControlRecent},
+		{name: "future month", svc: &mockAggService{err: errors.New("must not be called")}, query: "/api/v1/calendar?ticker=PETR4&year=2025&month=11", status: http.StatusOK, wantCache: cacheControlRecent},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(tc.svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			var out dto.CalendarResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			if out.Ticker != "PETR4" || out.Year != 2025 || out.Days == nil || len(out.Days) != tc.days {
+				t.Fatalf("unexpected body: %+v", out)
+			}
+			if tc.days > 0 && (out.Days[0].Date != "2025-08-01" || out.Days[0].Volume != 500 || out.Days[0].MaxPrice != 30.5) {
+				t.Fatalf("unexpected first day: %+v", out.Days[0])
+			}
+			if tc.wantEnd != "" && tc.svc.gotRange[1].Format("2006-01-02") != tc.wantEnd {
+				t.Fatalf("expected range end %s, got %s", tc.wantEnd, tc.svc.gotRange[1])
+			}
+			if got := w.Header().Get("Cache-Control"); got != tc.wantCache {
+				t.Fatalf("expected Cache-Control %q, got %q", tc.wantCache, got)
+			}
+		})
+	}
+}
+
+func TestGetGaps_TableDriven(t *testing.T) {
+	prev := nowFunc
+	t.Cleanup(func() { nowFunc = prev })
+	nowFunc = func() time.Time { return time.Date(2025, 9, 23, 15, 0, 0, 0, time.UTC) }
+
+	hole := time.Date(2025, 9, 17, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name    string
+		svc     *mockAggService
+		query   string
+		status  int
+		want    []string
+		wantEnd string
+	}{
+		{name: "data_fim only", svc: &mockAggService{}, query: "/api/v1/gaps?data_fim=2025-09-19", status: http.StatusBadRequest},
+		{name: "invalid date", svc: &mockAggService{}, query: "/api/v1/gaps?data_inicio=15/09/2025", status: http.StatusBadRequest},
+		{name: "start after default end", svc: &mockAggService{}, query: "/api/v1/gaps?data_inicio=2025-09-30", status: http.StatusBadRequest},
+		{name: "range too long", svc: &mockAggService{}, query: "/api/v1/gaps?data_inicio=2024-01-01&data_fim=2025-09-19", status: http.StatusBadRequest},
+		{name: "internal error", svc: &mockAggService{err: errors.New("db down")}, query: "/api/v1/gaps?data_inicio=2025-09-15&data_fim=2025-09-19", status: http.StatusInternalServerError},
+		{name: "gap reported", svc: &mockAggService{missing: []time.Time{hole}}, query: "/api/v1/gaps?data_inicio=2025-09-15&data_fim=2025-09-19", status: http.StatusOK, want: []string{"2025-09-17"}, wantEnd: "2025-09-19"},
+		{name: "end defaults to yesterday", svc: &mockAggService{}, query: "/api/v1/gaps?data_inicio=2025-09-15", status: http.StatusOK, want: []string{}, wantEnd: "2025-09-22"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(tc.svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			var out dto.GapsResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			if out.RangeStart != "2025-09-15" || out.RangeEnd != tc.wantEnd || out.Count != len(tc.want) || out.MissingDates == nil {
+				t.Fatalf("unexpected body: %+v", out)
+			}
+			for i := range tc.want {
+				if out.MissingDates[i] != tc.want[i] {
+					t.Fatalf("missing_dates=%v, want %v", out.MissingDates, tc.want)
+				}
+			}
+			if got := tc.svc.gotRange[1].Format("2006-01-02"); got != tc.wantEnd {
+				t.Fatalf("service got end %s, want %s", got, tc.wantEnd)
+			}
+			if w.Header().Get("Cache-Control") != "no-store" {
+				t.Fatalf("expected no-store, got %q", w.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestCompare_TableDriven(t *testing.T) {
+	priceRatio, volRatio := 2.0, 0.5
+	full := &models.Comparison{
+		TickerA: "PETR4", TickerB: "VALE3",
+		A:             &models.Aggregate{Ticker: "PETR4", MaxRangeValue: 20, MaxDailyVolume: 100},
+		B:             &models.Aggregate{Ticker: "VALE3", MaxRangeValue: 10, MaxDailyVolume: 200},
+		MaxPriceRatio: &priceRatio,
+		VolumeRatio:   &volRatio,
+	}
+	partial := &models.Comparison{
+		TickerA: "PETR4", TickerB: "VALE3",
+		A: &models.Aggregate{Ticker: "PETR4", MaxRangeValue: 20, MaxDailyVolume: 100},
+	}
+
+	cases := []struct {
+		name   string
+		svc    *mockAggService
+		query  string
+		status int
+		assert func(t *testing.T, out dto.CompareResponse)
+	}{
+		{name: "missing ticker_b", svc: &mockAggService{}, query: "/api/v1/compare?ticker_a=PETR4", status: http.StatusBadRequest},
+		{name: "invalid data_inicio", svc: &mockAggService{}, query: "/api/v1/compare?ticker_a=PETR4&ticker_b=VALE3&data_inicio=bad", status: http.StatusBadRequest},
+		{name: "no data for either", svc: &mockAggService{err: service.ErrNoData}, query: "/api/v1/compare?ticker_a=PETR4&ticker_b=VALE3", status: http.StatusNotFound},
+		{name: "internal error", svc: &mockAggService{err: errors.New("db down")}, query: "/api/v1/compare?ticker_a=PETR4&ticker_b=VALE3", status: http.StatusInternalServerError},
+		{
+			name:   "full comparison",
+			svc:    &mockAggService{cmp: full},
+			query:  "/api/v1/compare?ticker_a=petr4&ticker_b=vale3&data_inicio=2025-09-01&data_fim=2025-09-10",
+			status: http.StatusOK,
+			assert: func(t *testing.T, out dto.CompareResponse) {
+				if out.Partial || len(out.MissingTickers) != 0 {
+					t.Fatalf("expected full result, got %+v", out)
+				}
+				if out.A == nil || out.B == nil || out.MaxPriceRatio == nil || *out.MaxPriceRatio != 2.0 || out.VolumeRatio == nil || *out.VolumeRatio != 0.5 {
+					t.Fatalf("unexpected body: %+v", out)
+				}
+			},
+		},
+		{
+			name:   "partial comparison",
+			svc:    &mockAggService{cmp: partial},
+			query:  "/api/v1/compare?ticker_a=PETR4&ticker_b=VALE3",
+			status: http.StatusOK,
+			assert: func(t *testing.T, out dto.CompareResponse) {
+				if !out.Partial || len(out.MissingTickers) != 1 || out.MissingTickers[0] != "VALE3" {
+					t.Fatalf("expected partial result missing VALE3, got %+v", out)
+				}
+				if out.B != nil || out.MaxPriceRatio != nil || out.VolumeRatio != nil {
+					t.Fatalf("expected nil B and ratios, got %+v", out)
+				}
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(tc.svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, w.Code)
+			}
+			if tc.assert == nil {
+				return
+			}
+			var out dto.CompareResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			tc.assert(t, out)
+		})
+	}
+}
+
+func TestGetParticipantActivity_TableDriven(t *testing.T) {
+	summary := &models.ParticipantSummary{
+		Code:      "3",
+		Tickers:   []models.ParticipantActivity{{Ticker: "PETR4", BuyVolume: 300, SellVolume: 100}},
+		Truncated: true,
+	}
+	cases := []struct {
+		name   string
+		svc    *mockAggService
+		query  string
+		status int
+	}{
+		{name: "missing code", svc: &mockAggService{}, query: "/api/v1/participant", status: http.StatusBadRequest},
+		{name: "invalid data_fim", svc: &mockAggService{}, query: "/api/v1/participant?code=3&data_fim=bad", status: http.StatusBadRequest},
+		{name: "no activity", svc: &mockAggService{err: service.ErrNoData}, query: "/api/v1/participant?code=3", status: http.StatusNotFound},
+		{name: "internal error", svc: &mockAggService{err: errors.New("db down")}, query: "/api/v1/participant?code=3", status: http.StatusInternalServerError},
+		{name: "success", svc: &mockAggService{participant: summary}, query: "/api/v1/participant?code=3&data_inicio=2025-09-01&data_fim=2025-09-30", status: http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(tc.svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, w.Code)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			var out dto.ParticipantResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			if out.Code != "3" || !out.Truncated || len(out.Tickers) != 1 || out.Tickers[0].BuyVolume != 300 || out.Tickers[0].SellVolume != 100 {
+				t.Fatalf("unexpected body: %+v", out)
+			}
+		})
+	}
+}
+
+func TestGetFreshness_TableDriven(t *testing.T) {
+	old := nowFunc
+	// Monday 2025-09-22; latest expected business day is the same day.
+	nowFunc = func() time.Time { return time.Date(2025, 9, 22, 15, 0, 0, 0, time.UTC) }
+	t.Cleanup(func() { nowFunc = old })
+
+	entry := func(day int) *models.IngestionLogEntry {
+		return &models.IngestionLogEntry{
+			FileDate:   time.Date(2025, 9, day, 0, 0, 0, 0, time.UTC),
+			RowCount:   42,
+			IngestedAt: time.Date(2025, 9, day, 22, 0, 0, 0, time.UTC),
+		}
+	}
+
+	cases := []struct {
+		name        string
+		svc         *mockAggService
+		status      int
+		wantLatest  string
+		wantCurrent bool
+	}{
+		{name: "current", svc: &mockAggService{latest: entry(22)}, status: http.StatusOK, wantLatest: "2025-09-22", wantCurrent: true},
+		{name: "stale (friday loaded on monday)", svc: &mockAggService{latest: entry(19)}, status: http.StatusOK, wantLatest: "2025-09-19", wantCurrent: false},
+		{name: "nothing ingested", svc: &mockAggService{}, status: http.StatusOK},
+		{name: "internal error", svc: &mockAggService{err: errors.New("db down")}, status: http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(tc.svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/freshness", nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, w.Code)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			var out dto.FreshnessResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			if out.ExpectedDate != "2025-09-22" || out.IsCurrent != tc.wantCurrent {
+				t.Fatalf("unexpected body: %+v", out)
+			}
+			if tc.wantLatest == "" {
+				if out.LatestDate != nil || out.RowCount != nil {
+					t.Fatalf("expected null latest fields, got %+v", out)
+				}
+				return
+			}
+			if out.LatestDate == nil || *out.LatestDate != tc.wantLatest || out.RowCount == nil || *out.RowCount != 42 {
+				t.Fatalf("unexpected latest fields: %+v", out)
+			}
+		})
+	}
+}
+
+func TestExportTrades_TableDriven(t *testing.T) {
+	day := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+	trade := models.Trade{
+		ReferenceDate:         day,
+		InstrumentCode:        "PETR4",
+		UpdateAction:          "0",
+		TradePrice:            10.5,
+		TradeQuantity:         100,
+		ClosingTime:           time.Date(0, 1, 1, 10, 15, 30, 0, time.UTC),
+		TradeIdentifierCode:   "T1",
+		SessionType:           "1",
+		TradeDate:             day,
+		BuyerParticipantCode:  "3",
+		SellerParticipantCode: "72",
+	}
+	many := make([]models.Trade, exportFlushRows+5)
+	for i := range many {
+		many[i] = trade
+	}
+	header := "reference_date,instrument_code,update_action,trade_price,trade_quantity,closing_time,trade_identifier_code,session_type,trade_date,buyer_participant_code,seller_participant_code\n"
+	row := "2025-09-18,PETR4,0,10.5,100,10:15:30,T1,1,2025-09-18,3,72\n"
+	precise := trade
+	precise.TradePrice = 10.123456
+	preciseRow := "2025-09-18,PETR4,0,10.123456,100,10:15:30,T1,1,2025-09-18,3,72\n"
+	unknown := trade
+	unknown.TradePrice, unknown.TradeQuantity = models.MissingPrice(), models.MissingQuantity()
+	unknownRow := "2025-09-18,PETR4,0,,,10:15:30,T1,1,2025-09-18,3,72\n"
+
+	old := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = old })
+	config.AppConfig.Server.PriceDecimals = 2
+
+	cases := []struct {
+		name      string
+		svc       *mockAggService
+		query     string
+		status    int
+		wantBody  string
+		wantRows  int
+		wantFile  string
+		wantNoCSV bool
+	}{
+		{name: "missing data", svc: &mockAggService{}, query: "/api/v1/trades/export?ticker=PETR4", status: http.StatusBadRequest, wantNoCSV: true},
+		{name: "invalid data", svc: &mockAggService{}, query: "/api/v1/trades/export?data=18-09-2025", status: http.StatusBadRequest, wantNoCSV: true},
+		{name: "query error before rows", svc: &mockAggService{err: errors.New("db down")}, query: "/api/v1/trades/export?data=2025-09-18", status: http.StatusInternalServerError, wantNoCSV: true},
+		{name: "empty day writes header only", svc: &mockAggService{}, query: "/api/v1/trades/export?data=2025-09-18", status: http.StatusOK, wantBody: header, wantFile: "trades_2025-09-18.csv"},
+		{name: "rows for ticker", svc: &mockAggService{trades: []models.Trade{trade}}, query: "/api/v1/trades/export?ticker=petr4&data=2025-09-18", status: http.StatusOK, wantBody: header + row, wantFile: "trades_PETR4_2025-09-18.csv"},
+		{name: "many rows across flushes", svc: &mockAggService{trades: many}, query: "/api/v1/trades/export?data=2025-09-18", status: http.StatusOK, wantRows: len(many) + 1, wantFile: "trades_2025-09-18.csv"},
+		{name: "raw price is not rounded", svc: &mockAggService{trades: []models.Trade{precise}}, query: "/api/v1/trades/export?data=2025-09-18", status: http.StatusOK, wantBody: header + preciseRow, wantFile: "trades_2025-09-18.csv"},
+		{name: "null price and quantity are empty", svc: &mockAggService{trades: []models.Trade{unknown}}, query: "/api/v1/trades/export?data=2025-09-18", status: http.StatusOK, wantBody: header + unknownRow, wantFile: "trades_2025-09-18.csv"},
+		{name: "error mid-stream keeps sent rows", svc: &mockAggService{trades: []models.Trade{trade}, err: errors.New("conn reset")}, query: "/api/v1/trades/export?data=2025-09-18", status: http.StatusOK, wantBody: header + row, wantFile: "trades_2025-09-18.csv"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(tc.svc)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.query, nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("status: want %d got %d body=%s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.wantNoCSV {
+				if ct := w.Header().Get("Content-Type"); ct == "text/csv; charset=utf-8" {
+					t.Fatalf("unexpected CSV content type on error")
+				}
+				return
+			}
+			if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="`+tc.wantFile+`"` {
+				t.Fatalf("Content-Disposition=%q", cd)
+			}
+			if tc.wantBody != "" && w.Body.String() != tc.wantBody {
+				t.Fatalf("body:\nwant %q\ngot  %q", tc.wantBody, w.Body.String())
+			}
+			if tc.wantRows > 0 {
+				if n := strings.Count(w.Body.String(), "\n"); n != tc.wantRows {
+					t.Fatalf("rows: want %d got %d", tc.wantRows, n)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamTradesNDJSON_TableDriven(t *testing.T) {
+	day := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+	trade := models.Trade{
+		ReferenceDate:         day,
+		InstrumentCode:        "PETR4",
+		UpdateAction:          "0",
+		TradePrice:            10.5,
+		TradeQuantity:         100,
+		ClosingTime:           time.Date(0, 1, 1, 10, 15, 30, 0, time.UTC),
+		TradeIdentifierCode:   "T1",
+		SessionType:           "1",
+		TradeDate:             day,
+		BuyerParticipantCode:  "3",
+		SellerParticipantCode: "72",
+	}
+	many := make([]models.Trade, exportFlushRows+5)
+	for i := range many {
+		many[i] = trade
+	}
+	line := `{"reference_date":"2025-09-18","instrument_code":"PETR4","update_action":"0","trade_price":10.5,"trade_quantity":100,"closing_time":"10:15:30","trade_identifier_code":"T1","session_type":"1","trade_date":"2025-09-18","buyer_participant_code":"3","seller_participant_code":"72"}` + "\n"
+	precise := trade
+	precise.TradePrice = 10.123456
+	preciseLine := strings.Replace(line, `"trade_price":10.5`, `"trade_price":10.123456`, 1)
+	unknown := trade
+	unknown.TradePrice, unknown.TradeQuantity = models.MissingPrice(), models.MissingQuantity()
+	unknownLine := strings.Replace(line, `"trade_price":10.5,"trade_quantity":100`, `"trade_price":null,"trade_quantity":null`, 1)
+
+	old := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = old })
+	config.AppConfig.Server.PriceDecimals = 2
+
+	cases := []struct {
+		name       string
+		svc        *mockAggService
+		query      string
+		status     int
+		wantBody   string
+		wantLines  int
+		wantNDJSON bool
+	}{
+		{name: "missing data", svc: &mockAggService{}, query: "/api/v1/trades/stream?ticker=PETR4", status: http.StatusBadRequest},
+		{name: "invalid data", svc: &mockAggService{}, query: "/api/v1/trades/stream?data=18-09-2025", status: http.StatusBadRequest},
+		{name: "query error before rows", svc: &mockAggService{err: errors.New("db down")}, query: "/api/v1/trades/stream?data=2025-09-18", status: http.StatusInternalServerError},
+		{name: "empty day", svc: &mockAggService{}, query: "/api/v1/trades/stream?data=2025-09-18", status: http.StatusOK, wantNDJSON: true},
+		{name: "rows for ticker", svc: &mockAggService{trades: []models.Trade{trade}}, query: "/api/v1/trades/stream?ticker=petr4&data=2025-09-18", status: http.StatusOK, wantBody: line, wantNDJSON: true},
+		{name: "many rows across flushes", svc: &mockAggService{trades: many}, query: "/api/v1/trades/stream?data=2025-09-18", status: http.StatusOK, wantLines: len(many), wantNDJSON: true},
+		{name: "raw price is not rounded", svc: &mockAggService{trades: []models.Trade{precise}}, query: "/api/v1/trades/stream?data=2025-09-18", status: http.StatusOK, wantBody: preciseLine, wantNDJSON: true},
+		{name: "null price and quantity", svc: &mockAggService{trades: []models.Trade{unknown}}, query: "/api/v1/trades/stream?data=2025-09-18", status: http.StatusOK, wantBody: unknownLine, wantNDJSON: true},
+		{name: "error mid-stream keeps sent rows", svc: &mockAggService{trades: []models.Trade{trade}, err: errors.New("conn reset")}, query: "/api/v1/trades/stream?data=2025-09-18", status: http.StatusOK, wantBody: line, wantNDJSON: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(tc.svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.query, nil))
+
+			if w.Code != tc.status {
+				t.Fatalf("status: want %d got %d body=%s", tc.status, w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); (ct == "application/x-ndjson") != tc.wantNDJSON {
+				t.Fatalf("Content-Type=%q, want NDJSON=%v", ct, tc.wantNDJSON)
+			}
+			if !tc.wantNDJSON {
+				return
+			}
+			if tc.wantLines == 0 && w.Body.String() != tc.wantBody {
+				t.Fatalf("body:\nwant %q\ngot  %q", tc.wantBody, w.Body.String())
+			}
+			if tc.wantLines > 0 {
+				if n := strings.Count(w.Body.String(), "\n"); n != tc.wantLines {
+					t.Fatalf("lines: want %d got %d", tc.wantLines, n)
+				}
+			}
+		})
+	}
+}
+
+func TestGetSpread_TableDriven(t *testing.T) {
+	spread := &models.PriceSpread{Ticker: "PETR4", MaxPrice: 22, MinPrice: 20, SpreadAbs: 2, SpreadPct: 10}
+	cases := []struct {
+		name   string
+		svc    *mockAggService
+		query  string
+		status int
+	}{
+		{name: "missing ticker", svc: &mockAggService{}, query: "/api/v1/spread", status: http.StatusBadRequest},
+		{name: "invalid date", svc: &mockAggService{}, query: "/api/v1/spread?ticker=PETR4&data_inicio=12/09/2025", status: http.StatusBadRequest},
+		{name: "no trades", svc: &mockAggService{err: service.ErrNoData}, query: "/api/v1/spread?ticker=PETR4", status: http.StatusNotFound},
+		{name: "internal error", svc: &mockAggService{err: errors.New("db down")}, query: "/api/v1/spread?ticker=PETR4", status: http.StatusInternalServerError},
+		{name: "ok", svc: &mockAggService{spread: spread}, query: "/api/v1/spread?ticker=petr4&data_inicio=2025-09-12&data_fim=2025-09-19", status: http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(tc.svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			var out dto.SpreadResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			want := dto.SpreadResponse{Ticker: "PETR4", MaxPrice: 22, MinPrice: 20, SpreadAbs: 2, SpreadPct: 10, RangeStart: "2025-09-12", RangeEnd: "2025-09-19"}
+			if out != want {
+				t.Fatalf("unexpected body: %+v", out)
+			}
+		})
+	}
+}
+
+func TestGetNotional_TableDriven(t *testing.T) {
+	notional := &models.Notional{Ticker: "PETR4", Notional: 4680.000000000001, TradeCount: 4}
+	cases := []struct {
+		name   string
+		svc    *mockAggService
+		query  string
+		status int
+	}{
+		{name: "missing ticker", svc: &mockAggService{}, query: "/api/v1/notional", status: http.StatusBadRequest},
+		{name: "invalid date", svc: &mockAggService{}, query: "/api/v1/notional?ticker=PETR4&data_inicio=12/09/2025", status: http.StatusBadRequest},
+		{name: "no trades", svc: &mockAggService{err: service.ErrNoData}, query: "/api/v1/notional?ticker=PETR4", status: http.StatusNotFound},
+		{name: "internal error", svc: &mockAggService{err: errors.New("db down")}, query: "/api/v1/notional?ticker=PETR4", status: http.StatusInternalServerError},
+		{name: "ok", svc: &mockAggService{notional: notional}, query: "/api/v1/notional?ticker=petr4&data_inicio=2025-09-11&data_fim=2025-09-13", status: http.StatusOK},
+	}
+
+	old := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = old })
+	config.AppConfig.Server.PriceDecimals = 4
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(tc.svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			var out dto.NotionalResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			want := dto.NotionalResponse{Ticker: "PETR4", NotionalTraded: 4680, TradeCount: 4, RangeStart: "2025-09-11", RangeEnd: "2025-09-13"}
+			if out != want {
+				t.Fatalf("unexpected body: %+v", out)
+			}
+		})
+	}
+}
+
+func TestGetLatestPrice_TableDriven(t *testing.T) {
+	latest := &models.LatestPrice{
+		Ticker:      "PETR4",
+		Price:       21.5,
+		Quantity:    300,
+		TradeDate:   time.Date(2025, 9, 19, 0, 0, 0, 0, time.UTC),
+		ClosingTime: time.Date(0, 1, 1, 17, 54, 59, 0, time.UTC),
+	}
+	cases := []struct {
+		name   string
+		svc    *mockAggService
+		query  string
+		status int
+		want   dto.LatestPriceResponse
+	}{
+		{name: "missing ticker", svc: &mockAggService{}, query: "/api/v1/latest", status: http.StatusBadRequest},
+		{name: "no trades", svc: &mockAggService{err: service.ErrNoData}, query: "/api/v1/latest?ticker=PETR4", status: http.StatusNotFound},
+		{name: "internal error", svc: &mockAggService{err: errors.New("db down")}, query: "/api/v1/latest?ticker=PETR4", status: http.StatusInternalServerError},
+		{name: "ok", svc: &mockAggService{latestPrice: latest}, query: "/api/v1/latest?ticker=petr4", status: http.StatusOK,
+			want: dto.LatestPriceResponse{Ticker: "PETR4", Price: 21.5, Quantity: 300, TradeDate: "2025-09-19", ClosingTime: "17:54:59"}},
+		{name: "unknown closing time", svc: &mockAggService{latestPrice: &models.LatestPrice{Ticker: "PETR4", Price: 21.5, TradeDate: latest.TradeDate}}, query: "/api/v1/latest?ticker=PETR4", status: http.StatusOK,
+			want: dto.LatestPriceResponse{Ticker: "PETR4", Price: 21.5, TradeDate: "2025-09-19"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(tc.svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			if got := tc.svc.gotTickers; len(got) != 1 || got[0] != "PETR4" {
+				t.Fatalf("service got tickers %v, want [PETR4]", got)
+			}
+			if cc := w.Header().Get("Cache-Control"); cc != cacheControlRecent {
+				t.Fatalf("unexpected Cache-Control %q", cc)
+			}
+			var out dto.LatestPriceResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			if out != tc.want {
+				t.Fatalf("unexpected body: %+v", out)
+			}
+		})
+	}
+}
+
+func TestGetCrossTrades_TableDriven(t *testing.T) {
+	page := &models.CrossSummary{Buyer: "3", Seller: "72", Tickers: []models.CrossActivity{{Ticker: "PETR4", Volume: 500, TradeCount: 4}}, HasMore: true}
+	cases := []struct {
+		name     string
+		svc      *mockAggService
+		query    string
+		status   int
+		wantPage [2]int
+	}{
+		{name: "neither buyer nor seller", svc: &mockAggService{}, query: "/api/v1/cross?data_inicio=2025-09-01", status: http.StatusBadRequest},
+		{name: "invalid limit", svc: &mockAggService{}, query: "/api/v1/cross?buyer=3&limit=0", status: http.StatusBadRequest},
+		{name: "limit too large", svc: &mockAggService{}, query: "/api/v1/cross?buyer=3&limit=501", status: http.StatusBadRequest},
+		{name: "negative offset", svc: &mockAggService{}, query: "/api/v1/cross?buyer=3&offset=-1", status: http.StatusBadRequest},
+		{name: "no trades", svc: &mockAggService{err: service.ErrNoData}, query: "/api/v1/cross?buyer=3", status: http.StatusNotFound},
+		{name: "internal error", svc: &mockAggService{err: errors.New("db down")}, query: "/api/v1/cross?seller=72", status: http.StatusInternalServerError},
+		{name: "default page", svc: &mockAggService{cross: page}, query: "/api/v1/cross?buyer=3&seller=72", status: http.StatusOK, wantPage: [2]int{50, 0}},
+		{name: "explicit page", svc: &mockAggService{cross: page}, query: "/api/v1/cross?buyer=3&seller=72&limit=1&offset=2", status: http.StatusOK, wantPage: [2]int{1, 2}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(tc.svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.query, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			if tc.svc.gotPage != tc.wantPage {
+				t.Fatalf("service got page %v, want %v", tc.svc.gotPage, tc.wantPage)
+			}
+			var out dto.CrossResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			if out.Buyer != "3" || out.Seller != "72" || len(out.Tickers) != 1 || out.Tickers[0].TradeCount != 4 ||
+				!out.HasMore || out.Limit != tc.wantPage[0] || out.Offset != tc.wantPage[1] {
+				t.Fatalf("unexpected body: %+v", out)
+			}
+		})
+	}
+}
@@ -1,33 +1,133 @@
 package api
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/internal/calendar"
+	"github.com/guttosm/b3pulse/internal/domain/dto"
+	"github.com/guttosm/b3pulse/internal/domain/models"
+	"github.com/guttosm/b3pulse/internal/middleware"
+)
+
+// defaultReadyTimeout bounds the readiness ping when no timeout is configured.
+const defaultReadyTimeout = 2 * time.Second
+
+// HealthCheck is one named dependency check reported by GET /health/detail.
+//
+// Fields:
+//   - Name: identifies the check in the response (e.g. "database").
+//   - Critical: a failure makes /health/detail answer 503; a non-critical
+//     failure only degrades the overall status.
+//   - Check: returns nil when the dependency is healthy. It runs under the
+//     readiness timeout and should honour ctx.
+type HealthCheck struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) error
+}
 
 // HealthHandler provides liveness and readiness endpoints for the service.
 //
 // Responsibilities:
 //   - /healthz: Basic liveness probe (always returns 200 OK).
-//   - /readyz: Readiness probe (depends on database connectivity).
+//   - /readyz: Readiness probe (depends on database connectivity and, with
+//     RequireData, on recently ingested data).
+//   - /health/detail: Status of every registered HealthCheck, for status pages.
 type HealthHandler struct {
-	dbPing func() error // Function to check database connectivity
+	dbPing    func(context.Context) error // Function to check database connectivity
+	timeout   time.Duration               // Upper bound for a single readiness check
+	dataCheck func(context.Context) error // Set by RequireData; nil skips the data check
+	checks    []HealthCheck               // Reported by /health/detail, in registration order
 }
 
 // NewHealthHandler constructs a HealthHandler with the provided dbPing function.
 //
 // Parameters:
-//   - dbPing (func() error): A function used to check if the database is reachable.
-//     Typically, this is db.Ping from *sql.DB.
+//   - dbPing (func(context.Context) error): A function used to check if the database is reachable.
+//     Typically, this is db.PingContext from *sql.DB. It is also registered as the
+//     critical "database" check of /health/detail.
+//   - timeout (time.Duration): Maximum time /readyz waits for dbPing; <= 0 uses 2s.
 //
 // Returns:
 //   - *HealthHandler: A new handler instance.
-func NewHealthHandler(dbPing func() error) *HealthHandler {
-	return &HealthHandler{dbPing: dbPing}
+func NewHealthHandler(dbPing func(context.Context) error, timeout time.Duration) *HealthHandler {
+	if timeout <= 0 {
+		timeout = defaultReadyTimeout
+	}
+	h := &HealthHandler{dbPing: dbPing, timeout: timeout}
+	if dbPing != nil {
+		h.AddCheck(HealthCheck{Name: "database", Critical: true, Check: dbPing})
+	}
+	return h
+}
+
+// AddCheck registers c with /health/detail. It does not affect /readyz.
+//
+// Returns:
+//   - *HealthHandler: h, for chaining.
+func (h *HealthHandler) AddCheck(c HealthCheck) *HealthHandler {
+	h.checks = append(h.checks, c)
+	return h
+}
+
+// RequireData makes /readyz also report not ready until latest returns an
+// entry for one of the last maxAgeDays business days (on or before today in
+// APP_TIMEZONE), so traffic is not routed to an instance before data is loaded.
+//
+// Parameters:
+//   - latest (func() (*models.IngestionLogEntry, error)): Returns the most recent
+//     ingestion_log entry, or nil when nothing was ingested; typically the
+//     repository's GetLatestIngestion.
+//   - maxAgeDays (int): Number of recent business days that count as fresh; <= 0 uses 1.
+//
+// Returns:
+//   - *HealthHandler: h, for chaining.
+func (h *HealthHandler) RequireData(latest func() (*models.IngestionLogEntry, error), maxAgeDays int) *HealthHandler {
+	h.dataCheck = DataFreshnessCheck(latest, maxAgeDays)
+	return h
+}
+
+// DataFreshnessCheck returns a check that fails unless latest returns an entry
+// for one of the last maxAgeDays business days (on or before today in
+// APP_TIMEZONE); maxAgeDays <= 0 uses 1. It backs RequireData and can be
+// registered with AddCheck.
+func DataFreshnessCheck(latest func() (*models.IngestionLogEntry, error), maxAgeDays int) func(context.Context) error {
+	if maxAgeDays <= 0 {
+		maxAgeDays = 1
+	}
+	return func(context.Context) error {
+		entry, err := latest()
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return errors.New("nothing ingested yet")
+		}
+		days := calendar.LastNBusinessDays(maxAgeDays, today())
+		oldest := days[len(days)-1].Format("2006-01-02")
+		// Compare calendar dates (YYYY-MM-DD sorts chronologically).
+		if got := entry.FileDate.Format("2006-01-02"); got < oldest {
+			return fmt.Errorf("latest ingested day %s is older than %s", got, oldest)
+		}
+		return nil
+	}
 }
 
 // Register mounts the health and readiness endpoints into the provided Gin router.
 //
 // Routes:
 //   - GET /healthz: Always returns 200 OK.
-//   - GET /readyz: Returns 200 OK if dbPing succeeds, 503 if database is not reachable.
+//   - GET /readyz: Returns 200 OK if dbPing succeeds, 503 if the database is not reachable
+//     or does not answer within the configured timeout. With RequireData, it also
+//     returns 503 ("no_data") while no recent business day has been ingested.
+//   - GET /health/detail: Returns dto.HealthDetailResponse; 200 unless a critical check
+//     fails (503). Failure reasons are logged, not returned.
 //
 // Parameters:
 //   - r (*gin.Engine): The Gin router to register routes on.
@@ -52,10 +152,86 @@ func (h *HealthHandler) Register(r *gin.Engine) {
 	// @Failure      503  {object}  map[string]string
 	// @Router       /readyz [get]
 	r.GET("/readyz", func(c *gin.Context) {
-		if h.dbPing != nil && h.dbPing() != nil {
+		if h.dbPing != nil && h.await(c.Request.Context(), h.dbPing) != nil {
 			c.JSON(503, gin.H{"status": "degraded"})
 			return
 		}
+		if h.dataCheck != nil && h.await(c.Request.Context(), h.dataCheck) != nil {
+			c.JSON(503, gin.H{"status": "no_data"})
+			return
+		}
 		c.JSON(200, gin.H{"status": "ready"})
 	})
+
+	// Dependency report (runs every registered check)
+	// @Summary      Detailed health
+	// @Description  Reports each dependency check with its status and latency
+	// @Tags         health
+	// @Produce      json
+	// @Success      200  {object}  dto.HealthDetailResponse
+	// @Failure      503  {object}  dto.HealthDetailResponse
+	// @Router       /health/detail [get]
+	r.GET("/health/detail", h.detail)
+}
+
+// detail runs every check concurrently, each under the readiness timeout, and
+// reports them in registration order.
+func (h *HealthHandler) detail(c *gin.Context) {
+	resp := dto.HealthDetailResponse{Status: "up", Checks: make([]dto.HealthCheck, len(h.checks))}
+	errs := make([]error, len(h.checks))
+
+	var wg sync.WaitGroup
+	for i, hc := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			errs[i] = h.await(c.Request.Context(), hc.Check)
+			resp.Checks[i] = dto.HealthCheck{
+				Name:      hc.Name,
+				Status:    "up",
+				Critical:  hc.Critical,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		resp.Checks[i].Status = "down"
+		middleware.Log(c).Warn().Err(err).Str("check", h.checks[i].Name).Msg("health check failed")
+		switch {
+		case h.checks[i].Critical:
+			resp.Status = "down"
+		case resp.Status == "up":
+			resp.Status = "degraded"
+		}
+	}
+
+	status := http.StatusOK
+	if resp.Status == "down" {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, resp)
+}
+
+// await runs check under the readiness timeout. The result is awaited in a
+// select so the probe answers on time even if the driver ignores ctx, e.g. on a
+// half-open TCP connection.
+func (h *HealthHandler) await(ctx context.Context, check func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	done := make(chan error, 1) // buffered: a late check must not block forever
+	go func() { done <- check(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
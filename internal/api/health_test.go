@@ -1,11 +1,17 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/internal/domain/dto"
+	"github.com/guttosm/b3pulse/internal/domain/models"
 )
 
 func TestHealthHandler(t *testing.T) {
@@ -24,17 +30,17 @@ func TestHealthHandler(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			var ping func() error
+			var ping func(context.Context) error
 			if tc.path == "/readyz" {
 				if tc.pingErr {
-					ping = func() error { return assertErr{} }
+					ping = func(context.Context) error { return assertErr{} }
 				} else {
-					ping = func() error { return nil }
+					ping = func(context.Context) error { return nil }
 				}
 			}
 
 			r := gin.New()
-			NewHealthHandler(ping).Register(r)
+			NewHealthHandler(ping, 0).Register(r)
 			w := httptest.NewRecorder()
 			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
 			r.ServeHTTP(w, req)
@@ -48,3 +54,127 @@ func TestHealthHandler(t *testing.T) {
 type assertErr struct{}
 
 func (assertErr) Error() string { return "err" }
+
+func TestHealthHandler_ReadyzTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	cases := []struct {
+		name string
+		ping func(context.Context) error
+	}{
+		{
+			name: "ping honours ctx",
+			ping: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		},
+		{
+			name: "ping ignores ctx",
+			ping: func(context.Context) error {
+				<-release
+				return nil
+			},
+		},
+	}
+
+	const timeout = 50 * time.Millisecond
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := gin.New()
+			NewHealthHandler(tc.ping, timeout).Register(r)
+
+			start := time.Now()
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if elapsed := time.Since(start); elapsed > 10*timeout {
+				t.Fatalf("readyz took %v, want about %v", elapsed, timeout)
+			}
+			if w.Code != http.StatusServiceUnavailable {
+				t.Fatalf("want 503 got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestHealthHandler_RequireData(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := nowFunc
+	t.Cleanup(func() { nowFunc = prev })
+	// Tuesday: with 3 days, 2025-09-19 (Friday) is the oldest fresh business day.
+	nowFunc = func() time.Time { return time.Date(2025, 9, 23, 15, 0, 0, 0, time.UTC) }
+
+	entry := func(day string) func() (*models.IngestionLogEntry, error) {
+		return func() (*models.IngestionLogEntry, error) {
+			d, _ := time.Parse("2006-01-02", day)
+			return &models.IngestionLogEntry{FileDate: d}, nil
+		}
+	}
+	cases := []struct {
+		name   string
+		latest func() (*models.IngestionLogEntry, error)
+		want   int
+		status string
+	}{
+		{name: "nothing ingested", latest: func() (*models.IngestionLogEntry, error) { return nil, nil }, want: 503, status: "no_data"},
+		{name: "lookup error", latest: func() (*models.IngestionLogEntry, error) { return nil, assertErr{} }, want: 503, status: "no_data"},
+		{name: "stale", latest: entry("2025-09-18"), want: 503, status: "no_data"},
+		{name: "oldest fresh day", latest: entry("2025-09-19"), want: 200, status: "ready"},
+		{name: "today", latest: entry("2025-09-23"), want: 200, status: "ready"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := gin.New()
+			NewHealthHandler(func(context.Context) error { return nil }, 0).RequireData(tc.latest, 3).Register(r)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if w.Code != tc.want || !strings.Contains(w.Body.String(), `"status":"`+tc.status+`"`) {
+				t.Fatalf("got %d %s, want %d %s", w.Code, w.Body.String(), tc.want, tc.status)
+			}
+		})
+	}
+}
+
+func TestHealthHandler_Detail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ok := func(context.Context) error { return nil }
+	fail := func(context.Context) error { return assertErr{} }
+	cases := []struct {
+		name     string
+		db       func(context.Context) error
+		extra    func(context.Context) error
+		want     int
+		status   string
+		statuses []string
+	}{
+		{name: "all up", db: ok, extra: ok, want: 200, status: "up", statuses: []string{"up", "up"}},
+		{name: "non-critical down", db: ok, extra: fail, want: 200, status: "degraded", statuses: []string{"up", "down"}},
+		{name: "critical down", db: fail, extra: ok, want: 503, status: "down", statuses: []string{"down", "up"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := gin.New()
+			NewHealthHandler(tc.db, 0).AddCheck(HealthCheck{Name: "freshness", Check: tc.extra}).Register(r)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/detail", nil))
+			if w.Code != tc.want {
+				t.Fatalf("code = %d, want %d: %s", w.Code, tc.want, w.Body.String())
+			}
+			var got dto.HealthDetailResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got.Status != tc.status || len(got.Checks) != 2 {
+				t.Fatalf("got %+v, want status %s with 2 checks", got, tc.status)
+			}
+			for i, name := range []string{"database", "freshness"} {
+				c := got.Checks[i]
+				if c.Name != name || c.Status != tc.statuses[i] || c.Critical != (i == 0) || c.LatencyMs < 0 {
+					t.Fatalf("check %d = %+v, want %s %s", i, c, name, tc.statuses[i])
+				}
+			}
+		})
+	}
+}
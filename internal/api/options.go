@@ -0,0 +1,96 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/config"
+	"github.com/guttosm/b3pulse/internal/middleware"
+)
+
+// RouterOption customizes the router built by NewRouter.
+type RouterOption func(*routerOptions)
+
+// routerOptions holds what NewRouter installs; see ConfigOptions for the defaults.
+type routerOptions struct {
+	trustedProxies []string
+	debugHeader    bool
+	rateLimiter    gin.HandlerFunc // nil: no rate limiting
+	rateLimiterSet bool            // WithRateLimiter was given; otherwise NewRouter builds the default
+	bodyLimit      int64
+	middlewares    []gin.HandlerFunc // extra global middlewares, after the built-in ones
+	v1Auth         []gin.HandlerFunc
+	maxConcurrent  int
+	concurrentWait time.Duration
+	adminKey       string
+	pprof          bool
+}
+
+// ConfigOptions returns the options matching cfg: the settings NewRouter uses
+// by default, read from config.AppConfig. The rate limiter is not among them:
+// NewRouter builds middleware.RateLimiter only when no WithRateLimiter is given,
+// so an override never starts its sweeper.
+func ConfigOptions(cfg config.Config) []RouterOption {
+	var auth []gin.HandlerFunc
+	if len(cfg.Signing.Secrets) > 0 {
+		auth = append(auth, middleware.SignatureAuth(cfg.Signing.Secrets, cfg.Signing.MaxSkew))
+	}
+	return []RouterOption{
+		WithTrustedProxies(cfg.Server.TrustedProxies),
+		WithDebugHeader(cfg.Debug.Header),
+		WithBodyLimit(cfg.Server.MaxBodyBytes),
+		WithAuth(auth...),
+		WithConcurrencyLimit(cfg.Server.MaxConcurrent, cfg.Server.ConcurrentWait),
+		WithAdminKey(cfg.Admin.APIKey),
+		WithPprof(cfg.Debug.Pprof),
+	}
+}
+
+// WithTrustedProxies trusts X-Forwarded-For only from these IPs/CIDRs; none
+// makes ClientIP() always the TCP peer address.
+func WithTrustedProxies(proxies []string) RouterOption {
+	return func(o *routerOptions) { o.trustedProxies = proxies }
+}
+
+// WithDebugHeader honours "X-Debug: true" (see middleware.DebugLogging).
+func WithDebugHeader(allow bool) RouterOption {
+	return func(o *routerOptions) { o.debugHeader = allow }
+}
+
+// WithRateLimiter replaces the per-client rate limiter; nil disables rate limiting.
+func WithRateLimiter(mw gin.HandlerFunc) RouterOption {
+	return func(o *routerOptions) { o.rateLimiter, o.rateLimiterSet = mw, true }
+}
+
+// WithBodyLimit caps request bodies at maxBytes (0 = unlimited, see middleware.BodyLimit).
+func WithBodyLimit(maxBytes int64) RouterOption {
+	return func(o *routerOptions) { o.bodyLimit = maxBytes }
+}
+
+// WithMiddleware appends global middlewares (e.g. CORS, compression, tracing),
+// run in the given order after the built-in ones and before the request
+// timeout. Repeated calls append.
+func WithMiddleware(mw ...gin.HandlerFunc) RouterOption {
+	return func(o *routerOptions) { o.middlewares = append(o.middlewares, mw...) }
+}
+
+// WithAuth replaces the middlewares authenticating /api/v1 requests, run in the
+// given order before the concurrency limit; none leaves the API open.
+func WithAuth(mw ...gin.HandlerFunc) RouterOption {
+	return func(o *routerOptions) { o.v1Auth = mw }
+}
+
+// WithConcurrencyLimit caps /api/v1 requests in flight (see middleware.ConcurrencyLimit).
+func WithConcurrencyLimit(maxInFlight int, wait time.Duration) RouterOption {
+	return func(o *routerOptions) { o.maxConcurrent, o.concurrentWait = maxInFlight, wait }
+}
+
+// WithAdminKey sets the X-Admin-Key required by /admin and pprof; empty leaves them open.
+func WithAdminKey(key string) RouterOption {
+	return func(o *routerOptions) { o.adminKey = key }
+}
+
+// WithPprof mounts net/http/pprof under /debug/pprof behind the admin key.
+func WithPprof(enabled bool) RouterOption {
+	return func(o *routerOptions) { o.pprof = enabled }
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http/pprof"
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pprofPath is where the net/http/pprof handlers are mounted when ENABLE_PPROF
+// is set; pprof.Index derives profile names from paths under it.
+const pprofPath = "/debug/pprof"
+
+// pprofMutexFraction samples 1 in N mutex contention events while pprof is enabled.
+const pprofMutexFraction = 5
+
+// registerPprof mounts the standard pprof handlers on rg (which must be
+// rooted at pprofPath): the index, CPU profile, trace, cmdline, symbol and
+// the named runtime profiles (heap, goroutine, mutex, block, allocs,
+// threadcreate). It also turns on mutex sampling, which is off by default.
+func registerPprof(rg *gin.RouterGroup) {
+	runtime.SetMutexProfileFraction(pprofMutexFraction)
+
+	rg.GET("/", gin.WrapF(pprof.Index))
+	rg.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	rg.GET("/profile", gin.WrapF(pprof.Profile))
+	rg.GET("/symbol", gin.WrapF(pprof.Symbol))
+	rg.POST("/symbol", gin.WrapF(pprof.Symbol))
+	rg.GET("/trace", gin.WrapF(pprof.Trace))
+	for _, name := range []string{"heap", "goroutine", "mutex", "block", "allocs", "threadcreate"} {
+		rg.GET("/"+name, gin.WrapH(pprof.Handler(name)))
+	}
+}
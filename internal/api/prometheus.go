@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/guttosm/b3pulse/internal/domain/dto"
+)
+
+const (
+	// formatPrometheus selects the Prometheus text exposition format on GetAggregate.
+	formatPrometheus = "prometheus"
+
+	// prometheusContentType is the content type of text exposition format 0.0.4.
+	prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// promMetric is one gauge of the aggregate exposition.
+type promMetric struct {
+	name  string
+	help  string
+	value func(dto.AggregateResponse) float64
+}
+
+// aggregateMetrics are rendered, in order, by renderAggregatePrometheus.
+var aggregateMetrics = []promMetric{
+	{name: "b3pulse_max_price", help: "Maximum trade price in the range.", value: func(r dto.AggregateResponse) float64 { return r.MaxRangeValue }},
+	{name: "b3pulse_max_daily_volume", help: "Maximum daily traded volume in the range.", value: func(r dto.AggregateResponse) float64 { return float64(r.MaxDailyVolume) }},
+	{name: "b3pulse_min_daily_volume", help: "Minimum daily traded volume among days with trades in the range.", value: func(r dto.AggregateResponse) float64 { return float64(r.MinDailyVolume) }},
+	{name: "b3pulse_trade_count", help: "Number of trades in the range.", value: func(r dto.AggregateResponse) float64 { return float64(r.TradeCount) }},
+}
+
+// renderAggregatePrometheus renders resp as Prometheus gauges labelled with the
+// ticker (and the resolved range bounds when set), e.g.
+//
+//	b3pulse_max_price{ticker="PETR4"} 12.5
+func renderAggregatePrometheus(resp dto.AggregateResponse) []byte {
+	labels := []string{promLabel("ticker", resp.Ticker)}
+	if resp.RangeStart != "" {
+		labels = append(labels, promLabel("range_start", resp.RangeStart))
+	}
+	if resp.RangeEnd != "" {
+		labels = append(labels, promLabel("range_end", resp.RangeEnd))
+	}
+	set := "{" + strings.Join(labels, ",") + "}"
+
+	var b strings.Builder
+	for _, m := range aggregateMetrics {
+		name := promName(m.name)
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, m.help)
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", name)
+		fmt.Fprintf(&b, "%s%s %s\n", name, set, strconv.FormatFloat(m.value(resp), 'g', -1, 64))
+	}
+	return []byte(b.String())
+}
+
+// promLabel formats name="value", sanitizing the name and escaping the value
+// as the exposition format requires (backslash, double quote and newline).
+func promLabel(name, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return promName(name) + `="` + value + `"`
+}
+
+// promName replaces every character outside [a-zA-Z0-9_] with '_' and prefixes
+// a leading digit, yielding a valid metric or label name.
+func promName(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/guttosm/b3pulse/internal/domain/dto"
+	"github.com/guttosm/b3pulse/internal/domain/models"
+)
+
+// promSampleLine matches a sample of the text exposition format:
+// name{label="value",...} number.
+var promSampleLine = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*(\{[a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\\n]|\\[\\"n])*"(,[a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\\n]|\\[\\"n])*")*\})? [-+0-9.eE]+$`)
+
+// assertValidExposition fails unless every line of body is a HELP/TYPE comment
+// or a well-formed sample.
+func assertValidExposition(t *testing.T, body string) {
+	t.Helper()
+	if !strings.HasSuffix(body, "\n") {
+		t.Fatalf("exposition must end with a newline: %q", body)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		if strings.HasPrefix(line, "# HELP ") || strings.HasPrefix(line, "# TYPE ") {
+			continue
+		}
+		if !promSampleLine.MatchString(line) {
+			t.Fatalf("invalid exposition line %q", line)
+		}
+	}
+}
+
+func TestRenderAggregatePrometheus(t *testing.T) {
+	body := string(renderAggregatePrometheus(dto.AggregateResponse{
+		Ticker: "PETR4", MaxRangeValue: 12.5, MaxDailyVolume: 150000, MinDailyVolume: 32000, TradeCount: 4210,
+		RangeStart: "2025-09-12", RangeEnd: "2025-09-18",
+	}))
+	assertValidExposition(t, body)
+
+	for _, want := range []string{
+		"# TYPE b3pulse_max_price gauge\n",
+		`b3pulse_max_price{ticker="PETR4",range_start="2025-09-12",range_end="2025-09-18"} 12.5` + "\n",
+		`b3pulse_max_daily_volume{ticker="PETR4",range_start="2025-09-12",range_end="2025-09-18"} 150000` + "\n",
+		`b3pulse_min_daily_volume{ticker="PETR4",range_start="2025-09-12",range_end="2025-09-18"} 32000` + "\n",
+		`b3pulse_trade_count{ticker="PETR4",range_start="2025-09-12",range_end="2025-09-18"} 4210` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("missing %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestRenderAggregatePrometheus_EscapesLabelValues(t *testing.T) {
+	body := string(renderAggregatePrometheus(dto.AggregateResponse{Ticker: "A\"B\\C\nD"}))
+	assertValidExposition(t, body)
+	if want := `b3pulse_max_price{ticker="A\"B\\C\nD"} 0`; !strings.Contains(body, want) {
+		t.Fatalf("missing %q in:\n%s", want, body)
+	}
+}
+
+func TestPromName(t *testing.T) {
+	cases := map[string]string{
+		"b3pulse_max_price": "b3pulse_max_price",
+		"range-start":       "range_start",
+		"9lives":            "_9lives",
+		"a.b c":             "a_b_c",
+	}
+	for in, want := range cases {
+		if got := promName(in); got != want {
+			t.Fatalf("promName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestGetAggregate_FormatPrometheus(t *testing.T) {
+	svc := &mockAggService{resp: &models.Aggregate{Ticker: "PETR4", MaxRangeValue: 12.5, MaxDailyVolume: 100, TradeCount: 3}}
+	cases := []struct {
+		name  string
+		query string
+		code  int
+		ctype string
+	}{
+		{name: "prometheus", query: "ticker=PETR4&format=prometheus", code: http.StatusOK, ctype: prometheusContentType},
+		{name: "json by default", query: "ticker=PETR4", code: http.StatusOK, ctype: "application/json; charset=utf-8"},
+		{name: "explicit json", query: "ticker=PETR4&format=json", code: http.StatusOK, ctype: "application/json; charset=utf-8"},
+		{name: "unknown format", query: "ticker=PETR4&format=xml", code: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := setupRouterWithMock(svc)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate?"+tc.query, nil))
+			if w.Code != tc.code {
+				t.Fatalf("expected %d, got %d: %s", tc.code, w.Code, w.Body.String())
+			}
+			if tc.ctype == "" {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != tc.ctype {
+				t.Fatalf("Content-Type: want %q got %q", tc.ctype, got)
+			}
+			if tc.ctype == prometheusContentType {
+				assertValidExposition(t, w.Body.String())
+				if !strings.Contains(w.Body.String(), `b3pulse_max_price{ticker="PETR4"`) {
+					t.Fatalf("unexpected body:\n%s", w.Body.String())
+				}
+			}
+		})
+	}
+}
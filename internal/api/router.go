@@ -2,58 +2,178 @@ package api
 
 import (
 	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/config"
+	"github.com/guttosm/b3pulse/internal/domain/dto"
+	"github.com/guttosm/b3pulse/internal/logger"
 	"github.com/guttosm/b3pulse/internal/middleware"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+// exportTradesPath and streamTradesPath are exempt from the request timeout.
+const (
+	exportTradesPath = "/api/v1/trades/export"
+	streamTradesPath = "/api/v1/trades/stream"
+)
+
+// defaultRequestTimeout applies when REQUEST_TIMEOUT is not configured.
+const defaultRequestTimeout = 10 * time.Second
+
+// requestTimeout is read per request so SetRequestTimeout takes effect without a restart.
+var requestTimeout atomic.Int64
+
+func init() { requestTimeout.Store(int64(defaultRequestTimeout)) }
+
+// SetRequestTimeout changes the deadline applied to API requests; it is safe
+// to call while serving. Non-positive values are ignored.
+func SetRequestTimeout(d time.Duration) {
+	if d > 0 {
+		requestTimeout.Store(int64(d))
+	}
+}
+
 // NewRouter creates a Gin engine with routes configured.
 // It receives a Handler instance with all business logic already injected.
 //
 // Responsibilities:
-//   - Registers global middlewares (RequestID, Logger, Recovery, RateLimiter).
-//   - Adds request timeout handling (10 seconds).
+//   - Registers global middlewares (RequestID, latency stats, Logger, Recovery, RateLimiter).
+//   - Trusts X-Forwarded-For only from TRUSTED_PROXIES (none by default), so ClientIP() is accurate.
+//   - Adds request timeout handling (REQUEST_TIMEOUT, default 10 seconds), except for the streaming CSV/NDJSON exports.
 //   - Mounts Swagger docs (/swagger/*any).
-//   - Configures API v1 routes (/api/v1).
+//   - Configures API v1 routes (/api/v1), requiring HMAC-signed requests when
+//     SIGNING_SECRETS is set (see middleware.SignatureAuth) and, with
+//     MAX_CONCURRENT_REQUESTS, shedding load beyond that many in-flight requests
+//     (see middleware.ConcurrencyLimit).
+//   - Mounts admin routes (/admin), guarded by ADMIN_API_KEY when set.
+//   - Answers unknown paths with a 404 ErrorResponse (code NOT_FOUND), and known
+//     paths called with the wrong method with 405 (code METHOD_NOT_ALLOWED) plus
+//     an Allow header, instead of Gin's plain-text 404.
+//   - With ENABLE_PPROF, mounts net/http/pprof under /debug/pprof behind the same
+//     admin key (config validation requires one); CPU profiles and traces are
+//     exempt from the request timeout but still bounded by SERVER_WRITE_TIMEOUT.
 //
 // Note:
 //   - Health and readiness endpoints (/healthz, /readyz) are registered in app.InitializeApp().
+//   - With API_ENVELOPE=true, JSON responses are wrapped in dto.Envelope; the probes,
+//     the JSON Schema and the CSV/NDJSON exports keep their raw bodies.
+//
+// Options:
+//   - Without options the router is configured from config.AppConfig, as with
+//     ConfigOptions(config.AppConfig) plus the default rate limiter; opts are
+//     applied on top, in order.
+//   - The global chain is fixed up to the body limit: RequestID, ContextLogger,
+//     DebugLogging, latency stats, RequestLogger, Recovery, ErrorHandler, the
+//     rate limiter and BodyLimit. Those cannot be reordered or removed (only the
+//     rate limiter can be replaced or disabled, and BodyLimit turned off with
+//     0); WithMiddleware adds to the chain after them, in the order given.
 //
 // Parameters:
 //   - handler (*Handler): The HTTP handler with business logic.
+//   - opts (...RouterOption): Overrides of the configured middlewares.
 //
 // Returns:
 //   - *gin.Engine: Configured Gin router.
-func NewRouter(handler *Handler) *gin.Engine {
+func NewRouter(handler *Handler, opts ...RouterOption) *gin.Engine {
+	var o routerOptions
+	for _, opt := range append(ConfigOptions(config.AppConfig), opts...) {
+		opt(&o)
+	}
+	if !o.rateLimiterSet {
+		o.rateLimiter = middleware.RateLimiter()
+	}
+
 	router := gin.New()
+	router.HandleMethodNotAllowed = true
+	stats := middleware.NewLatencyStats()
+
+	// ─── Client IP ────────────────────────────────
+	// Only trust X-Forwarded-For from configured proxies; with none configured
+	// (nil), ClientIP() is always the TCP peer address.
+	if err := router.SetTrustedProxies(o.trustedProxies); err != nil {
+		logger.L().Error().Err(err).Msg("invalid trusted proxies, trusting none")
+		_ = router.SetTrustedProxies(nil)
+	}
 
 	// ─── Middlewares ───────────────────────────────
 	router.Use(
 		middleware.RequestID(),
+		middleware.ContextLogger(),
+		middleware.DebugLogging(o.debugHeader),
+		stats.Middleware(),
 		middleware.RequestLogger(),
 		middleware.RecoveryMiddleware(),
 		middleware.ErrorHandler,
-		middleware.RateLimiter(),
 	)
+	if o.rateLimiter != nil {
+		router.Use(o.rateLimiter)
+	}
+	router.Use(middleware.BodyLimit(o.bodyLimit))
+	if len(o.middlewares) > 0 {
+		router.Use(o.middlewares...)
+	}
 
 	// ─── Timeout ──────────────────────────────────
+	SetRequestTimeout(config.AppConfig.Server.RequestTimeout)
+	// The CSV/NDJSON exports stream for as long as the client keeps reading, and pprof
+	// CPU profiles/traces sample for ?seconds=N; both are still bounded by
+	// request cancellation when the client disconnects.
 	router.Use(func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		if p := c.FullPath(); p == exportTradesPath || p == streamTradesPath || strings.HasPrefix(p, pprofPath+"/") {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(requestTimeout.Load()))
 		defer cancel()
 		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	})
 
+	// ─── Unmatched requests ───────────────────────
+	router.NoRoute(func(c *gin.Context) {
+		middleware.RespondErrorCode(c, http.StatusNotFound, dto.CodeNotFound, "route not found", nil)
+	})
+	router.NoMethod(func(c *gin.Context) {
+		middleware.RespondErrorCode(c, http.StatusMethodNotAllowed, dto.CodeMethodNotAllowed, "method not allowed", nil)
+	})
+
 	// ─── Swagger ──────────────────────────────────
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// ─── API v1 ───────────────────────────────────
-	v1 := router.Group("/api/v1")
+	v1Chain := append(append([]gin.HandlerFunc{}, o.v1Auth...), middleware.ConcurrencyLimit(o.maxConcurrent, o.concurrentWait))
+	v1 := router.Group("/api/v1", v1Chain...)
 	{
 		v1.GET("/aggregate", handler.GetAggregate)
+		v1.GET("/aggregate/daily", handler.GetDailyAggregate)
+		v1.GET("/aggregate/by-session", handler.GetAggregateBySession)
+		v1.GET("/aggregate/schema", handler.GetAggregateSchema)
+		v1.GET("/calendar", handler.GetCalendar)
+		v1.GET("/compare", handler.Compare)
+		v1.GET("/participant", handler.GetParticipantActivity)
+		v1.GET("/cross", handler.GetCrossTrades)
+		v1.GET("/spread", handler.GetSpread)
+		v1.GET("/notional", handler.GetNotional)
+		v1.GET("/sma", handler.GetSMA)
+		v1.GET("/latest", handler.GetLatestPrice)
+		v1.GET("/freshness", handler.GetFreshness)
+		v1.GET("/gaps", handler.GetGaps)
+		v1.GET("/trades/export", handler.ExportTrades)
+		v1.GET("/trades/stream", handler.StreamTradesNDJSON)
+	}
+
+	// ─── Admin ────────────────────────────────────
+	admin := router.Group("/admin", middleware.AdminAuth(o.adminKey))
+	NewAdminHandler(stats).Register(admin)
+
+	// ─── Profiling (opt-in) ───────────────────────
+	if o.pprof {
+		registerPprof(router.Group(pprofPath, middleware.AdminAuth(o.adminKey)))
 	}
 
 	return router
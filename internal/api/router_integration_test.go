@@ -4,6 +4,7 @@
 package api
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
 	"encoding/json"
@@ -22,6 +23,7 @@ import (
 
 	"github.com/guttosm/b3pulse/config"
 	"github.com/guttosm/b3pulse/internal/app"
+	"github.com/guttosm/b3pulse/internal/domain/dto"
 )
 
 func startPG(t *testing.T) (dsn string, host string, port nat.Port, terminate func()) {
@@ -137,3 +139,60 @@ func TestAPI_E2E_Aggregate_WithStartDate(t *testing.T) {
 		t.Fatalf("unexpected body: %+v", body)
 	}
 }
+
+func TestAPI_E2E_TradesStream(t *testing.T) {
+	dsn, host, port, term := startPG(t)
+	defer term()
+	db := openAndMigrate(t, dsn)
+	defer db.Close()
+
+	day := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+	seedForE2E(t, db, day)
+
+	config.AppConfig.Postgres.Host = host
+	p, _ := nat.ParsePort(port.Port())
+	config.AppConfig.Postgres.Port = int(p)
+	config.AppConfig.Postgres.User = "postgres"
+	config.AppConfig.Postgres.Password = "postgres"
+	config.AppConfig.Postgres.DBName = "b3pulse"
+	config.AppConfig.Postgres.SSLMode = "disable"
+
+	router, cleanup, err := app.InitializeApp()
+	if err != nil {
+		t.Fatalf("init app: %v", err)
+	}
+	defer cleanup()
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/v1/trades/stream?ticker=E2E4&data=" + day.Format("2006-01-02"))
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("unexpected status=%d content-type=%q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	// Read the stream line by line, as an incremental consumer would.
+	var total int64
+	lines := 0
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		var tl dto.TradeLine
+		if err := json.Unmarshal(sc.Bytes(), &tl); err != nil {
+			t.Fatalf("line %d: %v", lines+1, err)
+		}
+		if tl.InstrumentCode != "E2E4" || tl.TradeDate != "2025-09-18" || tl.TradeQuantity == nil {
+			t.Fatalf("unexpected line: %+v", tl)
+		}
+		total += *tl.TradeQuantity
+		lines++
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if lines != 2 || total != 100 {
+		t.Fatalf("got %d lines totalling %d, want 2 lines totalling 100", lines, total)
+	}
+}
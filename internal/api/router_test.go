@@ -5,24 +5,92 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/config"
+	"github.com/guttosm/b3pulse/internal/domain/dto"
 	"github.com/guttosm/b3pulse/internal/domain/models"
+	"github.com/guttosm/b3pulse/internal/middleware"
 	"github.com/guttosm/b3pulse/internal/service"
+	"github.com/guttosm/b3pulse/internal/storage"
 )
 
 // mockAggService implements service.AggregateService for testing router wiring
 type mockAggServiceRouter struct {
-	resp *models.Aggregate
-	err  error
+	resp        *models.Aggregate
+	err         error
+	hasDeadline bool
 }
 
-func (m *mockAggServiceRouter) GetAggregate(_ context.Context, _ string, _ *time.Time, _ *time.Time) (*models.Aggregate, error) {
+func (m *mockAggServiceRouter) GetAggregate(_ context.Context, _ string, _ *time.Time, _ *time.Time, _ storage.AggregateOptions) (*models.Aggregate, error) {
 	return m.resp, m.err
 }
 
+func (m *mockAggServiceRouter) GetDailyAggregate(_ context.Context, _ string, _ time.Time) (*models.DailyAggregate, error) {
+	return nil, m.err
+}
+
+func (m *mockAggServiceRouter) GetAggregateBySession(_ context.Context, _ string, _ *time.Time, _ *time.Time) (map[string]*models.Aggregate, error) {
+	return nil, m.err
+}
+
+func (m *mockAggServiceRouter) Compare(_ context.Context, _, _ string, _ *time.Time, _ *time.Time) (*models.Comparison, error) {
+	return nil, m.err
+}
+
+func (m *mockAggServiceRouter) GetParticipantActivity(_ context.Context, _ string, _ *time.Time, _ *time.Time) (*models.ParticipantSummary, error) {
+	return nil, m.err
+}
+
+func (m *mockAggServiceRouter) GetLatestIngestion(_ context.Context) (*models.IngestionLogEntry, error) {
+	return nil, m.err
+}
+
+func (m *mockAggServiceRouter) StreamTrades(ctx context.Context, _ string, _ time.Time, _ func(models.Trade) error) error {
+	_, m.hasDeadline = ctx.Deadline()
+	return m.err
+}
+
+func (m *mockAggServiceRouter) GetCalendar(_ context.Context, _ string, _ time.Time, _ time.Time) ([]models.DailyAggregate, error) {
+	return nil, m.err
+}
+
+func (m *mockAggServiceRouter) GetSMA(_ context.Context, _ string, _ time.Time, _ time.Time, _ int) ([]models.MovingAveragePoint, error) {
+	return nil, m.err
+}
+
+func (m *mockAggServiceRouter) FindMissingIngestionDates(_ context.Context, _ time.Time, _ time.Time) ([]time.Time, error) {
+	return nil, m.err
+}
+
+func (m *mockAggServiceRouter) GetPriceSpread(_ context.Context, _ string, _ *time.Time, _ *time.Time) (*models.PriceSpread, error) {
+	return nil, m.err
+}
+
+func (m *mockAggServiceRouter) GetNotional(_ context.Context, _ string, _ *time.Time, _ *time.Time) (*models.Notional, error) {
+	return nil, m.err
+}
+
+func (m *mockAggServiceRouter) GetInstrument(_ context.Context, _ string) (*models.Instrument, error) {
+	return nil, m.err
+}
+
+func (m *mockAggServiceRouter) GetLatestPrice(_ context.Context, _ string) (*models.LatestPrice, error) {
+	return nil, m.err
+}
+
+func (m *mockAggServiceRouter) GetCrossTrades(_ context.Context, _ string, _ string, _ *time.Time, _ *time.Time, _ int, _ int) (*models.CrossSummary, error) {
+	return nil, m.err
+}
+
+func (m *mockAggServiceRouter) ExplainAggregate(_ context.Context, _ string, _ *time.Time, _ *time.Time, _ storage.AggregateOptions) (json.RawMessage, error) {
+	return nil, m.err
+}
+
 var _ service.AggregateService = (*mockAggServiceRouter)(nil)
 
 func TestNewRouter_WiringAndMiddlewares(t *testing.T) {
@@ -56,3 +124,279 @@ func TestNewRouter_WiringAndMiddlewares(t *testing.T) {
 		t.Fatalf("unexpected body: %+v", out)
 	}
 }
+
+func TestNewRouter_UnmatchedRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := NewRouter(NewHandler(&mockAggServiceRouter{}))
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		code   string
+		allow  string
+	}{
+		{name: "wrong method", method: http.MethodPost, path: "/api/v1/aggregate?ticker=PETR4", status: http.StatusMethodNotAllowed, code: dto.CodeMethodNotAllowed, allow: "GET"},
+		{name: "unknown path", method: http.MethodGet, path: "/api/v1/nope", status: http.StatusNotFound, code: dto.CodeNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, w.Code)
+			}
+			var out dto.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || out.Code != tc.code || out.Message == "" {
+				t.Fatalf("expected ErrorResponse with code %s, got %s (err=%v)", tc.code, w.Body.String(), err)
+			}
+			if got := w.Header().Get("Allow"); got != tc.allow {
+				t.Fatalf("Allow=%q, want %q", got, tc.allow)
+			}
+			if w.Header().Get("X-Request-ID") == "" {
+				t.Fatalf("expected global middlewares to run")
+			}
+		})
+	}
+}
+
+func TestNewRouter_AdminStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = prev })
+	config.AppConfig.Admin.APIKey = "secret"
+
+	svc := &mockAggServiceRouter{resp: &models.Aggregate{Ticker: "PETR4"}}
+	r := NewRouter(NewHandler(svc))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/aggregate?ticker=PETR4&data_inicio=2025-09-01", nil))
+
+	// Without key
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/stats", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without admin key, got %d", w.Code)
+	}
+
+	// With key
+	req := httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
+	req.Header.Set(middleware.AdminKeyHeader, "secret")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var out struct {
+		Routes []middleware.RouteStats `json:"routes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	var found bool
+	for _, rs := range out.Routes {
+		if rs.Route == "GET /api/v1/aggregate" && rs.Count == 1 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("aggregate route missing from stats: %+v", out.Routes)
+	}
+}
+
+func TestNewRouter_Pprof(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = prev })
+	config.AppConfig.Admin.APIKey = "secret"
+
+	get := func(r *gin.Engine, path string, withKey bool) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if withKey {
+			req.Header.Set(middleware.AdminKeyHeader, "secret")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	r := NewRouter(NewHandler(&mockAggServiceRouter{}))
+	if code := get(r, "/debug/pprof/heap", true); code != http.StatusNotFound {
+		t.Fatalf("pprof must not be mounted by default, got %d", code)
+	}
+
+	config.AppConfig.Debug.Pprof = true
+	r = NewRouter(NewHandler(&mockAggServiceRouter{}))
+	if code := get(r, "/debug/pprof/goroutine", false); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without admin key, got %d", code)
+	}
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/goroutine?debug=1", "/debug/pprof/mutex", "/debug/pprof/profile?seconds=1"} {
+		if code := get(r, path, true); code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, code)
+		}
+	}
+}
+
+func TestNewRouter_BodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = prev })
+	config.AppConfig.Admin.APIKey = "secret"
+	config.AppConfig.Debug.Pprof = true // POST /debug/pprof/symbol reads its body
+	config.AppConfig.Server.MaxBodyBytes = 32
+	r := NewRouter(NewHandler(&mockAggServiceRouter{}))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/debug/pprof/symbol", strings.NewReader(body))
+		req.Header.Set(middleware.AdminKeyHeader, "secret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post("0x1"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 within the limit, got %d", w.Code)
+	}
+	w := post(strings.Repeat("0x1+", 64))
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), dto.CodeBodyTooLarge) {
+		t.Fatalf("expected 413 %s, got %d: %s", dto.CodeBodyTooLarge, w.Code, w.Body.String())
+	}
+}
+
+func TestNewRouter_Signing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = prev })
+
+	get := func(r *gin.Engine, sign bool) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/freshness", nil)
+		if sign {
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			req.Header.Set(middleware.SignatureKeyIDHeader, "partner")
+			req.Header.Set(middleware.SignatureTimestampHeader, ts)
+			req.Header.Set(middleware.SignatureHeader, middleware.Sign("s3cret", http.MethodGet, "/api/v1/freshness", "", ts))
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := get(NewRouter(NewHandler(&mockAggServiceRouter{})), false); code != http.StatusOK {
+		t.Fatalf("signing must be off by default, got %d", code)
+	}
+
+	config.AppConfig.Signing = config.SigningConfig{Secrets: map[string]string{"partner": "s3cret"}, MaxSkew: time.Minute}
+	r := NewRouter(NewHandler(&mockAggServiceRouter{}))
+	if code := get(r, false); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for unsigned request, got %d", code)
+	}
+	if code := get(r, true); code != http.StatusOK {
+		t.Fatalf("expected 200 for signed request, got %d", code)
+	}
+}
+
+func TestNewRouter_TrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = prev })
+
+	cases := []struct {
+		name    string
+		proxies []string
+		want    string
+	}{
+		{name: "none configured ignores X-Forwarded-For", proxies: nil, want: "192.0.2.1"},
+		{name: "trusted proxy forwards client IP", proxies: []string{"192.0.2.0/24"}, want: "203.0.113.7"},
+		{name: "untrusted peer ignores X-Forwarded-For", proxies: []string{"10.0.0.1"}, want: "192.0.2.1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config.AppConfig.Server.TrustedProxies = tc.proxies
+			r := NewRouter(NewHandler(&mockAggServiceRouter{}))
+			r.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+
+			req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+			req.RemoteAddr = "192.0.2.1:4321"
+			req.Header.Set("X-Forwarded-For", "203.0.113.7")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if got := w.Body.String(); got != tc.want {
+				t.Fatalf("ClientIP=%q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewRouter_ExportSkipsTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, path := range []string{"/api/v1/trades/export", "/api/v1/trades/stream"} {
+		t.Run(path, func(t *testing.T) {
+			svc := &mockAggServiceRouter{}
+			r := NewRouter(NewHandler(svc))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path+"?data=2025-09-18", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", w.Code)
+			}
+			if svc.hasDeadline {
+				t.Fatalf("export must not run under the request timeout")
+			}
+		})
+	}
+}
+
+func TestNewRouter_Options(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = prev })
+	// Signing is on in the config; WithAuth replaces it below.
+	config.AppConfig.Signing = config.SigningConfig{Secrets: map[string]string{"partner": "s3cret"}, MaxSkew: time.Minute}
+
+	get := func(r *gin.Engine, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	reject := func(code int) gin.HandlerFunc {
+		return func(c *gin.Context) { c.AbortWithStatus(code) }
+	}
+	tag := func(name string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			if c.GetString(middleware.RequestIDKey) == "" {
+				t.Errorf("%s ran before the built-in middlewares", name)
+			}
+			c.Header("X-Order", c.Writer.Header().Get("X-Order")+name)
+			c.Next()
+		}
+	}
+
+	// The configured options leave the rate limiter to NewRouter, which builds
+	// the default (and starts its sweeper) only without an override.
+	var o routerOptions
+	for _, opt := range ConfigOptions(config.AppConfig) {
+		opt(&o)
+	}
+	if o.rateLimiter != nil || o.rateLimiterSet {
+		t.Fatal("ConfigOptions must not build a rate limiter")
+	}
+
+	// Custom middlewares run in order; an empty WithAuth opens the API.
+	r := NewRouter(NewHandler(&mockAggServiceRouter{}), WithAuth(), WithMiddleware(tag("a")), WithMiddleware(tag("b")))
+	w := get(r, "/api/v1/freshness")
+	if w.Code != http.StatusOK || w.Header().Get("X-Order") != "ab" {
+		t.Fatalf("expected 200 with X-Order=ab, got %d %q", w.Code, w.Header().Get("X-Order"))
+	}
+
+	// The rate limiter is replaceable, and WithAuth only guards /api/v1.
+	r = NewRouter(NewHandler(&mockAggServiceRouter{}), WithRateLimiter(reject(http.StatusTooManyRequests)))
+	if code := get(r, "/api/v1/freshness").Code; code != http.StatusTooManyRequests {
+		t.Fatalf("expected custom rate limiter to answer 429, got %d", code)
+	}
+	r = NewRouter(NewHandler(&mockAggServiceRouter{}), WithRateLimiter(nil), WithAuth(reject(http.StatusForbidden)))
+	if code := get(r, "/api/v1/freshness").Code; code != http.StatusForbidden {
+		t.Fatalf("expected custom auth to answer 403, got %d", code)
+	}
+	if code := get(r, "/admin/stats").Code; code != http.StatusOK {
+		t.Fatalf("expected /admin to skip the API auth, got %d", code)
+	}
+}
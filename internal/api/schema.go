@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/internal/domain/dto"
+)
+
+// jsonSchemaDraft is the JSON Schema dialect emitted by jsonSchemaFor.
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// aggregateSchema is the JSON Schema of dto.AggregateResponse, built once on first use.
+var aggregateSchema = sync.OnceValue(func() []byte {
+	doc := jsonSchemaFor(reflect.TypeOf(dto.AggregateResponse{}))
+	doc["$schema"] = jsonSchemaDraft
+	doc["title"] = "AggregateResponse"
+	b, err := json.Marshal(doc)
+	if err != nil {
+		panic(err) // only maps, slices and strings: cannot fail
+	}
+	return b
+})
+
+// GetAggregateSchema handles GET /api/v1/aggregate/schema requests.
+//
+// The schema is derived from the json tags of dto.AggregateResponse, so new
+// fields show up automatically. Fields without omitempty are required.
+//
+// GetAggregateSchema godoc
+// @Summary      Aggregate response schema
+// @Description  Returns a JSON Schema (draft 2020-12) describing the /api/v1/aggregate response
+// @Tags         aggregate
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}  "JSON Schema"
+// @Router       /api/v1/aggregate/schema [get]
+func (h *Handler) GetAggregateSchema(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(http.StatusOK, "application/schema+json", aggregateSchema())
+}
+
+// jsonSchemaFor describes t as a JSON Schema object using encoding/json rules:
+// exported fields named by their json tag, "-" skipped, omitempty optional and
+// pointers nullable. Only the kinds used by the response DTOs are mapped;
+// json.RawMessage accepts any JSON value.
+func jsonSchemaFor(t reflect.Type) map[string]any {
+	if t == reflect.TypeOf(json.RawMessage(nil)) {
+		return map[string]any{}
+	}
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t, nullable = t.Elem(), true
+	}
+
+	var s map[string]any
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]any{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" && opts == "" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = jsonSchemaFor(f.Type)
+			if !strings.Contains(","+opts+",", ",omitempty,") {
+				required = append(required, name)
+			}
+		}
+		s = map[string]any{"type": "object", "properties": props, "required": required, "additionalProperties": false}
+	case reflect.Slice, reflect.Array:
+		s = map[string]any{"type": "array", "items": jsonSchemaFor(t.Elem())}
+	case reflect.Map:
+		s = map[string]any{"type": "object", "additionalProperties": jsonSchemaFor(t.Elem())}
+	case reflect.String:
+		s = map[string]any{"type": "string"}
+	case reflect.Bool:
+		s = map[string]any{"type": "boolean"}
+	case reflect.Float32, reflect.Float64:
+		s = map[string]any{"type": "number"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = map[string]any{"type": "integer"}
+	default:
+		s = map[string]any{}
+	}
+
+	if nullable {
+		if typ, ok := s["type"].(string); ok {
+			s["type"] = []string{typ, "null"}
+		}
+	}
+	return s
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestJSONSchemaFor(t *testing.T) {
+	type inner struct {
+		N int `json:"n"`
+	}
+	type sample struct {
+		Name     string           `json:"name"`
+		Price    *float64         `json:"price"`
+		Tags     []string         `json:"tags,omitempty"`
+		Inner    inner            `json:"inner"`
+		Counts   map[string]int64 `json:"counts,omitempty"`
+		Skipped  string           `json:"-"`
+		Untagged bool
+		Raw      map[string]string `json:"raw,omitempty"`
+	}
+
+	s := jsonSchemaFor(reflect.TypeOf(sample{}))
+	props := s["properties"].(map[string]any)
+	var names []string
+	for k := range props {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	if want := []string{"Untagged", "counts", "inner", "name", "price", "raw", "tags"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("properties=%v, want %v", names, want)
+	}
+	if got := s["required"].([]string); !reflect.DeepEqual(got, []string{"name", "price", "inner", "Untagged"}) {
+		t.Fatalf("required=%v", got)
+	}
+	if got := props["price"].(map[string]any)["type"]; !reflect.DeepEqual(got, []string{"number", "null"}) {
+		t.Fatalf("pointer should be nullable number, got %v", got)
+	}
+	if got := props["tags"].(map[string]any)["items"].(map[string]any)["type"]; got != "string" {
+		t.Fatalf("tags items type=%v", got)
+	}
+	if got := props["inner"].(map[string]any)["properties"].(map[string]any)["n"].(map[string]any)["type"]; got != "integer" {
+		t.Fatalf("nested int type=%v", got)
+	}
+}
+
+func TestGetAggregateSchema(t *testing.T) {
+	r := setupRouterWithMock(&mockAggService{})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/aggregate/schema", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("want 200 got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/schema+json" {
+		t.Fatalf("Content-Type=%q", ct)
+	}
+
+	var doc struct {
+		Schema     string                       `json:"$schema"`
+		Title      string                       `json:"title"`
+		Type       string                       `json:"type"`
+		Properties map[string]map[string]string `json:"properties"`
+		Required   []string                     `json:"required"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if doc.Schema != jsonSchemaDraft || doc.Title != "AggregateResponse" || doc.Type != "object" {
+		t.Fatalf("unexpected header: %+v", doc)
+	}
+	want := map[string]string{
+		"ticker":                 "string",
+		"max_range_value":        "number",
+		"max_daily_volume":       "integer",
+		"min_daily_volume":       "integer",
+		"trade_count":            "integer",
+		"trading_days":           "integer",
+		"has_data_outside_range": "boolean",
+		"range_start":            "string",
+		"range_end":              "string",
+		"min_qty":                "integer",
+		"date_field":             "string",
+		"name":                   "string",
+		"isin":                   "string",
+		"query_plan":             "", // any JSON value
+	}
+	for name, typ := range want {
+		if got := doc.Properties[name]["type"]; got != typ {
+			t.Fatalf("%s: type=%q, want %q", name, got, typ)
+		}
+	}
+	if len(doc.Properties) != len(want) || len(doc.Required) != len(want)-7 { // range_*, min_qty, date_field, name, isin and query_plan are optional
+		t.Fatalf("schema out of sync with dto.AggregateResponse: %+v", doc)
+	}
+}
@@ -1,11 +1,17 @@
 package app
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/guttosm/b3pulse/config"
 	"github.com/guttosm/b3pulse/internal/api"
+	"github.com/guttosm/b3pulse/internal/logger"
+	"github.com/guttosm/b3pulse/internal/middleware"
 	"github.com/guttosm/b3pulse/internal/service"
 	"github.com/guttosm/b3pulse/internal/storage"
 )
@@ -15,12 +21,14 @@ import (
 // and any error encountered during initialization.
 //
 // Responsibilities:
-//   - Connects to PostgreSQL using InitPostgres().
-//   - Initializes the repository layer (TradesRepository).
+//   - Logs the effective configuration (config.Summary, secrets redacted).
+//   - Connects to PostgreSQL using InitPostgres() and, when POSTGRES_REPLICA_URL is
+//     set, to the read replica using InitPostgresReplica().
+//   - Initializes the repository layer (TradesRepository), reading from the replica if any.
 //   - Creates the HTTP handler layer to handle requests.
-//   - Configures the Gin router with all API routes.
+//   - Configures the Gin router with all API routes and the configured rate limit.
 //   - Registers health and readiness probes.
-//   - Provides a cleanup function to close resources (e.g., DB connection).
+//   - Provides a cleanup function to release resources (rate limiter sweeper, DB connection).
 //
 // Returns:
 //   - *gin.Engine: the configured Gin HTTP router.
@@ -29,6 +37,7 @@ import (
 func InitializeApp() (*gin.Engine, func(), error) {
 	// Load global configuration
 	cfg := config.AppConfig
+	logger.L().Info().Fields(cfg.Summary()).Msg("effective configuration")
 
 	// Connect to PostgreSQL
 	// indirection for unit testing
@@ -37,8 +46,19 @@ func InitializeApp() (*gin.Engine, func(), error) {
 		return nil, nil, fmt.Errorf("failed to initialize postgres: %w", err)
 	}
 
-	// Initialize repository layer (responsible for DB access)
-	repo := storage.NewTradesRepository(db)
+	// Optional read replica for the API's queries
+	var replica *sql.DB
+	if cfg.Postgres.ReplicaURL != "" {
+		if replica, err = replicaOpener(cfg); err != nil {
+			_ = db.Close()
+			return nil, nil, fmt.Errorf("failed to initialize postgres: %w", err)
+		}
+	}
+
+	// Initialize repository layer (responsible for DB access); reads go to
+	// the replica when configured
+	repo := storage.NewReplicaRepository(db, replica,
+		storage.WithSlowQueryWarn(time.Duration(cfg.Server.SlowQueryWarnMs)*time.Millisecond))
 
 	// Initialize service layer (business logic)
 	svc := service.NewAggregateService(repo)
@@ -47,15 +67,52 @@ func InitializeApp() (*gin.Engine, func(), error) {
 	handler := api.NewHandler(svc)
 
 	// Setup Gin router with routes
+	middleware.SetRateLimit(cfg.RateLimit.Requests, cfg.RateLimit.Window)
+	middleware.SetRateLimitFailOpen(cfg.RateLimit.FailOpen)
 	router := api.NewRouter(handler)
 
 	// Register health and readiness probes
-	healthHandler := api.NewHealthHandler(db.Ping)
+	ping := db.PingContext
+	if replica != nil {
+		ping = func(ctx context.Context) error {
+			if err := db.PingContext(ctx); err != nil {
+				return err
+			}
+			return replica.PingContext(ctx)
+		}
+	}
+	healthHandler := api.NewHealthHandler(ping, config.AppConfig.Server.ReadyzTimeout)
+	if cfg.Server.ReadyRequireData {
+		healthHandler.RequireData(repo.GetLatestIngestion, cfg.Server.ReadyMaxDataAge)
+	}
+	healthHandler.
+		AddCheck(api.HealthCheck{
+			Name:     "migrations",
+			Critical: true,
+			Check:    func(ctx context.Context) error { return storage.CheckSchemaVersion(ctx, db) },
+		}).
+		AddCheck(api.HealthCheck{
+			Name:  "freshness",
+			Check: api.DataFreshnessCheck(repo.GetLatestIngestion, cfg.Server.ReadyMaxDataAge),
+		}).
+		AddCheck(api.HealthCheck{
+			Name:     "rate_limiter",
+			Critical: !cfg.RateLimit.FailOpen,
+			Check:    middleware.RateLimitStoreHealth,
+		})
 	healthHandler.Register(router)
 
 	// Cleanup resources on shutdown
 	cleanup := func() {
+		middleware.StopRateLimiterSweeper()
+		// Prepared statements go before the pools they were prepared on.
+		if c, ok := repo.(io.Closer); ok {
+			_ = c.Close()
+		}
 		_ = db.Close()
+		if replica != nil {
+			_ = replica.Close()
+		}
 	}
 
 	return router, cleanup, nil
@@ -2,9 +2,12 @@ package app
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/guttosm/b3pulse/config"
@@ -97,3 +100,57 @@ func TestInitializeApp_HappyPath(t *testing.T) {
 		t.Fatalf("unmet expectations: %v", err)
 	}
 }
+
+func TestInitializeApp_Replica(t *testing.T) {
+	old, oldPrimary, oldReplica := config.AppConfig, postgresOpener, replicaOpener
+	t.Cleanup(func() { config.AppConfig, postgresOpener, replicaOpener = old, oldPrimary, oldReplica })
+	config.AppConfig.Postgres.ReplicaURL = "postgres://reader@replica/b3pulse"
+	config.AppConfig.RateLimit = config.RateLimitConfig{Requests: 100, Window: time.Minute}
+	config.AppConfig.Server.RequestTimeout = 10 * time.Second
+
+	// mocks returns fresh primary and replica handles wired into the openers.
+	mocks := func(t *testing.T) (sqlmock.Sqlmock, sqlmock.Sqlmock) {
+		primary, pmock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock new: %v", err)
+		}
+		replica, rmock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock new: %v", err)
+		}
+		postgresOpener = func(config.Config) (*sql.DB, error) { return primary, nil }
+		replicaOpener = func(config.Config) (*sql.DB, error) { return replica, nil }
+		return pmock, rmock
+	}
+
+	t.Run("open failure", func(t *testing.T) {
+		mocks(t)
+		replicaOpener = func(config.Config) (*sql.DB, error) { return nil, errors.New("replica down") }
+		if r, cleanup, err := InitializeApp(); err == nil || r != nil || cleanup != nil {
+			t.Fatalf("expected replica error, got err=%v", err)
+		}
+	})
+
+	t.Run("reads use the replica", func(t *testing.T) {
+		pmock, rmock := mocks(t)
+		router, cleanup, err := InitializeApp()
+		if err != nil {
+			t.Fatalf("InitializeApp: %v", err)
+		}
+		defer cleanup()
+
+		rmock.ExpectQuery(regexp.QuoteMeta("SELECT file_date, filename, row_count, ingested_at")).
+			WillReturnRows(sqlmock.NewRows([]string{"file_date", "filename", "row_count", "ingested_at"}))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/freshness", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("freshness status=%d body=%s", w.Code, w.Body.String())
+		}
+		if err := rmock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("replica: %v", err)
+		}
+		if err := pmock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("primary: %v", err)
+		}
+	})
+}
@@ -1,12 +1,15 @@
 package app
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 
 	"github.com/guttosm/b3pulse/config"
-
-	_ "github.com/lib/pq" // PostgreSQL driver for database/sql
+	"github.com/guttosm/b3pulse/internal/logger"
+	"github.com/lib/pq" // PostgreSQL driver for database/sql
 )
 
 // InitPostgres initializes a PostgreSQL connection using the provided configuration.
@@ -15,8 +18,10 @@ import (
 //   - cfg (config.Config): The application configuration object containing Postgres settings.
 //
 // Behavior:
-//   - Constructs a DSN (Data Source Name) using values from cfg.Postgres.
-//   - Opens a database handle with sql.Open.
+//   - Uses cfg.Postgres.URL as the DSN (Data Source Name) when set, otherwise
+//     constructs one from the other cfg.Postgres fields.
+//   - Opens a database handle with sql.Open, or, when cfg.Postgres.InitSQL is set,
+//     through a connector that runs it on every new connection.
 //   - Immediately pings the database to validate connectivity.
 //   - Returns the live connection if successful.
 //
@@ -35,31 +40,113 @@ import (
 // sqlOpener is an indirection for unit testing; defaults to sql.Open
 var sqlOpener = sql.Open
 
+// connectorOpener builds the driver connector wrapped by initSQLConnector;
+// an indirection for unit testing.
+var connectorOpener = func(dsn string) (driver.Connector, error) { return pq.NewConnector(dsn) }
+
 func InitPostgres(cfg config.Config) (*sql.DB, error) {
-	// Construct PostgreSQL DSN from configuration
-	dsn := fmt.Sprintf(
-		"postgres://%s:%s@%s:%d/%s?sslmode=%s",
-		cfg.Postgres.User,
-		cfg.Postgres.Password,
-		cfg.Postgres.Host,
-		cfg.Postgres.Port,
-		cfg.Postgres.DBName,
-		cfg.Postgres.SSLMode,
-	)
+	// Prefer the configured DSN (it may carry extra parameters from DATABASE_URL)
+	dsn := cfg.Postgres.URL
+	if dsn == "" {
+		dsn = fmt.Sprintf(
+			"postgres://%s:%s@%s:%d/%s?sslmode=%s",
+			cfg.Postgres.User,
+			cfg.Postgres.Password,
+			cfg.Postgres.Host,
+			cfg.Postgres.Port,
+			cfg.Postgres.DBName,
+			cfg.Postgres.SSLMode,
+		)
+	}
+
+	db, err := openAndPing(dsn, cfg.Postgres.InitSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.L().Info().
+		Str("host", cfg.Postgres.Host).
+		Int("port", cfg.Postgres.Port).
+		Str("user", cfg.Postgres.User).
+		Str("db", cfg.Postgres.DBName).
+		Str("source", cfg.Postgres.Source).
+		Msg("connected to postgres")
+
+	return db, nil
+}
+
+// InitPostgresReplica opens and pings the read replica at cfg.Postgres.ReplicaURL.
+// Callers only use it when ReplicaURL is set.
+func InitPostgresReplica(cfg config.Config) (*sql.DB, error) {
+	db, err := openAndPing(cfg.Postgres.ReplicaURL, cfg.Postgres.InitSQL)
+	if err != nil {
+		return nil, fmt.Errorf("replica: %w", err)
+	}
+	logger.L().Info().Msg("connected to postgres read replica")
+	return db, nil
+}
 
+// openAndPing opens a handle for dsn and verifies connectivity, closing the
+// handle again when the ping fails. A non-empty initSQL runs on every new
+// connection of the pool (see initSQLConnector).
+func openAndPing(dsn string, initSQL string) (*sql.DB, error) {
 	// Initialize database handle (does not establish a real connection yet)
-	db, err := sqlOpener("postgres", dsn)
+	db, err := openDB(dsn, initSQL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open postgres: %w", err)
 	}
 
 	// Verify connectivity by pinging the database
 	if err := db.Ping(); err != nil {
+		_ = db.Close()
 		return nil, fmt.Errorf("failed to ping postgres: %w", err)
 	}
-
 	return db, nil
 }
 
-// postgresOpener is an indirection used by InitializeApp; overridden in tests to avoid real connections.
-var postgresOpener = InitPostgres
+// openDB opens dsn with sqlOpener, or through initSQLConnector when initSQL is set.
+func openDB(dsn string, initSQL string) (*sql.DB, error) {
+	if initSQL == "" {
+		return sqlOpener("postgres", dsn)
+	}
+	connector, err := connectorOpener(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(initSQLConnector{Connector: connector, initSQL: initSQL}), nil
+}
+
+// initSQLConnector runs initSQL (POSTGRES_INIT_SQL) once on each connection the
+// wrapped connector opens, before database/sql hands it out. A failing
+// statement closes the connection and fails the query that needed it.
+type initSQLConnector struct {
+	driver.Connector
+	initSQL string
+}
+
+// Connect opens a connection and runs the init SQL on it.
+func (c initSQLConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		_ = conn.Close()
+		return nil, errors.New("postgres driver cannot run POSTGRES_INIT_SQL")
+	}
+	// No arguments, so the driver uses the simple protocol and several
+	// semicolon-separated statements are allowed.
+	if _, err := execer.ExecContext(ctx, c.initSQL, nil); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("POSTGRES_INIT_SQL: %w", err)
+	}
+	return conn, nil
+}
+
+// postgresOpener and replicaOpener are indirections used by InitializeApp;
+// overridden in tests to avoid real connections.
+var (
+	postgresOpener = InitPostgres
+	replicaOpener  = InitPostgresReplica
+)
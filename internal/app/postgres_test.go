@@ -1,7 +1,9 @@
 package app
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"testing"
 
@@ -41,3 +43,84 @@ func TestInitPostgres_PingError(t *testing.T) {
 		t.Fatalf("expected ping error from InitPostgres")
 	}
 }
+
+// fakeConn records the statements executed on it.
+type fakeConn struct {
+	execErr error
+	execs   []string
+	closed  bool
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { c.closed = true; return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.execs = append(c.execs, query)
+	return driver.RowsAffected(0), c.execErr
+}
+
+// fakeConnector hands out conn, or fails with err.
+type fakeConnector struct {
+	conn *fakeConn
+	err  error
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.conn, nil
+}
+func (c fakeConnector) Driver() driver.Driver { return nil }
+
+func TestInitSQLConnector(t *testing.T) {
+	const initSQL = "SET search_path TO b3pulse; SET ROLE app"
+
+	t.Run("runs init SQL on connect", func(t *testing.T) {
+		conn := &fakeConn{}
+		got, err := initSQLConnector{Connector: fakeConnector{conn: conn}, initSQL: initSQL}.Connect(context.Background())
+		if err != nil || got != conn {
+			t.Fatalf("Connect: conn=%v err=%v", got, err)
+		}
+		if len(conn.execs) != 1 || conn.execs[0] != initSQL {
+			t.Fatalf("executed %q, want [%q]", conn.execs, initSQL)
+		}
+	})
+
+	t.Run("failing init SQL closes the connection", func(t *testing.T) {
+		conn := &fakeConn{execErr: errors.New("role does not exist")}
+		if _, err := (initSQLConnector{Connector: fakeConnector{conn: conn}, initSQL: initSQL}).Connect(context.Background()); err == nil {
+			t.Fatalf("expected error")
+		}
+		if !conn.closed {
+			t.Fatalf("connection left open after a failed init SQL")
+		}
+	})
+
+	t.Run("connect error", func(t *testing.T) {
+		if _, err := (initSQLConnector{Connector: fakeConnector{err: errors.New("refused")}, initSQL: initSQL}).Connect(context.Background()); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+}
+
+func TestInitPostgres_InitSQLUsesConnector(t *testing.T) {
+	oldOpen, oldConn := sqlOpener, connectorOpener
+	t.Cleanup(func() { sqlOpener, connectorOpener = oldOpen, oldConn })
+	sqlOpener = func(string, string) (*sql.DB, error) {
+		t.Fatalf("sql.Open must not be used with POSTGRES_INIT_SQL")
+		return nil, nil
+	}
+	conn := &fakeConn{}
+	connectorOpener = func(string) (driver.Connector, error) { return fakeConnector{conn: conn}, nil }
+
+	// The ping opens the first connection, which must have run the init SQL.
+	db, err := InitPostgres(config.Config{Postgres: config.PostgresConfig{URL: "postgres://u:p@h/d", InitSQL: "SET ROLE app"}})
+	if err != nil {
+		t.Fatalf("InitPostgres: %v", err)
+	}
+	_ = db.Close()
+	if len(conn.execs) == 0 || conn.execs[0] != "SET ROLE app" {
+		t.Fatalf("init SQL not run, executed %q", conn.execs)
+	}
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/guttosm/b3pulse/config"
+	"github.com/guttosm/b3pulse/internal/api"
+	"github.com/guttosm/b3pulse/internal/logger"
+	"github.com/guttosm/b3pulse/internal/middleware"
+)
+
+// configReader is an indirection for reading fresh configuration; tests can override this.
+var configReader = config.Read
+
+// ReloadConfig re-reads configuration (defaults, .env, environment) and applies
+// the subset that can change while serving, without dropping connections:
+//   - LOG_LEVEL via logger.SetLevel.
+//   - RATE_LIMIT_REQUESTS / RATE_LIMIT_WINDOW via middleware.SetRateLimit.
+//   - RATE_LIMIT_FAIL_OPEN via middleware.SetRateLimitFailOpen.
+//   - REQUEST_TIMEOUT via api.SetRequestTimeout.
+//
+// A changed SERVER_PORT or database DSN needs a restart and is ignored with a
+// warning; any other setting is ignored as well. config.AppConfig keeps its
+// startup values. If the new configuration is invalid nothing is applied.
+func ReloadConfig() error {
+	next, err := configReader()
+	if err == nil {
+		err = config.Validate(next)
+	}
+	if err != nil {
+		return fmt.Errorf("reload config: %w", err)
+	}
+
+	cur := config.AppConfig
+	if next.Server.Port != cur.Server.Port {
+		logger.L().Warn().Str("running", cur.Server.Port).Str("configured", next.Server.Port).Msg("SERVER_PORT changed; restart to apply")
+	}
+	if next.Postgres.URL != cur.Postgres.URL {
+		// The DSN carries the password, so it is not logged.
+		logger.L().Warn().Msg("database settings changed; restart to apply")
+	}
+
+	logger.SetLevel(next.Log.Level)
+	middleware.SetRateLimit(next.RateLimit.Requests, next.RateLimit.Window)
+	middleware.SetRateLimitFailOpen(next.RateLimit.FailOpen)
+	api.SetRequestTimeout(next.Server.RequestTimeout)
+
+	logger.L().Info().
+		Str("log_level", next.Log.Level).
+		Int("rate_limit_requests", next.RateLimit.Requests).
+		Dur("rate_limit_window", next.RateLimit.Window).
+		Bool("rate_limit_fail_open", next.RateLimit.FailOpen).
+		Dur("request_timeout", next.Server.RequestTimeout).
+		Msg("configuration reloaded")
+	return nil
+}
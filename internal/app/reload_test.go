@@ -0,0 +1,89 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/config"
+	"github.com/guttosm/b3pulse/internal/api"
+	"github.com/guttosm/b3pulse/internal/logger"
+	"github.com/guttosm/b3pulse/internal/middleware"
+	"github.com/rs/zerolog"
+)
+
+func TestReloadConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	oldCfg, oldReader := config.AppConfig, configReader
+	t.Cleanup(func() {
+		config.AppConfig, configReader = oldCfg, oldReader
+		logger.Init()
+		middleware.SetRateLimit(60, time.Minute)
+		api.SetRequestTimeout(10 * time.Second)
+		middleware.StopRateLimiterSweeper()
+	})
+
+	base := config.Config{
+		Server:    config.ServerConfig{Port: "8080", RequestTimeout: 10 * time.Second},
+		Postgres:  config.PostgresConfig{Host: "db", Port: 5432, User: "u", Password: "p", DBName: "b3pulse", URL: "postgres://old"},
+		RateLimit: config.RateLimitConfig{Requests: 60, Window: time.Minute},
+		Log:       config.LogConfig{Level: "info"},
+	}
+	config.AppConfig = base
+	logger.Init()
+
+	r := api.NewRouter(api.NewHandler(nil))
+	var remaining time.Duration
+	r.GET("/deadline", func(c *gin.Context) {
+		dl, _ := c.Request.Context().Deadline()
+		remaining = time.Until(dl)
+		c.Status(http.StatusOK)
+	})
+	get := func() int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/deadline", nil)
+		req.RemoteAddr = "198.51.100.9:1234"
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// An invalid configuration is rejected and nothing changes.
+	configReader = func() (config.Config, error) { return config.Config{}, errors.New("bad env") }
+	if err := ReloadConfig(); err == nil {
+		t.Fatalf("expected reload error")
+	}
+	if logger.L().GetLevel() != zerolog.InfoLevel {
+		t.Fatalf("level changed on failed reload: %v", logger.L().GetLevel())
+	}
+
+	// Hot-reloadable values are applied; port and DSN changes are ignored.
+	next := base
+	next.Server.Port = "9090"
+	next.Postgres.URL = "postgres://new"
+	next.Server.RequestTimeout = 2 * time.Second
+	next.RateLimit = config.RateLimitConfig{Requests: 1, Window: time.Hour}
+	next.Log.Level = "debug"
+	configReader = func() (config.Config, error) { return next, nil }
+	if err := ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig: %v", err)
+	}
+
+	if logger.L().GetLevel() != zerolog.DebugLevel {
+		t.Fatalf("expected debug level after reload, got %v", logger.L().GetLevel())
+	}
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("first request: code=%d", code)
+	}
+	if remaining <= 0 || remaining > 2*time.Second {
+		t.Fatalf("request timeout not applied, remaining=%v", remaining)
+	}
+	if code := get(); code != http.StatusTooManyRequests {
+		t.Fatalf("rate limit not applied, second request code=%d", code)
+	}
+	if config.AppConfig.Server.Port != "8080" || config.AppConfig.Postgres.URL != "postgres://old" {
+		t.Fatalf("startup config must be kept: %+v", config.AppConfig)
+	}
+}
@@ -15,14 +15,14 @@ type AggregateService interface {
 }
 
 type aggregateService struct {
-	repo storage.TradesRepository
+	repo storage.TradeReader
 }
 
-func NewAggregateService(repo storage.TradesRepository) AggregateService {
+func NewAggregateService(repo storage.TradeReader) AggregateService {
 	return &aggregateService{repo: repo}
 }
 
 func (s *aggregateService) GetAggregate(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time) (*models.Aggregate, error) {
 	// In the future, we might add caching, input normalization, feature flags, etc.
-	return s.repo.GetAggregateByTicker(ticker, startDate, endDate)
+	return s.repo.GetAggregateByTicker(ctx, ticker, startDate, endDate, storage.AggregateOptions{})
 }
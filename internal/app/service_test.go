@@ -2,21 +2,64 @@ package app
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/guttosm/b3pulse/internal/domain/models"
+	"github.com/guttosm/b3pulse/internal/storage"
 )
 
 type fakeRepoForService struct{}
 
-func (fakeRepoForService) InsertTradesBatch([]models.Trade) error { return nil }
-func (fakeRepoForService) GetAggregateByTicker(t string, s, e *time.Time) (*models.Aggregate, error) {
+func (fakeRepoForService) GetAggregateByTicker(_ context.Context, t string, s, e *time.Time, _ storage.AggregateOptions) (*models.Aggregate, error) {
 	return &models.Aggregate{Ticker: t, MaxRangeValue: 1.23, MaxDailyVolume: 456}, nil
 }
-func (fakeRepoForService) HasIngestionForDate(time.Time) (bool, error)     { return false, nil }
-func (fakeRepoForService) UpsertIngestionLog(time.Time, string, int) error { return nil }
-func (fakeRepoForService) DeleteTradesByDate(time.Time) error              { return nil }
+func (fakeRepoForService) GetDailyAggregate(string, time.Time) (*models.DailyAggregate, error) {
+	return nil, nil
+}
+func (fakeRepoForService) HasIngestionForDate(time.Time) (bool, error) { return false, nil }
+func (fakeRepoForService) CountTradesByDate(time.Time) (int64, error)  { return 0, nil }
+func (fakeRepoForService) GetIngestionCheckpoint(time.Time) (*models.IngestionCheckpoint, error) {
+	return nil, nil
+}
+func (fakeRepoForService) TickerExists(string) (bool, error) { return false, nil }
+func (fakeRepoForService) GetLatestIngestion() (*models.IngestionLogEntry, error) {
+	return nil, nil
+}
+func (fakeRepoForService) GetParticipantActivity(string, *time.Time, *time.Time) ([]models.ParticipantActivity, error) {
+	return nil, nil
+}
+func (fakeRepoForService) StreamTradesByDate(context.Context, string, time.Time, func(models.Trade) error) error {
+	return nil
+}
+func (fakeRepoForService) GetAggregateBySession(string, *time.Time, *time.Time) (map[string]*models.Aggregate, error) {
+	return nil, nil
+}
+func (fakeRepoForService) ExplainAggregate(context.Context, string, *time.Time, *time.Time, storage.AggregateOptions) (json.RawMessage, error) {
+	return nil, nil
+}
+func (fakeRepoForService) GetDailyAggregates(string, time.Time, time.Time) ([]models.DailyAggregate, error) {
+	return nil, nil
+}
+func (fakeRepoForService) FindMissingIngestionDates(time.Time, time.Time) ([]time.Time, error) {
+	return nil, nil
+}
+func (fakeRepoForService) GetPriceSpread(string, *time.Time, *time.Time) (*models.PriceSpread, error) {
+	return nil, nil
+}
+func (fakeRepoForService) GetNotional(string, *time.Time, *time.Time) (*models.Notional, error) {
+	return nil, nil
+}
+func (fakeRepoForService) GetInstrument(string) (*models.Instrument, error) {
+	return nil, nil
+}
+func (fakeRepoForService) GetLatestPrice(string) (*models.LatestPrice, error) {
+	return nil, nil
+}
+func (fakeRepoForService) GetCrossTrades(string, string, *time.Time, *time.Time, int, int) ([]models.CrossActivity, error) {
+	return nil, nil
+}
 
 func TestAggregateService_DelegatesToRepo(t *testing.T) {
 	svc := NewAggregateService(fakeRepoForService{})
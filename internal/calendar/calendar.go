@@ -0,0 +1,179 @@
+// Package calendar implements the Brazilian (B3) business-day calendar shared
+// by ingestion and the API: weekends, national fixed holidays, the movable
+// holidays derived from Easter, and extra closures from EXTRA_HOLIDAYS.
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// extraHolidays holds the closures configured via SetExtraHolidays.
+type extraHolidays struct {
+	yearly map[string]struct{} // "MM-DD", every year
+	dated  map[string]struct{} // "YYYY-MM-DD", that year only
+}
+
+// extra is read on every IsBusinessDay call and swapped atomically by SetExtraHolidays.
+var extra atomic.Pointer[extraHolidays]
+
+// SetExtraHolidays replaces the extra closures merged into the fixed holidays.
+// Entries are "MM-DD" (every year) or "YYYY-MM-DD" (that year only). Malformed
+// entries return an error and leave the current set unchanged; nil clears it.
+func SetExtraHolidays(entries []string) error {
+	h := &extraHolidays{yearly: map[string]struct{}{}, dated: map[string]struct{}{}}
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		switch len(e) {
+		case len("01-02"):
+			// Parse with a leap year so "02-29" is accepted.
+			if _, err := time.Parse("2006-01-02", "2024-"+e); err != nil {
+				return fmt.Errorf("extra holiday %q: expected MM-DD or YYYY-MM-DD", e)
+			}
+			h.yearly[e] = struct{}{}
+		case len("2006-01-02"):
+			if _, err := time.Parse("2006-01-02", e); err != nil {
+				return fmt.Errorf("extra holiday %q: expected MM-DD or YYYY-MM-DD", e)
+			}
+			h.dated[e] = struct{}{}
+		default:
+			return fmt.Errorf("extra holiday %q: expected MM-DD or YYYY-MM-DD", e)
+		}
+	}
+	extra.Store(h)
+	return nil
+}
+
+// LastNBusinessDays returns the last n Brazilian business days (most recent first).
+// It excludes Saturdays, Sundays, and BR national/movable holidays.
+func LastNBusinessDays(n int, from time.Time) []time.Time {
+	out := make([]time.Time, 0, n)
+	d := truncateToDate(from)
+
+	for len(out) < n {
+		if IsBusinessDay(d) {
+			out = append(out, d)
+		}
+		d = d.AddDate(0, 0, -1)
+	}
+	return out
+}
+
+// BusinessDaysBetween returns the business days from start to end, both
+// inclusive, in ascending order; it is empty when end is before start.
+func BusinessDaysBetween(start, end time.Time) []time.Time {
+	var out []time.Time
+	end = truncateToDate(end)
+	for d := truncateToDate(start); !d.After(end); d = d.AddDate(0, 0, 1) {
+		if IsBusinessDay(d) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// HasBusinessDay reports whether any day from start to end, both inclusive,
+// is a business day. It stops at the first one, so it costs a few days of
+// scanning however long the range is; it is false when end is before start.
+func HasBusinessDay(start, end time.Time) bool {
+	end = truncateToDate(end)
+	for d := truncateToDate(start); !d.After(end); d = d.AddDate(0, 0, 1) {
+		if IsBusinessDay(d) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateToDate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// LatestBusinessDay returns the most recent business day on or before from.
+func LatestBusinessDay(from time.Time) time.Time {
+	return LastNBusinessDays(1, from)[0]
+}
+
+// IsBusinessDay returns true if date is a business day in Brazil.
+func IsBusinessDay(d time.Time) bool {
+	// Weekend
+	if wd := d.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		return false
+	}
+
+	// National fixed holidays
+	fixed := map[string]struct{}{
+		"01-01": {}, // New Year
+		"04-21": {}, // Tiradentes
+		"05-01": {}, // Labor Day
+		"09-07": {}, // Independence Day
+		"10-12": {}, // Our Lady Aparecida
+		"11-02": {}, // All Souls' Day
+		"11-15": {}, // Republic Proclamation
+		"12-25": {}, // Christmas
+	}
+	key := d.Format("01-02")
+	if _, ok := fixed[key]; ok {
+		return false
+	}
+
+	// Extra closures configured via EXTRA_HOLIDAYS
+	if h := extra.Load(); h != nil {
+		if _, ok := h.yearly[key]; ok {
+			return false
+		}
+		if _, ok := h.dated[d.Format("2006-01-02")]; ok {
+			return false
+		}
+	}
+
+	// Movable holidays (computed from Easter)
+	y := d.Year()
+	easter := easterSunday(y)
+
+	// Carnival Monday & Tuesday (48/47 days before Easter Sunday)
+	carnivalMon := easter.AddDate(0, 0, -48)
+	carnivalTue := easter.AddDate(0, 0, -47)
+	// Good Friday (2 days before Easter)
+	goodFriday := easter.AddDate(0, 0, -2)
+	// Corpus Christi (60 days after Easter)
+	corpusChristi := easter.AddDate(0, 0, 60)
+
+	// Compare by calendar date: easter is built in time.Local while d may be in
+	// any location (e.g. UTC), so comparing time.Time values would miss matches.
+	movables := map[string]struct{}{
+		carnivalMon.Format("01-02"):   {},
+		carnivalTue.Format("01-02"):   {},
+		goodFriday.Format("01-02"):    {},
+		corpusChristi.Format("01-02"): {},
+	}
+	if _, ok := movables[key]; ok {
+		return false
+	}
+
+	return true
+}
+
+// easterSunday returns the date of Easter Sunday for a given year
+// (Meeus/Jones/Butcher algorithm).
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := ((h + l - 7*m + 114) % 31) + 1
+
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
+}
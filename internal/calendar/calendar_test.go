@@ -0,0 +1,150 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsBusinessDay_WeekendsAndFixed(t *testing.T) {
+	// Weekend
+	if IsBusinessDay(time.Date(2025, 9, 21, 0, 0, 0, 0, time.Local)) { // Sunday
+		t.Fatal("Sunday should not be business day")
+	}
+	// Fixed holiday 07-Sep (Independence Day)
+	if IsBusinessDay(time.Date(2025, 9, 7, 0, 0, 0, 0, time.Local)) {
+		t.Fatal("Sept 7 should not be business day")
+	}
+}
+
+func TestLastNBusinessDays_CountAndOrder(t *testing.T) {
+	from := time.Date(2025, 9, 20, 12, 30, 0, 0, time.Local) // Sat
+	days := LastNBusinessDays(5, from)
+	if len(days) != 5 {
+		t.Fatalf("want 5 got %d", len(days))
+	}
+	// Ensure strictly decreasing dates and no weekends
+	for i := 0; i < len(days); i++ {
+		if i > 0 && !days[i].Before(days[i-1]) {
+			t.Fatal("dates should be strictly decreasing")
+		}
+		wd := days[i].Weekday()
+		if wd == time.Saturday || wd == time.Sunday {
+			t.Fatal("weekend day returned")
+		}
+	}
+}
+
+func TestBusinessDaysBetween(t *testing.T) {
+	// September 2025: 22 weekdays, Sep 7 (Independence Day) falls on a Sunday.
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 9, 30, 0, 0, 0, 0, time.UTC)
+	days := BusinessDaysBetween(start, end)
+	if len(days) != 22 || !days[0].Equal(start) || !days[len(days)-1].Equal(end) {
+		t.Fatalf("unexpected September days: %d %v..%v", len(days), days[0], days[len(days)-1])
+	}
+	for i := 1; i < len(days); i++ {
+		if !days[i].After(days[i-1]) {
+			t.Fatal("dates should be strictly increasing")
+		}
+	}
+
+	// April 2025: 22 weekdays minus Good Friday (Apr 18) and Tiradentes (Apr 21).
+	apr := BusinessDaysBetween(time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC))
+	if len(apr) != 20 {
+		t.Fatalf("want 20 April business days, got %d", len(apr))
+	}
+	if got := BusinessDaysBetween(end, start); len(got) != 0 {
+		t.Fatalf("reversed range should be empty, got %v", got)
+	}
+}
+
+func TestHasBusinessDay(t *testing.T) {
+	sat := time.Date(2025, 9, 13, 0, 0, 0, 0, time.UTC)
+	sun := time.Date(2025, 9, 14, 0, 0, 0, 0, time.UTC)
+	mon := time.Date(2025, 9, 15, 0, 0, 0, 0, time.UTC)
+	if HasBusinessDay(sat, sun) {
+		t.Fatal("a weekend has no business day")
+	}
+	if !HasBusinessDay(sat, mon) || !HasBusinessDay(mon, mon) {
+		t.Fatal("Monday Sep 15 is a business day")
+	}
+	if HasBusinessDay(mon, sat) {
+		t.Fatal("reversed range should have no business day")
+	}
+
+	// Stops at the first business day, so the widest range is cheap.
+	start := time.Now()
+	if !HasBusinessDay(time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Fatal("want a business day in 0001..9999")
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("HasBusinessDay took %s on a huge range", elapsed)
+	}
+}
+
+func TestIsBusinessDay_MovableHolidaysAnyLocation(t *testing.T) {
+	// 2025: Carnival Mar 3-4, Good Friday Apr 18, Corpus Christi Jun 19.
+	for _, loc := range []*time.Location{time.UTC, time.FixedZone("BRT", -3*3600)} {
+		for _, d := range []time.Time{
+			time.Date(2025, 3, 3, 0, 0, 0, 0, loc),
+			time.Date(2025, 3, 4, 0, 0, 0, 0, loc),
+			time.Date(2025, 4, 18, 0, 0, 0, 0, loc),
+			time.Date(2025, 6, 19, 0, 0, 0, 0, loc),
+		} {
+			if IsBusinessDay(d) {
+				t.Fatalf("%s (%s) should be a holiday", d.Format("2006-01-02"), loc)
+			}
+		}
+	}
+}
+
+func TestLatestBusinessDay(t *testing.T) {
+	sat := time.Date(2025, 9, 20, 10, 0, 0, 0, time.UTC)
+	if got := LatestBusinessDay(sat); got.Format("2006-01-02") != "2025-09-19" {
+		t.Fatalf("want Friday 2025-09-19, got %s", got.Format("2006-01-02"))
+	}
+	fri := time.Date(2025, 9, 19, 23, 0, 0, 0, time.UTC)
+	if got := LatestBusinessDay(fri); got.Format("2006-01-02") != "2025-09-19" {
+		t.Fatalf("business day should return itself, got %s", got.Format("2006-01-02"))
+	}
+}
+
+func TestSetExtraHolidays(t *testing.T) {
+	t.Cleanup(func() { _ = SetExtraHolidays(nil) })
+
+	// Fri 2025-11-21 and Mon 2025-12-22 are regular business days.
+	yearly := time.Date(2025, 11, 21, 0, 0, 0, 0, time.UTC)
+	dated := time.Date(2025, 12, 22, 0, 0, 0, 0, time.UTC)
+	if !IsBusinessDay(yearly) || !IsBusinessDay(dated) {
+		t.Fatalf("precondition: expected business days before configuring extras")
+	}
+
+	if err := SetExtraHolidays([]string{"11-21", " 2025-12-22 ", "02-29"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if IsBusinessDay(yearly) || IsBusinessDay(dated) {
+		t.Fatalf("extra holidays should not be business days")
+	}
+	// MM-DD applies every year; YYYY-MM-DD only to its year.
+	if IsBusinessDay(time.Date(2024, 11, 21, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("MM-DD entry should apply to other years")
+	}
+	if !IsBusinessDay(time.Date(2026, 12, 22, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("YYYY-MM-DD entry should not apply to other years")
+	}
+	// LastNBusinessDays skips them too (Mon 2025-11-24 back to Thu 2025-11-20).
+	days := LastNBusinessDays(2, time.Date(2025, 11, 24, 12, 0, 0, 0, time.UTC))
+	if days[1].Day() != 20 {
+		t.Fatalf("expected 2025-11-21 to be skipped, got %v", days)
+	}
+
+	for _, bad := range []string{"13-01", "2025-02-30", "2025/12/22", "1-5", "tomorrow"} {
+		if err := SetExtraHolidays([]string{bad}); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+	// A failed call leaves the previous set in place.
+	if IsBusinessDay(yearly) {
+		t.Fatalf("invalid input must not replace the configured set")
+	}
+}
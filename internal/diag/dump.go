@@ -0,0 +1,67 @@
+// Package diag writes runtime diagnostics (goroutine dump, heap profile) on
+// demand, so a stuck process can be inspected without always-on pprof.
+package diag
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"time"
+)
+
+// Dump writes a goroutine dump with full stacks and a heap profile.
+//
+// With dir set, it creates goroutines-<ts>.txt and heap-<ts>.pprof there (the
+// latter in the binary format read by `go tool pprof`) and returns their paths.
+// With dir empty, both are written as text to w (typically os.Stderr) and no
+// paths are returned.
+func Dump(dir string, w io.Writer, now time.Time) ([]string, error) {
+	if dir == "" {
+		if err := pprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+			return nil, fmt.Errorf("goroutine dump: %w", err)
+		}
+		if err := pprof.Lookup("heap").WriteTo(w, 1); err != nil {
+			return nil, fmt.Errorf("heap profile: %w", err)
+		}
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create dump dir: %w", err)
+	}
+	ts := now.UTC().Format("20060102T150405.000Z")
+	var paths []string
+	for _, p := range []struct {
+		profile, file string
+		debug         int
+	}{
+		{"goroutine", "goroutines-" + ts + ".txt", 2},
+		{"heap", "heap-" + ts + ".pprof", 0},
+	} {
+		path := filepath.Join(dir, p.file)
+		if err := writeProfile(path, p.profile, p.debug); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// writeProfile writes the named runtime profile to a new file at path.
+func writeProfile(path, profile string, debug int) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("%s profile: %w", profile, err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("%s profile: %w", profile, cerr)
+		}
+	}()
+	if err := pprof.Lookup(profile).WriteTo(f, debug); err != nil {
+		return fmt.Errorf("%s profile: %w", profile, err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package diag
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDump_Dir(t *testing.T) {
+	dir := t.TempDir() + "/nested"
+	now := time.Date(2025, 9, 18, 12, 30, 0, 0, time.UTC)
+
+	paths, err := Dump(dir, nil, now)
+	if err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	if len(paths) != 2 || !strings.HasSuffix(paths[0], "goroutines-20250918T123000.000Z.txt") || !strings.HasSuffix(paths[1], "heap-20250918T123000.000Z.pprof") {
+		t.Fatalf("unexpected paths: %v", paths)
+	}
+	for _, p := range paths {
+		if fi, err := os.Stat(p); err != nil || fi.Size() == 0 {
+			t.Fatalf("%s: missing or empty (err=%v)", p, err)
+		}
+	}
+	b, _ := os.ReadFile(paths[0])
+	if !strings.Contains(string(b), "TestDump_Dir") {
+		t.Fatalf("goroutine dump should include the test's stack")
+	}
+}
+
+func TestDump_Writer(t *testing.T) {
+	var buf bytes.Buffer
+	paths, err := Dump("", &buf, time.Now())
+	if err != nil || paths != nil {
+		t.Fatalf("unexpected paths=%v err=%v", paths, err)
+	}
+	if out := buf.String(); !strings.Contains(out, "goroutine ") || !strings.Contains(out, "heap profile:") {
+		t.Fatalf("expected goroutine dump and text heap profile, got %d bytes", len(out))
+	}
+}
@@ -1,12 +1,26 @@
 package dto
 
+import "encoding/json"
+
 // AggregateResponse represents the JSON structure returned by the
 // GET /api/v1/aggregate endpoint.
 //
 // Fields match the API contract and may differ from internal domain models.
 // This ensures loose coupling between the API surface and business logic.
 type AggregateResponse struct {
-	Ticker         string  `json:"ticker" example:"PETR4"`            // Stock ticker requested
-	MaxRangeValue  float64 `json:"max_range_value" example:"20.50"`   // Maximum price observed in the period
-	MaxDailyVolume int64   `json:"max_daily_volume" example:"150000"` // Maximum daily traded volume in the period
+	Ticker              string  `json:"ticker" example:"PETR4"`                                      // Stock ticker requested
+	MaxRangeValue       float64 `json:"max_range_value" example:"20.50"`                             // Maximum price observed in the period
+	MaxDailyVolume      int64   `json:"max_daily_volume" example:"150000"`                           // Maximum daily traded volume in the period
+	MinDailyVolume      int64   `json:"min_daily_volume" example:"32000"`                            // Minimum daily traded volume among days with trades in the period
+	TradeCount          int64   `json:"trade_count" example:"4210"`                                  // Number of trades backing the figures
+	TradingDays         int     `json:"trading_days" example:"5"`                                    // Number of days with trades in the period (not calendar days)
+	HasDataOutsideRange bool    `json:"has_data_outside_range" example:"false"`                      // True when the ticker only traded outside the period (figures are zero)
+	RangeStart          string  `json:"range_start,omitempty" example:"2025-09-12"`                  // Resolved first trade date of the period (omitted when unbounded)
+	RangeEnd            string  `json:"range_end,omitempty" example:"2025-09-18"`                    // Resolved last trade date of the period (omitted when unbounded)
+	MinQty              int64   `json:"min_qty,omitempty" example:"100"`                             // Quantity floor applied to max_range_value (omitted when not requested)
+	DateField           string  `json:"date_field,omitempty" example:"trade_date"`                   // Date column the range applies to (omitted when not requested)
+	Name                string  `json:"name,omitempty" example:"PETROLEO BRASILEIRO S.A. PETROBRAS"` // Company name from the instruments reference file; only with ?enrich=true
+	ISIN                string  `json:"isin,omitempty" example:"BRPETRACNPR6"`                       // ISIN from the instruments reference file; only with ?enrich=true
+
+	QueryPlan json.RawMessage `json:"query_plan,omitempty" swaggertype:"object"` // EXPLAIN (ANALYZE, FORMAT JSON) output; only with ?explain=true and DEBUG_EXPLAIN
 }
@@ -0,0 +1,18 @@
+package dto
+
+// CalendarResponse represents the JSON structure returned by the
+// GET /api/v1/calendar endpoint: one entry per trading day of the month.
+type CalendarResponse struct {
+	Ticker string        `json:"ticker" example:"PETR4"` // Stock ticker requested
+	Year   int           `json:"year" example:"2025"`    // Requested year
+	Month  int           `json:"month" example:"9"`      // Requested month (1-12)
+	Days   []CalendarDay `json:"days"`                   // Trading days in date order; empty for a month not started yet
+}
+
+// CalendarDay is a single trading day of a CalendarResponse. Volume and
+// MaxPrice are zero on trading days without trades.
+type CalendarDay struct {
+	Date     string  `json:"date" example:"2025-09-18"` // Trade date (YYYY-MM-DD)
+	Volume   int64   `json:"volume" example:"150000"`   // Total traded volume on the day
+	MaxPrice float64 `json:"max_price" example:"20.50"` // Maximum price observed on the day
+}
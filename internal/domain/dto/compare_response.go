@@ -0,0 +1,17 @@
+package dto
+
+// CompareResponse represents the JSON structure returned by the
+// GET /api/v1/compare endpoint.
+//
+// When only one ticker has data in the period, Partial is true, the missing
+// side is null, MissingTickers names it and both ratios are null.
+type CompareResponse struct {
+	TickerA        string             `json:"ticker_a" example:"PETR4"`                  // First ticker requested
+	TickerB        string             `json:"ticker_b" example:"VALE3"`                  // Second ticker requested
+	A              *AggregateResponse `json:"a"`                                         // Aggregate for ticker_a (null when missing)
+	B              *AggregateResponse `json:"b"`                                         // Aggregate for ticker_b (null when missing)
+	MaxPriceRatio  *float64           `json:"max_price_ratio" example:"0.45"`            // a.max_range_value / b.max_range_value
+	VolumeRatio    *float64           `json:"volume_ratio" example:"1.80"`               // a.max_daily_volume / b.max_daily_volume
+	Partial        bool               `json:"partial" example:"false"`                   // True when one ticker has no data
+	MissingTickers []string           `json:"missing_tickers,omitempty" example:"VALE3"` // Tickers with no data in the period
+}
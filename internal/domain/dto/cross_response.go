@@ -0,0 +1,19 @@
+package dto
+
+// CrossActivityResponse is one per-ticker row of CrossResponse.
+type CrossActivityResponse struct {
+	Ticker     string `json:"ticker" example:"PETR4"`   // Instrument traded
+	Volume     int64  `json:"volume" example:"120000"`  // Quantity traded between the pair
+	TradeCount int64  `json:"trade_count" example:"42"` // Number of trades between the pair
+}
+
+// CrossResponse represents the JSON structure returned by the
+// GET /api/v1/cross endpoint.
+type CrossResponse struct {
+	Buyer   string                  `json:"buyer,omitempty" example:"3"`   // Buyer participant code (omitted when any)
+	Seller  string                  `json:"seller,omitempty" example:"72"` // Seller participant code (omitted when any)
+	Tickers []CrossActivityResponse `json:"tickers"`                       // Activity per ticker, busiest first
+	Limit   int                     `json:"limit" example:"50"`            // Page size applied
+	Offset  int                     `json:"offset" example:"0"`            // Tickers skipped before this page
+	HasMore bool                    `json:"has_more" example:"false"`      // True when a next page exists (offset+limit)
+}
@@ -0,0 +1,11 @@
+package dto
+
+// DailyAggregateResponse represents the JSON structure returned by the
+// GET /api/v1/aggregate/daily endpoint.
+type DailyAggregateResponse struct {
+	Ticker      string  `json:"ticker" example:"PETR4"`        // Stock ticker requested
+	Date        string  `json:"date" example:"2025-09-18"`     // Trade date (YYYY-MM-DD)
+	MaxPrice    float64 `json:"max_price" example:"20.50"`     // Maximum price observed on the day
+	TotalVolume int64   `json:"total_volume" example:"150000"` // Total traded volume on the day
+	TradeCount  int64   `json:"trade_count" example:"1234"`    // Number of trades on the day
+}
@@ -0,0 +1,13 @@
+package dto
+
+// Envelope wraps every JSON response when API_ENVELOPE is enabled.
+//
+// Exactly one of Data and Error is non-null. RequestID mirrors the
+// X-Request-ID response header.
+//
+// swagger:model Envelope
+type Envelope struct {
+	Data      any            `json:"data"`
+	Error     *ErrorResponse `json:"error"`
+	RequestID string         `json:"request_id" example:"6f1c2d3e-4b5a-4c7d-9e8f-0a1b2c3d4e5f"`
+}
@@ -7,15 +7,29 @@ import "time"
 // Fields:
 //   - message: A human-readable description of the error.
 //   - error: A more technical detail of the error (optional, omitted if empty).
+//   - code: A stable machine-readable error code (optional, omitted if empty); see the Code* constants.
 //   - timestamp: Time when the error occurred, useful for debugging and correlation.
 //
 // swagger:model ErrorResponse
 type ErrorResponse struct {
 	Message      string    `json:"message" example:"Something went wrong"`
 	ErrorDetails string    `json:"error,omitempty" example:"internal server error"`
+	Code         string    `json:"code,omitempty" example:"METHOD_NOT_ALLOWED"`
 	Timestamp    time.Time `json:"timestamp" example:"2025-08-02T15:04:05Z07:00"`
 }
 
+// Machine-readable values of ErrorResponse.Code. Clients should branch on the
+// code rather than on the message, which may change.
+const (
+	CodeNotFound         = "NOT_FOUND"                // no route matches the path
+	CodeMethodNotAllowed = "METHOD_NOT_ALLOWED"       // the path exists for other methods (see the Allow header)
+	CodeBodyTooLarge     = "BODY_TOO_LARGE"           // the request body exceeds SERVER_MAX_BODY_BYTES
+	CodeNoTradingDays    = "NO_TRADING_DAYS_IN_RANGE" // the date range holds only weekends/holidays
+	CodeServerBusy       = "SERVER_BUSY"              // too many requests in flight (MAX_CONCURRENT_REQUESTS); retry later
+	CodeRateLimited      = "RATE_LIMITED"             // the client IP exceeded RATE_LIMIT_REQUESTS in the window
+	CodeRateLimitDown    = "RATE_LIMITER_UNAVAILABLE" // the rate-limit store failed and RATE_LIMIT_FAIL_OPEN=false
+)
+
 // Error implements the error interface for ErrorResponse.
 //
 // Returns:
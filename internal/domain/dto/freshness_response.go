@@ -0,0 +1,13 @@
+package dto
+
+// FreshnessResponse represents the JSON structure returned by the
+// GET /api/v1/freshness endpoint.
+//
+// LatestDate, IngestedAt and RowCount are null when nothing was ingested yet.
+type FreshnessResponse struct {
+	LatestDate   *string `json:"latest_date" example:"2025-09-18"`           // Most recent ingested business day
+	IngestedAt   *string `json:"ingested_at" example:"2025-09-19T02:00:00Z"` // When that day was ingested (RFC 3339)
+	RowCount     *int64  `json:"row_count" example:"1234567"`                // Trades ingested for that day
+	ExpectedDate string  `json:"expected_date" example:"2025-09-18"`         // Latest business day data is expected for
+	IsCurrent    bool    `json:"is_current" example:"true"`                  // latest_date >= expected_date
+}
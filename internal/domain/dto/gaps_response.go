@@ -0,0 +1,10 @@
+package dto
+
+// GapsResponse represents the JSON structure returned by the
+// GET /api/v1/gaps endpoint.
+type GapsResponse struct {
+	RangeStart   string   `json:"range_start" example:"2025-09-01"` // First day checked
+	RangeEnd     string   `json:"range_end" example:"2025-09-30"`   // Last day checked (inclusive)
+	MissingDates []string `json:"missing_dates"`                    // Business days without an ingestion, ascending; empty when complete
+	Count        int      `json:"count" example:"1"`                // len(missing_dates)
+}
@@ -0,0 +1,19 @@
+package dto
+
+// HealthDetailResponse represents the JSON structure returned by the
+// GET /health/detail endpoint.
+//
+// Status is "up" when every check passes, "degraded" when only non-critical
+// checks fail, and "down" when a critical check fails.
+type HealthDetailResponse struct {
+	Status string        `json:"status" example:"up"` // Overall status: up, degraded or down
+	Checks []HealthCheck `json:"checks"`              // One entry per registered check, in registration order
+}
+
+// HealthCheck is the result of one dependency check in HealthDetailResponse.
+type HealthCheck struct {
+	Name      string  `json:"name" example:"database"`  // Check name
+	Status    string  `json:"status" example:"up"`      // up or down
+	Critical  bool    `json:"critical" example:"true"`  // Whether a failure makes the overall status down (503)
+	LatencyMs float64 `json:"latency_ms" example:"1.8"` // Time the check took, in milliseconds
+}
@@ -0,0 +1,11 @@
+package dto
+
+// LatestPriceResponse represents the JSON structure returned by the
+// GET /api/v1/latest endpoint.
+type LatestPriceResponse struct {
+	Ticker      string  `json:"ticker" example:"PETR4"`                    // Stock ticker requested
+	Price       float64 `json:"price" example:"20.50"`                     // Price of the most recent trade
+	Quantity    int64   `json:"quantity" example:"100"`                    // Quantity of the most recent trade
+	TradeDate   string  `json:"trade_date,omitempty" example:"2025-09-18"` // Session date of the trade, omitted when unknown
+	ClosingTime string  `json:"closing_time,omitempty" example:"17:54:59"` // Trade time (HH:MM:SS), omitted when unknown
+}
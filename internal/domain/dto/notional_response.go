@@ -0,0 +1,11 @@
+package dto
+
+// NotionalResponse represents the JSON structure returned by the
+// GET /api/v1/notional endpoint.
+type NotionalResponse struct {
+	Ticker         string  `json:"ticker" example:"PETR4"`                     // Stock ticker requested
+	NotionalTraded float64 `json:"notional_traded" example:"1523400.50"`       // Financial value traded (sum of price * quantity), in R$
+	TradeCount     int64   `json:"trade_count" example:"4210"`                 // Number of trades backing notional_traded
+	RangeStart     string  `json:"range_start,omitempty" example:"2025-09-12"` // Resolved first trade date of the period (omitted when unbounded)
+	RangeEnd       string  `json:"range_end,omitempty" example:"2025-09-18"`   // Resolved last trade date of the period (omitted when unbounded)
+}
@@ -0,0 +1,16 @@
+package dto
+
+// ParticipantActivityResponse is one per-ticker row of ParticipantResponse.
+type ParticipantActivityResponse struct {
+	Ticker     string `json:"ticker" example:"PETR4"`      // Instrument traded
+	BuyVolume  int64  `json:"buy_volume" example:"120000"` // Quantity bought by the participant
+	SellVolume int64  `json:"sell_volume" example:"95000"` // Quantity sold by the participant
+}
+
+// ParticipantResponse represents the JSON structure returned by the
+// GET /api/v1/participant endpoint.
+type ParticipantResponse struct {
+	Code      string                        `json:"code" example:"3"`          // Participant code requested
+	Tickers   []ParticipantActivityResponse `json:"tickers"`                   // Activity per ticker, busiest first
+	Truncated bool                          `json:"truncated" example:"false"` // True when results were capped
+}
@@ -0,0 +1,13 @@
+package dto
+
+// SessionAggregateResponse represents the JSON structure returned by the
+// GET /api/v1/aggregate/by-session endpoint.
+//
+// Sessions is keyed by session type (TipoSessaoPregao) and is empty, not
+// absent, when the ticker has no trades in the period.
+type SessionAggregateResponse struct {
+	Ticker     string                        `json:"ticker" example:"PETR4"`                     // Stock ticker requested
+	Sessions   map[string]*AggregateResponse `json:"sessions"`                                   // Aggregate per session type
+	RangeStart string                        `json:"range_start,omitempty" example:"2025-09-12"` // Resolved first trade date of the period (omitted when unbounded)
+	RangeEnd   string                        `json:"range_end,omitempty" example:"2025-09-18"`   // Resolved last trade date of the period (omitted when unbounded)
+}
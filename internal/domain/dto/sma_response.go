@@ -0,0 +1,18 @@
+package dto
+
+// SMAResponse represents the JSON structure returned by the
+// GET /api/v1/sma endpoint: the simple moving average of the daily close.
+type SMAResponse struct {
+	Ticker     string   `json:"ticker" example:"PETR4"`           // Stock ticker requested
+	Window     int      `json:"window" example:"20"`              // Number of trading days averaged
+	RangeStart string   `json:"range_start" example:"2025-09-01"` // First date of the period
+	RangeEnd   string   `json:"range_end" example:"2025-09-30"`   // Last date of the period
+	Days       []SMADay `json:"days"`                             // Days with trades in date order; empty when none
+}
+
+// SMADay is a single trading day of an SMAResponse.
+type SMADay struct {
+	Date  string   `json:"date" example:"2025-09-18"` // Trade date (YYYY-MM-DD)
+	Close float64  `json:"close" example:"20.10"`     // Price of the day's last trade
+	SMA   *float64 `json:"sma" example:"19.85"`       // Mean close over the window ending on this day; null until window trading days are available
+}
@@ -0,0 +1,13 @@
+package dto
+
+// SpreadResponse represents the JSON structure returned by the
+// GET /api/v1/spread endpoint.
+type SpreadResponse struct {
+	Ticker     string  `json:"ticker" example:"PETR4"`                     // Stock ticker requested
+	MaxPrice   float64 `json:"max_price" example:"20.50"`                  // Highest trade price in the period
+	MinPrice   float64 `json:"min_price" example:"18.20"`                  // Lowest trade price in the period
+	SpreadAbs  float64 `json:"spread_abs" example:"2.30"`                  // max_price - min_price
+	SpreadPct  float64 `json:"spread_pct" example:"12.64"`                 // spread_abs as a percentage of min_price (0 when min_price is 0)
+	RangeStart string  `json:"range_start,omitempty" example:"2025-09-12"` // Resolved first trade date of the period (omitted when unbounded)
+	RangeEnd   string  `json:"range_end,omitempty" example:"2025-09-18"`   // Resolved last trade date of the period (omitted when unbounded)
+}
@@ -0,0 +1,17 @@
+package dto
+
+// TradeLine represents one trade, written as one line of the NDJSON stream
+// returned by GET /api/v1/trades/stream. Fields match the CSV export columns.
+type TradeLine struct {
+	ReferenceDate         string   `json:"reference_date,omitempty" example:"2025-09-18"` // DataReferencia, omitted when unknown
+	InstrumentCode        string   `json:"instrument_code" example:"PETR4"`               // Stock ticker
+	UpdateAction          string   `json:"update_action" example:"0"`                     // AcaoAtualizacao
+	TradePrice            *float64 `json:"trade_price" example:"20.50"`                   // Trade price, null when unknown
+	TradeQuantity         *int64   `json:"trade_quantity" example:"100"`                  // Trade quantity, null when unknown
+	ClosingTime           string   `json:"closing_time,omitempty" example:"17:54:59"`     // Trade time (HH:MM:SS), omitted when unknown
+	TradeIdentifierCode   string   `json:"trade_identifier_code" example:"10"`            // CodigoIdentificadorNegocio
+	SessionType           string   `json:"session_type" example:"1"`                      // TipoSessaoPregao
+	TradeDate             string   `json:"trade_date,omitempty" example:"2025-09-18"`     // Session date, omitted when unknown
+	BuyerParticipantCode  string   `json:"buyer_participant_code" example:"3"`            // Buying broker
+	SellerParticipantCode string   `json:"seller_participant_code" example:"72"`          // Selling broker
+}
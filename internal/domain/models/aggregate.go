@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // Aggregate represents the result of aggregated queries
 // over trades for a specific ticker.
 //
@@ -8,12 +10,25 @@ package models
 //   - MaxRangeValue: The maximum unit price observed in the selected period.
 //   - MaxDailyVolume: The maximum number of assets traded in a single day
 //     during the selected period.
+//   - MinDailyVolume: The number of assets traded on the least active day
+//     (among days with trades) during the selected period.
+//   - TradeCount: The number of trades the figures are based on.
+//   - TradingDays: The number of distinct days with trades in the selected period.
+//   - HasDataOutsideRange: True when the ticker has no trades in the period but
+//     does have trades on other dates; the other figures are then zero.
+//   - LatestDate: The latest day with trades in the period, by the date column the
+//     period applies to; zero when there are none. Not part of the JSON body.
 //
 // This model is returned by the API when querying /api/v1/aggregate.
 //
 // swagger:model Aggregate
 type Aggregate struct {
-	Ticker         string  `json:"ticker" example:"PETR4"`
-	MaxRangeValue  float64 `json:"max_range_value" example:"20.50"`
-	MaxDailyVolume int64   `json:"max_daily_volume" example:"150000"`
+	Ticker              string    `json:"ticker" example:"PETR4"`
+	MaxRangeValue       float64   `json:"max_range_value" example:"20.50"`
+	MaxDailyVolume      int64     `json:"max_daily_volume" example:"150000"`
+	MinDailyVolume      int64     `json:"min_daily_volume" example:"32000"`
+	TradeCount          int64     `json:"trade_count" example:"4210"`
+	TradingDays         int       `json:"trading_days" example:"5"`
+	HasDataOutsideRange bool      `json:"has_data_outside_range" example:"false"`
+	LatestDate          time.Time `json:"-"`
 }
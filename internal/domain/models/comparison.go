@@ -0,0 +1,37 @@
+package models
+
+// Comparison holds the aggregates of two tickers over the same period
+// together with the ratios derived from them.
+//
+// Fields:
+//   - TickerA, TickerB: The ticker symbols being compared.
+//   - A, B: The aggregates for each ticker; nil when the ticker has no trades in the period.
+//   - MaxPriceRatio: A.MaxRangeValue / B.MaxRangeValue; nil when either side is missing or B is zero.
+//   - VolumeRatio: A.MaxDailyVolume / B.MaxDailyVolume; nil when either side is missing or B is zero.
+//
+// swagger:model Comparison
+type Comparison struct {
+	TickerA       string     `json:"ticker_a" example:"PETR4"`
+	TickerB       string     `json:"ticker_b" example:"VALE3"`
+	A             *Aggregate `json:"a"`
+	B             *Aggregate `json:"b"`
+	MaxPriceRatio *float64   `json:"max_price_ratio" example:"0.45"`
+	VolumeRatio   *float64   `json:"volume_ratio" example:"1.80"`
+}
+
+// Partial reports whether only one of the two tickers had data.
+func (c *Comparison) Partial() bool {
+	return (c.A == nil) != (c.B == nil)
+}
+
+// MissingTickers lists the tickers for which no aggregate was found.
+func (c *Comparison) MissingTickers() []string {
+	var missing []string
+	if c.A == nil {
+		missing = append(missing, c.TickerA)
+	}
+	if c.B == nil {
+		missing = append(missing, c.TickerB)
+	}
+	return missing
+}
@@ -0,0 +1,29 @@
+package models
+
+// CrossActivity represents the trades in a single instrument between a buyer
+// and a seller participant.
+//
+// Fields:
+//   - Ticker: The instrument traded (e.g., "PETR4").
+//   - Volume: Total quantity traded between the pair.
+//   - TradeCount: Number of trades between the pair.
+//
+// swagger:model CrossActivity
+type CrossActivity struct {
+	Ticker     string `json:"ticker" example:"PETR4"`
+	Volume     int64  `json:"volume" example:"120000"`
+	TradeCount int64  `json:"trade_count" example:"42"`
+}
+
+// CrossSummary is one page of a buyer/seller pair's per-ticker activity.
+//
+// Fields:
+//   - Buyer, Seller: The participant codes queried; either may be empty (any participant).
+//   - Tickers: Per-ticker activity, ordered by volume descending.
+//   - HasMore: True when further pages exist.
+type CrossSummary struct {
+	Buyer   string
+	Seller  string
+	Tickers []CrossActivity
+	HasMore bool
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// DailyAggregate represents aggregated trade figures for a ticker on a single trade date.
+//
+// Fields:
+//   - Ticker: The ticker symbol used in the aggregation (e.g., "PETR4").
+//   - Date: The trade date the figures refer to.
+//   - MaxPrice: The maximum unit price observed on that day.
+//   - ClosePrice: The price of the day's last trade by closing time.
+//   - TotalVolume: The total number of assets traded on that day.
+//   - TradeCount: The number of trades executed on that day.
+//
+// swagger:model DailyAggregate
+type DailyAggregate struct {
+	Ticker      string    `json:"ticker" example:"PETR4"`
+	Date        time.Time `json:"date" example:"2025-09-18T00:00:00Z"`
+	MaxPrice    float64   `json:"max_price" example:"20.50"`
+	ClosePrice  float64   `json:"close_price" example:"20.10"`
+	TotalVolume int64     `json:"total_volume" example:"150000"`
+	TradeCount  int64     `json:"trade_count" example:"1234"`
+}
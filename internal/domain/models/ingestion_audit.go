@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// IngestionAudit is a durable record of a single ingestion run.
+//
+// Fields:
+//   - RunID: Unique identifier of the run (UUID).
+//   - Mode: How the target dates were selected (e.g., "window").
+//   - RequestedDates: Business days the run was asked to ingest.
+//   - FilesProcessed: Number of files actually parsed and persisted (skipped files excluded).
+//   - TotalRows: Total number of trades persisted across all files.
+//   - Errors: Error messages collected during the run (empty on success).
+//   - Success: Whether the run completed without errors.
+//   - Force: Whether already-ingested days were reprocessed.
+//   - StartedAt/FinishedAt: Wall-clock boundaries of the run.
+type IngestionAudit struct {
+	RunID          string
+	Mode           string
+	RequestedDates []time.Time
+	FilesProcessed int
+	TotalRows      int64
+	Errors         []string
+	Success        bool
+	Force          bool
+	StartedAt      time.Time
+	FinishedAt     time.Time
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IngestionCheckpoint mirrors one row of ingestion_checkpoint: how far a
+// resumable ingestion of a file got before it stopped.
+//
+// Fields:
+//   - FileDate: The business day the file refers to.
+//   - Filename: The file being ingested.
+//   - LineNumber: Last file line (header = 1) whose batch was committed.
+//   - RowCount: Trades persisted from the file up to LineNumber.
+type IngestionCheckpoint struct {
+	FileDate   time.Time
+	Filename   string
+	LineNumber int
+	RowCount   int
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// IngestionLogEntry mirrors one row of ingestion_log: a business day whose file was ingested.
+//
+// Fields:
+//   - FileDate: The business day the file refers to.
+//   - Filename: The ingested file name.
+//   - RowCount: Number of trades persisted from the file.
+//   - IngestedAt: When the file was (last) ingested.
+type IngestionLogEntry struct {
+	FileDate   time.Time
+	Filename   string
+	RowCount   int64
+	IngestedAt time.Time
+}
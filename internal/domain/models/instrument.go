@@ -0,0 +1,13 @@
+package models
+
+// Instrument is reference data of a ticker from B3's instruments file.
+//
+// Fields:
+//   - Code: The ticker symbol (TckrSymb, e.g., "PETR4").
+//   - ISIN: International Securities Identification Number; empty when not published.
+//   - Name: Name of the issuing company (CrpnNm); empty when not published.
+type Instrument struct {
+	Code string
+	ISIN string
+	Name string
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// LatestPrice is the most recent trade of a ticker.
+//
+// Fields:
+//   - Ticker: The ticker symbol (e.g., "PETR4").
+//   - Price: Trade price of the latest trade.
+//   - Quantity: Quantity of the latest trade.
+//   - TradeDate: Session date of the latest trade.
+//   - ClosingTime: Clock time of the trade (zero date); zero when the file left it empty.
+type LatestPrice struct {
+	Ticker      string
+	Price       float64
+	Quantity    int64
+	TradeDate   time.Time
+	ClosingTime time.Time
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// MovingAveragePoint is one trading day of a simple moving average series.
+//
+// Fields:
+//   - Date: The trade date.
+//   - Close: The day's close (see DailyAggregate.ClosePrice).
+//   - SMA: The mean close of this day and the previous window-1 trading days
+//     with trades; nil while fewer than window such days are stored.
+type MovingAveragePoint struct {
+	Date  time.Time
+	Close float64
+	SMA   *float64
+}
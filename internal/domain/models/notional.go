@@ -0,0 +1,13 @@
+package models
+
+// Notional is the financial value (R$) traded in a ticker over a period.
+//
+// Fields:
+//   - Ticker: The ticker symbol used in the aggregation (e.g., "PETR4").
+//   - Notional: SUM(trade_price * trade_quantity) over the period.
+//   - TradeCount: Number of trades backing Notional.
+type Notional struct {
+	Ticker     string
+	Notional   float64
+	TradeCount int64
+}
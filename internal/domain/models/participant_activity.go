@@ -0,0 +1,28 @@
+package models
+
+// ParticipantActivity represents the volume a market participant (broker)
+// traded in a single instrument, split by side.
+//
+// Fields:
+//   - Ticker: The instrument traded (e.g., "PETR4").
+//   - BuyVolume: Total quantity where the participant was the buyer.
+//   - SellVolume: Total quantity where the participant was the seller.
+//
+// swagger:model ParticipantActivity
+type ParticipantActivity struct {
+	Ticker     string `json:"ticker" example:"PETR4"`
+	BuyVolume  int64  `json:"buy_volume" example:"120000"`
+	SellVolume int64  `json:"sell_volume" example:"95000"`
+}
+
+// ParticipantSummary groups a participant's per-ticker activity.
+//
+// Fields:
+//   - Code: The participant code queried.
+//   - Tickers: Per-ticker activity, ordered by total (buy + sell) volume descending.
+//   - Truncated: True when more tickers matched than the response cap allows.
+type ParticipantSummary struct {
+	Code      string
+	Tickers   []ParticipantActivity
+	Truncated bool
+}
@@ -0,0 +1,18 @@
+package models
+
+// PriceSpread is the high-low range of a ticker's trade prices over a period.
+//
+// Fields:
+//   - Ticker: The ticker symbol used in the aggregation (e.g., "PETR4").
+//   - MaxPrice, MinPrice: The highest and lowest trade prices in the period.
+//   - SpreadAbs: MaxPrice - MinPrice.
+//   - SpreadPct: SpreadAbs as a percentage of MinPrice; 0 when MinPrice is 0.
+//
+// swagger:model PriceSpread
+type PriceSpread struct {
+	Ticker    string  `json:"ticker" example:"PETR4"`
+	MaxPrice  float64 `json:"max_price" example:"20.50"`
+	MinPrice  float64 `json:"min_price" example:"18.20"`
+	SpreadAbs float64 `json:"spread_abs" example:"2.30"`
+	SpreadPct float64 `json:"spread_pct" example:"12.64"`
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"math"
+	"time"
+)
 
 // Trade represents a single row in the B3 business file.
 // Each field matches one column in the .txt file.
@@ -17,6 +20,11 @@ import "time"
 //  9. TradeDate
 //  10. BuyerParticipantCode
 //  11. SellerParticipantCode
+//
+// Zero dates and times are stored as NULL. TradePrice is stored as NULL when it
+// is NaN, which ingestion uses for an empty price with INGEST_EMPTY_PRICE_NULL;
+// see IsPriceMissing. TradeQuantity is stored as NULL when it is MissingQuantity.
+// Trades streamed back from the database carry these sentinels for NULL columns.
 type Trade struct {
 	ReferenceDate         time.Time
 	InstrumentCode        string
@@ -30,3 +38,15 @@ type Trade struct {
 	BuyerParticipantCode  string
 	SellerParticipantCode string
 }
+
+// MissingPrice returns the TradePrice sentinel for a trade without a price.
+func MissingPrice() float64 { return math.NaN() }
+
+// IsPriceMissing reports whether the trade has no price (see MissingPrice).
+func (t Trade) IsPriceMissing() bool { return math.IsNaN(t.TradePrice) }
+
+// MissingQuantity returns the TradeQuantity sentinel for a trade without a quantity.
+func MissingQuantity() int64 { return math.MinInt64 }
+
+// IsQuantityMissing reports whether the trade has no quantity (see MissingQuantity).
+func (t Trade) IsQuantityMissing() bool { return t.TradeQuantity == math.MinInt64 }
@@ -0,0 +1,150 @@
+// Package fetch downloads input files over HTTP so that only complete files
+// ever appear under their final name in the input directory.
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/guttosm/b3pulse/internal/logger"
+)
+
+const (
+	// defaultTimeout bounds each attempt, including reading the body, so a
+	// stalled transfer is abandoned and retried.
+	defaultTimeout = 5 * time.Minute
+	// defaultRetries is how many times a failed attempt is retried.
+	defaultRetries = 3
+	// defaultBackoff is the pause before the first retry; it doubles after each one.
+	defaultBackoff = 2 * time.Second
+)
+
+// ErrShortRead reports a body shorter than the response's Content-Length.
+var ErrShortRead = errors.New("short read")
+
+// Fetcher downloads a URL to a file.
+//
+// Behavior:
+//   - Each attempt is bounded by the client timeout (5m by default).
+//   - Network errors, timeouts, 5xx responses and short reads are retried up
+//     to Retries times, waiting Backoff, then twice as long, and so on.
+//   - Other non-2xx responses are not retried.
+//   - The body is streamed to a temporary file next to the destination, which
+//     is renamed over it only once the whole body was written and synced.
+//   - Cancelling the context stops the transfer and any pending retry.
+type Fetcher struct {
+	Client  *http.Client
+	Retries int
+	Backoff time.Duration
+}
+
+// New creates a Fetcher with default timeout, retries and backoff.
+func New() *Fetcher {
+	return &Fetcher{
+		Client:  &http.Client{Timeout: defaultTimeout},
+		Retries: defaultRetries,
+		Backoff: defaultBackoff,
+	}
+}
+
+// statusError reports a non-2xx response.
+type statusError struct {
+	code int
+}
+
+func (e statusError) Error() string { return fmt.Sprintf("server returned status %d", e.code) }
+
+func retryable(err error) bool {
+	var se statusError
+	if errors.As(err, &se) {
+		return se.code >= 500
+	}
+	return true
+}
+
+// Download fetches url into dest, retrying transient failures.
+//
+// Returns the number of bytes written. On error dest is left untouched.
+func (f *Fetcher) Download(ctx context.Context, url string, dest string) (int64, error) {
+	delay := f.Backoff
+	for attempt := 0; ; attempt++ {
+		n, err := f.download(ctx, url, dest)
+		if err == nil {
+			return n, nil
+		}
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if attempt >= f.Retries || !retryable(err) {
+			return 0, fmt.Errorf("download %s: %w", url, err)
+		}
+		logger.L().Warn().Err(err).Str("url", url).Int("attempt", attempt+1).Dur("retry_in", delay).Msg("download failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// download makes a single attempt.
+func (f *Fetcher) download(ctx context.Context, url string, dest string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, statusError{code: resp.StatusCode}
+	}
+
+	// The dot prefix keeps the partial file out of directory listings and
+	// away from the names the ingester looks for.
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".*.part")
+	if err != nil {
+		return 0, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	// The transport already fails a body cut short of Content-Length with
+	// io.ErrUnexpectedEOF; both paths report ErrShortRead.
+	n, err := io.Copy(tmp, resp.Body)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return 0, fmt.Errorf("%w: got %d of %d bytes", ErrShortRead, n, resp.ContentLength)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return 0, fmt.Errorf("%w: got %d of %d bytes", ErrShortRead, n, resp.ContentLength)
+	}
+	if err := tmp.Sync(); err != nil {
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return 0, err
+	}
+	committed = true
+	return n, nil
+}
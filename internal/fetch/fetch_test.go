@@ -0,0 +1,174 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const body = "DataReferencia;CodigoInstrumento\n2025-09-12;PETR4\n"
+
+func testFetcher() *Fetcher {
+	return &Fetcher{Client: &http.Client{Timeout: 2 * time.Second}, Retries: 2, Backoff: time.Millisecond}
+}
+
+// assertOnlyFile fails unless dir holds exactly want (or nothing when want is
+// empty), so leftover partial files are caught too.
+func assertOnlyFile(t *testing.T, dir string, want string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want == "" && len(names) != 0 || want != "" && (len(names) != 1 || names[0] != want) {
+		t.Fatalf("want only %q in dir, got %v", want, names)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	cases := []struct {
+		name      string
+		responses []func(http.ResponseWriter) // one per attempt; the last repeats
+		wantCalls int32
+		wantErr   bool
+	}{
+		{
+			name:      "ok first try",
+			responses: []func(http.ResponseWriter){serve(body)},
+			wantCalls: 1,
+		},
+		{
+			name:      "503 then ok",
+			responses: []func(http.ResponseWriter){status(http.StatusServiceUnavailable), serve(body)},
+			wantCalls: 2,
+		},
+		{
+			name:      "short read then ok",
+			responses: []func(http.ResponseWriter){truncated(body), serve(body)},
+			wantCalls: 2,
+		},
+		{
+			name:      "5xx exhausts retries",
+			responses: []func(http.ResponseWriter){status(http.StatusBadGateway)},
+			wantCalls: 3,
+			wantErr:   true,
+		},
+		{
+			name:      "short read exhausts retries",
+			responses: []func(http.ResponseWriter){truncated(body)},
+			wantCalls: 3,
+			wantErr:   true,
+		},
+		{
+			name:      "4xx not retried",
+			responses: []func(http.ResponseWriter){status(http.StatusNotFound)},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(calls.Add(1))
+				tc.responses[min(n, len(tc.responses))-1](w)
+			}))
+			defer srv.Close()
+
+			dir := t.TempDir()
+			dest := filepath.Join(dir, "12092025_NEGOCIOSAVISTA.txt")
+			n, err := testFetcher().Download(context.Background(), srv.URL, dest)
+			if got := calls.Load(); got != tc.wantCalls {
+				t.Fatalf("want %d calls, got %d", tc.wantCalls, got)
+			}
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				assertOnlyFile(t, dir, "")
+				return
+			}
+			if err != nil || n != int64(len(body)) {
+				t.Fatalf("want %d bytes, got n=%d err=%v", len(body), n, err)
+			}
+			got, err := os.ReadFile(dest)
+			if err != nil || string(got) != body {
+				t.Fatalf("unexpected content %q err=%v", got, err)
+			}
+			assertOnlyFile(t, dir, filepath.Base(dest))
+		})
+	}
+}
+
+func TestDownload_KeepsExistingFileOnFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		truncated(body)(w)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "12092025_NEGOCIOSAVISTA.txt")
+	if err := os.WriteFile(dest, []byte("previous"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_, err := testFetcher().Download(context.Background(), srv.URL, dest)
+	if !errors.Is(err, ErrShortRead) {
+		t.Fatalf("want ErrShortRead, got %v", err)
+	}
+	if got, _ := os.ReadFile(dest); string(got) != "previous" {
+		t.Fatalf("existing file was replaced: %q", got)
+	}
+	assertOnlyFile(t, dir, filepath.Base(dest))
+}
+
+func TestDownload_ContextCancelled(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select { // stall until the client gives up
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	dir := t.TempDir()
+	start := time.Now()
+	_, err := testFetcher().Download(ctx, srv.URL, filepath.Join(dir, "out.txt"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("cancellation was not prompt: %s", time.Since(start))
+	}
+	assertOnlyFile(t, dir, "")
+}
+
+func serve(s string) func(http.ResponseWriter) {
+	return func(w http.ResponseWriter) { _, _ = w.Write([]byte(s)) }
+}
+
+func status(code int) func(http.ResponseWriter) {
+	return func(w http.ResponseWriter) { w.WriteHeader(code) }
+}
+
+// truncated announces the full length but sends only half of s.
+func truncated(s string) func(http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("Content-Length", "100")
+		_, _ = w.Write([]byte(s[:len(s)/2]))
+	}
+}
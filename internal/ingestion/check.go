@@ -0,0 +1,89 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+// CheckReport is the result of CheckDirectory: whether the input directory
+// holds a file for every business day of the window.
+type CheckReport struct {
+	Dir      string     `json:"dir"`
+	Complete bool       `json:"complete"`
+	Missing  int        `json:"missing"`
+	Days     []DayCheck `json:"days"` // ordered by day
+}
+
+// DayCheck describes the expected file of a single business day.
+type DayCheck struct {
+	Day       string `json:"day"` // YYYY-MM-DD
+	File      string `json:"file"`
+	Present   bool   `json:"present"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+	ModTime   string `json:"mod_time,omitempty"` // RFC 3339, omitted when absent
+}
+
+// CheckDirectory reports, for each of the last nDays business days (clamped to
+// 1..7, like ProcessDirectory), whether dir has its input file, with its size
+// and modification time. It only stats the files: nothing is opened or parsed
+// and the database is not used.
+//
+// Returns an error only when a file cannot be stat'ed for a reason other than
+// not existing; missing files are reported in the CheckReport.
+func CheckDirectory(dir string, nDays int) (CheckReport, error) {
+	nDays = min(max(nDays, 1), 7)
+	dates := LastNBusinessDays(nDays, time.Now()) // most recent first
+
+	report := CheckReport{Dir: dir, Days: make([]DayCheck, 0, len(dates))}
+	for i := len(dates) - 1; i >= 0; i-- {
+		d := dates[i]
+		name := fileNameFor(d)
+		day := DayCheck{Day: d.Format("2006-01-02"), File: name}
+
+		info, err := os.Stat(filepath.Join(dir, name))
+		switch {
+		case err == nil:
+			day.Present, day.SizeBytes, day.ModTime = true, info.Size(), info.ModTime().UTC().Format(time.RFC3339)
+		case os.IsNotExist(err):
+			report.Missing++
+		default:
+			return report, fmt.Errorf("stat failed for %s: %w", name, err)
+		}
+		report.Days = append(report.Days, day)
+	}
+	report.Complete = report.Missing == 0
+	return report, nil
+}
+
+// WriteJSON writes r as a single line of JSON.
+func (r CheckReport) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// WriteText writes r as an aligned table, one line per day, followed by a
+// one-line verdict.
+func (r CheckReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "DAY\tFILE\tSTATUS\tSIZE\tMODIFIED")
+	for _, d := range r.Days {
+		if d.Present {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\tpresent\t%d\t%s\n", d.Day, d.File, d.SizeBytes, d.ModTime)
+		} else {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\tMISSING\t-\t-\n", d.Day, d.File)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if r.Complete {
+		_, err := fmt.Fprintf(w, "complete: %d of %d files present in %s\n", len(r.Days), len(r.Days), r.Dir)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "incomplete: %d of %d files missing in %s\n", r.Missing, len(r.Days), r.Dir)
+	return err
+}
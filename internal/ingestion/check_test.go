@@ -0,0 +1,69 @@
+package ingestion
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckDirectory(t *testing.T) {
+	dir := t.TempDir()
+	days := LastNBusinessDays(3, time.Now()) // most recent first
+	writeFile(t, dir, fileNameFor(days[0]), "abc")
+	writeFile(t, dir, fileNameFor(days[2]), "abcdef")
+	mtime := time.Date(2025, 9, 12, 22, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, fileNameFor(days[2])), mtime, mtime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	report, err := CheckDirectory(dir, 3)
+	if err != nil {
+		t.Fatalf("CheckDirectory: %v", err)
+	}
+	if report.Complete || report.Missing != 1 || len(report.Days) != 3 {
+		t.Fatalf("want 1 of 3 missing, got %+v", report)
+	}
+	oldest, middle, latest := report.Days[0], report.Days[1], report.Days[2]
+	if oldest.Day != days[2].Format("2006-01-02") || !oldest.Present || oldest.SizeBytes != 6 || oldest.ModTime != "2025-09-12T22:30:00Z" {
+		t.Fatalf("unexpected oldest day: %+v", oldest)
+	}
+	if middle.Present || middle.File != fileNameFor(days[1]) || middle.SizeBytes != 0 || middle.ModTime != "" {
+		t.Fatalf("unexpected missing day: %+v", middle)
+	}
+	if !latest.Present || latest.SizeBytes != 3 {
+		t.Fatalf("unexpected latest day: %+v", latest)
+	}
+
+	var text bytes.Buffer
+	if err := report.WriteText(&text); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	if !strings.Contains(text.String(), fileNameFor(days[1])+"  MISSING") || !strings.Contains(text.String(), "incomplete: 1 of 3 files missing") {
+		t.Fatalf("unexpected text report:\n%s", text.String())
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	var decoded CheckReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.Missing != 1 || len(decoded.Days) != 3 || decoded.Days[1].Present {
+		t.Fatalf("unexpected JSON report %s err=%v", buf.String(), err)
+	}
+
+	// Complete once the gap is filled; days are clamped to 1..7.
+	writeFile(t, dir, fileNameFor(days[1]), "x")
+	if report, err = CheckDirectory(dir, 3); err != nil || !report.Complete {
+		t.Fatalf("want complete, got %+v err=%v", report, err)
+	}
+	if report, err = CheckDirectory(dir, 0); err != nil || len(report.Days) != 1 {
+		t.Fatalf("want 1 day for nDays=0, got %+v err=%v", report, err)
+	}
+	if report, _ = CheckDirectory(dir, 30); len(report.Days) != 7 {
+		t.Fatalf("want 7 days for nDays=30, got %d", len(report.Days))
+	}
+}
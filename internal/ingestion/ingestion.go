@@ -3,16 +3,24 @@ package ingestion
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/guttosm/b3pulse/config"
+	"github.com/guttosm/b3pulse/internal/domain/models"
 	"github.com/guttosm/b3pulse/internal/logger"
+	"github.com/guttosm/b3pulse/internal/notify"
 	"github.com/guttosm/b3pulse/internal/storage"
 )
 
@@ -20,27 +28,129 @@ const (
 	fileDateLayout   = "02-01-2006" // DD-MM-YYYY
 	fileSuffix       = "_NEGOCIOSAVISTA.txt"
 	defaultBatchSize = 5000
+
+	// auditModeWindow identifies runs that ingest the last N business days.
+	auditModeWindow = "window"
 )
 
+// notifierCtor builds the run-completion notifier from config; tests can override this.
+var notifierCtor = func() notify.Notifier {
+	if url := config.AppConfig.Ingest.WebhookURL; url != "" {
+		return notify.NewWebhookNotifier(url)
+	}
+	return notify.Noop{}
+}
+
 // repoCtor is an indirection for creating the repository; tests can override this.
 var repoCtor = func(db *sql.DB) storage.TradesRepository {
-	return storage.NewTradesRepository(db)
+	return storage.NewTradesRepository(db, storage.WithTxTimeouts(storage.TxTimeouts{
+		IdleInTransactionMs: config.AppConfig.Ingest.IdleInTxTimeoutMs,
+		StatementMs:         config.AppConfig.Ingest.StatementTimeoutMs,
+	}))
+}
+
+// ProcessOptions holds the per-run switches of ProcessDirectory, mirroring the
+// ingest command's flags. The zero value is a plain run: already ingested days
+// are skipped and the first failing file stops the run.
+type ProcessOptions struct {
+	Force           bool // reprocess days already in ingestion_log, deleting their trades first
+	AllowMissing    bool // skip days without a file instead of failing the run
+	ContinueOnError bool // keep loading the other files after one fails (see PartialFailureError)
+	Analyze         bool // run ANALYZE on trades after a successful run that loaded files
+	Resume          bool // checkpoint every batch and continue partial files from the last one
+	ConfirmDelete   bool // let Force delete trades of days ingestion_log does not account for
 }
 
 //   - dir: directory containing .txt input files.
 //   - db:  open *sql.DB (PostgreSQL).
+//   - opts: per-run switches (see ProcessOptions).
 //
 // Behavior:
 //   - Expects exactly one file per business day with name "DD-MM-YYYY_NEGOCIOSAVISTA.txt".
-//   - Uses a concurrency limit based on CPU count (min(7, NumCPU)).
-//   - For each file, parses & inserts trades in batches via repository.
-//   - If any file returns error, cancels the rest and returns that error.
+//   - With AllowMissing, absent days are logged and skipped instead of failing the run
+//     (guards against holiday-calendar drift); the run still fails if every day is missing.
+//   - Fails fast when a file is larger than config.AppConfig.Ingest.MaxFileBytes (0 = unlimited).
+//   - Fails a file with fewer data rows than INGEST_MIN_ROWS (e.g. header-only or truncated),
+//     deleting whatever it inserted and writing no ingestion_log entry.
+//   - Uses a concurrency limit based on CPU count (min(7, NumCPU)). Files are parsed on
+//     the worker pool shared by every run of the process (INGEST_WORKERS, default 7),
+//     so overlapping runs together never parse more files than that at once. Files still
+//     waiting for a worker when the run is cancelled or fails are not started.
+//   - Caps batches buffered across all files at INGEST_MAX_INFLIGHT_BATCHES (default: the parallelism).
+//   - For each file, parses & inserts trades in batches via repository. With
+//     INGEST_PARTITION_MONTHLY, the month's partition of trades is created first if missing.
+//   - Skips days already in ingestion_log, unless Force. A logged day with no trades left
+//     (e.g. purged by hand) is logged as a discrepancy and reprocessed.
+//   - If any file returns error, cancels the rest and returns that error. With ContinueOnError,
+//     the other files still run and a *PartialFailureError lists the days that succeeded and failed.
+//   - With Analyze, runs ANALYZE on trades after a successful run that loaded at least one
+//     file, so the planner sees fresh statistics; a failure there is only logged.
+//   - With Resume, commits an ingestion_checkpoint row with every batch, and a file that
+//     failed part-way in an earlier Resume run continues after its last committed line
+//     instead of starting over (see parseAndPersistFile). The checkpoint is dropped once
+//     the day is logged; with Force the file always starts over.
+//   - With Force, a day's existing trades are only deleted when ingestion_log (or, with
+//     Resume, a checkpoint) records that they were ingested; otherwise the file fails
+//     with ErrUnloggedDelete unless ConfirmDelete is set. The row count is logged first.
+//   - Records an ingestion_audit row at the end of the run (success or failure).
+//   - POSTs a run summary to INGEST_WEBHOOK_URL when set; notification failures are only logged.
 //
 // Returns:
 //   - error: first error encountered (if any).
-func ProcessDirectory(ctx context.Context, dir string, db *sql.DB, nDays int, parallel int, force bool) error {
+func ProcessDirectory(ctx context.Context, dir string, db *sql.DB, nDays int, parallel int, opts ProcessOptions) error {
+	_, err := ProcessDirectoryWithSummary(ctx, dir, db, nDays, parallel, opts)
+	return err
+}
+
+// ProcessDirectoryWithSummary behaves like ProcessDirectory and also returns a
+// RunSummary of the run (per-file status, rows and durations), filled in on
+// success and failure alike. Files never reached by a fail-fast run are absent.
+func ProcessDirectoryWithSummary(ctx context.Context, dir string, db *sql.DB, nDays int, parallel int, opts ProcessOptions) (summary RunSummary, err error) {
 	// use indirection to allow tests to swap repository constructor
 	repo := repoCtor(db)
+	var files fileSummaries
+
+	// Audit trail for the whole run, written on every exit path.
+	var dates []time.Time
+	var filesProcessed, totalRows atomic.Int64
+	audit := models.IngestionAudit{
+		RunID:     uuid.NewString(),
+		Mode:      auditModeWindow,
+		Force:     opts.Force,
+		StartedAt: time.Now(),
+	}
+	defer func() {
+		audit.RequestedDates = dates
+		audit.FilesProcessed = int(filesProcessed.Load())
+		audit.TotalRows = totalRows.Load()
+		audit.Success = err == nil
+		var partial *PartialFailureError
+		if errors.As(err, &partial) {
+			for _, fe := range partial.Failed {
+				audit.Errors = append(audit.Errors, fe.Error())
+			}
+		} else if err != nil {
+			audit.Errors = []string{err.Error()}
+		}
+		audit.FinishedAt = time.Now()
+		summary = RunSummary{
+			RunID:      audit.RunID,
+			Success:    audit.Success,
+			Files:      files.sorted(),
+			TotalRows:  audit.TotalRows,
+			DurationMs: audit.FinishedAt.Sub(audit.StartedAt).Milliseconds(),
+		}
+		if err != nil {
+			summary.Error = err.Error()
+		}
+		if aerr := repo.RecordIngestionRun(audit); aerr != nil {
+			logger.L().Warn().Str("run_id", audit.RunID).Err(aerr).Msg("record ingestion audit failed")
+		}
+		// Notify even if the run's context was cancelled; the notifier bounds its own time.
+		if nerr := notifierCtor().NotifyIngestion(context.WithoutCancel(ctx), audit); nerr != nil {
+			logger.L().Warn().Str("run_id", audit.RunID).Err(nerr).Msg("ingestion notification failed")
+		}
+	}()
 
 	// Build the list of the last 7 business days (Brazil).
 	if nDays < 1 {
@@ -49,31 +159,44 @@ func ProcessDirectory(ctx context.Context, dir string, db *sql.DB, nDays int, pa
 	if nDays > 7 {
 		nDays = 7
 	}
-	dates := LastNBusinessDays(nDays, time.Now())
+	dates = LastNBusinessDays(nDays, time.Now())
 
 	// Build expected filenames & validate presence upfront.
-	var files []string
+	var paths []string
 	var missing []string
 
 	for _, d := range dates {
-		name := d.Format(fileDateLayout) + fileSuffix
+		name := fileNameFor(d)
 		full := filepath.Join(dir, name)
-		files = append(files, full)
 
-		if _, err := os.Stat(full); err != nil {
+		info, err := os.Stat(full)
+		if err != nil {
 			if os.IsNotExist(err) {
 				missing = append(missing, name)
-			} else {
-				return fmt.Errorf("stat failed for %s: %w", full, err)
+				continue
 			}
+			return summary, fmt.Errorf("stat failed for %s: %w", full, err)
+		}
+
+		// Guard against runaway inputs before spending any time parsing them.
+		if maxBytes := config.AppConfig.Ingest.MaxFileBytes; maxBytes > 0 && info.Size() > maxBytes {
+			logger.L().Error().Str("file", name).Int64("size_bytes", info.Size()).Int64("max_bytes", maxBytes).Msg("file exceeds size limit")
+			return summary, fmt.Errorf("file %s is %d bytes, exceeds INGEST_MAX_FILE_BYTES=%d", full, info.Size(), maxBytes)
 		}
+		paths = append(paths, full)
 	}
 
 	if len(missing) > 0 {
-		return fmt.Errorf("missing required files: %s", strings.Join(missing, ", "))
+		if !opts.AllowMissing || len(paths) == 0 {
+			return summary, fmt.Errorf("missing required files: %s", strings.Join(missing, ", "))
+		}
+		for _, name := range missing {
+			logger.L().Warn().Str("run_id", audit.RunID).Str("file", name).Msg("file missing, skipping day")
+			files.add(name, FileMissing, 0, 0, nil)
+		}
 	}
 
-	logger.L().Info().Int("files", len(files)).Str("dir", dir).Msg("ingestion start")
+	logger.L().Info().Str("run_id", audit.RunID).Int("files", len(paths)).Int("skipped_days", len(missing)).Str("dir", dir).Msg("ingestion start")
 
 	// Concurrency: default to min(7, NumCPU), or use provided clamp(1..7)
 	maxParallel := 7
@@ -86,22 +209,35 @@ func ProcessDirectory(ctx context.Context, dir string, db *sql.DB, nDays int, pa
 		maxParallel = c
 	}
 
-	logger.L().Info().Int("max_parallel", maxParallel).Msg("ingestion configured")
+	// Memory guard: bound buffered batches across all files (default one per worker).
+	maxInflight := config.AppConfig.Ingest.MaxInflightBatches
+	if maxInflight <= 0 {
+		maxInflight = maxParallel
+	}
+	limiter := newBatchLimiter(maxInflight)
+
+	logger.L().Info().Int("max_parallel", maxParallel).Int("max_inflight_batches", maxInflight).Msg("ingestion configured")
 
-	// errgroup will cancel siblings on first error.
+	// errgroup will cancel siblings on first error; the shared pool bounds
+	// parsing across concurrent runs.
+	var outcomes fileOutcomes
 	g, gctx := errgroup.WithContext(ctx)
-	sem := make(chan struct{}, maxParallel)
+	g.SetLimit(maxParallel)
+	pool := workerPool()
 
-	for i, file := range files {
+	for i, file := range paths {
 		idx := i
 		f := file
-		sem <- struct{}{}
 
-		g.Go(func() error {
-			defer func() { <-sem }()
+		task := func() (ferr error) {
+			// With continueOnError a failure is recorded and swallowed so siblings keep running.
+			defer func() { ferr = outcomes.record(f, ferr, opts.ContinueOnError) }()
 			start := time.Now()
+			status, rows := FileIngested, 0
+			// Runs before record, so a swallowed failure is still reported.
+			defer func() { files.add(f, status, rows, time.Since(start), ferr) }()
 			base := filepath.Base(f)
-			logger.L().Info().Int("idx", idx+1).Int("total", len(files)).Str("file", base).Msg("file start")
+			logger.L().Info().Int("idx", idx+1).Int("total", len(paths)).Str("file", base).Msg("file start")
 
 			// Determine the business date from the filename (DD-MM-YYYY_...)
 			datePart := strings.TrimSuffix(base, fileSuffix)
@@ -117,39 +253,226 @@ func ProcessDirectory(ctx context.Context, dir string, db *sql.DB, nDays int, pa
 				logger.L().Error().Str("file", base).Err(err).Msg("check ingestion log failed")
 				return fmt.Errorf("file %s: check ingestion log: %w", f, err)
 			}
-			if exists && !force {
-				logger.L().Info().Int("idx", idx+1).Int("total", len(files)).Str("file", base).Bool("skipped", true).Msg("already ingested")
+			if exists && !opts.Force {
+				// A log entry whose trades were purged by hand would otherwise be
+				// skipped forever; treat such a day as not ingested.
+				n, err := repo.CountTradesByDate(d)
+				if err != nil {
+					logger.L().Error().Str("file", base).Err(err).Msg("count existing trades failed")
+					return fmt.Errorf("file %s: count existing trades: %w", f, err)
+				}
+				if n == 0 {
+					logger.L().Warn().Str("file", base).Str("date", d.Format("2006-01-02")).Msg("ingestion log entry without trades, reprocessing")
+					exists = false
+				}
+			}
+			if exists && !opts.Force {
+				logger.L().Info().Int("idx", idx+1).Int("total", len(paths)).Str("file", base).Bool("skipped", true).Msg("already ingested")
+				status = FileSkipped
 				return nil
 			}
-			if exists && force {
-				// Delete existing data for that date and reprocess
+			if opts.Force && (exists || opts.Resume) {
+				// Delete existing data for that date and reprocess; with resume this
+				// also discards a partial run's rows and checkpoint
+				n, err := checkForceDelete(repo, d, exists, opts.Resume, opts.ConfirmDelete)
+				if err != nil {
+					logger.L().Error().Str("file", base).Int64("rows", n).Err(err).Msg("force delete refused")
+					return fmt.Errorf("file %s: %w", f, err)
+				}
+				logger.L().Info().Str("file", base).Int64("rows", n).Bool("logged", exists).Msg("deleting existing trades")
 				if err := repo.DeleteTradesByDate(d); err != nil {
 					logger.L().Error().Str("file", base).Err(err).Msg("delete existing failed")
 					return fmt.Errorf("file %s: delete existing: %w", f, err)
 				}
+				if opts.Resume {
+					if err := repo.DeleteIngestionCheckpoint(d); err != nil {
+						return fmt.Errorf("file %s: delete checkpoint: %w", f, err)
+					}
+				}
+			}
+
+			// With INGEST_PARTITION_MONTHLY, the day's rows need their month's partition.
+			if config.AppConfig.Ingest.PartitionMonthly {
+				if err := repo.EnsurePartitionForDate(d); err != nil {
+					logger.L().Error().Str("file", base).Err(err).Msg("ensure partition failed")
+					return fmt.Errorf("file %s: ensure partition: %w", f, err)
+				}
+			}
+
+			// With --resume, continue after the last batch an earlier run committed.
+			var cp *models.IngestionCheckpoint
+			if opts.Resume {
+				if cp, err = repo.GetIngestionCheckpoint(d); err != nil {
+					logger.L().Error().Str("file", base).Err(err).Msg("load checkpoint failed")
+					return fmt.Errorf("file %s: load checkpoint: %w", f, err)
+				}
+				if cp == nil {
+					cp = &models.IngestionCheckpoint{FileDate: d, Filename: base}
+				} else {
+					logger.L().Info().Str("file", base).Int("line", cp.LineNumber).Int("rows", cp.RowCount).Msg("resuming from checkpoint")
+				}
 			}
 
 			// Process each file; this function:
 			// - validates header/order/columns strictly
 			// - parses rows tolerantly (empty cells allowed)
 			// - inserts in batches (defaultBatchSize)
-			total, err := parseAndPersistFile(gctx, f, repo, defaultBatchSize)
+			// - skips instruments rejected by INGEST_INSTRUMENT_FILTER
+			// - drops in-file duplicate trades when INGEST_DEDUP is set
+			stats, err := parseAndPersistFile(gctx, f, repo, defaultBatchSize, limiter, cp)
 			if err != nil {
 				logger.L().Error().Str("file", base).Dur("elapsed", time.Since(start)).Err(err).Msg("file failed")
 				return fmt.Errorf("file %s: %w", f, err)
 			}
-			if err := repo.UpsertIngestionLog(d, base, total); err != nil {
+			// Truncated deliveries: undo this day's rows so a rerun starts clean.
+			if minRows := config.AppConfig.Ingest.MinRows; stats.dataRows() < minRows {
+				logger.L().Error().Str("file", base).Int("rows", stats.dataRows()).Int("min_rows", minRows).Msg("file below minimum row count")
+				if err := repo.DeleteTradesByDate(d); err != nil {
+					return fmt.Errorf("file %s: delete rows of short file: %w", f, err)
+				}
+				if opts.Resume {
+					if err := repo.DeleteIngestionCheckpoint(d); err != nil {
+						return fmt.Errorf("file %s: delete checkpoint of short file: %w", f, err)
+					}
+				}
+				return fmt.Errorf("file %s has %d data rows, below INGEST_MIN_ROWS=%d", f, stats.dataRows(), minRows)
+			}
+			if err := repo.UpsertIngestionLog(d, base, stats.Rows); err != nil {
 				logger.L().Error().Str("file", base).Err(err).Msg("update ingestion log failed")
 				return fmt.Errorf("file %s: upsert ingestion log: %w", f, err)
 			}
-			logger.L().Info().Int("idx", idx+1).Int("total", len(files)).Str("file", base).Int("rows", total).Dur("elapsed", time.Since(start)).Bool("force", force).Msg("file done")
+			if opts.Resume {
+				// The day is logged, so a leftover checkpoint is never used again.
+				if err := repo.DeleteIngestionCheckpoint(d); err != nil {
+					logger.L().Warn().Str("file", base).Err(err).Msg("delete checkpoint failed")
+				}
+			}
+			if stats.DateMismatches > 0 {
+				logger.L().Warn().Str("file", base).Int("date_mismatches", stats.DateMismatches).Msg("rows with trade date different from file date")
+			}
+			rows = stats.Rows
+			filesProcessed.Add(1)
+			totalRows.Add(int64(stats.Rows))
+			logger.L().Info().Int("idx", idx+1).Int("total", len(paths)).Str("file", base).Int("rows", stats.Rows).Int("duplicates_dropped", stats.Duplicates).Int("filtered", stats.Filtered).Int("date_mismatches", stats.DateMismatches).Dur("elapsed", time.Since(start)).Bool("force", opts.Force).Msg("file done")
 			return nil
-		})
+		}
+		g.Go(func() error { return pool.Submit(gctx, task) })
 	}
 
 	if err := g.Wait(); err != nil {
-		return err
+		return summary, err
+	}
+	if err := outcomes.err(); err != nil {
+		return summary, err
+	}
+
+	if opts.Analyze && filesProcessed.Load() > 0 {
+		start := time.Now()
+		if aerr := repo.AnalyzeTrades(ctx); aerr != nil {
+			logger.L().Warn().Str("run_id", audit.RunID).Err(aerr).Msg("analyze trades failed")
+		} else {
+			logger.L().Info().Str("run_id", audit.RunID).Dur("elapsed", time.Since(start)).Msg("analyze trades done")
+		}
+	}
+	return summary, nil
+}
+
+// ErrUnloggedDelete is returned (wrapped) when --force would delete trades of a
+// day that neither ingestion_log nor a --resume checkpoint accounts for.
+var ErrUnloggedDelete = errors.New("refusing to delete trades of a day without an ingestion_log entry (use --confirm-delete)")
+
+// checkForceDelete counts the trades stored for day d and decides whether force
+// may delete them. Rows are trusted when the day is logged, when resume finds a
+// checkpoint of an earlier partial run, or when confirmDelete is set; a day
+// without rows has nothing to protect. It returns the row count either way.
+func checkForceDelete(repo storage.TradesRepository, d time.Time, logged, resume, confirmDelete bool) (int64, error) {
+	n, err := repo.CountTradesByDate(d)
+	if err != nil {
+		return 0, fmt.Errorf("count existing trades: %w", err)
+	}
+	if logged || confirmDelete || n == 0 {
+		return n, nil
+	}
+	if resume {
+		cp, err := repo.GetIngestionCheckpoint(d)
+		if err != nil {
+			return n, fmt.Errorf("load checkpoint: %w", err)
+		}
+		if cp != nil {
+			return n, nil
+		}
+	}
+	return n, fmt.Errorf("%d trades of %s: %w", n, d.Format("2006-01-02"), ErrUnloggedDelete)
+}
+
+// FileError is the failure of a single day's file in a continue-on-error run.
+type FileError struct {
+	Day string // Business day from the filename (YYYY-MM-DD), or the filename if unparsable
+	Err error
+}
+
+func (e FileError) Error() string { return e.Day + ": " + e.Err.Error() }
+
+// PartialFailureError is returned by ProcessDirectory with continueOnError when
+// some files failed. Days that succeeded (or were already ingested) are listed
+// so only the failed ones need a rerun.
+type PartialFailureError struct {
+	Succeeded []string // YYYY-MM-DD, ascending
+	Failed    []FileError
+}
+
+func (e *PartialFailureError) Error() string {
+	msgs := make([]string, 0, len(e.Failed))
+	for _, fe := range e.Failed {
+		msgs = append(msgs, fe.Error())
 	}
+	return fmt.Sprintf("ingestion failed for %d of %d days (succeeded: %s): %s",
+		len(e.Failed), len(e.Failed)+len(e.Succeeded), strings.Join(e.Succeeded, ", "), strings.Join(msgs, "; "))
+}
 
+// Unwrap exposes the per-file errors to errors.Is/As.
+func (e *PartialFailureError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, fe := range e.Failed {
+		errs = append(errs, fe.Err)
+	}
+	return errs
+}
+
+// fileOutcomes collects per-file results across workers.
+type fileOutcomes struct {
+	mu        sync.Mutex
+	succeeded []string
+	failed    []FileError
+}
+
+// record notes the outcome for path. It returns err unchanged (fail fast)
+// unless keepGoing is set, in which case failures are kept and nil is returned.
+func (o *fileOutcomes) record(path string, err error, keepGoing bool) error {
+	day := filepath.Base(path)
+	if d, ok := fileDay(path); ok {
+		day = d.Format("2006-01-02")
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err == nil {
+		o.succeeded = append(o.succeeded, day)
+		return nil
+	}
+	if !keepGoing {
+		return err
+	}
+	o.failed = append(o.failed, FileError{Day: day, Err: err})
 	return nil
 }
+
+// err returns a *PartialFailureError if any failure was kept, else nil.
+func (o *fileOutcomes) err() error {
+	if len(o.failed) == 0 {
+		return nil
+	}
+	sort.Strings(o.succeeded)
+	sort.Slice(o.failed, func(i, j int) bool { return o.failed[i].Day < o.failed[j].Day })
+	return &PartialFailureError{Succeeded: o.succeeded, Failed: o.failed}
+}
@@ -156,7 +156,7 @@ func TestIngestion_EndToEnd_ProcessDirectory(t *testing.T) {
 	// nDays=1 to only look for the single file we wrote
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
-	if err := ProcessDirectory(ctx, tdir, db, 1, 2, false); err != nil {
+	if err := ProcessDirectory(ctx, tdir, db, 1, 2, ProcessOptions{}); err != nil {
 		t.Fatalf("ProcessDirectory: %v", err)
 	}
 
@@ -5,6 +5,8 @@ package ingestion
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -12,7 +14,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/guttosm/b3pulse/config"
 	"github.com/guttosm/b3pulse/internal/domain/models"
+	"github.com/guttosm/b3pulse/internal/notify"
 	"github.com/guttosm/b3pulse/internal/storage"
 )
 
@@ -21,13 +25,44 @@ type fakeRepoIngestion struct {
 	has      map[time.Time]bool
 	inserted int
 	deleted  map[time.Time]bool
+	audits   []models.IngestionAudit
+	analyzed int
+	// checkpoints holds --resume progress per day
+	checkpoints map[time.Time]models.IngestionCheckpoint
+	// stored is the number of trades already in the table per day
+	stored map[time.Time]int64
+	// instruments collects UpsertInstruments batches
+	instruments [][]models.Instrument
+	// partitions lists the days EnsurePartitionForDate was called for
+	partitions []time.Time
 }
 
 func (f *fakeRepoIngestion) InsertTradesBatch(trades []models.Trade) error {
 	f.inserted += len(trades)
 	return nil
 }
-func (f *fakeRepoIngestion) GetAggregateByTicker(string, *time.Time, *time.Time) (*models.Aggregate, error) {
+func (f *fakeRepoIngestion) InsertTradesBatchWithCheckpoint(trades []models.Trade, cp models.IngestionCheckpoint) error {
+	if f.checkpoints == nil {
+		f.checkpoints = map[time.Time]models.IngestionCheckpoint{}
+	}
+	f.inserted += len(trades)
+	f.checkpoints[cp.FileDate] = cp
+	return nil
+}
+func (f *fakeRepoIngestion) GetIngestionCheckpoint(date time.Time) (*models.IngestionCheckpoint, error) {
+	if cp, ok := f.checkpoints[date]; ok {
+		return &cp, nil
+	}
+	return nil, nil
+}
+func (f *fakeRepoIngestion) DeleteIngestionCheckpoint(date time.Time) error {
+	delete(f.checkpoints, date)
+	return nil
+}
+func (f *fakeRepoIngestion) GetAggregateByTicker(context.Context, string, *time.Time, *time.Time, storage.AggregateOptions) (*models.Aggregate, error) {
+	return nil, nil
+}
+func (f *fakeRepoIngestion) GetDailyAggregate(string, time.Time) (*models.DailyAggregate, error) {
 	return nil, nil
 }
 func (f *fakeRepoIngestion) HasIngestionForDate(date time.Time) (bool, error) {
@@ -40,6 +75,9 @@ func (f *fakeRepoIngestion) UpsertIngestionLog(date time.Time, filename string,
 	f.has[date] = true
 	return nil
 }
+func (f *fakeRepoIngestion) CountTradesByDate(date time.Time) (int64, error) {
+	return f.stored[date], nil
+}
 func (f *fakeRepoIngestion) DeleteTradesByDate(date time.Time) error {
 	if f.deleted == nil {
 		f.deleted = map[time.Time]bool{}
@@ -48,6 +86,63 @@ func (f *fakeRepoIngestion) DeleteTradesByDate(date time.Time) error {
 	return nil
 }
 
+func (f *fakeRepoIngestion) RecordIngestionRun(audit models.IngestionAudit) error {
+	f.audits = append(f.audits, audit)
+	return nil
+}
+
+func (f *fakeRepoIngestion) TickerExists(string) (bool, error) { return false, nil }
+
+func (f *fakeRepoIngestion) GetLatestIngestion() (*models.IngestionLogEntry, error) {
+	return nil, nil
+}
+
+func (f *fakeRepoIngestion) GetParticipantActivity(string, *time.Time, *time.Time) ([]models.ParticipantActivity, error) {
+	return nil, nil
+}
+func (f *fakeRepoIngestion) StreamTradesByDate(context.Context, string, time.Time, func(models.Trade) error) error {
+	return nil
+}
+func (f *fakeRepoIngestion) GetAggregateBySession(string, *time.Time, *time.Time) (map[string]*models.Aggregate, error) {
+	return nil, nil
+}
+func (f *fakeRepoIngestion) ExplainAggregate(context.Context, string, *time.Time, *time.Time, storage.AggregateOptions) (json.RawMessage, error) {
+	return nil, nil
+}
+func (f *fakeRepoIngestion) GetDailyAggregates(string, time.Time, time.Time) ([]models.DailyAggregate, error) {
+	return nil, nil
+}
+func (f *fakeRepoIngestion) FindMissingIngestionDates(time.Time, time.Time) ([]time.Time, error) {
+	return nil, nil
+}
+func (f *fakeRepoIngestion) GetPriceSpread(string, *time.Time, *time.Time) (*models.PriceSpread, error) {
+	return nil, nil
+}
+func (f *fakeRepoIngestion) GetNotional(string, *time.Time, *time.Time) (*models.Notional, error) {
+	return nil, nil
+}
+func (f *fakeRepoIngestion) GetLatestPrice(string) (*models.LatestPrice, error) {
+	return nil, nil
+}
+func (f *fakeRepoIngestion) GetInstrument(string) (*models.Instrument, error) {
+	return nil, nil
+}
+func (f *fakeRepoIngestion) UpsertInstruments(in []models.Instrument) error {
+	f.instruments = append(f.instruments, append([]models.Instrument(nil), in...))
+	return nil
+}
+func (f *fakeRepoIngestion) GetCrossTrades(string, string, *time.Time, *time.Time, int, int) ([]models.CrossActivity, error) {
+	return nil, nil
+}
+func (f *fakeRepoIngestion) AnalyzeTrades(context.Context) error {
+	f.analyzed++
+	return nil
+}
+func (f *fakeRepoIngestion) EnsurePartitionForDate(date time.Time) error {
+	f.partitions = append(f.partitions, date)
+	return nil
+}
+
 // dummyDB satisfies *sql.DB usage but is nil internally; we never call db methods directly in tests due to repoCtor override.
 func dummyDB() *sql.DB { return (*sql.DB)(nil) }
 
@@ -78,17 +173,46 @@ func TestProcessDirectory_SkipIfAlreadyIngested(t *testing.T) {
 	fname := days[0].Format(fileDateLayout) + fileSuffix
 	writeFile(t, dir, fname, sampleFile())
 
-	fr := &fakeRepoIngestion{has: map[time.Time]bool{dayUTC: true}}
+	fr := &fakeRepoIngestion{has: map[time.Time]bool{dayUTC: true}, stored: map[time.Time]int64{dayUTC: 2}}
 	old := repoCtor
 	repoCtor = func(_ *sql.DB) storage.TradesRepository { return fr }
 	t.Cleanup(func() { repoCtor = old })
 
-	if err := ProcessDirectory(context.Background(), dir, dummyDB(), 1, runtime.NumCPU(), false); err != nil {
+	if err := ProcessDirectory(context.Background(), dir, dummyDB(), 1, runtime.NumCPU(), ProcessOptions{Analyze: true}); err != nil {
 		t.Fatalf("ProcessDirectory err: %v", err)
 	}
 	if fr.inserted != 0 {
 		t.Fatalf("expected no inserts when already ingested, got %d", fr.inserted)
 	}
+	if fr.analyzed != 0 {
+		t.Fatalf("expected no ANALYZE when nothing was loaded, got %d", fr.analyzed)
+	}
+}
+
+func TestProcessDirectory_LoggedDayWithoutTradesIsReprocessed(t *testing.T) {
+	dir := t.TempDir()
+	days := LastNBusinessDays(1, time.Now())
+	dayUTC := time.Date(days[0].Year(), days[0].Month(), days[0].Day(), 0, 0, 0, 0, time.UTC)
+	writeFile(t, dir, days[0].Format(fileDateLayout)+fileSuffix, sampleFile())
+
+	// ingestion_log has the day, but its trades were purged.
+	fr := &fakeRepoIngestion{has: map[time.Time]bool{dayUTC: true}}
+	old := repoCtor
+	repoCtor = func(_ *sql.DB) storage.TradesRepository { return fr }
+	t.Cleanup(func() { repoCtor = old })
+
+	if err := ProcessDirectory(context.Background(), dir, dummyDB(), 1, 1, ProcessOptions{}); err != nil {
+		t.Fatalf("ProcessDirectory err: %v", err)
+	}
+	if fr.inserted != 2 {
+		t.Fatalf("expected 2 inserted rows, got %d", fr.inserted)
+	}
+	if fr.deleted[dayUTC] {
+		t.Fatalf("expected no delete when there was nothing to delete")
+	}
+	if !fr.has[dayUTC] {
+		t.Fatalf("expected ingestion_log entry to be rewritten")
+	}
 }
 
 func TestProcessDirectory_ForceReprocess(t *testing.T) {
@@ -104,9 +228,12 @@ func TestProcessDirectory_ForceReprocess(t *testing.T) {
 	repoCtor = func(_ *sql.DB) storage.TradesRepository { return fr }
 	t.Cleanup(func() { repoCtor = old })
 
-	if err := ProcessDirectory(context.Background(), dir, dummyDB(), 1, 1, true); err != nil {
+	if err := ProcessDirectory(context.Background(), dir, dummyDB(), 1, 1, ProcessOptions{Force: true, Analyze: true}); err != nil {
 		t.Fatalf("ProcessDirectory err: %v", err)
 	}
+	if fr.analyzed != 1 {
+		t.Fatalf("expected one ANALYZE after reprocessing, got %d", fr.analyzed)
+	}
 	if !fr.deleted[dayUTC] {
 		t.Fatalf("expected delete for %v", dayUTC)
 	}
@@ -115,14 +242,109 @@ func TestProcessDirectory_ForceReprocess(t *testing.T) {
 	}
 }
 
+func TestProcessDirectory_Resume(t *testing.T) {
+	days := LastNBusinessDays(1, time.Now())
+	dayUTC := time.Date(days[0].Year(), days[0].Month(), days[0].Day(), 0, 0, 0, 0, time.UTC)
+	fname := days[0].Format(fileDateLayout) + fileSuffix
+
+	cases := []struct {
+		name        string
+		force       bool
+		wantInsert  int
+		wantDeleted bool
+	}{
+		{name: "continues after checkpoint", wantInsert: 1},
+		{name: "force starts over", force: true, wantInsert: 2, wantDeleted: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, fname, sampleFile())
+
+			// An earlier --resume run committed the first data row (line 2)
+			fr := &fakeRepoIngestion{checkpoints: map[time.Time]models.IngestionCheckpoint{
+				dayUTC: {FileDate: dayUTC, Filename: fname, LineNumber: 2, RowCount: 1},
+			}}
+			old := repoCtor
+			repoCtor = func(_ *sql.DB) storage.TradesRepository { return fr }
+			t.Cleanup(func() { repoCtor = old })
+
+			summary, err := ProcessDirectoryWithSummary(context.Background(), dir, dummyDB(), 1, 1, ProcessOptions{Force: tc.force, Resume: true})
+			if err != nil {
+				t.Fatalf("ProcessDirectory err: %v", err)
+			}
+			if fr.inserted != tc.wantInsert || fr.deleted[dayUTC] != tc.wantDeleted {
+				t.Fatalf("inserted=%d deleted=%v, want %d/%v", fr.inserted, fr.deleted[dayUTC], tc.wantInsert, tc.wantDeleted)
+			}
+			if summary.TotalRows != 2 || !fr.has[dayUTC] {
+				t.Fatalf("want day logged with 2 rows, got total=%d logged=%v", summary.TotalRows, fr.has[dayUTC])
+			}
+			if _, ok := fr.checkpoints[dayUTC]; ok {
+				t.Fatalf("checkpoint should be dropped once the day is logged")
+			}
+		})
+	}
+
+}
+
+func TestProcessDirectory_ForceDeleteGuard(t *testing.T) {
+	days := LastNBusinessDays(1, time.Now())
+	dayUTC := time.Date(days[0].Year(), days[0].Month(), days[0].Day(), 0, 0, 0, 0, time.UTC)
+	fname := days[0].Format(fileDateLayout) + fileSuffix
+
+	cases := []struct {
+		name          string
+		logged        bool
+		confirmDelete bool
+		wantErr       bool
+	}{
+		{name: "unlogged day is refused", wantErr: true},
+		{name: "unlogged day with confirm", confirmDelete: true},
+		{name: "logged day", logged: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, fname, sampleFile())
+
+			// Trades of the day exist, but without a checkpoint vouching for them.
+			fr := &fakeRepoIngestion{has: map[time.Time]bool{dayUTC: tc.logged}, stored: map[time.Time]int64{dayUTC: 5}}
+			old := repoCtor
+			repoCtor = func(_ *sql.DB) storage.TradesRepository { return fr }
+			t.Cleanup(func() { repoCtor = old })
+
+			err := ProcessDirectory(context.Background(), dir, dummyDB(), 1, 1, ProcessOptions{Force: true, Resume: true, ConfirmDelete: tc.confirmDelete})
+			if tc.wantErr {
+				if !errors.Is(err, ErrUnloggedDelete) {
+					t.Fatalf("want ErrUnloggedDelete, got %v", err)
+				}
+				if fr.deleted[dayUTC] || fr.inserted != 0 {
+					t.Fatalf("refused day must be left alone, deleted=%v inserted=%d", fr.deleted[dayUTC], fr.inserted)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProcessDirectory err: %v", err)
+			}
+			if !fr.deleted[dayUTC] || fr.inserted != 2 {
+				t.Fatalf("want day deleted and reloaded, deleted=%v inserted=%d", fr.deleted[dayUTC], fr.inserted)
+			}
+		})
+	}
+}
+
 // minimal fake repo to inject specific errors
 type errRepo struct {
-	hasErr    error
-	upsertErr error
+	hasErr       error
+	upsertErr    error
+	partitionErr error
 }
 
 func (e *errRepo) InsertTradesBatch([]models.Trade) error { return nil }
-func (e *errRepo) GetAggregateByTicker(string, *time.Time, *time.Time) (*models.Aggregate, error) {
+func (e *errRepo) GetAggregateByTicker(context.Context, string, *time.Time, *time.Time, storage.AggregateOptions) (*models.Aggregate, error) {
+	return nil, nil
+}
+func (e *errRepo) GetDailyAggregate(string, time.Time) (*models.DailyAggregate, error) {
 	return nil, nil
 }
 func (e *errRepo) HasIngestionForDate(time.Time) (bool, error) {
@@ -133,11 +355,62 @@ func (e *errRepo) HasIngestionForDate(time.Time) (bool, error) {
 }
 func (e *errRepo) UpsertIngestionLog(time.Time, string, int) error { return e.upsertErr }
 func (e *errRepo) DeleteTradesByDate(time.Time) error              { return nil }
+func (e *errRepo) CountTradesByDate(time.Time) (int64, error)      { return 0, nil }
+func (e *errRepo) InsertTradesBatchWithCheckpoint([]models.Trade, models.IngestionCheckpoint) error {
+	return nil
+}
+func (e *errRepo) GetIngestionCheckpoint(time.Time) (*models.IngestionCheckpoint, error) {
+	return nil, nil
+}
+func (e *errRepo) DeleteIngestionCheckpoint(time.Time) error      { return nil }
+func (e *errRepo) RecordIngestionRun(models.IngestionAudit) error { return nil }
+func (e *errRepo) TickerExists(string) (bool, error)              { return false, nil }
+func (e *errRepo) GetLatestIngestion() (*models.IngestionLogEntry, error) {
+	return nil, nil
+}
+func (e *errRepo) GetParticipantActivity(string, *time.Time, *time.Time) ([]models.ParticipantActivity, error) {
+	return nil, nil
+}
+func (e *errRepo) StreamTradesByDate(context.Context, string, time.Time, func(models.Trade) error) error {
+	return nil
+}
+func (e *errRepo) AnalyzeTrades(context.Context) error { return nil }
+func (e *errRepo) GetAggregateBySession(string, *time.Time, *time.Time) (map[string]*models.Aggregate, error) {
+	return nil, nil
+}
+func (e *errRepo) ExplainAggregate(context.Context, string, *time.Time, *time.Time, storage.AggregateOptions) (json.RawMessage, error) {
+	return nil, nil
+}
+func (e *errRepo) GetDailyAggregates(string, time.Time, time.Time) ([]models.DailyAggregate, error) {
+	return nil, nil
+}
+func (e *errRepo) FindMissingIngestionDates(time.Time, time.Time) ([]time.Time, error) {
+	return nil, nil
+}
+func (e *errRepo) GetPriceSpread(string, *time.Time, *time.Time) (*models.PriceSpread, error) {
+	return nil, nil
+}
+func (e *errRepo) GetNotional(string, *time.Time, *time.Time) (*models.Notional, error) {
+	return nil, nil
+}
+func (e *errRepo) GetLatestPrice(string) (*models.LatestPrice, error) {
+	return nil, nil
+}
+func (e *errRepo) GetInstrument(string) (*models.Instrument, error) { return nil, nil }
+func (e *errRepo) UpsertInstruments([]models.Instrument) error      { return e.upsertErr }
+func (e *errRepo) EnsurePartitionForDate(time.Time) error           { return e.partitionErr }
+func (e *errRepo) GetCrossTrades(string, string, *time.Time, *time.Time, int, int) ([]models.CrossActivity, error) {
+	return nil, nil
+}
 
 func TestProcessDirectory_MissingFiles(t *testing.T) {
 	dir := t.TempDir()
+	old := repoCtor
+	repoCtor = func(_ *sql.DB) storage.TradesRepository { return &fakeRepoIngestion{} }
+	t.Cleanup(func() { repoCtor = old })
+
 	// no files created => should report missing
-	err := ProcessDirectory(context.Background(), dir, (*sql.DB)(nil), 1, runtime.NumCPU(), false)
+	err := ProcessDirectory(context.Background(), dir, (*sql.DB)(nil), 1, runtime.NumCPU(), ProcessOptions{})
 	if err == nil || !strings.Contains(err.Error(), "missing required files") {
 		t.Fatalf("expected missing files error, got %v", err)
 	}
@@ -159,11 +432,49 @@ func TestProcessDirectory_HasIngestionError(t *testing.T) {
 	repoCtor = func(_ *sql.DB) storage.TradesRepository { return &errRepo{hasErr: context.DeadlineExceeded} }
 	t.Cleanup(func() { repoCtor = old })
 
-	if err := ProcessDirectory(context.Background(), dir, (*sql.DB)(nil), 1, 1, false); err == nil {
+	if err := ProcessDirectory(context.Background(), dir, (*sql.DB)(nil), 1, 1, ProcessOptions{}); err == nil {
 		t.Fatalf("expected error from HasIngestionForDate")
 	}
 }
 
+func TestProcessDirectory_PartitionMonthly(t *testing.T) {
+	dir := t.TempDir()
+	days := LastNBusinessDays(1, time.Now())
+	dayUTC := time.Date(days[0].Year(), days[0].Month(), days[0].Day(), 0, 0, 0, 0, time.UTC)
+	writeFile(t, dir, days[0].Format(fileDateLayout)+fileSuffix, sampleFile())
+
+	oldRepo, oldCfg := repoCtor, config.AppConfig
+	t.Cleanup(func() { repoCtor, config.AppConfig = oldRepo, oldCfg })
+
+	// Off by default: no partition is touched.
+	fr := &fakeRepoIngestion{}
+	repoCtor = func(_ *sql.DB) storage.TradesRepository { return fr }
+	if err := ProcessDirectory(context.Background(), dir, dummyDB(), 1, 1, ProcessOptions{}); err != nil {
+		t.Fatalf("ProcessDirectory err: %v", err)
+	}
+	if len(fr.partitions) != 0 {
+		t.Fatalf("expected no partition calls, got %v", fr.partitions)
+	}
+
+	config.AppConfig.Ingest.PartitionMonthly = true
+	fr = &fakeRepoIngestion{}
+	if err := ProcessDirectory(context.Background(), dir, dummyDB(), 1, 1, ProcessOptions{}); err != nil {
+		t.Fatalf("ProcessDirectory err: %v", err)
+	}
+	if len(fr.partitions) != 1 || !fr.partitions[0].Equal(dayUTC) || fr.inserted != 2 {
+		t.Fatalf("expected partition for %v before 2 inserts, got %v inserted=%d", dayUTC, fr.partitions, fr.inserted)
+	}
+
+	// A failure fails the file before anything is inserted.
+	repoCtor = func(_ *sql.DB) storage.TradesRepository {
+		return &errRepo{partitionErr: errors.New("trades is not partitioned")}
+	}
+	err := ProcessDirectory(context.Background(), dir, dummyDB(), 1, 1, ProcessOptions{})
+	if err == nil || !strings.Contains(err.Error(), "ensure partition") {
+		t.Fatalf("expected ensure partition error, got %v", err)
+	}
+}
+
 func TestProcessDirectory_UpsertLogError(t *testing.T) {
 	dir := t.TempDir()
 	d := LastNBusinessDays(1, time.Now())[0]
@@ -180,7 +491,282 @@ func TestProcessDirectory_UpsertLogError(t *testing.T) {
 	repoCtor = func(_ *sql.DB) storage.TradesRepository { return &errRepo{upsertErr: context.Canceled} }
 	t.Cleanup(func() { repoCtor = old })
 
-	if err := ProcessDirectory(context.Background(), dir, (*sql.DB)(nil), 1, 1, false); err == nil {
+	if err := ProcessDirectory(context.Background(), dir, (*sql.DB)(nil), 1, 1, ProcessOptions{}); err == nil {
 		t.Fatalf("expected error from UpsertIngestionLog")
 	}
 }
+
+func TestProcessDirectory_RecordsAudit(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dir := t.TempDir()
+		day := LastNBusinessDays(1, time.Now())[0]
+		writeFile(t, dir, day.Format(fileDateLayout)+fileSuffix, sampleFile())
+
+		fr := &fakeRepoIngestion{}
+		old := repoCtor
+		repoCtor = func(_ *sql.DB) storage.TradesRepository { return fr }
+		t.Cleanup(func() { repoCtor = old })
+
+		if err := ProcessDirectory(context.Background(), dir, dummyDB(), 1, 1, ProcessOptions{Force: true}); err != nil {
+			t.Fatalf("ProcessDirectory err: %v", err)
+		}
+		if len(fr.audits) != 1 {
+			t.Fatalf("expected 1 audit row, got %d", len(fr.audits))
+		}
+		a := fr.audits[0]
+		if !a.Success || !a.Force || a.FilesProcessed != 1 || a.TotalRows != 2 || len(a.Errors) != 0 {
+			t.Fatalf("unexpected audit: %+v", a)
+		}
+		if a.RunID == "" || a.Mode != auditModeWindow || len(a.RequestedDates) != 1 || a.FinishedAt.Before(a.StartedAt) {
+			t.Fatalf("unexpected audit metadata: %+v", a)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		dir := t.TempDir() // no files => missing required files
+
+		fr := &fakeRepoIngestion{}
+		old := repoCtor
+		repoCtor = func(_ *sql.DB) storage.TradesRepository { return fr }
+		t.Cleanup(func() { repoCtor = old })
+
+		if err := ProcessDirectory(context.Background(), dir, dummyDB(), 1, 1, ProcessOptions{}); err == nil {
+			t.Fatalf("expected error")
+		}
+		if len(fr.audits) != 1 {
+			t.Fatalf("expected 1 audit row, got %d", len(fr.audits))
+		}
+		a := fr.audits[0]
+		if a.Success || len(a.Errors) != 1 || !strings.Contains(a.Errors[0], "missing required files") {
+			t.Fatalf("unexpected audit: %+v", a)
+		}
+	})
+}
+
+func TestProcessDirectory_MaxFileBytes(t *testing.T) {
+	dir := t.TempDir()
+	day := LastNBusinessDays(1, time.Now())[0]
+	writeFile(t, dir, day.Format(fileDateLayout)+fileSuffix, sampleFile())
+
+	fr := &fakeRepoIngestion{}
+	oldRepo := repoCtor
+	repoCtor = func(_ *sql.DB) storage.TradesRepository { return fr }
+	oldCfg := config.AppConfig
+	t.Cleanup(func() {
+		repoCtor = oldRepo
+		config.AppConfig = oldCfg
+	})
+
+	// Limit below the sample file size => fail fast, nothing inserted
+	config.AppConfig.Ingest.MaxFileBytes = 16
+	err := ProcessDirectory(context.Background(), dir, dummyDB(), 1, 1, ProcessOptions{})
+	if err == nil || !strings.Contains(err.Error(), "exceeds INGEST_MAX_FILE_BYTES") {
+		t.Fatalf("expected size limit error, got %v", err)
+	}
+	if fr.inserted != 0 {
+		t.Fatalf("expected no inserts, got %d", fr.inserted)
+	}
+
+	// Generous limit => processed normally
+	config.AppConfig.Ingest.MaxFileBytes = 1 << 20
+	if err := ProcessDirectory(context.Background(), dir, dummyDB(), 1, 1, ProcessOptions{}); err != nil {
+		t.Fatalf("ProcessDirectory err: %v", err)
+	}
+	if fr.inserted != 2 {
+		t.Fatalf("expected 2 inserted rows, got %d", fr.inserted)
+	}
+}
+
+func TestProcessDirectory_MinRows(t *testing.T) {
+	dir := t.TempDir()
+	day := LastNBusinessDays(1, time.Now())[0]
+	dayUTC := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	writeFile(t, dir, day.Format(fileDateLayout)+fileSuffix, sampleFile()) // 2 data rows
+
+	oldRepo, oldCfg := repoCtor, config.AppConfig
+	t.Cleanup(func() {
+		repoCtor = oldRepo
+		config.AppConfig = oldCfg
+	})
+
+	cases := []struct {
+		name    string
+		minRows int
+		wantErr bool
+	}{
+		{name: "disabled", minRows: 0},
+		{name: "at threshold", minRows: 2},
+		{name: "below threshold", minRows: 3, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fr := &fakeRepoIngestion{}
+			repoCtor = func(_ *sql.DB) storage.TradesRepository { return fr }
+			config.AppConfig.Ingest.MinRows = tc.minRows
+
+			err := ProcessDirectory(context.Background(), dir, dummyDB(), 1, 1, ProcessOptions{})
+			if !tc.wantErr {
+				if err != nil || !fr.has[dayUTC] {
+					t.Fatalf("expected success with ingestion log, got err=%v has=%v", err, fr.has)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "has 2 data rows, below INGEST_MIN_ROWS=3") {
+				t.Fatalf("expected min rows error, got %v", err)
+			}
+			if fr.has[dayUTC] || !fr.deleted[dayUTC] {
+				t.Fatalf("short file must be rolled back without a log entry: has=%v deleted=%v", fr.has, fr.deleted)
+			}
+		})
+	}
+}
+
+func TestProcessDirectory_AllowMissing(t *testing.T) {
+	days := LastNBusinessDays(2, time.Now())
+
+	cases := []struct {
+		name         string
+		present      []time.Time
+		allowMissing bool
+		wantErr      bool
+		wantFiles    int
+	}{
+		{name: "one missing, strict", present: days[:1], allowMissing: false, wantErr: true},
+		{name: "one missing, allowed", present: days[:1], allowMissing: true, wantFiles: 1},
+		{name: "all missing, allowed", present: nil, allowMissing: true, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, d := range tc.present {
+				writeFile(t, dir, d.Format(fileDateLayout)+fileSuffix, sampleFile())
+			}
+
+			fr := &fakeRepoIngestion{}
+			old := repoCtor
+			repoCtor = func(_ *sql.DB) storage.TradesRepository { return fr }
+			t.Cleanup(func() { repoCtor = old })
+
+			err := ProcessDirectory(context.Background(), dir, dummyDB(), 2, 1, ProcessOptions{AllowMissing: tc.allowMissing})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err=%v, wantErr=%v", err, tc.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "missing required files") {
+				t.Fatalf("expected missing files error, got %v", err)
+			}
+			if len(fr.audits) != 1 || fr.audits[0].FilesProcessed != tc.wantFiles {
+				t.Fatalf("unexpected audit: %+v", fr.audits)
+			}
+		})
+	}
+}
+
+// recordingNotifier captures notifications and optionally fails.
+type recordingNotifier struct {
+	audits []models.IngestionAudit
+	err    error
+}
+
+func (r *recordingNotifier) NotifyIngestion(_ context.Context, audit models.IngestionAudit) error {
+	r.audits = append(r.audits, audit)
+	return r.err
+}
+
+func TestProcessDirectory_NotifiesCompletion(t *testing.T) {
+	cases := []struct {
+		name        string
+		withFile    bool
+		notifyErr   error
+		wantSuccess bool
+	}{
+		{name: "success", withFile: true, wantSuccess: true},
+		{name: "failure", withFile: false, wantSuccess: false},
+		{name: "notifier error does not fail run", withFile: true, notifyErr: errors.New("webhook down"), wantSuccess: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.withFile {
+				day := LastNBusinessDays(1, time.Now())[0]
+				writeFile(t, dir, day.Format(fileDateLayout)+fileSuffix, sampleFile())
+			}
+
+			oldRepo, oldNotifier := repoCtor, notifierCtor
+			rn := &recordingNotifier{err: tc.notifyErr}
+			repoCtor = func(_ *sql.DB) storage.TradesRepository { return &fakeRepoIngestion{} }
+			notifierCtor = func() notify.Notifier { return rn }
+			t.Cleanup(func() { repoCtor, notifierCtor = oldRepo, oldNotifier })
+
+			err := ProcessDirectory(context.Background(), dir, dummyDB(), 1, 1, ProcessOptions{})
+			if (err == nil) != tc.wantSuccess {
+				t.Fatalf("err=%v, wantSuccess=%v", err, tc.wantSuccess)
+			}
+			if len(rn.audits) != 1 || rn.audits[0].Success != tc.wantSuccess {
+				t.Fatalf("unexpected notifications: %+v", rn.audits)
+			}
+		})
+	}
+}
+
+func TestProcessDirectory_ContinueOnError(t *testing.T) {
+	days := LastNBusinessDays(3, time.Now())
+	bad := days[1]
+
+	cases := []struct {
+		name            string
+		continueOnError bool
+		wantFiles       int
+	}{
+		{name: "fail fast by default", continueOnError: false},
+		{name: "continue past failures", continueOnError: true, wantFiles: 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, d := range days {
+				content := sampleFile()
+				if d.Equal(bad) {
+					content = "X;Y;Z\n" // invalid header
+				}
+				writeFile(t, dir, d.Format(fileDateLayout)+fileSuffix, content)
+			}
+
+			fr := &fakeRepoIngestion{}
+			old := repoCtor
+			repoCtor = func(_ *sql.DB) storage.TradesRepository { return fr }
+			t.Cleanup(func() { repoCtor = old })
+
+			// parallel=1 so the bad day is processed before later files in fail-fast mode.
+			err := ProcessDirectory(context.Background(), dir, dummyDB(), 3, 1, ProcessOptions{ContinueOnError: tc.continueOnError})
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+
+			var partial *PartialFailureError
+			if !tc.continueOnError {
+				if errors.As(err, &partial) {
+					t.Fatalf("fail-fast run should not return a partial failure: %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &partial) {
+				t.Fatalf("expected *PartialFailureError, got %T: %v", err, err)
+			}
+			badDay := bad.Format("2006-01-02")
+			wantOK := []string{days[2].Format("2006-01-02"), days[0].Format("2006-01-02")}
+			if len(partial.Failed) != 1 || partial.Failed[0].Day != badDay || !strings.Contains(partial.Failed[0].Err.Error(), "invalid header") {
+				t.Fatalf("unexpected failures: %+v", partial.Failed)
+			}
+			if len(partial.Succeeded) != 2 || partial.Succeeded[0] != wantOK[0] || partial.Succeeded[1] != wantOK[1] {
+				t.Fatalf("succeeded=%v, want %v", partial.Succeeded, wantOK)
+			}
+			if !strings.Contains(err.Error(), "1 of 3 days") {
+				t.Fatalf("unexpected summary: %v", err)
+			}
+			a := fr.audits[0]
+			if a.Success || a.FilesProcessed != tc.wantFiles || len(a.Errors) != 1 || !strings.HasPrefix(a.Errors[0], badDay) {
+				t.Fatalf("unexpected audit: %+v", a)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package ingestion
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/guttosm/b3pulse/config"
+	"github.com/guttosm/b3pulse/internal/domain/models"
+	"github.com/guttosm/b3pulse/internal/logger"
+)
+
+// Columns read from B3's instruments reference file ("InstrumentsConsolidatedFile",
+// cadastro de instrumentos). The file has many more; they are matched by name.
+const (
+	instrumentCodeColumn = "TckrSymb"
+	instrumentISINColumn = "ISIN"
+	instrumentNameColumn = "CrpnNm"
+)
+
+// maxInstrumentPreambleLines bounds the lines skipped before the header; some
+// exports start with a status line such as "Status do Arquivo: Final".
+const maxInstrumentPreambleLines = 5
+
+// parseInstruments reads a semicolon-separated instruments reference file.
+// The header is located by its TckrSymb column (after an optional UTF-8 BOM
+// and a short preamble) and must also name ISIN and CrpnNm; column order is
+// free. Rows without a ticker are skipped, codes are trimmed and upper-cased,
+// and a ticker listed twice keeps its last row. The result is sorted by code.
+func parseInstruments(in io.Reader) ([]models.Instrument, error) {
+	r := newTradeReader(in, config.AppConfig.Ingest.ReadBufferBytes)
+
+	var code, isin, name int
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("invalid instruments file: no header with column %q", instrumentCodeColumn)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read header: %w", err)
+		}
+		if len(rec) > 0 {
+			rec[0] = strings.TrimPrefix(rec[0], utf8BOM)
+		}
+		pos := make(map[string]int, len(rec))
+		for i, h := range rec {
+			pos[strings.TrimSpace(h)] = i
+		}
+		if _, ok := pos[instrumentCodeColumn]; ok {
+			cols := make([]int, 0, 3)
+			for _, col := range []string{instrumentCodeColumn, instrumentISINColumn, instrumentNameColumn} {
+				idx, ok := pos[col]
+				if !ok {
+					return nil, fmt.Errorf("invalid instruments header: missing column %q", col)
+				}
+				cols = append(cols, idx)
+			}
+			code, isin, name = cols[0], cols[1], cols[2]
+			break
+		}
+		if line >= maxInstrumentPreambleLines {
+			return nil, fmt.Errorf("invalid instruments file: no header with column %q in the first %d lines", instrumentCodeColumn, maxInstrumentPreambleLines)
+		}
+	}
+
+	byCode := map[string]models.Instrument{}
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read row: %w", err)
+		}
+		field := func(i int) string {
+			if i >= len(rec) {
+				return ""
+			}
+			return strings.TrimSpace(rec[i])
+		}
+		c := strings.ToUpper(field(code))
+		if c == "" {
+			continue
+		}
+		// Clone, since the reader reuses the record's backing storage.
+		byCode[c] = models.Instrument{Code: strings.Clone(c), ISIN: strings.Clone(field(isin)), Name: strings.Clone(field(name))}
+	}
+
+	out := make([]models.Instrument, 0, len(byCode))
+	for _, in := range byCode {
+		out = append(out, in)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out, nil
+}
+
+// LoadInstruments parses B3's instruments reference file at path (see
+// parseInstruments) and upserts it into the instruments table in batches, so
+// aggregates requested with ?enrich=true can include the ISIN and company name.
+// It is independent of trade ingestion and may be rerun with a newer file.
+//
+// Returns the number of instruments written.
+func LoadInstruments(ctx context.Context, path string, db *sql.DB) (int, error) {
+	f, err := fileSource.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("open: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	instruments, err := parseInstruments(f)
+	if err != nil {
+		return 0, fmt.Errorf("file %s: %w", path, err)
+	}
+
+	repo := repoCtor(db)
+	for start := 0; start < len(instruments); start += defaultBatchSize {
+		if err := ctx.Err(); err != nil {
+			return start, err
+		}
+		end := min(start+defaultBatchSize, len(instruments))
+		if err := repo.UpsertInstruments(instruments[start:end]); err != nil {
+			return start, fmt.Errorf("file %s: upsert instruments: %w", path, err)
+		}
+	}
+	logger.L().Info().Str("file", filepath.Base(path)).Int("instruments", len(instruments)).Msg("instruments loaded")
+	return len(instruments), nil
+}
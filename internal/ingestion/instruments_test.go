@@ -0,0 +1,93 @@
+package ingestion
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/guttosm/b3pulse/internal/domain/models"
+	"github.com/guttosm/b3pulse/internal/storage"
+)
+
+func TestParseInstruments(t *testing.T) {
+	cases := []struct {
+		name    string
+		in      string
+		want    []models.Instrument
+		wantErr string
+	}{
+		{
+			name: "reference layout",
+			in: utf8BOM + "RptDt;TckrSymb;Asst;SctyCtgyNm;CrpnNm;ISIN\n" +
+				"2025-09-12;PETR4;PETR;SHARES;PETROLEO BRASILEIRO S.A. PETROBRAS;BRPETRACNPR6\n" +
+				"2025-09-12; vale3 ;VALE;SHARES;VALE S.A.;BRVALEACNOR0\n",
+			want: []models.Instrument{
+				{Code: "PETR4", ISIN: "BRPETRACNPR6", Name: "PETROLEO BRASILEIRO S.A. PETROBRAS"},
+				{Code: "VALE3", ISIN: "BRVALEACNOR0", Name: "VALE S.A."},
+			},
+		},
+		{
+			name: "status preamble, blank code and duplicate",
+			in: "Status do Arquivo: Final\n" +
+				"TckrSymb;CrpnNm;ISIN\n" +
+				";ORPHAN;BR0000000000\n" +
+				"ITUB4;OLD NAME;BRITUBACNPR1\n" +
+				"ITUB4;ITAU UNIBANCO HOLDING S.A.;BRITUBACNPR1\n",
+			want: []models.Instrument{{Code: "ITUB4", ISIN: "BRITUBACNPR1", Name: "ITAU UNIBANCO HOLDING S.A."}},
+		},
+		{name: "missing column", in: "TckrSymb;CrpnNm\nPETR4;PETROBRAS\n", wantErr: `missing column "ISIN"`},
+		{name: "no header", in: "a\nb\nc\nd\ne\nf\n", wantErr: "no header"},
+		{name: "empty", in: "", wantErr: "no header"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseInstruments(strings.NewReader(tc.in))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("want error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("want %d instruments, got %+v", len(tc.want), got)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("instrument %d: want %+v, got %+v", i, tc.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadInstruments(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "instruments.csv", "TckrSymb;ISIN;CrpnNm\nPETR4;BRPETRACNPR6;PETROBRAS\nVALE3;BRVALEACNOR0;VALE\n")
+
+	fr := &fakeRepoIngestion{}
+	old := repoCtor
+	repoCtor = func(_ *sql.DB) storage.TradesRepository { return fr }
+	t.Cleanup(func() { repoCtor = old })
+
+	n, err := LoadInstruments(context.Background(), filepath.Join(dir, "instruments.csv"), dummyDB())
+	if err != nil || n != 2 {
+		t.Fatalf("want 2 instruments, got n=%d err=%v", n, err)
+	}
+	if len(fr.instruments) != 1 || len(fr.instruments[0]) != 2 || fr.instruments[0][0].Code != "PETR4" {
+		t.Fatalf("unexpected upserts: %+v", fr.instruments)
+	}
+
+	if _, err := LoadInstruments(context.Background(), filepath.Join(dir, "missing.csv"), dummyDB()); err == nil {
+		t.Fatalf("expected error for a missing file")
+	}
+
+	writeFile(t, dir, "bad.csv", "TckrSymb;ISIN\nPETR4;BRPETRACNPR6\n")
+	if _, err := LoadInstruments(context.Background(), filepath.Join(dir, "bad.csv"), dummyDB()); err == nil {
+		t.Fatalf("expected error for a header without CrpnNm")
+	}
+}
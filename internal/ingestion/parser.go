@@ -1,16 +1,20 @@
 package ingestion
 
 import (
+	"bufio"
 	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
-	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/guttosm/b3pulse/config"
 	"github.com/guttosm/b3pulse/internal/domain/models"
+	"github.com/guttosm/b3pulse/internal/instrument"
 	"github.com/guttosm/b3pulse/internal/storage"
 )
 
@@ -30,66 +34,343 @@ var expectedHeaders = []string{
 	"CodigoParticipanteVendedor",
 }
 
+// columnMap holds, for each expectedHeaders position, the index of that column
+// in the file. It is used with INGEST_COLUMNS_BY_NAME, where the header may
+// list the expected columns in any order, alongside extra ones.
+type columnMap []int
+
+// newColumnMap builds the columnMap for header, which must name every expected
+// column exactly once. It does not retain header.
+func newColumnMap(header []string) (columnMap, error) {
+	pos := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		if _, dup := pos[h]; dup {
+			return nil, fmt.Errorf("invalid header: duplicate column %q", h)
+		}
+		pos[h] = i
+	}
+	m := make(columnMap, len(expectedHeaders))
+	for i, name := range expectedHeaders {
+		idx, ok := pos[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid header: missing column %q", name)
+		}
+		m[i] = idx
+	}
+	return m, nil
+}
+
+// reorder copies the fields of rec into dst in expectedHeaders order, so the
+// result can be passed to recordToTrade. dst must have len(expectedHeaders).
+func (m columnMap) reorder(rec []string, dst []string) []string {
+	for i, idx := range m {
+		dst[i] = rec[idx]
+	}
+	return dst
+}
+
+// flushNow is an indirection for the current time used by INGEST_FLUSH_INTERVAL;
+// tests override it.
+var flushNow = time.Now
+
+// defaultReadBufferBytes is the read buffer used when INGEST_READ_BUFFER_BYTES is unset.
+const defaultReadBufferBytes = 64 * 1024
+
+// utf8BOM is the byte order mark some editors prepend to UTF-8 files.
+const utf8BOM = "\ufeff"
+
+// isBlankRecord reports whether rec is a whitespace-only line, which the CSV
+// reader returns as a single field (fully empty lines are skipped by it).
+func isBlankRecord(rec []string) bool {
+	return len(rec) == 1 && strings.TrimSpace(rec[0]) == ""
+}
+
+// newTradeReader builds the CSV reader for a B3 trades file.
+//
+// The input is wrapped in a bufio.Reader of bufSize bytes (default 64KB when
+// bufSize <= 0) to cut read syscalls on slow or network-mounted storage, and
+// ReuseRecord is enabled to avoid allocating a new slice per row. Callers must
+// not keep the returned record slice across Read calls.
+func newTradeReader(in io.Reader, bufSize int) *csv.Reader {
+	if bufSize <= 0 {
+		bufSize = defaultReadBufferBytes
+	}
+	r := csv.NewReader(bufio.NewReaderSize(in, bufSize))
+	r.Comma = ';'
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1 // allow variable but we’ll check explicitly
+	r.ReuseRecord = true
+	return r
+}
+
+// tradeBufPool recycles batch buffers across files so concurrent workers do not
+// each allocate a fresh batch-sized slice per file. It holds *[]models.Trade.
+var tradeBufPool sync.Pool
+
+// getTradeBuf returns an empty buffer with capacity of at least batch.
+func getTradeBuf(batch int) *[]models.Trade {
+	if p, ok := tradeBufPool.Get().(*[]models.Trade); ok && cap(*p) >= batch {
+		return p
+	}
+	b := make([]models.Trade, 0, batch)
+	return &b
+}
+
+// putTradeBuf zeroes the buffer (so pooled memory does not pin parsed strings)
+// and returns it to the pool. Repositories must not retain batches passed to
+// InsertTradesBatch.
+func putTradeBuf(p *[]models.Trade) {
+	clear((*p)[:cap(*p)])
+	*p = (*p)[:0]
+	tradeBufPool.Put(p)
+}
+
+// batchLimiter caps how many trade batches are buffered at once across all
+// files, so memory is bounded by slots × batch size rather than by parallelism.
+// A slot is held from the first row of a batch until it is inserted. A nil
+// limiter is unlimited.
+type batchLimiter chan struct{}
+
+// newBatchLimiter returns a limiter with n slots, or nil (unlimited) when n <= 0.
+func newBatchLimiter(n int) batchLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return make(batchLimiter, n)
+}
+
+// acquire blocks until a slot is free or ctx is done.
+func (l batchLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot taken by acquire.
+func (l batchLimiter) release() {
+	if l != nil {
+		<-l
+	}
+}
+
+// maxInternedStrings bounds the per-file interner; beyond it values are kept as-is.
+const maxInternedStrings = 16384
+
+// stringInterner deduplicates low-cardinality columns within one file, so every
+// row of a ticker shares one copy of its code instead of a substring pinning the
+// whole CSV line it was read from.
+type stringInterner map[string]string
+
+func (in stringInterner) intern(s string) string {
+	if v, ok := in[s]; ok {
+		return v
+	}
+	if len(in) >= maxInternedStrings {
+		return s
+	}
+	v := strings.Clone(s)
+	in[v] = v
+	return v
+}
+
+// internTrade interns the repeated columns of t in place.
+func (in stringInterner) internTrade(t *models.Trade) {
+	t.InstrumentCode = in.intern(t.InstrumentCode)
+	t.UpdateAction = in.intern(t.UpdateAction)
+	t.SessionType = in.intern(t.SessionType)
+	t.BuyerParticipantCode = in.intern(t.BuyerParticipantCode)
+	t.SellerParticipantCode = in.intern(t.SellerParticipantCode)
+}
+
+// tradeKey identifies a trade within a file for INGEST_DEDUP.
+type tradeKey struct {
+	instrument string
+	tradeDate  int64 // Unix seconds of TradeDate
+	identifier string
+}
+
+// dedupSet tracks the trade keys seen in one file. It is only allocated when
+// INGEST_DEDUP is enabled, since it grows with the number of rows.
+type dedupSet map[tradeKey]struct{}
+
+// seen records t and reports whether an identical key was already recorded.
+// Rows without a trade identifier are never considered duplicates.
+func (s dedupSet) seen(t *models.Trade) bool {
+	if t.TradeIdentifierCode == "" {
+		return false
+	}
+	k := tradeKey{instrument: t.InstrumentCode, tradeDate: t.TradeDate.Unix(), identifier: t.TradeIdentifierCode}
+	if _, ok := s[k]; ok {
+		return true
+	}
+	s[k] = struct{}{}
+	return false
+}
+
+// fileStats counts what happened to the rows of one file.
+type fileStats struct {
+	Rows       int // rows persisted
+	Duplicates int // rows dropped by INGEST_DEDUP
+	Filtered   int // rows skipped by INGEST_INSTRUMENT_FILTER
+	// DateMismatches counts rows whose trade date differs from the filename's
+	// day (INGEST_VALIDATE_DATES=warn); they are still persisted.
+	DateMismatches int
+}
+
+// dataRows is the number of data rows read from the file, kept or not.
+func (s fileStats) dataRows() int { return s.Rows + s.Duplicates + s.Filtered }
+
+// fileNameFor returns the input file name expected for day d,
+// "DD-MM-YYYY_NEGOCIOSAVISTA.txt"; fileDay is its inverse.
+func fileNameFor(d time.Time) string {
+	return d.Format(fileDateLayout) + fileSuffix
+}
+
+// fileDay returns the business day encoded in a "DD-MM-YYYY_NEGOCIOSAVISTA.txt"
+// path, or false when the name does not follow that layout.
+func fileDay(path string) (time.Time, bool) {
+	d, err := time.Parse(fileDateLayout, strings.TrimSuffix(filepath.Base(path), fileSuffix))
+	return d, err == nil
+}
+
 // parseAndPersistFile opens, validates, parses, and persists one file in batches.
+// Rows whose instrument is rejected by INGEST_INSTRUMENT_FILTER are skipped
+// before parsing, and when INGEST_DEDUP is enabled duplicate rows are dropped;
+// both are counted in the returned fileStats. With INGEST_VALIDATE_DATES each
+// row's trade date is compared with the day in the filename (catching files
+// renamed to the wrong day): "warn" counts mismatches, "strict" fails the file.
+// Dedup only covers a single file;
+// duplicates across files must be rejected by a database unique constraint on
+// the same key, which the schema does not define yet.
+//
+// With a non-nil cp (--resume) every batch is committed together with an
+// updated checkpoint, and lines up to cp.LineNumber are read and validated but
+// not inserted again. This relies on batches being inserted strictly in file
+// order, one at a time, so every line before the checkpoint is committed; it
+// also assumes the file is unchanged since the checkpoint was written.
+//
 // It fails on:
-//   - header not matching expected order/length
-//   - unrecoverable I/O errors
+//   - header not matching expected order/length; with INGEST_COLUMNS_BY_NAME, a header
+//     missing or repeating an expected name (order and extra columns are then free)
+//   - unrecoverable I/O errors; transient ones while opening the file or reading
+//     its header are first retried up to INGEST_OPEN_RETRIES times (see openWithHeader)
 //
 // It tolerates:
 //   - empty cells (they become zero values)
+//   - a leading UTF-8 BOM and blank (whitespace-only) lines at the end of the
+//     file, both left behind by Windows/Excel re-saves; a blank line followed
+//     by more data still fails the column count check
 //
 // Parameters:
 //   - ctx:    context for cancellation/timeouts.
 //   - path:   file path.
-//   - repo:   repository for DB insertion.
+//   - repo:   repository for DB insertion (only writes are needed).
 //   - batch:  batch size for inserts (e.g., 5000).
-func parseAndPersistFile(ctx context.Context, path string, repo storage.TradesRepository, batch int) (int, error) {
-	f, err := os.Open(path)
+//   - limiter: caps buffered batches across concurrent files (nil = unlimited).
+//   - cp:     checkpoint to resume from (nil = no checkpointing).
+func parseAndPersistFile(ctx context.Context, path string, repo storage.TradeWriter, batch int, limiter batchLimiter, cp *models.IngestionCheckpoint) (stats fileStats, err error) {
+	f, r, header, err := openWithHeader(ctx, path, config.AppConfig.Ingest.ReadBufferBytes, config.AppConfig.Ingest.OpenRetries)
 	if err != nil {
-		return 0, fmt.Errorf("open: %w", err)
+		return fileStats{}, err
 	}
 	defer func() { _ = f.Close() }()
 
-	r := csv.NewReader(f)
-	r.Comma = ';'
-	r.LazyQuotes = true
-	r.FieldsPerRecord = -1 // allow variable but we’ll check explicitly
-
-	// Validate headers strictly.
-	header, err := r.Read()
-	if err != nil {
-		return 0, fmt.Errorf("read header: %w", err)
-	}
-	if len(header) != len(expectedHeaders) {
-		return 0, fmt.Errorf("invalid header length: expected %d, got %d", len(expectedHeaders), len(header))
-	}
-	for i, h := range header {
-		if strings.TrimSpace(h) != expectedHeaders[i] {
-			return 0, fmt.Errorf("invalid header at col %d: expected %q, got %q", i+1, expectedHeaders[i], h)
+	// Validate headers: strictly by position (default), or by name.
+	header[0] = strings.TrimPrefix(header[0], utf8BOM)
+	var cols columnMap
+	if config.AppConfig.Ingest.ColumnsByName {
+		if cols, err = newColumnMap(header); err != nil {
+			return fileStats{}, err
+		}
+	} else {
+		if len(header) != len(expectedHeaders) {
+			return fileStats{}, fmt.Errorf("invalid header length: expected %d, got %d", len(expectedHeaders), len(header))
+		}
+		for i, h := range header {
+			if strings.TrimSpace(h) != expectedHeaders[i] {
+				return fileStats{}, fmt.Errorf("invalid header at col %d: expected %q, got %q", i+1, expectedHeaders[i], h)
+			}
 		}
 	}
+	numCols := len(header) // every row must have as many columns as the header
+	var ordered []string   // reorder target, only used with cols
+	if cols != nil {
+		ordered = make([]string, len(expectedHeaders))
+	}
 
 	// Parse rows streaming; flush batches to DB.
-	buf := make([]models.Trade, 0, batch)
+	// A batch buffer is only held while it is being filled or inserted, under a
+	// limiter slot, and comes from a pool shared by all concurrent files.
+	var bufp *[]models.Trade
+	var buf []models.Trade
+	release := func() {
+		if bufp == nil {
+			return
+		}
+		*bufp = buf
+		putTradeBuf(bufp)
+		bufp, buf = nil, nil
+		limiter.release()
+	}
+	defer release()
+	interner := make(stringInterner)
+	filter := config.AppConfig.Ingest.InstrumentFilter
+	validateDates := config.AppConfig.Ingest.ValidateDates
+	day, hasDay := fileDay(path)
+	if !hasDay {
+		validateDates = config.ValidateDatesOff // nothing to compare against
+	}
+	var dedup dedupSet
+	if config.AppConfig.Ingest.Dedup {
+		dedup = make(dedupSet)
+	}
 	lineNumber := 1 // header already read
+	blankLine := 0  // first blank line seen; fatal only if data follows it
+	resumeLine := 0 // lines up to here were committed by an earlier run
+	if cp != nil {
+		resumeLine, stats.Rows = cp.LineNumber, cp.RowCount
+	}
+	// With a flush interval, a partial batch is also committed once the
+	// interval has passed since the last flush. It is checked as rows arrive.
+	flushInterval := config.AppConfig.Ingest.FlushInterval
+	var lastFlush time.Time
+	if flushInterval > 0 {
+		lastFlush = flushNow()
+	}
 
 	flush := func() error {
 		if len(buf) == 0 {
 			return nil
 		}
-		if err := repo.InsertTradesBatch(buf); err != nil {
+		var err error
+		if cp != nil {
+			next := *cp
+			next.LineNumber, next.RowCount = lineNumber, stats.Rows
+			err = repo.InsertTradesBatchWithCheckpoint(buf, next)
+		} else {
+			err = repo.InsertTradesBatch(buf)
+		}
+		if err != nil {
 			return err
 		}
-		buf = buf[:0]
+		release()
+		if flushInterval > 0 {
+			lastFlush = flushNow()
+		}
 		return nil
 	}
 
-	total := 0
-
 	for {
 		select {
 		case <-ctx.Done():
-			return 0, ctx.Err()
+			return fileStats{}, ctx.Err()
 		default:
 		}
 
@@ -98,36 +379,107 @@ func parseAndPersistFile(ctx context.Context, path string, repo storage.TradesRe
 			if err == io.EOF {
 				break
 			}
-			return 0, fmt.Errorf("read line after %d: %w", lineNumber, err)
+			return fileStats{}, fmt.Errorf("read line after %d: %w", lineNumber, err)
 		}
 		lineNumber++
 
-		// Enforce structure: exactly 11 columns. If not, fail entire ingestion.
-		if len(rec) != len(expectedHeaders) {
-			return 0, fmt.Errorf("invalid column count on line %d: expected %d got %d", lineNumber, len(expectedHeaders), len(rec))
+		if isBlankRecord(rec) {
+			if blankLine == 0 {
+				blankLine = lineNumber
+			}
+			continue
+		}
+		if blankLine != 0 {
+			return fileStats{}, fmt.Errorf("invalid column count on line %d: expected %d got 1", blankLine, numCols)
+		}
+
+		// Enforce structure: exactly as many columns as the header (11 by
+		// position). If not, fail entire ingestion.
+		if len(rec) != numCols {
+			return fileStats{}, fmt.Errorf("invalid column count on line %d: expected %d got %d", lineNumber, numCols, len(rec))
+		}
+		if cols != nil {
+			rec = cols.reorder(rec, ordered)
+		}
+
+		// Cheap skip on the raw column, before any parsing or allocation.
+		if !filter.Keep(strings.TrimSpace(rec[1])) {
+			stats.Filtered++
+			continue
 		}
 
+		// rec is reused by the next Read (ReuseRecord); recordToTrade copies
+		// everything it needs into tr, so nothing below may retain rec.
 		tr, err := recordToTrade(rec)
 		if err != nil {
 			// Structural/format error → fail the whole pipeline (explicit requirement).
-			return 0, fmt.Errorf("line %d: %w", lineNumber, err)
+			return fileStats{}, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		interner.internTrade(&tr)
+		if validateDates != config.ValidateDatesOff && !tr.TradeDate.IsZero() && !tr.TradeDate.Equal(day) {
+			if validateDates == config.ValidateDatesStrict {
+				return fileStats{}, fmt.Errorf("line %d: trade date %s differs from file date %s",
+					lineNumber, tr.TradeDate.Format("2006-01-02"), day.Format("2006-01-02"))
+			}
+			stats.DateMismatches++
+		}
+		if dedup != nil && dedup.seen(&tr) {
+			stats.Duplicates++
+			continue
 		}
 
+		if lineNumber <= resumeLine {
+			continue // already committed, and counted in cp.RowCount
+		}
+
+		if bufp == nil {
+			if err := limiter.acquire(ctx); err != nil {
+				return fileStats{}, err
+			}
+			bufp = getTradeBuf(batch)
+			buf = *bufp
+		}
 		buf = append(buf, tr)
-		total++
-		if len(buf) >= batch {
+		stats.Rows++
+		if len(buf) >= batch || (flushInterval > 0 && flushNow().Sub(lastFlush) >= flushInterval) {
 			if err := flush(); err != nil {
-				return 0, fmt.Errorf("flush batch ending line %d: %w", lineNumber, err)
+				return fileStats{}, fmt.Errorf("flush batch ending line %d: %w", lineNumber, err)
 			}
 		}
 	}
 
 	// Final flush
 	if err := flush(); err != nil {
-		return 0, fmt.Errorf("final flush: %w", err)
+		return fileStats{}, fmt.Errorf("final flush: %w", err)
 	}
 
-	return total, nil
+	return stats, nil
+}
+
+// parseClosingTime parses HoraFechamento into a clock time on the zero date.
+// Only the first 6 digits (HHMMSS) are used, unless millis is set and s has
+// exactly 9 digits (HHMMSSmmm), in which case the milliseconds are kept.
+//
+// The result is in UTC only as a carrier: closing_time is a TIME without time
+// zone, so the value is stored as B3 wall-clock time, exactly as in the file.
+func parseClosingTime(s string, millis bool) (time.Time, error) {
+	if len(s) < 6 {
+		return time.Time{}, fmt.Errorf("invalid ClosingTime length (need at least HHMMSS): %q", s)
+	}
+	h, err := time.Parse("150405", s[:6])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid ClosingTime: %v", err)
+	}
+	var nsec int
+	if millis && len(s) == 9 {
+		ms, err := strconv.Atoi(s[6:])
+		if err != nil || ms < 0 {
+			return time.Time{}, fmt.Errorf("invalid ClosingTime milliseconds: %q", s)
+		}
+		nsec = ms * int(time.Millisecond)
+	}
+	// Keep only the clock part.
+	return time.Date(0, 1, 1, h.Hour(), h.Minute(), h.Second(), nsec, time.UTC), nil
 }
 
 // recordToTrade converts a single CSV record (already validated length==11)
@@ -137,11 +489,13 @@ func parseAndPersistFile(ctx context.Context, path string, repo storage.TradesRe
 // Column order (Portuguese header → English model fields):
 //
 //	 0 DataReferencia               → ReferenceDate (DATE, "2006-01-02")
-//	 1 CodigoInstrumento            → InstrumentCode (string)
+//	 1 CodigoInstrumento            → InstrumentCode (string, canonicalized via INSTRUMENT_ALIASES)
 //	 2 AcaoAtualizacao              → UpdateAction (string, keep as-is)
-//	 3 PrecoNegocio                 → TradePrice (float, comma→dot, empty→0)
+//	 3 PrecoNegocio                 → TradePrice (float, see parseDecimal, empty→0,
+//	                                  or models.MissingPrice with INGEST_EMPTY_PRICE_NULL)
 //	 4 QuantidadeNegociada          → TradeQuantity (int64, empty→0)
-//	 5 HoraFechamento               → ClosingTime (TIME; HHMMSSmmm → HH:MM:SS; empty→zero)
+//	 5 HoraFechamento               → ClosingTime (TIME; HHMMSSmmm → HH:MM:SS, see parseClosingTime; empty→zero,
+//	                                  all zeros→zero with INGEST_TREAT_ZERO_TIME_AS_NULL)
 //	 6 CodigoIdentificadorNegocio   → TradeIdentifierCode (string)
 //	 7 TipoSessaoPregao             → SessionType (string, keep as-is)
 //	 8 DataNegocio                  → TradeDate (DATE, "2006-01-02")
@@ -159,20 +513,21 @@ func recordToTrade(rec []string) (models.Trade, error) {
 		t.ReferenceDate = d
 	}
 
-	// InstrumentCode (1)
-	t.InstrumentCode = strings.TrimSpace(rec[1])
+	// InstrumentCode (1) — aliases map to the same code the API resolves tickers to
+	t.InstrumentCode = instrument.Canonical(strings.TrimSpace(rec[1]))
 
 	// UpdateAction (2) — keep as string to match DB schema
 	t.UpdateAction = strings.TrimSpace(rec[2])
 
-	// TradePrice (3) — may be empty, uses comma as decimal separator
+	// TradePrice (3) — may be empty, usually uses comma as decimal separator
 	if s := strings.TrimSpace(rec[3]); s != "" {
-		s = strings.ReplaceAll(s, ",", ".")
-		v, err := strconv.ParseFloat(s, 64)
+		v, err := parseDecimal(s)
 		if err != nil {
 			return t, fmt.Errorf("invalid TradePrice: %v", err)
 		}
 		t.TradePrice = v
+	} else if config.AppConfig.Ingest.EmptyPriceNull {
+		t.TradePrice = models.MissingPrice()
 	}
 
 	// TradeQuantity (4) — may be empty
@@ -184,19 +539,14 @@ func recordToTrade(rec []string) (models.Trade, error) {
 		t.TradeQuantity = v
 	}
 
-	// ClosingTime (5) — may be empty, often "HHMMSSmmm" → we use only "HHMMSS"
-	if s := strings.TrimSpace(rec[5]); s != "" {
-		if len(s) < 6 {
-			return t, fmt.Errorf("invalid ClosingTime length (need at least HHMMSS): %q", s)
-		}
-		// Only first 6 digits → HHMMSS
-		hhmmss := s[:6]
-		h, err := time.Parse("150405", hhmmss)
+	// ClosingTime (5) — may be empty, often "HHMMSSmmm"; some exports write
+	// "000000000" for a missing time, which would otherwise read as midnight
+	if s := strings.TrimSpace(rec[5]); s != "" && !(config.AppConfig.Ingest.ZeroTimeAsNull && strings.Trim(s, "0") == "") {
+		ct, err := parseClosingTime(s, config.AppConfig.Ingest.ClosingTimeMillis)
 		if err != nil {
-			return t, fmt.Errorf("invalid ClosingTime: %v", err)
+			return t, err
 		}
-		// Keep only the clock part.
-		t.ClosingTime = time.Date(0, 1, 1, h.Hour(), h.Minute(), h.Second(), 0, time.UTC)
+		t.ClosingTime = ct
 	}
 
 	// TradeIdentifierCode (6)
@@ -222,3 +572,45 @@ func recordToTrade(rec []string) (models.Trade, error) {
 
 	return t, nil
 }
+
+// parseDecimal parses a decimal number written with either a comma or a dot as
+// the decimal separator, optionally with thousands separators.
+//
+// Supported forms:
+//   - "10,50" / "10.50"       → 10.5 (single separator is the decimal point)
+//   - "1.234,56" / "1,234.56" → 1234.56 (the last separator is the decimal point)
+//   - "1.234.567"             → 1234567 (repeated separator only → thousands)
+func parseDecimal(s string) (float64, error) {
+	lastDot := strings.LastIndexByte(s, '.')
+	lastComma := strings.LastIndexByte(s, ',')
+
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		// Both present: the rightmost one is the decimal separator.
+		if lastComma > lastDot {
+			s = strings.ReplaceAll(s, ".", "")
+			s = strings.Replace(s, ",", ".", 1)
+		} else {
+			s = strings.ReplaceAll(s, ",", "")
+		}
+	case lastComma >= 0:
+		if strings.Count(s, ",") > 1 {
+			s = strings.ReplaceAll(s, ",", "")
+		} else if len(s) <= 32 {
+			// Hot path for B3 prices ("10,50"): swap the separator in a stack
+			// buffer instead of allocating a new string per row.
+			var b [32]byte
+			n := copy(b[:], s)
+			b[lastComma] = '.'
+			return strconv.ParseFloat(string(b[:n]), 64)
+		} else {
+			s = strings.Replace(s, ",", ".", 1)
+		}
+	case lastDot >= 0:
+		if strings.Count(s, ".") > 1 {
+			s = strings.ReplaceAll(s, ".", "")
+		}
+	}
+
+	return strconv.ParseFloat(s, 64)
+}
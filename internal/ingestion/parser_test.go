@@ -1,30 +1,50 @@
 package ingestion
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
 
+	"github.com/guttosm/b3pulse/config"
 	"github.com/guttosm/b3pulse/internal/domain/models"
+	"github.com/guttosm/b3pulse/internal/instrument"
+	"golang.org/x/sync/errgroup"
 )
 
 type fakeRepo struct {
-	batches [][]models.Trade
-	err     error
+	batches     [][]models.Trade
+	checkpoints []models.IngestionCheckpoint
+	err         error
 }
 
 func (f *fakeRepo) InsertTradesBatch(trades []models.Trade) error {
 	f.batches = append(f.batches, append([]models.Trade(nil), trades...))
 	return f.err
 }
-func (f *fakeRepo) GetAggregateByTicker(string, *time.Time, *time.Time) (*models.Aggregate, error) {
-	return nil, nil
+func (f *fakeRepo) InsertTradesBatchWithCheckpoint(trades []models.Trade, cp models.IngestionCheckpoint) error {
+	if err := f.InsertTradesBatch(trades); err != nil {
+		return err
+	}
+	f.checkpoints = append(f.checkpoints, cp)
+	return nil
 }
-func (f *fakeRepo) HasIngestionForDate(time.Time) (bool, error)     { return false, nil }
+func (f *fakeRepo) DeleteIngestionCheckpoint(time.Time) error       { return nil }
 func (f *fakeRepo) UpsertIngestionLog(time.Time, string, int) error { return nil }
 func (f *fakeRepo) DeleteTradesByDate(time.Time) error              { return nil }
+func (f *fakeRepo) RecordIngestionRun(models.IngestionAudit) error  { return nil }
+func (f *fakeRepo) AnalyzeTrades(context.Context) error             { return nil }
+func (f *fakeRepo) UpsertInstruments([]models.Instrument) error     { return nil }
+func (f *fakeRepo) EnsurePartitionForDate(time.Time) error          { return nil }
 
 func writeTempFile(t *testing.T, dir, name, content string) string {
 	t.Helper()
@@ -52,13 +72,17 @@ func TestParseAndPersistFile_TableDriven(t *testing.T) {
 		{name: "bad col count", content: validHeader + "a;b\n", wantErr: true},
 		{name: "empty numeric tolerated", content: validHeader + ";PETR4;I;; ;;;;;;\n", wantErr: false, wantBatches: 1, wantRows: 1},
 		{name: "invalid price", content: validHeader + ";PETR4;I;abc;100;;;;;;;\n", wantErr: true},
+		{name: "utf-8 BOM before header", content: "\ufeff" + validHeader + validRow, wantErr: false, wantBatches: 1, wantRows: 1},
+		{name: "trailing blank lines", content: validHeader + validRow + "\r\n \r\n\t\n", wantErr: false, wantBatches: 1, wantRows: 1},
+		{name: "blank line before data", content: validHeader + " \n" + validRow, wantErr: true},
+		{name: "non-empty short row at EOF", content: validHeader + validRow + "x\n", wantErr: true},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			path := writeTempFile(t, dir, "file.txt", tc.content)
 			repo := &fakeRepo{}
-			n, err := parseAndPersistFile(context.Background(), path, repo, 5)
+			stats, err := parseAndPersistFile(context.Background(), path, repo, 5, nil, nil)
 			if tc.wantErr {
 				if err == nil {
 					t.Fatalf("expected error")
@@ -68,8 +92,8 @@ func TestParseAndPersistFile_TableDriven(t *testing.T) {
 			if err != nil {
 				t.Fatalf("unexpected err: %v", err)
 			}
-			if n != tc.wantRows {
-				t.Fatalf("rows: want %d got %d", tc.wantRows, n)
+			if stats.Rows != tc.wantRows {
+				t.Fatalf("rows: want %d got %d", tc.wantRows, stats.Rows)
 			}
 			if len(repo.batches) != tc.wantBatches {
 				t.Fatalf("batches: want %d got %d", tc.wantBatches, len(repo.batches))
@@ -91,7 +115,646 @@ func TestParseAndPersistFile_ContextCanceled(t *testing.T) {
 	repo := &fakeRepo{}
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // immediately canceled
-	if _, err := parseAndPersistFile(ctx, path, repo, 100); err == nil {
+	if _, err := parseAndPersistFile(ctx, path, repo, 100, nil, nil); err == nil {
 		t.Fatalf("expected context canceled error")
 	}
 }
+
+func TestParseAndPersistFile_ReuseRecordKeepsValues(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTempFile(t, dir, "multi.txt", sampleTradesFile(3))
+
+	repo := &fakeRepo{}
+	if _, err := parseAndPersistFile(context.Background(), path, repo, 10, nil, nil); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(repo.batches) != 1 || len(repo.batches[0]) != 3 {
+		t.Fatalf("unexpected batches: %+v", repo.batches)
+	}
+	for i, tr := range repo.batches[0] {
+		if want := fmt.Sprintf("T%d", i); tr.TradeIdentifierCode != want || tr.TradeQuantity != int64(100+i) {
+			t.Fatalf("row %d overwritten by record reuse: %+v", i, tr)
+		}
+	}
+}
+
+func TestParseAndPersistFile_Checkpoint(t *testing.T) {
+	path := writeTempFile(t, t.TempDir(), "big.txt", sampleTradesFile(7))
+	day := time.Date(2025, 9, 11, 0, 0, 0, 0, time.UTC)
+
+	t.Run("fresh start checkpoints every batch", func(t *testing.T) {
+		repo := &fakeRepo{}
+		stats, err := parseAndPersistFile(context.Background(), path, repo, 3, nil, &models.IngestionCheckpoint{FileDate: day})
+		if err != nil || stats.Rows != 7 {
+			t.Fatalf("rows=%d err=%v", stats.Rows, err)
+		}
+		want := []models.IngestionCheckpoint{
+			{FileDate: day, LineNumber: 4, RowCount: 3},
+			{FileDate: day, LineNumber: 7, RowCount: 6},
+			{FileDate: day, LineNumber: 8, RowCount: 7},
+		}
+		if !reflect.DeepEqual(repo.checkpoints, want) {
+			t.Fatalf("checkpoints=%+v, want %+v", repo.checkpoints, want)
+		}
+	})
+
+	t.Run("resume skips committed lines", func(t *testing.T) {
+		repo := &fakeRepo{}
+		stats, err := parseAndPersistFile(context.Background(), path, repo, 3, nil, &models.IngestionCheckpoint{FileDate: day, LineNumber: 4, RowCount: 3})
+		if err != nil || stats.Rows != 7 {
+			t.Fatalf("want cumulative rows=7, got rows=%d err=%v", stats.Rows, err)
+		}
+		if len(repo.batches) != 2 || repo.batches[0][0].TradeIdentifierCode != "T3" || len(repo.batches[1]) != 1 {
+			t.Fatalf("unexpected batches: %+v", repo.batches)
+		}
+		if last := repo.checkpoints[len(repo.checkpoints)-1]; last.LineNumber != 8 || last.RowCount != 7 {
+			t.Fatalf("unexpected final checkpoint: %+v", last)
+		}
+	})
+
+	t.Run("without checkpoint nothing is recorded", func(t *testing.T) {
+		repo := &fakeRepo{}
+		if _, err := parseAndPersistFile(context.Background(), path, repo, 3, nil, nil); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(repo.batches) != 3 || len(repo.checkpoints) != 0 {
+			t.Fatalf("batches=%d checkpoints=%+v", len(repo.batches), repo.checkpoints)
+		}
+	})
+}
+
+func TestParseAndPersistFile_Dedup(t *testing.T) {
+	prev := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = prev })
+
+	header := "DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor\n"
+	content := header +
+		"2025-09-11;PETR4;0;10,50;100;101530000;T1;1;2025-09-11;3;72\n" +
+		"2025-09-11;PETR4;0;10,50;100;101530000;T1;1;2025-09-11;3;72\n" + // exact duplicate
+		"2025-09-11;VALE3;0;60,00;10;101530000;T1;1;2025-09-11;3;72\n" + // same id, other instrument
+		"2025-09-11;PETR4;0;10,60;50;101531000;T2;1;2025-09-11;3;72\n" +
+		"2025-09-11;PETR4;0;10,60;50;101531000;T2;1;2025-09-11;3;72\n" + // exact duplicate
+		"2025-09-11;PETR4;0;10,70;5;101532000;;1;2025-09-11;3;72\n" + // no identifier: kept
+		"2025-09-11;PETR4;0;10,70;5;101532000;;1;2025-09-11;3;72\n"
+	path := writeTempFile(t, t.TempDir(), "dups.txt", content)
+
+	cases := []struct {
+		name        string
+		dedup       bool
+		wantRows    int
+		wantDropped int
+	}{
+		{name: "disabled keeps duplicates", dedup: false, wantRows: 7, wantDropped: 0},
+		{name: "enabled drops duplicates", dedup: true, wantRows: 5, wantDropped: 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config.AppConfig.Ingest.Dedup = tc.dedup
+			repo := &fakeRepo{}
+			stats, err := parseAndPersistFile(context.Background(), path, repo, 100, nil, nil)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if stats.Rows != tc.wantRows || stats.Duplicates != tc.wantDropped {
+				t.Fatalf("rows=%d dropped=%d, want %d/%d", stats.Rows, stats.Duplicates, tc.wantRows, tc.wantDropped)
+			}
+			if len(repo.batches) != 1 || len(repo.batches[0]) != tc.wantRows {
+				t.Fatalf("unexpected persisted batches: %d", len(repo.batches))
+			}
+		})
+	}
+}
+
+func TestParseAndPersistFile_InstrumentFilter(t *testing.T) {
+	prev := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = prev })
+
+	header := "DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor\n"
+	var sb strings.Builder
+	sb.WriteString(header)
+	for i, code := range []string{"PETR4", "PETR4F", "VALE3", "PETRJ250", "BOVA11"} {
+		fmt.Fprintf(&sb, "2025-09-11;%s;0;10,50;100;101530000;T%d;1;2025-09-11;3;72\n", code, i)
+	}
+	// A filtered row is skipped before parsing, so its bad price is never seen.
+	sb.WriteString("2025-09-11;PETRK300;0;not-a-price;100;101530000;T9;1;2025-09-11;3;72\n")
+	path := writeTempFile(t, t.TempDir(), "filter.txt", sb.String())
+
+	cases := []struct {
+		name         string
+		filter       string
+		wantCodes    []string
+		wantFiltered int
+	}{
+		{name: "no filter keeps all", filter: "", wantCodes: nil},
+		{name: "allowlist regex", filter: `allow:^[A-Z]{4}(3|4|11)$`, wantCodes: []string{"PETR4", "VALE3", "BOVA11"}, wantFiltered: 3},
+		{name: "denylist only", filter: `deny:F$|^PETR[A-Z]`, wantCodes: []string{"PETR4", "VALE3", "BOVA11"}, wantFiltered: 3},
+		{name: "allow prefixes then deny", filter: `allow:^(PETR|VALE) deny:F$|^PETR[A-Z]`, wantCodes: []string{"PETR4", "VALE3"}, wantFiltered: 4},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := config.ParseInstrumentFilter(tc.filter)
+			if err != nil {
+				t.Fatalf("parse filter: %v", err)
+			}
+			config.AppConfig.Ingest.InstrumentFilter = f
+			repo := &fakeRepo{}
+			stats, err := parseAndPersistFile(context.Background(), path, repo, 100, nil, nil)
+			if tc.wantCodes == nil {
+				if err == nil {
+					t.Fatalf("expected the unfiltered bad row to fail parsing")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if stats.Filtered != tc.wantFiltered || stats.Rows != len(tc.wantCodes) {
+				t.Fatalf("stats=%+v, want rows=%d filtered=%d", stats, len(tc.wantCodes), tc.wantFiltered)
+			}
+			for i, tr := range repo.batches[0] {
+				if tr.InstrumentCode != tc.wantCodes[i] {
+					t.Fatalf("row %d: got %s want %s", i, tr.InstrumentCode, tc.wantCodes[i])
+				}
+			}
+		})
+	}
+}
+
+// overlapRepo records the maximum number of batches being inserted at once.
+type overlapRepo struct {
+	fakeRepo
+	active, peak, rows atomic.Int64
+	failAfter          int64 // fail the Nth insert when > 0
+	calls              atomic.Int64
+}
+
+func (r *overlapRepo) InsertTradesBatch(trades []models.Trade) error {
+	if r.failAfter > 0 && r.calls.Add(1) >= r.failAfter {
+		return errors.New("insert failed")
+	}
+	n := r.active.Add(1)
+	defer r.active.Add(-1)
+	for {
+		p := r.peak.Load()
+		if n <= p || r.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond) // widen the window for overlap
+	r.rows.Add(int64(len(trades)))
+	return nil
+}
+
+func TestParseAndPersistFile_BatchLimiter(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 4; i++ {
+		paths = append(paths, writeTempFile(t, dir, fmt.Sprintf("f%d.txt", i), sampleTradesFile(20)))
+	}
+
+	cases := []struct {
+		name     string
+		limit    int
+		wantPeak int64 // upper bound on concurrent batches
+	}{
+		{name: "one slot serializes batches", limit: 1, wantPeak: 1},
+		{name: "two slots", limit: 2, wantPeak: 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &overlapRepo{}
+			limiter := newBatchLimiter(tc.limit)
+			g, ctx := errgroup.WithContext(context.Background())
+			for _, p := range paths {
+				g.Go(func() error {
+					_, err := parseAndPersistFile(ctx, p, repo, 5, limiter, nil)
+					return err
+				})
+			}
+			if err := g.Wait(); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if peak := repo.peak.Load(); peak > tc.wantPeak {
+				t.Fatalf("peak concurrent batches=%d, want <= %d", peak, tc.wantPeak)
+			}
+			if repo.rows.Load() != 80 || len(limiter) != 0 {
+				t.Fatalf("rows=%d slots still held=%d", repo.rows.Load(), len(limiter))
+			}
+		})
+	}
+
+	t.Run("slot released on insert error", func(t *testing.T) {
+		limiter := newBatchLimiter(1)
+		if _, err := parseAndPersistFile(context.Background(), paths[0], &overlapRepo{failAfter: 2}, 5, limiter, nil); err == nil {
+			t.Fatalf("expected insert error")
+		}
+		if len(limiter) != 0 {
+			t.Fatalf("slot leaked after error")
+		}
+	})
+
+	t.Run("acquire honours cancellation", func(t *testing.T) {
+		limiter := newBatchLimiter(1)
+		limiter <- struct{}{} // exhausted
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, err := parseAndPersistFile(ctx, paths[0], &overlapRepo{}, 5, limiter, nil); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+	})
+
+	if newBatchLimiter(0) != nil {
+		t.Fatalf("non-positive limit should be unlimited (nil)")
+	}
+}
+
+func TestParseAndPersistFile_ValidateDates(t *testing.T) {
+	prev := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = prev })
+
+	header := "DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor\n"
+	content := header +
+		"2025-09-11;PETR4;0;10,50;100;101530000;T1;1;2025-09-11;3;72\n" +
+		"2025-09-12;PETR4;0;10,50;100;101530000;T2;1;2025-09-12;3;72\n" + // swapped file
+		"2025-09-12;PETR4;0;10,50;100;101530000;T3;1;2025-09-12;3;72\n" +
+		"2025-09-11;PETR4;0;10,50;100;101530000;T4;1;;3;72\n" // empty trade date: not checked
+	dir := t.TempDir()
+	named := writeTempFile(t, dir, "11-09-2025"+fileSuffix, content)
+	unnamed := writeTempFile(t, dir, "adhoc.txt", content)
+
+	cases := []struct {
+		name           string
+		mode           string
+		path           string
+		wantErr        bool
+		wantMismatches int
+	}{
+		{name: "off", mode: config.ValidateDatesOff, path: named},
+		{name: "warn counts and keeps rows", mode: config.ValidateDatesWarn, path: named, wantMismatches: 2},
+		{name: "strict fails", mode: config.ValidateDatesStrict, path: named, wantErr: true},
+		{name: "no date in filename skips check", mode: config.ValidateDatesStrict, path: unnamed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config.AppConfig.Ingest.ValidateDates = tc.mode
+			stats, err := parseAndPersistFile(context.Background(), tc.path, &fakeRepo{}, 100, nil, nil)
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "line 3: trade date 2025-09-12 differs from file date 2025-09-11") {
+					t.Fatalf("expected date mismatch error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if stats.Rows != 4 || stats.DateMismatches != tc.wantMismatches {
+				t.Fatalf("stats=%+v, want rows=4 mismatches=%d", stats, tc.wantMismatches)
+			}
+		})
+	}
+}
+
+func TestParseAndPersistFile_ColumnsByName(t *testing.T) {
+	prev := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = prev })
+
+	// Reordered columns plus an extra one the parser should ignore.
+	reordered := "CodigoInstrumento;Extra;DataNegocio;PrecoNegocio;QuantidadeNegociada;DataReferencia;AcaoAtualizacao;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;CodigoParticipanteComprador;CodigoParticipanteVendedor\n" +
+		"PETR4;x;2025-09-11;10,50;100;2025-09-11;0;101530000;T1;1;3;72\n"
+	dir := t.TempDir()
+
+	cases := []struct {
+		name    string
+		byName  bool
+		content string
+		wantErr string
+	}{
+		{name: "by name accepts reordered header", byName: true, content: reordered},
+		{name: "positional rejects reordered header", byName: false, content: reordered, wantErr: "invalid header length"},
+		{name: "missing column", byName: true, content: strings.Replace(reordered, "PrecoNegocio", "Preco", 1), wantErr: `missing column "PrecoNegocio"`},
+		{name: "duplicate column", byName: true, content: strings.Replace(reordered, "Extra", "DataNegocio", 1), wantErr: `duplicate column "DataNegocio"`},
+		{name: "row shorter than header", byName: true, content: reordered + "PETR4;x;2025-09-11\n", wantErr: "invalid column count on line 3: expected 12 got 3"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config.AppConfig.Ingest.ColumnsByName = tc.byName
+			path := writeTempFile(t, dir, "cols.txt", tc.content)
+			repo := &fakeRepo{}
+			_, err := parseAndPersistFile(context.Background(), path, repo, 100, nil, nil)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if len(repo.batches) != 1 || len(repo.batches[0]) != 1 {
+				t.Fatalf("unexpected persisted batches: %+v", repo.batches)
+			}
+			got := repo.batches[0][0]
+			if got.InstrumentCode != "PETR4" || got.TradePrice != 10.50 || got.TradeQuantity != 100 ||
+				got.TradeIdentifierCode != "T1" || got.BuyerParticipantCode != "3" || got.SellerParticipantCode != "72" {
+				t.Fatalf("fields not mapped by name: %+v", got)
+			}
+		})
+	}
+}
+
+func TestParseAndPersistFile_FlushInterval(t *testing.T) {
+	prevCfg, prevNow := config.AppConfig, flushNow
+	t.Cleanup(func() { config.AppConfig, flushNow = prevCfg, prevNow })
+
+	// Each reading of the clock advances it by one second.
+	var clock time.Time
+	flushNow = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	path := writeTempFile(t, t.TempDir(), "slow.txt", sampleTradesFile(5))
+
+	cases := []struct {
+		name     string
+		interval time.Duration
+		want     []int // batch sizes
+	}{
+		{name: "disabled flushes by count only", interval: 0, want: []int{5}},
+		{name: "partial batch flushed when interval elapses", interval: 2 * time.Second, want: []int{2, 2, 1}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config.AppConfig.Ingest.FlushInterval = tc.interval
+			repo := &fakeRepo{}
+			stats, err := parseAndPersistFile(context.Background(), path, repo, 100, nil, nil)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			var got []int
+			for _, b := range repo.batches {
+				got = append(got, len(b))
+			}
+			if !reflect.DeepEqual(got, tc.want) || stats.Rows != 5 {
+				t.Fatalf("batch sizes=%v rows=%d, want %v rows=5", got, stats.Rows, tc.want)
+			}
+		})
+	}
+}
+
+func TestStringInterner(t *testing.T) {
+	in := make(stringInterner)
+	line := "PETR4;rest of the line"
+	a := in.intern(line[:5])
+	b := in.intern(strings.Clone("PETR4"))
+	if a != "PETR4" || unsafe.StringData(a) != unsafe.StringData(b) {
+		t.Fatalf("expected both values to share one interned copy")
+	}
+	if unsafe.StringData(a) == unsafe.StringData(line) {
+		t.Fatalf("interned value must not alias the source line")
+	}
+
+	// Bounded: once full, new values pass through unchanged.
+	for i := len(in); i < maxInternedStrings; i++ {
+		in.intern(fmt.Sprintf("K%d", i))
+	}
+	if got := in.intern("NEW3"); got != "NEW3" || len(in) != maxInternedStrings {
+		t.Fatalf("interner grew past its bound: len=%d", len(in))
+	}
+}
+
+func TestTradeBufPool(t *testing.T) {
+	p := getTradeBuf(8)
+	*p = append(*p, models.Trade{InstrumentCode: "PETR4"})
+	putTradeBuf(p)
+
+	q := getTradeBuf(8)
+	if len(*q) != 0 || cap(*q) < 8 {
+		t.Fatalf("expected empty buffer with cap>=8, got len=%d cap=%d", len(*q), cap(*q))
+	}
+	if full := (*q)[:1]; full[0].InstrumentCode != "" {
+		t.Fatalf("pooled buffer retained data: %+v", full[0])
+	}
+	if r := getTradeBuf(1 << 16); cap(*r) < 1<<16 {
+		t.Fatalf("expected fresh buffer for larger batch, got cap=%d", cap(*r))
+	}
+}
+
+// sampleTradesFile returns a valid file with n distinct rows.
+func sampleTradesFile(n int) string {
+	var sb bytes.Buffer
+	sb.WriteString("DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "2025-09-11;PETR4;0;10,50;%d;101530000;T%d;1;2025-09-11;3;72\n", 100+i, i)
+	}
+	return sb.String()
+}
+
+// BenchmarkTradeReader compares per-row allocations with and without ReuseRecord.
+//
+//	go test ./internal/ingestion -bench TradeReader -benchmem
+func BenchmarkTradeReader(b *testing.B) {
+	data := []byte(sampleTradesFile(10000))
+	for _, reuse := range []bool{false, true} {
+		b.Run(fmt.Sprintf("reuse=%v", reuse), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r := newTradeReader(bytes.NewReader(data), 0)
+				r.ReuseRecord = reuse
+				if _, err := r.Read(); err != nil { // header
+					b.Fatal(err)
+				}
+				for {
+					rec, err := r.Read()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						b.Fatal(err)
+					}
+					if _, err := recordToTrade(rec); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRecordToTrade_Aliases(t *testing.T) {
+	if err := instrument.SetAliases([]string{"PETR4F=PETR4"}); err != nil {
+		t.Fatalf("SetAliases: %v", err)
+	}
+	t.Cleanup(func() { _ = instrument.SetAliases(nil) })
+
+	rec := []string{"2025-09-11", " PETR4F ", "0", "10,50", "100", "101530000", "T1", "1", "2025-09-11", "3", "72"}
+	tr, err := recordToTrade(rec)
+	if err != nil {
+		t.Fatalf("recordToTrade: %v", err)
+	}
+	// Same map the API uses for query tickers (see api.queryTicker).
+	if tr.InstrumentCode != "PETR4" || tr.InstrumentCode != instrument.Canonical("PETR4F") {
+		t.Fatalf("InstrumentCode=%q, want PETR4", tr.InstrumentCode)
+	}
+
+	rec[1] = "VALE3"
+	if tr, _ = recordToTrade(rec); tr.InstrumentCode != "VALE3" {
+		t.Fatalf("unmapped code must pass through, got %q", tr.InstrumentCode)
+	}
+}
+
+func TestParseClosingTime(t *testing.T) {
+	cases := []struct {
+		name    string
+		in      string
+		millis  bool
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "HHMMSS", in: "101530", want: time.Date(0, 1, 1, 10, 15, 30, 0, time.UTC)},
+		{name: "9 digits truncated by default", in: "101530123", want: time.Date(0, 1, 1, 10, 15, 30, 0, time.UTC)},
+		{name: "9 digits with millis", in: "101530123", millis: true, want: time.Date(0, 1, 1, 10, 15, 30, 123*int(time.Millisecond), time.UTC)},
+		{name: "8 digits ignore millis", in: "10153012", millis: true, want: time.Date(0, 1, 1, 10, 15, 30, 0, time.UTC)},
+		{name: "too short", in: "1015", wantErr: true},
+		{name: "invalid clock", in: "256000", wantErr: true},
+		{name: "invalid millis", in: "1015301x3", millis: true, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseClosingTime(tc.in, tc.millis)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if !got.Equal(tc.want) || got.Location() != tc.want.Location() {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRecordToTrade_ClosingTimeMillis(t *testing.T) {
+	old := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = old })
+	config.AppConfig.Ingest.ClosingTimeMillis = true
+
+	rec := []string{"2025-09-11", "PETR4", "0", "10,50", "100", "101530456", "T1", "1", "2025-09-11", "3", "72"}
+	tr, err := recordToTrade(rec)
+	if err != nil {
+		t.Fatalf("recordToTrade: %v", err)
+	}
+	if tr.ClosingTime.Nanosecond() != 456*int(time.Millisecond) || tr.ClosingTime.Location() != time.UTC {
+		t.Fatalf("unexpected ClosingTime %v", tr.ClosingTime)
+	}
+
+	rec[5] = "10153"
+	if _, err := recordToTrade(rec); err == nil || !strings.Contains(err.Error(), "ClosingTime") {
+		t.Fatalf("expected ClosingTime error, got %v", err)
+	}
+}
+
+func TestRecordToTrade_ZeroClosingTime(t *testing.T) {
+	old := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = old })
+
+	rec := []string{"2025-09-11", "PETR4", "0", "10,50", "100", "000000000", "T1", "1", "2025-09-11", "3", "72"}
+	for _, zeros := range []string{"000000000", "000000", "0"} {
+		rec[5] = zeros
+		config.AppConfig.Ingest.ZeroTimeAsNull = true
+		tr, err := recordToTrade(rec)
+		if err != nil || !tr.ClosingTime.IsZero() {
+			t.Fatalf("%q: got %v, %v; want zero (NULL) ClosingTime", zeros, tr.ClosingTime, err)
+		}
+	}
+
+	// Disabled, a 9-digit zero time is parsed as midnight.
+	rec[5] = "000000000"
+	config.AppConfig.Ingest.ZeroTimeAsNull = false
+	tr, err := recordToTrade(rec)
+	if err != nil || tr.ClosingTime.IsZero() || tr.ClosingTime.Hour() != 0 {
+		t.Fatalf("disabled: got %v, %v; want midnight", tr.ClosingTime, err)
+	}
+}
+
+func TestRecordToTrade_EmptyPrice(t *testing.T) {
+	old := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = old })
+
+	rec := []string{"2025-09-11", "PETR4", "0", " ", "100", "101530000", "T1", "1", "2025-09-11", "3", "72"}
+	tr, err := recordToTrade(rec)
+	if err != nil || tr.TradePrice != 0 || tr.IsPriceMissing() {
+		t.Fatalf("default: got %v (missing=%v), %v; want 0", tr.TradePrice, tr.IsPriceMissing(), err)
+	}
+
+	config.AppConfig.Ingest.EmptyPriceNull = true
+	if tr, err = recordToTrade(rec); err != nil || !tr.IsPriceMissing() {
+		t.Fatalf("enabled: got %v, %v; want a missing price", tr.TradePrice, err)
+	}
+	rec[3] = "0,00"
+	if tr, err = recordToTrade(rec); err != nil || tr.IsPriceMissing() || tr.TradePrice != 0 {
+		t.Fatalf("enabled: an explicit zero must stay 0, got %v, %v", tr.TradePrice, err)
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{in: "10,50", want: 10.5},
+		{in: "10.50", want: 10.5},
+		{in: "1.234,56", want: 1234.56},
+		{in: "1234.56", want: 1234.56},
+		{in: "1,234.56", want: 1234.56},
+		{in: "1.234.567", want: 1234567},
+		{in: "42", want: 42},
+		{in: "1,2,3,4", want: 1234},
+		{in: "abc", wantErr: true},
+		{in: "1,23a", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := parseDecimal(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for %q, got %v", tc.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("parseDecimal(%q)=%v, want %v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+// discardRepo accepts batches without retaining them, isolating parser allocations.
+type discardRepo struct{ fakeRepo }
+
+func (discardRepo) InsertTradesBatch([]models.Trade) error { return nil }
+
+// BenchmarkParseAndPersist measures a full file parse with the default batch size.
+//
+//	go test ./internal/ingestion -bench ParseAndPersist -benchmem
+func BenchmarkParseAndPersist(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "bench.txt")
+	if err := os.WriteFile(path, []byte(sampleTradesFile(20000)), 0644); err != nil {
+		b.Fatal(err)
+	}
+	repo := &discardRepo{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseAndPersistFile(context.Background(), path, repo, defaultBatchSize, nil, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/guttosm/b3pulse/internal/logger"
+)
+
+// FileSource opens input files for parseAndPersistFile. The default reads the
+// local filesystem; tests substitute flaky or in-memory sources.
+type FileSource interface {
+	Open(path string) (io.ReadCloser, error)
+}
+
+type osFileSource struct{}
+
+func (osFileSource) Open(path string) (io.ReadCloser, error) { return os.Open(path) }
+
+// fileSource is the FileSource parseAndPersistFile reads from.
+var fileSource FileSource = osFileSource{}
+
+// openRetryBackoff is the wait before the first INGEST_OPEN_RETRIES retry; it
+// doubles on each further attempt. Tests shorten it.
+var openRetryBackoff = 200 * time.Millisecond
+
+// isTransientIOError reports whether err is an I/O failure that a retry may
+// resolve, such as a stale NFS handle, EIO or a timeout. Missing files,
+// permission problems and empty files are permanent.
+func isTransientIOError(err error) bool {
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) || errors.Is(err, io.EOF) {
+		return false
+	}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		switch errno {
+		case syscall.ESTALE, syscall.EIO, syscall.EAGAIN, syscall.EINTR, syscall.ETIMEDOUT:
+			return true
+		}
+	}
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
+
+// openWithHeader opens path through fileSource and reads its header record,
+// retrying transient failures of either step up to retries times with
+// exponential backoff. The caller must close the returned file, and must
+// validate the header before reading on (the reader reuses the record slice).
+func openWithHeader(ctx context.Context, path string, bufSize int, retries int) (io.ReadCloser, *csv.Reader, []string, error) {
+	backoff := openRetryBackoff
+	for attempt := 1; ; attempt++ {
+		f, r, header, err := openHeader(path, bufSize)
+		if err == nil {
+			return f, r, header, nil
+		}
+		if attempt > retries || !isTransientIOError(err) {
+			return nil, nil, nil, err
+		}
+
+		logger.L().Warn().Str("file", filepath.Base(path)).Int("attempt", attempt).Dur("backoff", backoff).Err(err).Msg("transient I/O error opening file, retrying")
+		select {
+		case <-ctx.Done():
+			return nil, nil, nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// openHeader is a single openWithHeader attempt.
+func openHeader(path string, bufSize int) (io.ReadCloser, *csv.Reader, []string, error) {
+	f, err := fileSource.Open(path)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("open: %w", err)
+	}
+	r := newTradeReader(f, bufSize)
+	header, err := r.Read()
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, nil, fmt.Errorf("read header: %w", err)
+	}
+	return f, r, header, nil
+}
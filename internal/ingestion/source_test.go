@@ -0,0 +1,101 @@
+package ingestion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/guttosm/b3pulse/config"
+)
+
+// flakySource fails the first failures opens with err, then reads the disk.
+type flakySource struct {
+	failures int
+	err      error
+	opens    int
+}
+
+func (s *flakySource) Open(path string) (io.ReadCloser, error) {
+	s.opens++
+	if s.opens <= s.failures {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: s.err}
+	}
+	return os.Open(path)
+}
+
+func withFileSource(t *testing.T, src FileSource, retries int) {
+	t.Helper()
+	prevSrc, prevBackoff, prevCfg := fileSource, openRetryBackoff, config.AppConfig
+	t.Cleanup(func() { fileSource, openRetryBackoff, config.AppConfig = prevSrc, prevBackoff, prevCfg })
+	fileSource, openRetryBackoff = src, time.Millisecond
+	config.AppConfig.Ingest.OpenRetries = retries
+}
+
+func TestParseAndPersistFile_OpenRetries(t *testing.T) {
+	validHeader := "DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor\n"
+	path := writeTempFile(t, t.TempDir(), "file.txt", validHeader+";PETR4;I;10,50;100;101530000;ABC;REGULAR;2025-09-11;B;S\n")
+
+	t.Run("transient error recovers", func(t *testing.T) {
+		src := &flakySource{failures: 1, err: syscall.ESTALE}
+		withFileSource(t, src, 2)
+		stats, err := parseAndPersistFile(context.Background(), path, &fakeRepo{}, 5, nil, nil)
+		if err != nil || stats.Rows != 1 || src.opens != 2 {
+			t.Fatalf("want recovery on 2nd open, got rows=%d opens=%d err=%v", stats.Rows, src.opens, err)
+		}
+	})
+
+	t.Run("default fails immediately", func(t *testing.T) {
+		src := &flakySource{failures: 1, err: syscall.ESTALE}
+		withFileSource(t, src, 0)
+		if _, err := parseAndPersistFile(context.Background(), path, &fakeRepo{}, 5, nil, nil); !errors.Is(err, syscall.ESTALE) || src.opens != 1 {
+			t.Fatalf("want ESTALE after 1 open, got opens=%d err=%v", src.opens, err)
+		}
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		src := &flakySource{failures: 5, err: syscall.EIO}
+		withFileSource(t, src, 2)
+		if _, err := parseAndPersistFile(context.Background(), path, &fakeRepo{}, 5, nil, nil); !errors.Is(err, syscall.EIO) || src.opens != 3 {
+			t.Fatalf("want EIO after 3 opens, got opens=%d err=%v", src.opens, err)
+		}
+	})
+
+	t.Run("permanent error not retried", func(t *testing.T) {
+		src := &flakySource{failures: 1, err: fs.ErrNotExist}
+		withFileSource(t, src, 3)
+		if _, err := parseAndPersistFile(context.Background(), path, &fakeRepo{}, 5, nil, nil); !errors.Is(err, fs.ErrNotExist) || src.opens != 1 {
+			t.Fatalf("want not-exist after 1 open, got opens=%d err=%v", src.opens, err)
+		}
+	})
+}
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string { return "i/o timeout" }
+func (timeoutErr) Timeout() bool { return true }
+
+func TestIsTransientIOError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&fs.PathError{Op: "open", Path: "f", Err: syscall.ESTALE}, true},
+		{fmt.Errorf("read header: %w", syscall.EIO), true},
+		{timeoutErr{}, true},
+		{&fs.PathError{Op: "open", Path: "f", Err: syscall.ENOENT}, false},
+		{&fs.PathError{Op: "open", Path: "f", Err: syscall.EACCES}, false},
+		{fmt.Errorf("read header: %w", io.EOF), false},
+		{errors.New("invalid header length"), false},
+	}
+	for _, tc := range cases {
+		if got := isTransientIOError(tc.err); got != tc.want {
+			t.Fatalf("isTransientIOError(%v)=%v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
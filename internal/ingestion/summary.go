@@ -0,0 +1,82 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// File statuses reported in a FileSummary.
+const (
+	FileIngested = "ingested" // parsed and persisted
+	FileSkipped  = "skipped"  // already ingested and not forced
+	FileMissing  = "missing"  // absent and skipped via allowMissing
+	FileFailed   = "failed"
+)
+
+// RunSummary is the machine-readable result of an ingestion run, printed by
+// the CLI with --output json.
+type RunSummary struct {
+	RunID      string        `json:"run_id"`
+	Success    bool          `json:"success"`
+	Files      []FileSummary `json:"files"` // ordered by day
+	TotalRows  int64         `json:"total_rows"`
+	DurationMs int64         `json:"duration_ms"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// FileSummary is the outcome of a single day's file.
+type FileSummary struct {
+	File       string `json:"file"`
+	Day        string `json:"day,omitempty"` // YYYY-MM-DD, omitted if the filename has no valid date
+	Status     string `json:"status"`        // one of FileIngested, FileSkipped, FileMissing, FileFailed
+	Rows       int    `json:"rows"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// WriteJSON writes s as a single line of JSON.
+func (s RunSummary) WriteJSON(w io.Writer) error {
+	if s.Files == nil {
+		s.Files = []FileSummary{}
+	}
+	return json.NewEncoder(w).Encode(s)
+}
+
+// fileSummaries collects FileSummary entries across workers.
+type fileSummaries struct {
+	mu    sync.Mutex
+	files []FileSummary
+}
+
+// add records the outcome for path; err, when non-nil, marks it failed.
+func (fs *fileSummaries) add(path, status string, rows int, elapsed time.Duration, err error) {
+	s := FileSummary{File: filepath.Base(path), Status: status, Rows: rows, DurationMs: elapsed.Milliseconds()}
+	if d, ok := fileDay(path); ok {
+		s.Day = d.Format("2006-01-02")
+	}
+	if err != nil {
+		s.Status, s.Error = FileFailed, err.Error()
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.files = append(fs.files, s)
+}
+
+// sorted returns the entries ordered by day, then file name.
+func (fs *fileSummaries) sorted() []FileSummary {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	out := append([]FileSummary(nil), fs.files...)
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Day != out[j].Day {
+			return out[i].Day < out[j].Day
+		}
+		return out[i].File < out[j].File
+	})
+	return out
+}
@@ -0,0 +1,108 @@
+package ingestion
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guttosm/b3pulse/internal/storage"
+)
+
+func TestRunSummary_WriteJSON(t *testing.T) {
+	cases := []struct {
+		name    string
+		summary RunSummary
+		want    string
+	}{
+		{
+			name:    "empty run keeps files as an array",
+			summary: RunSummary{Error: "db down"},
+			want:    `{"run_id":"","success":false,"files":[],"total_rows":0,"duration_ms":0,"error":"db down"}`,
+		},
+		{
+			name: "files",
+			summary: RunSummary{RunID: "r1", Success: true, TotalRows: 2, DurationMs: 15, Files: []FileSummary{
+				{File: "18-09-2025_NEGOCIOSAVISTA.txt", Day: "2025-09-18", Status: FileIngested, Rows: 2, DurationMs: 12},
+				{File: "19-09-2025_NEGOCIOSAVISTA.txt", Day: "2025-09-19", Status: FileSkipped},
+			}},
+			want: `{"run_id":"r1","success":true,"files":[` +
+				`{"file":"18-09-2025_NEGOCIOSAVISTA.txt","day":"2025-09-18","status":"ingested","rows":2,"duration_ms":12},` +
+				`{"file":"19-09-2025_NEGOCIOSAVISTA.txt","day":"2025-09-19","status":"skipped","rows":0,"duration_ms":0}` +
+				`],"total_rows":2,"duration_ms":15}`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tc.summary.WriteJSON(&buf); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if got := buf.String(); got != tc.want+"\n" {
+				t.Fatalf("got  %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProcessDirectoryWithSummary(t *testing.T) {
+	days := LastNBusinessDays(4, time.Now())
+	dir := t.TempDir()
+	// days[0] is missing, days[1] already ingested, days[2] has a bad header.
+	writeFile(t, dir, days[1].Format(fileDateLayout)+fileSuffix, sampleFile())
+	writeFile(t, dir, days[2].Format(fileDateLayout)+fileSuffix, "X;Y;Z\n")
+	writeFile(t, dir, days[3].Format(fileDateLayout)+fileSuffix, sampleFile())
+
+	skipped := time.Date(days[1].Year(), days[1].Month(), days[1].Day(), 0, 0, 0, 0, time.UTC)
+	fr := &fakeRepoIngestion{has: map[time.Time]bool{skipped: true}, stored: map[time.Time]int64{skipped: 2}}
+	old := repoCtor
+	repoCtor = func(_ *sql.DB) storage.TradesRepository { return fr }
+	t.Cleanup(func() { repoCtor = old })
+
+	summary, err := ProcessDirectoryWithSummary(context.Background(), dir, dummyDB(), 4, 1, ProcessOptions{AllowMissing: true, ContinueOnError: true})
+	var partial *PartialFailureError
+	if !errors.As(err, &partial) {
+		t.Fatalf("expected *PartialFailureError, got %v", err)
+	}
+	if summary.Success || summary.RunID == "" || summary.TotalRows != 2 || summary.Error != err.Error() {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+
+	// Ordered by day, oldest first (LastNBusinessDays is newest first).
+	want := map[string]string{
+		days[3].Format("2006-01-02"): FileIngested,
+		days[2].Format("2006-01-02"): FileFailed,
+		days[1].Format("2006-01-02"): FileSkipped,
+		days[0].Format("2006-01-02"): FileMissing,
+	}
+	if len(summary.Files) != len(want) {
+		t.Fatalf("expected %d files, got %+v", len(want), summary.Files)
+	}
+	for i, f := range summary.Files {
+		if i > 0 && summary.Files[i-1].Day > f.Day {
+			t.Fatalf("files not ordered by day: %+v", summary.Files)
+		}
+		if want[f.Day] != f.Status {
+			t.Fatalf("%s: status=%s, want %s", f.Day, f.Status, want[f.Day])
+		}
+		switch f.Status {
+		case FileIngested:
+			if f.Rows != 2 {
+				t.Fatalf("expected 2 rows, got %+v", f)
+			}
+		case FileFailed:
+			if !strings.Contains(f.Error, "invalid header") {
+				t.Fatalf("expected header error, got %+v", f)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := summary.WriteJSON(&buf); err != nil || !json.Valid(buf.Bytes()) {
+		t.Fatalf("invalid summary JSON %q: %v", buf.String(), err)
+	}
+}
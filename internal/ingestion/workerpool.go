@@ -0,0 +1,141 @@
+package ingestion
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/guttosm/b3pulse/config"
+)
+
+// defaultWorkers sizes the shared pool when INGEST_WORKERS is unset; it
+// matches the cap on files processed concurrently by one run.
+const defaultWorkers = 7
+
+// ErrPoolClosed is returned by WorkerPool.Submit after Shutdown.
+var ErrPoolClosed = errors.New("worker pool is shut down")
+
+// WorkerPool runs tasks on a fixed set of goroutines, so the number of files
+// parsed at once is bounded across every ingestion run sharing the pool, not
+// just within one run.
+type WorkerPool struct {
+	tasks  chan poolTask
+	mu     sync.RWMutex // held for reading while submitting, for writing by Shutdown
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// poolTask is a submitted function, the context it was submitted with, and
+// where its result goes.
+type poolTask struct {
+	ctx  context.Context
+	fn   func() error
+	done chan error
+}
+
+// NewWorkerPool starts a pool of size workers (at least one).
+func NewWorkerPool(size int) *WorkerPool {
+	if size < 1 {
+		size = 1
+	}
+	p := &WorkerPool{tasks: make(chan poolTask)}
+	p.wg.Add(size)
+	for range size {
+		go p.work()
+	}
+	return p
+}
+
+func (p *WorkerPool) work() {
+	defer p.wg.Done()
+	for t := range p.tasks {
+		// The submitter may have been cancelled while the task waited for us.
+		if err := t.ctx.Err(); err != nil {
+			t.done <- err
+			continue
+		}
+		t.done <- t.fn()
+	}
+}
+
+// Submit runs fn on the next free worker and returns its error. It blocks
+// while every worker is busy, and returns ErrPoolClosed once Shutdown was called.
+//
+// fn is skipped, and ctx.Err() returned, when ctx is done before a worker picks
+// it up, so a cancelled run stops queuing behind other runs' files.
+func (p *WorkerPool) Submit(ctx context.Context, fn func() error) error {
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrPoolClosed
+	}
+	if err := ctx.Err(); err != nil {
+		p.mu.RUnlock()
+		return err
+	}
+	t := poolTask{ctx: ctx, fn: fn, done: make(chan error, 1)}
+	select {
+	case p.tasks <- t:
+	case <-ctx.Done():
+		p.mu.RUnlock()
+		return ctx.Err()
+	}
+	p.mu.RUnlock()
+	return <-t.done
+}
+
+// Shutdown stops accepting tasks and waits until the running ones, and those
+// already waiting in Submit, have finished, or until ctx is done. It is safe to
+// call more than once.
+func (p *WorkerPool) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.mu.Lock()
+		if !p.closed {
+			p.closed = true
+			close(p.tasks)
+		}
+		p.mu.Unlock()
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var (
+	sharedPoolMu sync.Mutex
+	sharedPool   *WorkerPool
+)
+
+// workerPool returns the pool shared by all ingestion runs of the process,
+// starting it with INGEST_WORKERS workers (default 7) on first use.
+func workerPool() *WorkerPool {
+	sharedPoolMu.Lock()
+	defer sharedPoolMu.Unlock()
+	if sharedPool == nil {
+		size := config.AppConfig.Ingest.Workers
+		if size <= 0 {
+			size = defaultWorkers
+		}
+		sharedPool = NewWorkerPool(size)
+	}
+	return sharedPool
+}
+
+// ShutdownWorkerPool shuts the shared pool down (see WorkerPool.Shutdown); a
+// later run starts a fresh one. It is meant for process shutdown.
+func ShutdownWorkerPool(ctx context.Context) error {
+	sharedPoolMu.Lock()
+	p := sharedPool
+	sharedPool = nil
+	sharedPoolMu.Unlock()
+	if p == nil {
+		return nil
+	}
+	return p.Shutdown(ctx)
+}
@@ -0,0 +1,100 @@
+package ingestion
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool_BoundsConcurrency(t *testing.T) {
+	p := NewWorkerPool(2)
+	defer func() { _ = p.Shutdown(context.Background()) }()
+
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = p.Submit(context.Background(), func() error {
+				n := running.Add(1)
+				for {
+					old := peak.Load()
+					if n <= old || peak.CompareAndSwap(old, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	if got := peak.Load(); got > 2 {
+		t.Fatalf("peak concurrency = %d, want <= 2", got)
+	}
+}
+
+func TestWorkerPool_SubmitReturnsTaskError(t *testing.T) {
+	p := NewWorkerPool(1)
+	defer func() { _ = p.Shutdown(context.Background()) }()
+
+	boom := errors.New("boom")
+	if err := p.Submit(context.Background(), func() error { return boom }); !errors.Is(err, boom) {
+		t.Fatalf("Submit err = %v, want %v", err, boom)
+	}
+	if err := p.Submit(context.Background(), func() error { return nil }); err != nil {
+		t.Fatalf("Submit err = %v, want nil", err)
+	}
+}
+
+func TestWorkerPool_SubmitAfterShutdown(t *testing.T) {
+	p := NewWorkerPool(1)
+	for range 2 {
+		if err := p.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+	}
+	if err := p.Submit(context.Background(), func() error { return nil }); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("Submit after Shutdown err = %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestWorkerPool_SubmitCancelled(t *testing.T) {
+	p := NewWorkerPool(1)
+	defer func() { _ = p.Shutdown(context.Background()) }()
+
+	// Already cancelled: fn never runs.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var ran atomic.Bool
+	if err := p.Submit(ctx, func() error { ran.Store(true); return nil }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Submit err = %v, want context.Canceled", err)
+	}
+
+	// Cancelled while queued behind a busy worker: Submit returns without
+	// waiting for it, and fn is skipped.
+	release := make(chan struct{})
+	busy := make(chan error, 1)
+	started := make(chan struct{})
+	go func() {
+		busy <- p.Submit(context.Background(), func() error { close(started); <-release; return nil })
+	}()
+	<-started
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Submit(ctx, func() error { ran.Store(true); return nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Submit err = %v, want context.DeadlineExceeded", err)
+	}
+	close(release)
+	if err := <-busy; err != nil {
+		t.Fatalf("busy task err = %v", err)
+	}
+	if ran.Load() {
+		t.Fatal("cancelled task ran")
+	}
+}
@@ -0,0 +1,67 @@
+// Package instrument canonicalizes instrument codes so that the same asset
+// published under different codes aggregates under a single ticker.
+package instrument
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// aliases is read on every Canonical call and swapped atomically by SetAliases.
+var aliases atomic.Pointer[map[string]string]
+
+// SetAliases replaces the alias map. Entries are "ALIAS=CANONICAL"; codes are
+// trimmed and upper-cased. An alias mapped twice, or a canonical code that is
+// itself an alias (a chain), returns an error and leaves the current map
+// unchanged, so the result never depends on entry order. nil clears the map.
+func SetAliases(entries []string) error {
+	m := make(map[string]string, len(entries))
+	for _, e := range entries {
+		from, to, ok := strings.Cut(e, "=")
+		from, to = normalize(from), normalize(to)
+		if !ok || from == "" || to == "" {
+			return fmt.Errorf("instrument alias %q: expected ALIAS=CANONICAL", e)
+		}
+		if prev, dup := m[from]; dup && prev != to {
+			return fmt.Errorf("instrument alias %q: %s already maps to %s", e, from, prev)
+		}
+		m[from] = to
+	}
+	for from, to := range m {
+		if _, chained := m[to]; chained {
+			return fmt.Errorf("instrument alias %s=%s: %s is itself an alias", from, to, to)
+		}
+	}
+	aliases.Store(&m)
+	return nil
+}
+
+// Canonical returns the canonical code for code, or code itself when it has no alias.
+func Canonical(code string) string {
+	if m := aliases.Load(); m != nil {
+		if to, ok := (*m)[code]; ok {
+			return to
+		}
+	}
+	return code
+}
+
+// Aliases returns the configured mapping as sorted "ALIAS=CANONICAL" pairs, for logging.
+func Aliases() []string {
+	m := aliases.Load()
+	if m == nil {
+		return nil
+	}
+	out := make([]string, 0, len(*m))
+	for from, to := range *m {
+		out = append(out, from+"="+to)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,44 @@
+package instrument
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetAliases(t *testing.T) {
+	t.Cleanup(func() { _ = SetAliases(nil) })
+
+	cases := []struct {
+		name    string
+		entries []string
+		wantErr bool
+	}{
+		{name: "valid", entries: []string{"petr4f = PETR4", "VALE3F=VALE3", "VALE3F=VALE3"}},
+		{name: "missing separator", entries: []string{"PETR4F"}, wantErr: true},
+		{name: "empty side", entries: []string{"PETR4F="}, wantErr: true},
+		{name: "conflicting targets", entries: []string{"X=PETR4", "X=VALE3"}, wantErr: true},
+		{name: "chain", entries: []string{"A=B", "B=C"}, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := SetAliases(tc.entries); (err != nil) != tc.wantErr {
+				t.Fatalf("SetAliases err=%v, wantErr=%v", err, tc.wantErr)
+			}
+		})
+	}
+
+	// Failed calls above must not have replaced the valid map.
+	if got := Canonical("PETR4F"); got != "PETR4" {
+		t.Fatalf("Canonical(PETR4F)=%q, want PETR4", got)
+	}
+	if got := Canonical("ITUB4"); got != "ITUB4" {
+		t.Fatalf("unmapped code must pass through, got %q", got)
+	}
+	if got, want := Aliases(), []string{"PETR4F=PETR4", "VALE3F=VALE3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Aliases()=%v, want %v", got, want)
+	}
+
+	if err := SetAliases(nil); err != nil || Canonical("PETR4F") != "PETR4F" || len(Aliases()) != 0 {
+		t.Fatalf("nil should clear the map")
+	}
+}
@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// logfmtWriter converts zerolog's JSON events into logfmt lines
+// (key=value pairs separated by spaces), preserving field order.
+//
+// zerolog issues exactly one Write per event, so each call receives a
+// complete JSON object.
+type logfmtWriter struct {
+	out io.Writer
+}
+
+// Write implements io.Writer.
+func (w *logfmtWriter) Write(p []byte) (int, error) {
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+
+	tok, err := dec.Token()
+	if err != nil {
+		return 0, fmt.Errorf("logfmt: decode event: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return 0, fmt.Errorf("logfmt: expected JSON object, got %v", tok)
+	}
+
+	var buf bytes.Buffer
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return 0, fmt.Errorf("logfmt: decode key: %w", err)
+		}
+		key, _ := keyTok.(string)
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return 0, fmt.Errorf("logfmt: decode value for %q: %w", key, err)
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(raw))
+	}
+	buf.WriteByte('\n')
+
+	if _, err := w.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// logfmtValue renders a raw JSON value as a logfmt value. Strings are unquoted
+// when safe; nested objects/arrays are kept as compact JSON and quoted.
+func logfmtValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		// Non-string (number, bool, null, object, array)
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, raw); err == nil {
+			s = compact.String()
+		} else {
+			s = string(raw)
+		}
+		if len(s) > 0 && (s[0] == '{' || s[0] == '[') {
+			return strconv.Quote(s)
+		}
+		return s
+	}
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
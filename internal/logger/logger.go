@@ -1,43 +1,92 @@
 package logger
 
 import (
+	"context"
 	"io"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog"
 )
 
+// Supported values for LOG_FORMAT.
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+	FormatLogfmt  = "logfmt"
+)
+
+// base is swapped atomically so the level can change while requests are logging.
 var (
-	base zerolog.Logger
+	base atomic.Pointer[zerolog.Logger]
 )
 
-// Init configures the global JSON logger.
+// Init configures the global logger.
 //
 // Environment variables (optional):
 //   - LOG_LEVEL: debug|info|warn|error (default: info)
-//   - LOG_PRETTY: true|false (default: false)
+//   - LOG_FORMAT: json|console|logfmt (default: json)
+//   - LOG_PRETTY: true|false (deprecated; "true" maps to LOG_FORMAT=console when LOG_FORMAT is unset)
 func Init() {
+	InitTo(os.Stdout)
+}
+
+// InitTo is Init writing to out instead of stdout, e.g. stderr when stdout
+// carries command output.
+func InitTo(out io.Writer) {
 	level := parseLevel(getenv("LOG_LEVEL", "info"))
-	pretty := strings.EqualFold(getenv("LOG_PRETTY", "false"), "true")
+	format := parseFormat(os.Getenv("LOG_FORMAT"), os.Getenv("LOG_PRETTY"))
 
 	zerolog.TimeFieldFormat = time.RFC3339Nano
-	var w io.Writer = os.Stdout
-	if pretty {
-		cw := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
-		w = cw
-	}
-	l := zerolog.New(w).With().Timestamp().Logger().Level(level)
-	base = l
+	l := newLogger(out, format, level)
+	base.Store(&l)
+}
+
+// SetLevel replaces the global logger with a copy at the given level
+// (debug|info|warn|error), keeping its output and format. Loggers already
+// obtained from L() keep their previous level.
+func SetLevel(s string) {
+	l := L().Level(parseLevel(s))
+	base.Store(&l)
 }
 
 // L returns the global logger. Call Init() once on startup.
 func L() *zerolog.Logger {
-	if base.GetLevel() == zerolog.NoLevel {
-		Init()
+	if l := base.Load(); l != nil {
+		return l
 	}
-	return &base
+	Init()
+	return base.Load()
+}
+
+type ctxKey struct{}
+
+// WithContext returns a copy of ctx carrying l, for FromContext further down
+// the call chain (handlers → service → repository).
+func WithContext(ctx context.Context, l *zerolog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger stored by WithContext, or L() when ctx has none.
+func FromContext(ctx context.Context) *zerolog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*zerolog.Logger); ok && l != nil {
+		return l
+	}
+	return L()
+}
+
+// newLogger builds a logger writing to out in the given format.
+func newLogger(out io.Writer, format string, level zerolog.Level) zerolog.Logger {
+	var w io.Writer = out
+	switch format {
+	case FormatConsole:
+		w = zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339}
+	case FormatLogfmt:
+		w = &logfmtWriter{out: out}
+	}
+	return zerolog.New(w).With().Timestamp().Logger().Level(level)
 }
 
 func getenv(key, def string) string {
@@ -59,3 +108,21 @@ func parseLevel(s string) zerolog.Level {
 		return zerolog.InfoLevel
 	}
 }
+
+// parseFormat resolves the output format from LOG_FORMAT, falling back to the
+// legacy LOG_PRETTY flag when LOG_FORMAT is not set. Unknown values default to JSON.
+func parseFormat(format, pretty string) string {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case FormatConsole, "pretty":
+		return FormatConsole
+	case FormatLogfmt:
+		return FormatLogfmt
+	case FormatJSON:
+		return FormatJSON
+	case "":
+		if strings.EqualFold(pretty, "true") {
+			return FormatConsole
+		}
+	}
+	return FormatJSON
+}
@@ -1,7 +1,12 @@
 package logger
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/rs/zerolog"
@@ -57,8 +62,8 @@ func TestInitAndL(t *testing.T) {
 
 // Ensure L() never returns nil and initializes level if not set
 func TestLoggerAccessor_NotNil(t *testing.T) {
-	// Reset base to zero value to force Init path
-	base = zerolog.Logger{}
+	// Reset base to force Init path
+	base.Store(nil)
 	lg := L()
 	if lg == nil {
 		t.Fatalf("logger is nil")
@@ -68,3 +73,142 @@ func TestLoggerAccessor_NotNil(t *testing.T) {
 		t.Fatalf("logger level not initialized")
 	}
 }
+
+func TestInitTo(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "info")
+	t.Setenv("LOG_FORMAT", "json")
+	var buf bytes.Buffer
+	InitTo(&buf)
+	t.Cleanup(Init)
+
+	L().Info().Msg("to writer")
+	if !strings.Contains(buf.String(), `"message":"to writer"`) {
+		t.Fatalf("expected log line in writer, got %q", buf.String())
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	_ = os.Unsetenv("LOG_LEVEL")
+	Init()
+	SetLevel("warn")
+	if L().GetLevel() != zerolog.WarnLevel {
+		t.Fatalf("expected warn level, got %v", L().GetLevel())
+	}
+	SetLevel("bogus")
+	if L().GetLevel() != zerolog.InfoLevel {
+		t.Fatalf("unknown level should fall back to info, got %v", L().GetLevel())
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	Init()
+	if FromContext(context.Background()) != L() {
+		t.Fatalf("empty context should fall back to L()")
+	}
+	l := L().With().Str("request_id", "abc").Logger()
+	if got := FromContext(WithContext(context.Background(), &l)); got != &l {
+		t.Fatalf("expected stored logger, got %p want %p", got, &l)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	cases := []struct {
+		format string
+		pretty string
+		want   string
+	}{
+		{"", "", FormatJSON},
+		{"json", "true", FormatJSON},
+		{"console", "", FormatConsole},
+		{"LOGFMT", "", FormatLogfmt},
+		{"", "true", FormatConsole}, // back-compat with LOG_PRETTY
+		{"", "false", FormatJSON},
+		{"xml", "", FormatJSON},
+	}
+	for _, c := range cases {
+		if got := parseFormat(c.format, c.pretty); got != c.want {
+			t.Fatalf("parseFormat(%q,%q)=%q, want %q", c.format, c.pretty, got, c.want)
+		}
+	}
+}
+
+func TestNewLogger_Formats(t *testing.T) {
+	cases := []struct {
+		format string
+		assert func(t *testing.T, line string)
+	}{
+		{
+			format: FormatJSON,
+			assert: func(t *testing.T, line string) {
+				var m map[string]any
+				if err := json.Unmarshal([]byte(line), &m); err != nil {
+					t.Fatalf("invalid json %q: %v", line, err)
+				}
+				if m["message"] != "hello world" || m["ticker"] != "PETR4" {
+					t.Fatalf("unexpected fields: %v", m)
+				}
+			},
+		},
+		{
+			format: FormatLogfmt,
+			assert: func(t *testing.T, line string) {
+				kv := parseLogfmt(t, line)
+				if kv["level"] != "info" || kv["ticker"] != "PETR4" || kv["message"] != "hello world" || kv["rows"] != "42" {
+					t.Fatalf("unexpected fields: %v", kv)
+				}
+				if kv["time"] == "" {
+					t.Fatalf("missing time field: %q", line)
+				}
+			},
+		},
+		{
+			format: FormatConsole,
+			assert: func(t *testing.T, line string) {
+				if !strings.Contains(line, "INF") || !strings.Contains(line, "hello world") || !strings.Contains(line, "PETR4") {
+					t.Fatalf("unexpected console line: %q", line)
+				}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := newLogger(&buf, c.format, zerolog.InfoLevel)
+			l.Info().Str("ticker", "PETR4").Int("rows", 42).Msg("hello world")
+			c.assert(t, strings.TrimSpace(buf.String()))
+		})
+	}
+}
+
+// parseLogfmt is a minimal logfmt reader supporting bare and quoted values.
+func parseLogfmt(t *testing.T, line string) map[string]string {
+	t.Helper()
+	out := map[string]string{}
+	for len(line) > 0 {
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			t.Fatalf("malformed logfmt near %q", line)
+		}
+		key := line[:eq]
+		line = line[eq+1:]
+		var val string
+		if strings.HasPrefix(line, `"`) {
+			q, err := strconv.QuotedPrefix(line)
+			if err != nil {
+				t.Fatalf("bad quoted value %q: %v", line, err)
+			}
+			val, _ = strconv.Unquote(q)
+			line = line[len(q):]
+		} else if sp := strings.IndexByte(line, ' '); sp >= 0 {
+			val = line[:sp]
+			line = line[sp:]
+		} else {
+			val = line
+			line = ""
+		}
+		out[key] = val
+		line = strings.TrimPrefix(line, " ")
+	}
+	return out
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminKeyHeader is the request header carrying the admin API key.
+const AdminKeyHeader = "X-Admin-Key"
+
+// AdminAuth is a Gin middleware guarding administrative endpoints with a static API key.
+//
+// Behavior:
+//   - If key is empty, admin auth is disabled and every request passes through.
+//   - Otherwise the X-Admin-Key header must match key (constant-time comparison),
+//     or the request is aborted with HTTP 401.
+//
+// Usage:
+//
+//	admin := router.Group("/admin", middleware.AdminAuth(config.AppConfig.Admin.APIKey))
+func AdminAuth(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key == "" {
+			c.Next()
+			return
+		}
+		got := c.GetHeader(AdminKeyHeader)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(key)) != 1 {
+			AbortWithError(c, http.StatusUnauthorized, "invalid or missing admin key", nil)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/internal/domain/dto"
+)
+
+// bodyTooLargeMsg is the ErrorResponse message for requests rejected by BodyLimit.
+const bodyTooLargeMsg = "request body too large"
+
+// limitedBody wraps an http.MaxBytesReader and remembers whether the limit was hit.
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if IsBodyTooLarge(err) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// IsBodyTooLarge reports whether err comes from reading past the BodyLimit.
+// Handlers that read the body should answer such errors with 413.
+func IsBodyTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// BodyLimit is a Gin middleware capping the body of mutating requests
+// (POST, PUT, PATCH, DELETE) at maxBytes; maxBytes <= 0 disables it.
+//
+// Behavior:
+//   - A Content-Length above maxBytes is rejected with 413 before the handler runs.
+//   - Otherwise the body is wrapped in http.MaxBytesReader, so a chunked or
+//     understated body fails to read past the limit instead of being buffered.
+//     If the handler then writes nothing, the middleware responds 413.
+//
+// 413 responses carry an ErrorResponse with code BODY_TOO_LARGE.
+func BodyLimit(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes <= 0 || c.Request.Body == nil || !isMutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxBytes {
+			c.Abort()
+			RespondErrorCode(c, http.StatusRequestEntityTooLarge, dto.CodeBodyTooLarge, bodyTooLargeMsg, nil)
+			return
+		}
+
+		body := &limitedBody{ReadCloser: http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)}
+		c.Request.Body = body
+		c.Next()
+
+		if body.exceeded && !c.Writer.Written() {
+			RespondErrorCode(c, http.StatusRequestEntityTooLarge, dto.CodeBodyTooLarge, bodyTooLargeMsg, nil)
+		}
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
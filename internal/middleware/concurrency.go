@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/internal/domain/dto"
+)
+
+// serverBusyMsg is the ErrorResponse message for requests shed by ConcurrencyLimit.
+const serverBusyMsg = "server busy, retry later"
+
+// ConcurrencyLimit is a Gin middleware bounding the number of requests served
+// at once, whatever client they come from; maxInFlight <= 0 disables it. It
+// complements the per-IP RateLimiter, which cannot stop one client from
+// running many expensive queries concurrently.
+//
+// Behavior:
+//   - A request runs as soon as fewer than maxInFlight requests are in flight.
+//   - Otherwise it waits up to queueWait for a slot; at most maxInFlight
+//     requests wait at once, so further ones are shed immediately.
+//   - A shed request gets 503 with code SERVER_BUSY and a Retry-After header;
+//     a request whose client goes away while waiting is aborted the same way.
+func ConcurrencyLimit(maxInFlight int, queueWait time.Duration) gin.HandlerFunc {
+	if maxInFlight <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	slots := make(chan struct{}, maxInFlight)
+	queue := make(chan struct{}, maxInFlight)
+	retryAfter := strconv.Itoa(max(1, int(queueWait.Round(time.Second)/time.Second)))
+
+	shed := func(c *gin.Context) {
+		Log(c).Warn().Int("max_in_flight", maxInFlight).Msg("request shed, too many in flight")
+		c.Header("Retry-After", retryAfter)
+		c.Abort()
+		RespondErrorCode(c, http.StatusServiceUnavailable, dto.CodeServerBusy, serverBusyMsg, nil)
+	}
+
+	return func(c *gin.Context) {
+		select {
+		case slots <- struct{}{}:
+		default:
+			if !wait(c, slots, queue, queueWait) {
+				shed(c)
+				return
+			}
+		}
+		defer func() { <-slots }()
+		c.Next()
+	}
+}
+
+// wait queues c for a slot for up to d; it reports whether one was taken.
+func wait(c *gin.Context, slots, queue chan struct{}, d time.Duration) bool {
+	if d <= 0 {
+		return false
+	}
+	select {
+	case queue <- struct{}{}:
+		defer func() { <-queue }()
+	default:
+		return false // queue full
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
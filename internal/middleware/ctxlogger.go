@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/internal/logger"
+	"github.com/rs/zerolog"
+)
+
+// LoggerKey is the Gin context key holding the request-scoped *zerolog.Logger.
+const LoggerKey = "logger"
+
+// DebugHeader is the request header that asks for debug logging of a single
+// request (see DebugLogging).
+const DebugHeader = "X-Debug"
+
+// ContextLogger is a Gin middleware that derives a logger carrying the
+// request_id (set by RequestID(), which must run first) and stores it both in
+// the Gin context and in the request context, so handlers and the layers they
+// call log correlated lines.
+//
+// Usage:
+//
+//	router.Use(middleware.RequestID(), middleware.ContextLogger())
+//
+//	middleware.Log(c).Info().Msg("in the handler")
+//	logger.FromContext(ctx).Debug().Msg("in the service")
+func ContextLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		l := logger.L().With().Str("request_id", c.GetString(RequestIDKey)).Logger()
+		SetLogger(c, &l)
+		c.Next()
+	}
+}
+
+// DebugLogging is a Gin middleware that lowers the request-scoped logger to
+// debug level for requests sending "X-Debug: true", so a single client can be
+// traced in production without raising LOG_LEVEL for everyone. It must run
+// after ContextLogger(); with allow false (ALLOW_DEBUG_HEADER unset) the
+// header is ignored.
+//
+// Behavior:
+//   - Debug lines logged through Log(c) or logger.FromContext by the handler,
+//     service and repository are written for that request only.
+//   - Once the handler returns, a "debug request" line records the method,
+//     path, raw query, status and latency, plus any fields the handler added
+//     to the logger (e.g. the resolved ticker).
+func DebugLogging(allow bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if on, _ := strconv.ParseBool(c.GetHeader(DebugHeader)); !allow || !on {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		l := Log(c).Level(zerolog.DebugLevel).With().Bool("debug", true).Logger()
+		SetLogger(c, &l)
+
+		c.Next()
+
+		Log(c).Debug().
+			Str("method", c.Request.Method).
+			Str("path", c.Request.URL.Path).
+			Str("query", c.Request.URL.RawQuery).
+			Int("status", c.Writer.Status()).
+			Int64("latency_ms", time.Since(start).Milliseconds()).
+			Msg("debug request")
+	}
+}
+
+// SetLogger replaces the request-scoped logger, e.g. after adding handler
+// fields such as the ticker, so that the request context passed downstream
+// carries it too.
+func SetLogger(c *gin.Context, l *zerolog.Logger) {
+	c.Set(LoggerKey, l)
+	c.Request = c.Request.WithContext(logger.WithContext(c.Request.Context(), l))
+}
+
+// Log returns the request-scoped logger, or the global one when
+// ContextLogger() did not run.
+func Log(c *gin.Context) *zerolog.Logger {
+	if v, ok := c.Get(LoggerKey); ok {
+		if l, ok := v.(*zerolog.Logger); ok {
+			return l
+		}
+	}
+	return logger.L()
+}
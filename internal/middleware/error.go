@@ -4,7 +4,6 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/guttosm/b3pulse/internal/domain/dto"
 )
 
 // ErrorHandler is a Gin middleware that captures any errors registered during
@@ -23,10 +22,9 @@ func ErrorHandler(c *gin.Context) {
 
 	if len(c.Errors) > 0 {
 		firstErr := c.Errors[0].Err
-		errResponse := dto.NewErrorResponse("An unexpected error occurred", firstErr)
 
 		if !c.Writer.Written() {
-			c.JSON(http.StatusInternalServerError, errResponse)
+			RespondError(c, http.StatusInternalServerError, "An unexpected error occurred", firstErr)
 		}
 	}
 }
@@ -40,9 +38,9 @@ func ErrorHandler(c *gin.Context) {
 //   - err (error): The technical error (optional, can be nil).
 //
 // Behavior:
-//   - Constructs an ErrorResponse with the provided message and error.
+//   - Constructs an ErrorResponse with the provided message and error (enveloped when API_ENVELOPE=true).
 //   - Aborts the request immediately and writes the response.
 func AbortWithError(c *gin.Context, status int, msg string, err error) {
-	errResp := dto.NewErrorResponse(msg, err)
-	c.AbortWithStatusJSON(status, errResp)
+	c.Abort()
+	RespondError(c, status, msg, err)
 }
@@ -1,12 +1,14 @@
 package middleware
 
 import (
+	"context"
+	"math/rand/v2"
 	"net/http"
-	"time"
-
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/internal/domain/dto"
 	"github.com/guttosm/b3pulse/internal/logger"
 )
 
@@ -71,21 +73,111 @@ type client struct {
 	count    int
 }
 
+// RateLimitStore counts requests per client key. Incr records one request
+// from key at now and returns how many the key has made in the current window.
+// An error means the count is unknown; RateLimiter then applies the fail-open
+// policy (see SetRateLimitFailOpen).
+type RateLimitStore interface {
+	Incr(key string, now time.Time, window time.Duration) (int, error)
+}
+
+// RateLimitStorePinger is implemented by RateLimitStores that can report
+// whether their backend is reachable, e.g. a shared Redis.
+type RateLimitStorePinger interface {
+	Ping(ctx context.Context) error
+}
+
+// RateLimitStoreHealth pings the current RateLimitStore if it implements
+// RateLimitStorePinger; other stores, like the in-memory default, are always
+// healthy. It is used as a /health/detail check.
+func RateLimitStoreHealth(ctx context.Context) error {
+	rateLimiterLock.Lock()
+	st := store
+	rateLimiterLock.Unlock()
+	if p, ok := st.(RateLimitStorePinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// memoryStore is the default RateLimitStore, backed by the package-level
+// clients map. It never fails.
+type memoryStore struct{}
+
+// Incr implements RateLimitStore.
+func (memoryStore) Incr(key string, now time.Time, window time.Duration) (int, error) {
+	rateLimiterLock.Lock()
+	defer rateLimiterLock.Unlock()
+	cl, ok := clients[key]
+	if !ok || now.Sub(cl.lastSeen) > window {
+		cl = &client{lastSeen: now, count: 1}
+		clients[key] = cl
+	} else {
+		cl.count++
+		cl.lastSeen = now
+	}
+	return cl.count, nil
+}
+
 // Global in-memory store for rate limiting.
 // NOTE: In production, consider Redis or another distributed store for multi-instance deployments.
 var (
-	clients         = make(map[string]*client)
-	window          = time.Minute
-	limit           = 60
+	clients                        = make(map[string]*client)
+	window                         = time.Minute
+	limit                          = 60
+	store           RateLimitStore = memoryStore{}
+	failOpen                       = true
 	rateLimiterLock sync.Mutex
+
+	// sweeperStop is non-nil while the background sweeper is running; sweeperDone
+	// is closed once it has exited. Both are guarded by sweeperLock.
+	sweeperStop chan struct{}
+	sweeperDone chan struct{}
+	sweeperLock sync.Mutex
 )
 
+// SetRateLimit replaces the rate-limit parameters used by RateLimiter; it is
+// safe to call while serving. Non-positive values keep the current setting.
+// Existing per-client counters are kept and judged against the new values.
+func SetRateLimit(requests int, per time.Duration) {
+	rateLimiterLock.Lock()
+	defer rateLimiterLock.Unlock()
+	if requests > 0 {
+		limit = requests
+	}
+	if per > 0 {
+		window = per
+	}
+}
+
+// SetRateLimitStore replaces the store RateLimiter counts requests in; nil
+// restores the in-memory default. It is safe to call while serving.
+func SetRateLimitStore(s RateLimitStore) {
+	if s == nil {
+		s = memoryStore{}
+	}
+	rateLimiterLock.Lock()
+	defer rateLimiterLock.Unlock()
+	store = s
+}
+
+// SetRateLimitFailOpen sets what RateLimiter does when the store errors:
+// allow the request (true, the default) or reject it with 503 (false).
+// It is safe to call while serving.
+func SetRateLimitFailOpen(open bool) {
+	rateLimiterLock.Lock()
+	defer rateLimiterLock.Unlock()
+	failOpen = open
+}
+
 // RateLimiter is a simple in-memory middleware that limits the number of requests per client IP.
 //
 // Behavior:
-//   - Allows up to `limit` requests per `window` (default: 60 requests per 1 minute).
+//   - Allows up to `limit` requests per `window` (default: 60 requests per 1 minute; see SetRateLimit).
 //   - Identifies clients by their IP address.
 //   - If limit exceeded, returns HTTP 429 Too Many Requests.
+//   - If the store errors, logs a warning and either lets the request through
+//     or returns HTTP 503 Service Unavailable, per SetRateLimitFailOpen.
 //
 // Usage:
 //
@@ -96,33 +188,98 @@ var (
 //
 //	HTTP/1.1 429 Too Many Requests
 //	{
-//	    "error": "rate limit exceeded"
+//	    "message": "rate limit exceeded",
+//	    "code": "RATE_LIMITED",
+//	    ...
 //	}
+//
+// Both rejections are written with RespondErrorCode, so they follow API_ENVELOPE.
+//
+// The first call also starts a background sweeper that evicts idle clients;
+// call StopRateLimiterSweeper on shutdown.
 func RateLimiter() gin.HandlerFunc {
+	startRateLimiterSweeper()
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		now := time.Now()
-
-		// simple mutex using gin context to avoid global data race without introducing external deps
-		// NOTE: for high concurrency or multi-instance, use Redis or sync.Map with a proper mutex.
-		// Here we'll protect the map via a channel-like critical section using a package-level mutex.
 		rateLimiterLock.Lock()
-		cl, ok := clients[ip]
-		if !ok || now.Sub(cl.lastSeen) > window {
-			cl = &client{lastSeen: now, count: 1}
-			clients[ip] = cl
-		} else {
-			cl.count++
-			cl.lastSeen = now
-		}
-		exceeded := cl.count > limit
+		st, lim, win, open := store, limit, window, failOpen
 		rateLimiterLock.Unlock()
 
-		if exceeded {
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+		count, err := st.Incr(c.ClientIP(), time.Now(), win)
+		if err != nil {
+			Log(c).Warn().Err(err).Bool("fail_open", open).Msg("rate limit store error; applying fallback policy")
+			if !open {
+				c.Abort()
+				RespondErrorCode(c, http.StatusServiceUnavailable, dto.CodeRateLimitDown, "rate limiter unavailable", nil)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if count > lim {
+			c.Abort()
+			RespondErrorCode(c, http.StatusTooManyRequests, dto.CodeRateLimited, "rate limit exceeded", nil)
 			return
 		}
 
 		c.Next()
 	}
 }
+
+// startRateLimiterSweeper launches the idle-client sweeper unless it is already running.
+//
+// Each pass waits for one window plus up to 10% random jitter, so instances
+// started together do not sweep (and contend on the lock) in lockstep.
+func startRateLimiterSweeper() {
+	sweeperLock.Lock()
+	defer sweeperLock.Unlock()
+	if sweeperStop != nil {
+		return
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	sweeperStop, sweeperDone = stop, done
+
+	go func() {
+		defer close(done)
+		for {
+			rateLimiterLock.Lock()
+			interval := window
+			rateLimiterLock.Unlock()
+			if j := int64(interval / 10); j > 0 {
+				interval += time.Duration(rand.Int64N(j))
+			}
+
+			timer := time.NewTimer(interval)
+			select {
+			case <-stop:
+				timer.Stop()
+				return
+			case now := <-timer.C:
+				sweepClients(now)
+			}
+		}
+	}()
+}
+
+// StopRateLimiterSweeper stops the background sweeper started by RateLimiter
+// and waits for it to exit. It is safe to call multiple times.
+func StopRateLimiterSweeper() {
+	sweeperLock.Lock()
+	defer sweeperLock.Unlock()
+	if sweeperStop != nil {
+		close(sweeperStop)
+		<-sweeperDone
+		sweeperStop, sweeperDone = nil, nil
+	}
+}
+
+// sweepClients removes clients not seen within the current window.
+func sweepClients(now time.Time) {
+	rateLimiterLock.Lock()
+	defer rateLimiterLock.Unlock()
+	for ip, cl := range clients {
+		if now.Sub(cl.lastSeen) > window {
+			delete(clients, ip)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -44,6 +45,38 @@ func TestRequestLogger_Basic(t *testing.T) {
 	}
 }
 
+func TestContextLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	logger.Init()
+	var buf bytes.Buffer
+	router.Use(RequestID(), ContextLogger())
+	router.GET("/ping", func(c *gin.Context) {
+		if logger.FromContext(c.Request.Context()) != Log(c) {
+			t.Errorf("request context and gin context loggers differ")
+		}
+		l := Log(c).Output(&buf)
+		l.Info().Msg("in handler")
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	rid := w.Header().Get("X-Request-ID")
+	if rid == "" || !strings.Contains(buf.String(), `"request_id":"`+rid+`"`) {
+		t.Fatalf("log line missing request_id %q: %s", rid, buf.String())
+	}
+}
+
+func TestLog_WithoutContextLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if Log(c) != logger.L() {
+		t.Fatalf("expected global logger fallback")
+	}
+}
+
 func TestRequestLogger_ClientIP(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
@@ -62,3 +95,51 @@ func TestRequestLogger_ClientIP(t *testing.T) {
 		t.Fatalf("status %d, want 200", w.Code)
 	}
 }
+
+func TestDebugLogging(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("LOG_LEVEL", "info")
+	t.Cleanup(logger.Init)
+
+	cases := []struct {
+		name      string
+		allow     bool
+		header    string
+		wantDebug bool
+	}{
+		{name: "allowed with header", allow: true, header: "true", wantDebug: true},
+		{name: "allowed without header", allow: true},
+		{name: "allowed with header off", allow: true, header: "false"},
+		{name: "header not allowed", allow: false, header: "true"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger.InitTo(&buf)
+			router := gin.New()
+			router.Use(RequestID(), ContextLogger(), DebugLogging(tc.allow))
+			router.GET("/ping", func(c *gin.Context) {
+				Log(c).Debug().Msg("in handler")
+				logger.FromContext(c.Request.Context()).Debug().Msg("in service")
+				Log(c).Info().Msg("always")
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/ping?ticker=PETR4", nil)
+			if tc.header != "" {
+				req.Header.Set(DebugHeader, tc.header)
+			}
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			out := buf.String()
+			if !strings.Contains(out, `"message":"always"`) {
+				t.Fatalf("info line missing: %s", out)
+			}
+			for _, want := range []string{`"message":"in handler"`, `"message":"in service"`, `"message":"debug request"`, `"query":"ticker=PETR4"`, `"latency_ms":`} {
+				if got := strings.Contains(out, want); got != tc.wantDebug {
+					t.Fatalf("%s present=%v, want %v: %s", want, got, tc.wantDebug, out)
+				}
+			}
+		})
+	}
+}
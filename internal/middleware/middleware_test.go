@@ -1,12 +1,21 @@
 package middleware
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/config"
+	"github.com/guttosm/b3pulse/internal/domain/dto"
+	"github.com/rs/zerolog"
 )
 
 func TestRequestID(t *testing.T) {
@@ -54,35 +63,174 @@ func TestRecoveryMiddleware(t *testing.T) {
 
 func TestRateLimiter(t *testing.T) {
 	cases := []struct {
-		name   string
-		reqs   int
-		lim    int
-		expect int
+		name     string
+		reqs     int
+		lim      int
+		envelope bool
+		expect   int
+		wantBody string
 	}{
 		{name: "within limit", reqs: 2, lim: 3, expect: http.StatusOK},
-		{name: "exceed limit", reqs: 5, lim: 3, expect: http.StatusTooManyRequests},
+		{name: "exceed limit", reqs: 5, lim: 3, expect: http.StatusTooManyRequests, wantBody: `"code":"RATE_LIMITED"`},
+		{name: "exceed limit, enveloped", reqs: 5, lim: 3, envelope: true, expect: http.StatusTooManyRequests, wantBody: `"error":{"message":"rate limit exceeded"`},
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			gin.SetMode(gin.TestMode)
+			config.AppConfig.Server.Envelope = tc.envelope
+			t.Cleanup(func() { config.AppConfig.Server.Envelope = false })
 			r := gin.New()
 			window = time.Millisecond * 100
 			limit = tc.lim
 			r.Use(RateLimiter())
+			t.Cleanup(StopRateLimiterSweeper)
 			r.GET("/", func(c *gin.Context) { c.String(200, "ok") })
-			var last int
+			var last *httptest.ResponseRecorder
 			for i := 0; i < tc.reqs; i++ {
-				w := httptest.NewRecorder()
-				r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
-				last = w.Code
+				last = httptest.NewRecorder()
+				r.ServeHTTP(last, httptest.NewRequest(http.MethodGet, "/", nil))
+			}
+			if last.Code != tc.expect {
+				t.Fatalf("expected %d, got %d", tc.expect, last.Code)
 			}
-			if last != tc.expect {
-				t.Fatalf("expected %d, got %d", tc.expect, last)
+			if !strings.Contains(last.Body.String(), tc.wantBody) {
+				t.Fatalf("expected body with %s, got %s", tc.wantBody, last.Body.String())
 			}
 		})
 	}
 }
 
+// failingStore is a RateLimitStore whose backend is down.
+type failingStore struct{}
+
+func (failingStore) Incr(string, time.Time, time.Duration) (int, error) {
+	return 0, errors.New("connection refused")
+}
+
+// pingingStore is a RateLimitStore that reports its backend health.
+type pingingStore struct {
+	memoryStore
+	err error
+}
+
+func (s pingingStore) Ping(context.Context) error { return s.err }
+
+func TestRateLimitStoreHealth(t *testing.T) {
+	t.Cleanup(func() { SetRateLimitStore(nil) })
+	if err := RateLimitStoreHealth(context.Background()); err != nil {
+		t.Fatalf("memory store: %v", err)
+	}
+	SetRateLimitStore(pingingStore{err: errors.New("connection refused")})
+	if err := RateLimitStoreHealth(context.Background()); err == nil {
+		t.Fatal("expected ping error")
+	}
+	SetRateLimitStore(pingingStore{})
+	if err := RateLimitStoreHealth(context.Background()); err != nil {
+		t.Fatalf("healthy store: %v", err)
+	}
+}
+
+func TestRateLimiter_StoreError(t *testing.T) {
+	cases := []struct {
+		name     string
+		open     bool
+		expect   int
+		wantBody string
+	}{
+		{name: "fail open", open: true, expect: http.StatusOK, wantBody: "ok"},
+		{name: "fail closed", open: false, expect: http.StatusServiceUnavailable, wantBody: `"code":"RATE_LIMITER_UNAVAILABLE"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			SetRateLimitStore(failingStore{})
+			SetRateLimitFailOpen(tc.open)
+			t.Cleanup(func() {
+				SetRateLimitStore(nil)
+				SetRateLimitFailOpen(true)
+			})
+
+			var buf bytes.Buffer
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				l := zerolog.New(&buf)
+				SetLogger(c, &l)
+			}, RateLimiter())
+			t.Cleanup(StopRateLimiterSweeper)
+			r.GET("/", func(c *gin.Context) { c.String(200, "ok") })
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tc.expect || !strings.Contains(w.Body.String(), tc.wantBody) {
+				t.Fatalf("expected %d with %s, got %d %s", tc.expect, tc.wantBody, w.Code, w.Body.String())
+			}
+			if !strings.Contains(buf.String(), `"level":"warn"`) || !strings.Contains(buf.String(), "connection refused") {
+				t.Fatalf("expected warn log with store error, got %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestSweepClients(t *testing.T) {
+	now := time.Now()
+	rateLimiterLock.Lock()
+	window = time.Minute
+	clients = map[string]*client{
+		"1.1.1.1": {lastSeen: now.Add(-2 * time.Minute), count: 5},
+		"2.2.2.2": {lastSeen: now.Add(-10 * time.Second), count: 1},
+	}
+	rateLimiterLock.Unlock()
+
+	sweepClients(now)
+
+	rateLimiterLock.Lock()
+	defer rateLimiterLock.Unlock()
+	if _, ok := clients["1.1.1.1"]; ok {
+		t.Fatalf("expected idle client to be evicted")
+	}
+	if _, ok := clients["2.2.2.2"]; !ok {
+		t.Fatalf("expected active client to be kept")
+	}
+}
+
+func TestRateLimiterSweeper_EvictsAndStops(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rateLimiterLock.Lock()
+	window = 20 * time.Millisecond
+	limit = 10
+	clients = make(map[string]*client)
+	rateLimiterLock.Unlock()
+
+	StopRateLimiterSweeper()
+	r := gin.New()
+	r.Use(RateLimiter())
+	t.Cleanup(StopRateLimiterSweeper)
+	r.GET("/", func(c *gin.Context) { c.String(200, "ok") })
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		rateLimiterLock.Lock()
+		n := len(clients)
+		rateLimiterLock.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sweeper did not evict idle client")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	StopRateLimiterSweeper()
+	StopRateLimiterSweeper() // idempotent
+	sweeperLock.Lock()
+	running := sweeperStop != nil
+	sweeperLock.Unlock()
+	if running {
+		t.Fatalf("expected sweeper to be stopped")
+	}
+}
+
 func TestAbortWithError(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
@@ -98,3 +246,309 @@ func TestAbortWithError(t *testing.T) {
 		t.Fatalf("expected content-type set")
 	}
 }
+
+func TestRespond_Envelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = prev })
+
+	cases := []struct {
+		name     string
+		envelope bool
+		path     string
+		status   int
+		want     func(t *testing.T, body map[string]any, rid string)
+	}{
+		{name: "bare success", path: "/ok", status: http.StatusOK, want: func(t *testing.T, body map[string]any, _ string) {
+			if body["ticker"] != "PETR4" || body["data"] != nil {
+				t.Fatalf("expected bare DTO, got %v", body)
+			}
+		}},
+		{name: "bare error", path: "/err", status: http.StatusBadRequest, want: func(t *testing.T, body map[string]any, _ string) {
+			if body["message"] != "bad stuff" || body["error"] != "boom" || body["code"] != nil {
+				t.Fatalf("expected bare ErrorResponse, got %v", body)
+			}
+		}},
+		{name: "bare coded error", path: "/coded", status: http.StatusNotFound, want: func(t *testing.T, body map[string]any, _ string) {
+			if body["message"] != "gone" || body["code"] != dto.CodeNotFound {
+				t.Fatalf("expected coded ErrorResponse, got %v", body)
+			}
+		}},
+		{name: "enveloped success", envelope: true, path: "/ok", status: http.StatusOK, want: func(t *testing.T, body map[string]any, rid string) {
+			data, _ := body["data"].(map[string]any)
+			if data["ticker"] != "PETR4" || body["error"] != nil || body["request_id"] != rid {
+				t.Fatalf("unexpected envelope: %v", body)
+			}
+		}},
+		{name: "enveloped error", envelope: true, path: "/err", status: http.StatusBadRequest, want: func(t *testing.T, body map[string]any, rid string) {
+			errBody, _ := body["error"].(map[string]any)
+			if body["data"] != nil || errBody["message"] != "bad stuff" || errBody["error"] != "boom" || body["request_id"] != rid {
+				t.Fatalf("unexpected envelope: %v", body)
+			}
+		}},
+		{name: "enveloped coded error", envelope: true, path: "/coded", status: http.StatusNotFound, want: func(t *testing.T, body map[string]any, _ string) {
+			errBody, _ := body["error"].(map[string]any)
+			if errBody["code"] != dto.CodeNotFound {
+				t.Fatalf("unexpected envelope: %v", body)
+			}
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config.AppConfig.Server.Envelope = tc.envelope
+			r := gin.New()
+			r.Use(RequestID())
+			r.GET("/ok", func(c *gin.Context) { RespondJSON(c, http.StatusOK, gin.H{"ticker": "PETR4"}) })
+			r.GET("/err", func(c *gin.Context) { RespondError(c, http.StatusBadRequest, "bad stuff", assertErr{}) })
+			r.GET("/coded", func(c *gin.Context) { RespondErrorCode(c, http.StatusNotFound, dto.CodeNotFound, "gone", nil) })
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if w.Code != tc.status {
+				t.Fatalf("code=%d, want %d", w.Code, tc.status)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			tc.want(t, body, w.Header().Get("X-Request-ID"))
+		})
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	cases := []struct {
+		name   string
+		key    string
+		header string
+		expect int
+	}{
+		{name: "disabled when key empty", key: "", header: "", expect: http.StatusOK},
+		{name: "missing header", key: "secret", header: "", expect: http.StatusUnauthorized},
+		{name: "wrong key", key: "secret", header: "nope", expect: http.StatusUnauthorized},
+		{name: "valid key", key: "secret", header: "secret", expect: http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(AdminAuth(tc.key))
+			r.GET("/admin", func(c *gin.Context) { c.String(200, "ok") })
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tc.header != "" {
+				req.Header.Set(AdminKeyHeader, tc.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tc.expect {
+				t.Fatalf("expected %d, got %d", tc.expect, w.Code)
+			}
+		})
+	}
+}
+
+func TestHistogramQuantiles(t *testing.T) {
+	var h histogram
+	if h.quantile(0.5) != 0 {
+		t.Fatalf("empty histogram should report 0")
+	}
+	// 90 fast requests (~1ms) and 10 slow ones (~100ms)
+	for i := 0; i < 90; i++ {
+		h.record(time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		h.record(100 * time.Millisecond)
+	}
+	within := func(got, want time.Duration) bool {
+		return got >= want && float64(got) <= float64(want)*histGrowth
+	}
+	if p50 := h.quantile(0.5); !within(p50, time.Millisecond) {
+		t.Fatalf("p50=%v, want ~1ms", p50)
+	}
+	if p90 := h.quantile(0.9); !within(p90, time.Millisecond) {
+		t.Fatalf("p90=%v, want ~1ms", p90)
+	}
+	if p99 := h.quantile(0.99); !within(p99, 100*time.Millisecond) {
+		t.Fatalf("p99=%v, want ~100ms", p99)
+	}
+	// Out-of-range observations are clamped instead of growing memory
+	h.record(24 * time.Hour)
+	if got := bucketFor(24 * time.Hour); got != histBuckets-1 {
+		t.Fatalf("expected clamp to last bucket, got %d", got)
+	}
+}
+
+func TestLatencyStats_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stats := NewLatencyStats()
+	r := gin.New()
+	r.Use(stats.Middleware())
+	r.GET("/items/:id", func(c *gin.Context) { c.String(200, "ok") })
+	for _, p := range []string{"/items/1", "/items/2", "/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+	}
+	// Client-chosen methods on unknown paths share the single unmatched key.
+	for _, m := range []string{"FOO", "BAR", http.MethodPost} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(m, "/missing", nil))
+	}
+
+	snap := stats.Snapshot()
+	if len(snap) != 2 {
+		t.Fatalf("expected 2 routes, got %+v", snap)
+	}
+	if snap[0].Route != "GET /items/:id" || snap[0].Count != 2 {
+		t.Fatalf("unexpected first route: %+v", snap[0])
+	}
+	if snap[1].Route != unmatchedRoute || snap[1].Count != 4 {
+		t.Fatalf("unexpected second route: %+v", snap[1])
+	}
+	if got := statsMethod("BREW"); got != otherMethod {
+		t.Fatalf("statsMethod(BREW)=%q, want %q", got, otherMethod)
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func(limit int64, calls *int) *gin.Engine {
+		r := gin.New()
+		r.Use(BodyLimit(limit))
+		echo := func(c *gin.Context) {
+			*calls++
+			b, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				return // left to BodyLimit
+			}
+			c.String(http.StatusOK, "%d", len(b))
+		}
+		r.POST("/", echo)
+		r.GET("/", echo)
+		return r
+	}
+	big := strings.Repeat("x", 64)
+
+	cases := []struct {
+		name      string
+		limit     int64
+		method    string
+		chunked   bool
+		body      string
+		wantCode  int
+		wantCalls int
+	}{
+		{name: "within limit", limit: 64, method: http.MethodPost, body: big, wantCode: http.StatusOK, wantCalls: 1},
+		{name: "content-length over limit", limit: 16, method: http.MethodPost, body: big, wantCode: http.StatusRequestEntityTooLarge},
+		{name: "chunked over limit", limit: 16, method: http.MethodPost, chunked: true, body: big, wantCode: http.StatusRequestEntityTooLarge, wantCalls: 1},
+		{name: "reads are not limited", limit: 16, method: http.MethodGet, body: big, wantCode: http.StatusOK, wantCalls: 1},
+		{name: "disabled", limit: 0, method: http.MethodPost, body: big, wantCode: http.StatusOK, wantCalls: 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			req := httptest.NewRequest(tc.method, "/", strings.NewReader(tc.body))
+			if tc.chunked {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			newRouter(tc.limit, &calls).ServeHTTP(w, req)
+			if w.Code != tc.wantCode || calls != tc.wantCalls {
+				t.Fatalf("got code=%d calls=%d, want %d/%d: %s", w.Code, calls, tc.wantCode, tc.wantCalls, w.Body.String())
+			}
+			if tc.wantCode != http.StatusRequestEntityTooLarge {
+				return
+			}
+			var out dto.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || out.Code != dto.CodeBodyTooLarge {
+				t.Fatalf("want ErrorResponse with %s, got %s (err=%v)", dto.CodeBodyTooLarge, w.Body.String(), err)
+			}
+		})
+	}
+
+	if !IsBodyTooLarge(&http.MaxBytesError{Limit: 1}) || IsBodyTooLarge(errors.New("other")) {
+		t.Fatalf("IsBodyTooLarge misclassifies errors")
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const limit = 2
+	release := make(chan struct{})
+	started := make(chan struct{}, limit)
+	r := gin.New()
+	r.Use(ConcurrencyLimit(limit, 0))
+	r.GET("/slow", func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.String(200, "ok")
+	})
+
+	codes := make(chan int, limit)
+	for range limit {
+		go func() {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+			codes <- w.Code
+		}()
+	}
+	for range limit {
+		<-started
+	}
+
+	// All slots are taken, so the next request is shed.
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("N+1th request: got %d (Retry-After %q), want 503", w.Code, w.Header().Get("Retry-After"))
+	}
+	var body dto.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != dto.CodeServerBusy {
+		t.Fatalf("body = %s, want code %s", w.Body.String(), dto.CodeServerBusy)
+	}
+
+	close(release)
+	for range limit {
+		if code := <-codes; code != http.StatusOK {
+			t.Fatalf("in-flight request: got %d, want 200", code)
+		}
+	}
+
+	// Slots are released once requests finish.
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("after release: got %d, want 200", w.Code)
+	}
+}
+
+func TestConcurrencyLimit_QueueWait(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	r := gin.New()
+	r.Use(ConcurrencyLimit(1, 5*time.Second))
+	r.GET("/slow", func(c *gin.Context) {
+		select {
+		case started <- struct{}{}:
+			<-release
+		default:
+		}
+		c.String(200, "ok")
+	})
+
+	first := make(chan int, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		first <- w.Code
+	}()
+	<-started
+
+	// The second request queues until the first one frees its slot.
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusOK || <-first != http.StatusOK {
+		t.Fatalf("queued request: got %d, want 200", w.Code)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"runtime/debug"
 
 	"github.com/gin-gonic/gin"
-	"github.com/guttosm/b3pulse/internal/domain/dto"
 	"github.com/guttosm/b3pulse/internal/logger"
 )
 
@@ -36,8 +35,8 @@ func RecoveryMiddleware() gin.HandlerFunc {
 					Msg("panic recovered")
 
 				// Respond with standardized error structure
-				errResponse := dto.NewErrorResponse("Internal server error", fmt.Errorf("%v", r))
-				c.AbortWithStatusJSON(http.StatusInternalServerError, errResponse)
+				c.Abort()
+				RespondError(c, http.StatusInternalServerError, "Internal server error", fmt.Errorf("%v", r))
 			}
 		}()
 
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/guttosm/b3pulse/config"
+	"github.com/guttosm/b3pulse/internal/domain/dto"
+)
+
+// RespondJSON writes a successful JSON response.
+//
+// With API_ENVELOPE=true the body is wrapped as {"data": body, "error": null,
+// "request_id": ...}; otherwise body is written as is.
+func RespondJSON(c *gin.Context, status int, body any) {
+	c.JSON(status, envelope(c, body, nil))
+}
+
+// RespondError writes a dto.ErrorResponse built from msg and err (optional),
+// nested under "error" when API_ENVELOPE=true.
+func RespondError(c *gin.Context, status int, msg string, err error) {
+	RespondErrorCode(c, status, "", msg, err)
+}
+
+// RespondErrorCode is RespondError with a machine-readable code (one of the
+// dto.Code* constants) set on the ErrorResponse.
+func RespondErrorCode(c *gin.Context, status int, code string, msg string, err error) {
+	errResp := dto.NewErrorResponse(msg, err)
+	errResp.Code = code
+	c.JSON(status, envelope(c, nil, &errResp))
+}
+
+// envelope returns the body to serialize for the configured response mode.
+func envelope(c *gin.Context, data any, errResp *dto.ErrorResponse) any {
+	if !config.AppConfig.Server.Envelope {
+		if errResp != nil {
+			return *errResp
+		}
+		return data
+	}
+	return dto.Envelope{Data: data, Error: errResp, RequestID: c.GetString(RequestIDKey)}
+}
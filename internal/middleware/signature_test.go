@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSignatureAuth(t *testing.T) {
+	now := time.Date(2025, 9, 23, 15, 0, 0, 0, time.UTC)
+	prev := signatureNow
+	t.Cleanup(func() { signatureNow = prev })
+	signatureNow = func() time.Time { return now }
+
+	secrets := map[string]string{"partner": "s3cret"}
+	fresh := strconv.FormatInt(now.Add(-30*time.Second).Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+	future := strconv.FormatInt(now.Add(10*time.Minute).Unix(), 10)
+	valid := Sign("s3cret", http.MethodGet, "/api/v1/aggregate", "ticker=PETR4", fresh)
+
+	cases := []struct {
+		name   string
+		target string
+		keyID  string
+		ts     string
+		sig    string
+		expect int
+	}{
+		{name: "valid", target: "/api/v1/aggregate?ticker=PETR4", keyID: "partner", ts: fresh, sig: valid, expect: http.StatusOK},
+		{name: "tampered query", target: "/api/v1/aggregate?ticker=VALE3", keyID: "partner", ts: fresh, sig: valid, expect: http.StatusUnauthorized},
+		{name: "tampered timestamp", target: "/api/v1/aggregate?ticker=PETR4", keyID: "partner", ts: strconv.FormatInt(now.Unix(), 10), sig: valid, expect: http.StatusUnauthorized},
+		{name: "wrong secret", target: "/api/v1/aggregate?ticker=PETR4", keyID: "partner", ts: fresh, sig: Sign("other", http.MethodGet, "/api/v1/aggregate", "ticker=PETR4", fresh), expect: http.StatusUnauthorized},
+		{name: "expired timestamp", target: "/api/v1/aggregate?ticker=PETR4", keyID: "partner", ts: stale, sig: Sign("s3cret", http.MethodGet, "/api/v1/aggregate", "ticker=PETR4", stale), expect: http.StatusUnauthorized},
+		{name: "future timestamp", target: "/api/v1/aggregate?ticker=PETR4", keyID: "partner", ts: future, sig: Sign("s3cret", http.MethodGet, "/api/v1/aggregate", "ticker=PETR4", future), expect: http.StatusUnauthorized},
+		{name: "unknown key id", target: "/api/v1/aggregate?ticker=PETR4", keyID: "nobody", ts: fresh, sig: valid, expect: http.StatusUnauthorized},
+		{name: "missing signature", target: "/api/v1/aggregate?ticker=PETR4", keyID: "partner", ts: fresh, expect: http.StatusUnauthorized},
+		{name: "missing timestamp", target: "/api/v1/aggregate?ticker=PETR4", keyID: "partner", sig: valid, expect: http.StatusUnauthorized},
+		{name: "non-hex signature", target: "/api/v1/aggregate?ticker=PETR4", keyID: "partner", ts: fresh, sig: "zz", expect: http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(SignatureAuth(secrets, 5*time.Minute))
+			r.GET("/api/v1/aggregate", func(c *gin.Context) { c.String(200, "ok") })
+
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			for h, v := range map[string]string{SignatureKeyIDHeader: tc.keyID, SignatureTimestampHeader: tc.ts, SignatureHeader: tc.sig} {
+				if v != "" {
+					req.Header.Set(h, v)
+				}
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tc.expect {
+				t.Fatalf("expected %d, got %d: %s", tc.expect, w.Code, w.Body.String())
+			}
+		})
+	}
+}
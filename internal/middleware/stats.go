@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Histogram layout: bucket i covers latencies up to histMinMicros*histGrowth^i µs.
+// 200 buckets growing 10% each span 10µs to ~28 minutes with ≤10% relative error,
+// so every route uses the same fixed amount of memory regardless of traffic.
+const (
+	histBuckets   = 200
+	histGrowth    = 1.1
+	histMinMicros = 10.0
+)
+
+// unmatchedRoute groups requests that did not match any registered route,
+// keeping the number of tracked keys bounded. It is recorded without the
+// method, which is client-controlled for these requests.
+const unmatchedRoute = "unmatched"
+
+// otherMethod stands in for any method outside the standard HTTP set.
+const otherMethod = "OTHER"
+
+// histogram is a fixed-size, log-bucketed latency histogram.
+type histogram struct {
+	counts [histBuckets]uint64
+	total  uint64
+}
+
+func (h *histogram) record(d time.Duration) {
+	h.counts[bucketFor(d)]++
+	h.total++
+}
+
+// quantile returns the upper bound of the bucket containing the q-th quantile (0 < q ≤ 1).
+func (h *histogram) quantile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(h.total)))
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			return bucketUpper(i)
+		}
+	}
+	return bucketUpper(histBuckets - 1)
+}
+
+func bucketFor(d time.Duration) int {
+	us := float64(d) / float64(time.Microsecond)
+	if us <= histMinMicros {
+		return 0
+	}
+	i := int(math.Ceil(math.Log(us/histMinMicros) / math.Log(histGrowth)))
+	if i >= histBuckets {
+		return histBuckets - 1
+	}
+	return i
+}
+
+func bucketUpper(i int) time.Duration {
+	return time.Duration(histMinMicros * math.Pow(histGrowth, float64(i)) * float64(time.Microsecond))
+}
+
+// RouteStats is a point-in-time latency summary for a single route.
+type RouteStats struct {
+	Route string  `json:"route" example:"GET /api/v1/aggregate"`
+	Count uint64  `json:"count" example:"1024"`
+	P50Ms float64 `json:"p50_ms" example:"3.1"`
+	P90Ms float64 `json:"p90_ms" example:"8.4"`
+	P99Ms float64 `json:"p99_ms" example:"21.7"`
+}
+
+// LatencyStats tracks request counts and latency percentiles per route in memory.
+//
+// Routes are keyed by method and registered path pattern (e.g. "GET /api/v1/aggregate"),
+// so the number of histograms is bounded by the number of routes.
+type LatencyStats struct {
+	mu     sync.Mutex
+	routes map[string]*histogram
+}
+
+// NewLatencyStats creates an empty LatencyStats.
+func NewLatencyStats() *LatencyStats {
+	return &LatencyStats{routes: make(map[string]*histogram)}
+}
+
+// Middleware returns a Gin middleware that records the latency of each request.
+//
+// Usage:
+//
+//	stats := middleware.NewLatencyStats()
+//	router.Use(stats.Middleware())
+func (s *LatencyStats) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			s.Record(unmatchedRoute, time.Since(start))
+			return
+		}
+		s.Record(statsMethod(c.Request.Method)+" "+route, time.Since(start))
+	}
+}
+
+// statsMethod maps non-standard methods to otherMethod so they cannot add keys.
+func statsMethod(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return m
+	}
+	return otherMethod
+}
+
+// Record adds one observation for the given route key.
+func (s *LatencyStats) Record(route string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.routes[route]
+	if !ok {
+		h = &histogram{}
+		s.routes[route] = h
+	}
+	h.record(d)
+}
+
+// Snapshot returns the current per-route statistics, sorted by route.
+func (s *LatencyStats) Snapshot() []RouteStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]RouteStats, 0, len(s.routes))
+	for route, h := range s.routes {
+		out = append(out, RouteStats{
+			Route: route,
+			Count: h.total,
+			P50Ms: toMillis(h.quantile(0.50)),
+			P90Ms: toMillis(h.quantile(0.90)),
+			P99Ms: toMillis(h.quantile(0.99)),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Route < out[j].Route })
+	return out
+}
+
+func toMillis(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Millisecond)*1000) / 1000
+}
@@ -0,0 +1,142 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/guttosm/b3pulse/internal/domain/models"
+)
+
+const (
+	// defaultTimeout bounds each webhook attempt so a slow receiver cannot stall ingestion.
+	defaultTimeout = 5 * time.Second
+	// defaultRetryDelay is the pause before the single retry.
+	defaultRetryDelay = time.Second
+)
+
+// Notifier is notified when an ingestion run finishes, successfully or not.
+//
+// Implementations must be safe to call once per run and should return
+// promptly; callers treat errors as warnings and never fail the run.
+type Notifier interface {
+	NotifyIngestion(ctx context.Context, audit models.IngestionAudit) error
+}
+
+// Noop is a Notifier that does nothing; used when no notifier is configured.
+type Noop struct{}
+
+// NotifyIngestion implements Notifier.
+func (Noop) NotifyIngestion(context.Context, models.IngestionAudit) error { return nil }
+
+// IngestionPayload is the JSON body POSTed by WebhookNotifier.
+type IngestionPayload struct {
+	RunID          string   `json:"run_id"`
+	Success        bool     `json:"success"`
+	Force          bool     `json:"force"`
+	Dates          []string `json:"dates"`
+	FilesProcessed int      `json:"files_processed"`
+	TotalRows      int64    `json:"total_rows"`
+	DurationMs     int64    `json:"duration_ms"`
+	Errors         []string `json:"errors,omitempty"`
+	StartedAt      string   `json:"started_at"`
+	FinishedAt     string   `json:"finished_at"`
+}
+
+// NewIngestionPayload builds the webhook payload for an ingestion audit.
+func NewIngestionPayload(audit models.IngestionAudit) IngestionPayload {
+	dates := make([]string, 0, len(audit.RequestedDates))
+	for _, d := range audit.RequestedDates {
+		dates = append(dates, d.Format("2006-01-02"))
+	}
+	return IngestionPayload{
+		RunID:          audit.RunID,
+		Success:        audit.Success,
+		Force:          audit.Force,
+		Dates:          dates,
+		FilesProcessed: audit.FilesProcessed,
+		TotalRows:      audit.TotalRows,
+		DurationMs:     audit.FinishedAt.Sub(audit.StartedAt).Milliseconds(),
+		Errors:         audit.Errors,
+		StartedAt:      audit.StartedAt.UTC().Format(time.RFC3339),
+		FinishedAt:     audit.FinishedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// WebhookNotifier POSTs a JSON IngestionPayload to a URL.
+//
+// Behavior:
+//   - Each attempt is bounded by the client timeout (5s by default).
+//   - Network errors and 5xx responses are retried once after RetryDelay.
+//   - 4xx responses are not retried.
+type WebhookNotifier struct {
+	URL        string
+	Client     *http.Client
+	RetryDelay time.Duration
+}
+
+// NewWebhookNotifier creates a WebhookNotifier with default timeout and retry delay.
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:        url,
+		Client:     &http.Client{Timeout: defaultTimeout},
+		RetryDelay: defaultRetryDelay,
+	}
+}
+
+// NotifyIngestion implements Notifier.
+func (w *WebhookNotifier) NotifyIngestion(ctx context.Context, audit models.IngestionAudit) error {
+	body, err := json.Marshal(NewIngestionPayload(audit))
+	if err != nil {
+		return fmt.Errorf("marshal webhook payload: %w", err)
+	}
+
+	err = w.post(ctx, body)
+	if err == nil || !retryable(err) {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(w.RetryDelay):
+	}
+	return w.post(ctx, body)
+}
+
+// statusError reports a non-2xx webhook response.
+type statusError struct {
+	code int
+}
+
+func (e statusError) Error() string { return fmt.Sprintf("webhook returned status %d", e.code) }
+
+func retryable(err error) bool {
+	var se statusError
+	if errors.As(err, &se) {
+		return se.code >= 500
+	}
+	return true
+}
+
+func (w *WebhookNotifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return statusError{code: resp.StatusCode}
+	}
+	return nil
+}
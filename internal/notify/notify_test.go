@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/guttosm/b3pulse/internal/domain/models"
+)
+
+func sampleAudit() models.IngestionAudit {
+	start := time.Date(2025, 9, 19, 2, 0, 0, 0, time.UTC)
+	return models.IngestionAudit{
+		RunID:          "run-1",
+		RequestedDates: []time.Time{time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)},
+		FilesProcessed: 1,
+		TotalRows:      42,
+		Errors:         []string{"boom"},
+		StartedAt:      start,
+		FinishedAt:     start.Add(1500 * time.Millisecond),
+	}
+}
+
+func TestNewIngestionPayload(t *testing.T) {
+	p := NewIngestionPayload(sampleAudit())
+	if p.RunID != "run-1" || p.Success || p.TotalRows != 42 || p.DurationMs != 1500 {
+		t.Fatalf("unexpected payload: %+v", p)
+	}
+	if len(p.Dates) != 1 || p.Dates[0] != "2025-09-18" || len(p.Errors) != 1 {
+		t.Fatalf("unexpected payload dates/errors: %+v", p)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	cases := []struct {
+		name      string
+		statuses  []int
+		wantCalls int32
+		wantErr   bool
+	}{
+		{name: "ok first try", statuses: []int{http.StatusOK}, wantCalls: 1},
+		{name: "5xx then ok", statuses: []int{http.StatusBadGateway, http.StatusNoContent}, wantCalls: 2},
+		{name: "5xx twice", statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError}, wantCalls: 2, wantErr: true},
+		{name: "4xx not retried", statuses: []int{http.StatusBadRequest}, wantCalls: 1, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := calls.Add(1)
+				if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+					t.Errorf("unexpected content-type %q", ct)
+				}
+				var p IngestionPayload
+				if err := json.NewDecoder(r.Body).Decode(&p); err != nil || p.RunID != "run-1" {
+					t.Errorf("bad payload: %+v err=%v", p, err)
+				}
+				w.WriteHeader(tc.statuses[n-1])
+			}))
+			defer srv.Close()
+
+			n := NewWebhookNotifier(srv.URL)
+			n.RetryDelay = time.Millisecond
+			err := n.NotifyIngestion(context.Background(), sampleAudit())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err=%v, wantErr=%v", err, tc.wantErr)
+			}
+			if got := calls.Load(); got != tc.wantCalls {
+				t.Fatalf("calls=%d, want %d", got, tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifier_Unreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close() // nothing listening anymore
+
+	n := NewWebhookNotifier(url)
+	n.RetryDelay = time.Millisecond
+	if err := n.NotifyIngestion(context.Background(), sampleAudit()); err == nil {
+		t.Fatalf("expected error for unreachable webhook")
+	}
+}
@@ -2,25 +2,307 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"sort"
 	"time"
 
+	"github.com/guttosm/b3pulse/internal/calendar"
 	"github.com/guttosm/b3pulse/internal/domain/models"
+	"github.com/guttosm/b3pulse/internal/logger"
 	"github.com/guttosm/b3pulse/internal/storage"
 )
 
+// ErrNoData is returned when there is nothing to report for a request: a
+// ticker that has never traded, a day or participant without trades, or a
+// comparison where neither side has data. The API maps it to 404; match it
+// with errors.Is.
+var ErrNoData = errors.New("no data")
+
 // AggregateService defines business logic for computing aggregates.
+//
+// Methods log through logger.FromContext(ctx), so lines carry the request
+// fields (request_id, ticker, range) the API layer put in the context.
 type AggregateService interface {
-	GetAggregate(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time) (*models.Aggregate, error)
+	GetAggregate(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time, opts storage.AggregateOptions) (*models.Aggregate, error)
+	GetDailyAggregate(ctx context.Context, ticker string, date time.Time) (*models.DailyAggregate, error)
+	GetCalendar(ctx context.Context, ticker string, startDate time.Time, endDate time.Time) ([]models.DailyAggregate, error)
+	GetSMA(ctx context.Context, ticker string, startDate time.Time, endDate time.Time, window int) ([]models.MovingAveragePoint, error)
+	GetAggregateBySession(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time) (map[string]*models.Aggregate, error)
+	Compare(ctx context.Context, tickerA, tickerB string, startDate *time.Time, endDate *time.Time) (*models.Comparison, error)
+	GetParticipantActivity(ctx context.Context, code string, startDate *time.Time, endDate *time.Time) (*models.ParticipantSummary, error)
+	GetCrossTrades(ctx context.Context, buyer string, seller string, startDate *time.Time, endDate *time.Time, limit int, offset int) (*models.CrossSummary, error)
+	GetLatestIngestion(ctx context.Context) (*models.IngestionLogEntry, error)
+	FindMissingIngestionDates(ctx context.Context, start time.Time, end time.Time) ([]time.Time, error)
+	GetPriceSpread(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time) (*models.PriceSpread, error)
+	GetNotional(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time) (*models.Notional, error)
+	GetLatestPrice(ctx context.Context, ticker string) (*models.LatestPrice, error)
+	GetInstrument(ctx context.Context, ticker string) (*models.Instrument, error)
+	StreamTrades(ctx context.Context, ticker string, date time.Time, fn func(models.Trade) error) error
+	ExplainAggregate(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time, opts storage.AggregateOptions) (json.RawMessage, error)
 }
 
 type aggregateService struct {
-	repo storage.TradesRepository
+	repo storage.TradeReader
 }
 
-func NewAggregateService(repo storage.TradesRepository) AggregateService {
+func NewAggregateService(repo storage.TradeReader) AggregateService {
 	return &aggregateService{repo: repo}
 }
 
-func (s *aggregateService) GetAggregate(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time) (*models.Aggregate, error) {
-	return s.repo.GetAggregateByTicker(ticker, startDate, endDate)
+// GetAggregate returns the aggregate for a ticker in the period.
+//
+// When the period is empty but the ticker traded on other dates, it returns a
+// zeroed aggregate with HasDataOutsideRange set; it returns ErrNoData only for
+// tickers that have never traded. opts carries the quantity floor for the
+// max price, the date column and the end bound (see storage.AggregateOptions).
+func (s *aggregateService) GetAggregate(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time, opts storage.AggregateOptions) (*models.Aggregate, error) {
+	agg, err := s.repo.GetAggregateByTicker(ctx, ticker, startDate, endDate, opts)
+	if err != nil || agg != nil {
+		return agg, err
+	}
+
+	exists, err := s.repo.TickerExists(ticker)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		logger.FromContext(ctx).Debug().Msg("unknown ticker")
+		return nil, ErrNoData
+	}
+	logger.FromContext(ctx).Debug().Msg("no trades in range, ticker traded on other dates")
+	return &models.Aggregate{Ticker: ticker, HasDataOutsideRange: true}, nil
+}
+
+// GetDailyAggregate returns the figures of a single day, or ErrNoData when the
+// ticker did not trade that day.
+func (s *aggregateService) GetDailyAggregate(ctx context.Context, ticker string, date time.Time) (*models.DailyAggregate, error) {
+	daily, err := s.repo.GetDailyAggregate(ticker, date)
+	if err == nil && daily == nil {
+		return nil, ErrNoData
+	}
+	return daily, err
+}
+
+// GetCalendar returns one entry per business day between startDate and
+// endDate (inclusive, see calendar.BusinessDaysBetween), zero-filled for days
+// without trades. Days with trades that the calendar considers closed are kept,
+// so no ingested volume is hidden.
+func (s *aggregateService) GetCalendar(ctx context.Context, ticker string, startDate time.Time, endDate time.Time) ([]models.DailyAggregate, error) {
+	traded, err := s.repo.GetDailyAggregates(ticker, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	byDate := make(map[string]models.DailyAggregate, len(traded))
+	for _, d := range traded {
+		byDate[d.Date.Format("2006-01-02")] = d
+	}
+	days := calendar.BusinessDaysBetween(startDate, endDate)
+	out := make([]models.DailyAggregate, 0, len(days)+len(traded))
+	for _, day := range days {
+		key := day.Format("2006-01-02")
+		if d, ok := byDate[key]; ok {
+			out = append(out, d)
+			delete(byDate, key)
+			continue
+		}
+		out = append(out, models.DailyAggregate{Ticker: ticker, Date: day})
+	}
+	if len(byDate) > 0 {
+		for _, d := range byDate {
+			out = append(out, d)
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Date.Before(out[j].Date) })
+	}
+	return out, nil
+}
+
+// GetPriceSpread returns the high-low price spread in the period, or ErrNoData
+// when the ticker has no trades there.
+func (s *aggregateService) GetPriceSpread(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time) (*models.PriceSpread, error) {
+	spread, err := s.repo.GetPriceSpread(ticker, startDate, endDate)
+	if err == nil && spread == nil {
+		return nil, ErrNoData
+	}
+	return spread, err
+}
+
+// GetSMA returns the simple moving average of the daily close over window
+// trading days, one point per day with trades between startDate and endDate.
+//
+// The window counts days with trades, so holidays and days the ticker did not
+// trade are skipped rather than averaged as zero. Days with trades in the
+// window-1 business days before startDate are read too, so the average is
+// defined from startDate when that history is stored; until window days are
+// available a point's SMA is nil.
+func (s *aggregateService) GetSMA(ctx context.Context, ticker string, startDate time.Time, endDate time.Time, window int) ([]models.MovingAveragePoint, error) {
+	from := startDate
+	if window > 1 {
+		lookback := calendar.LastNBusinessDays(window-1, startDate.AddDate(0, 0, -1))
+		from = lookback[len(lookback)-1]
+	}
+	traded, err := s.repo.GetDailyAggregates(ticker, from, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]models.MovingAveragePoint, 0, len(traded))
+	var sum float64
+	for i, d := range traded {
+		sum += d.ClosePrice
+		if i >= window {
+			sum -= traded[i-window].ClosePrice
+		}
+		if d.Date.Before(startDate) {
+			continue
+		}
+		p := models.MovingAveragePoint{Date: d.Date, Close: d.ClosePrice}
+		if i+1 >= window {
+			avg := sum / float64(window)
+			p.SMA = &avg
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// GetNotional returns the financial value traded in the period, or ErrNoData
+// when the ticker has no trades there.
+func (s *aggregateService) GetNotional(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time) (*models.Notional, error) {
+	n, err := s.repo.GetNotional(ticker, startDate, endDate)
+	if err == nil && n == nil {
+		return nil, ErrNoData
+	}
+	return n, err
+}
+
+// GetLatestPrice returns the most recent trade of the ticker, or ErrNoData
+// when it has never traded.
+func (s *aggregateService) GetLatestPrice(ctx context.Context, ticker string) (*models.LatestPrice, error) {
+	latest, err := s.repo.GetLatestPrice(ticker)
+	if err == nil && latest == nil {
+		return nil, ErrNoData
+	}
+	return latest, err
+}
+
+// GetInstrument returns the reference data loaded for the ticker, or ErrNoData
+// when the instruments file was not loaded or does not list it.
+func (s *aggregateService) GetInstrument(ctx context.Context, ticker string) (*models.Instrument, error) {
+	in, err := s.repo.GetInstrument(ticker)
+	if err == nil && in == nil {
+		return nil, ErrNoData
+	}
+	return in, err
+}
+
+// GetAggregateBySession returns the aggregate per session type in the period;
+// the map is empty when the ticker has no trades there.
+func (s *aggregateService) GetAggregateBySession(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time) (map[string]*models.Aggregate, error) {
+	return s.repo.GetAggregateBySession(ticker, startDate, endDate)
+}
+
+// Compare computes the aggregates of both tickers over the same period and
+// derives their ratios (A over B). A ticker without data yields a nil
+// aggregate on its side and nil ratios; ErrNoData is returned only when
+// neither ticker has data.
+func (s *aggregateService) Compare(ctx context.Context, tickerA, tickerB string, startDate *time.Time, endDate *time.Time) (*models.Comparison, error) {
+	// Use the raw range aggregate: a ticker with no trades in the period is
+	// reported as missing here, whether or not it traded on other dates.
+	a, err := s.repo.GetAggregateByTicker(ctx, tickerA, startDate, endDate, storage.AggregateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.repo.GetAggregateByTicker(ctx, tickerB, startDate, endDate, storage.AggregateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if a == nil && b == nil {
+		return nil, ErrNoData
+	}
+	if a == nil || b == nil {
+		logger.FromContext(ctx).Debug().Bool("a_missing", a == nil).Bool("b_missing", b == nil).Msg("partial comparison")
+	}
+
+	cmp := &models.Comparison{TickerA: tickerA, TickerB: tickerB, A: a, B: b}
+	if a != nil && b != nil {
+		cmp.MaxPriceRatio = ratio(a.MaxRangeValue, b.MaxRangeValue)
+		cmp.VolumeRatio = ratio(float64(a.MaxDailyVolume), float64(b.MaxDailyVolume))
+	}
+	return cmp, nil
+}
+
+// GetParticipantActivity returns a participant's per-ticker buy/sell volume,
+// capped at storage.MaxParticipantTickers. It returns ErrNoData when the
+// participant has no trades in the period.
+func (s *aggregateService) GetParticipantActivity(ctx context.Context, code string, startDate *time.Time, endDate *time.Time) (*models.ParticipantSummary, error) {
+	rows, err := s.repo.GetParticipantActivity(code, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrNoData
+	}
+
+	summary := &models.ParticipantSummary{Code: code, Tickers: rows}
+	if len(rows) > storage.MaxParticipantTickers {
+		summary.Tickers = rows[:storage.MaxParticipantTickers]
+		summary.Truncated = true
+	}
+	return summary, nil
+}
+
+// GetCrossTrades returns one page (limit tickers from offset) of the per-ticker
+// activity between buyer and seller. It returns ErrNoData when the pair has no
+// trades at all; a page past the end is empty, not an error.
+func (s *aggregateService) GetCrossTrades(ctx context.Context, buyer string, seller string, startDate *time.Time, endDate *time.Time, limit int, offset int) (*models.CrossSummary, error) {
+	// One extra row tells whether another page exists.
+	rows, err := s.repo.GetCrossTrades(buyer, seller, startDate, endDate, limit+1, offset)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 && offset == 0 {
+		return nil, ErrNoData
+	}
+
+	summary := &models.CrossSummary{Buyer: buyer, Seller: seller, Tickers: rows}
+	if len(rows) > limit {
+		summary.Tickers = rows[:limit]
+		summary.HasMore = true
+	}
+	return summary, nil
+}
+
+// GetLatestIngestion returns the most recently ingested business day, or nil if
+// none; an empty ingestion log is a valid state, not ErrNoData.
+func (s *aggregateService) GetLatestIngestion(ctx context.Context) (*models.IngestionLogEntry, error) {
+	return s.repo.GetLatestIngestion()
+}
+
+// FindMissingIngestionDates returns the business days in [start, end] that
+// were never ingested, oldest first.
+func (s *aggregateService) FindMissingIngestionDates(ctx context.Context, start time.Time, end time.Time) ([]time.Time, error) {
+	return s.repo.FindMissingIngestionDates(start, end)
+}
+
+// StreamTrades passes every trade on date (optionally for one ticker) to fn
+// without buffering; see storage.TradeReader.StreamTradesByDate.
+func (s *aggregateService) StreamTrades(ctx context.Context, ticker string, date time.Time, fn func(models.Trade) error) error {
+	return s.repo.StreamTradesByDate(ctx, ticker, date, fn)
+}
+
+// ExplainAggregate returns the Postgres plan (JSON) of the GetAggregate range
+// query; see storage.TradeReader.ExplainAggregate.
+func (s *aggregateService) ExplainAggregate(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time, opts storage.AggregateOptions) (json.RawMessage, error) {
+	return s.repo.ExplainAggregate(ctx, ticker, startDate, endDate, opts)
+}
+
+// ratio returns num/den, or nil when den is zero.
+func ratio(num, den float64) *float64 {
+	if den == 0 {
+		return nil
+	}
+	r := num / den
+	return &r
 }
@@ -2,25 +2,94 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"testing"
 	"time"
 
 	"github.com/guttosm/b3pulse/internal/domain/models"
+	"github.com/guttosm/b3pulse/internal/storage"
 )
 
 type stubRepo struct {
-	agg *models.Aggregate
-	err error
+	agg       *models.Aggregate
+	byTicker  map[string]*models.Aggregate
+	daily     *models.DailyAggregate
+	activity  []models.ParticipantActivity
+	latest    *models.IngestionLogEntry
+	trades    []models.Trade
+	sessions  map[string]*models.Aggregate
+	plan      json.RawMessage
+	series    []models.DailyAggregate
+	missing   []time.Time
+	spread    *models.PriceSpread
+	notional  *models.Notional
+	price     *models.LatestPrice
+	instr     *models.Instrument
+	cross     []models.CrossActivity
+	exists    bool
+	existsErr error
+	err       error
+	gotFrom   time.Time // start date received by GetDailyAggregates
 }
 
-func (s *stubRepo) InsertTradesBatch(_ []models.Trade) error { return nil }
-func (s *stubRepo) GetAggregateByTicker(_ string, _ *time.Time, _ *time.Time) (*models.Aggregate, error) {
+func (s *stubRepo) GetAggregateByTicker(_ context.Context, ticker string, _ *time.Time, _ *time.Time, _ storage.AggregateOptions) (*models.Aggregate, error) {
+	if s.byTicker != nil {
+		return s.byTicker[ticker], s.err
+	}
 	return s.agg, s.err
 }
-func (s *stubRepo) HasIngestionForDate(_ time.Time) (bool, error)         { return false, nil }
-func (s *stubRepo) UpsertIngestionLog(_ time.Time, _ string, _ int) error { return nil }
-func (s *stubRepo) DeleteTradesByDate(_ time.Time) error                  { return nil }
+func (s *stubRepo) GetDailyAggregate(_ string, _ time.Time) (*models.DailyAggregate, error) {
+	return s.daily, s.err
+}
+func (s *stubRepo) HasIngestionForDate(_ time.Time) (bool, error) { return false, nil }
+func (s *stubRepo) CountTradesByDate(_ time.Time) (int64, error)  { return 0, nil }
+func (s *stubRepo) GetIngestionCheckpoint(_ time.Time) (*models.IngestionCheckpoint, error) {
+	return nil, nil
+}
+func (s *stubRepo) TickerExists(_ string) (bool, error) { return s.exists, s.existsErr }
+func (s *stubRepo) GetLatestIngestion() (*models.IngestionLogEntry, error) {
+	return s.latest, s.err
+}
+func (s *stubRepo) GetParticipantActivity(_ string, _ *time.Time, _ *time.Time) ([]models.ParticipantActivity, error) {
+	return s.activity, s.err
+}
+func (s *stubRepo) StreamTradesByDate(_ context.Context, _ string, _ time.Time, fn func(models.Trade) error) error {
+	for _, t := range s.trades {
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return s.err
+}
+func (s *stubRepo) GetAggregateBySession(string, *time.Time, *time.Time) (map[string]*models.Aggregate, error) {
+	return s.sessions, s.err
+}
+func (s *stubRepo) ExplainAggregate(context.Context, string, *time.Time, *time.Time, storage.AggregateOptions) (json.RawMessage, error) {
+	return s.plan, s.err
+}
+func (s *stubRepo) GetDailyAggregates(_ string, from time.Time, _ time.Time) ([]models.DailyAggregate, error) {
+	s.gotFrom = from
+	return s.series, s.err
+}
+func (s *stubRepo) FindMissingIngestionDates(time.Time, time.Time) ([]time.Time, error) {
+	return s.missing, s.err
+}
+func (s *stubRepo) GetPriceSpread(string, *time.Time, *time.Time) (*models.PriceSpread, error) {
+	return s.spread, s.err
+}
+func (s *stubRepo) GetNotional(string, *time.Time, *time.Time) (*models.Notional, error) {
+	return s.notional, s.err
+}
+func (s *stubRepo) GetInstrument(string) (*models.Instrument, error) {
+	return s.instr, s.err
+}
+func (s *stubRepo) GetLatestPrice(string) (*models.LatestPrice, error) {
+	return s.price, s.err
+}
+func (s *stubRepo) GetCrossTrades(string, string, *time.Time, *time.Time, int, int) ([]models.CrossActivity, error) {
+	return s.cross, s.err
+}
 
 func TestAggregateService_TableDriven(t *testing.T) {
 	cases := []struct {
@@ -43,7 +112,7 @@ func TestAggregateService_TableDriven(t *testing.T) {
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			svc := NewAggregateService(tc.repo)
-			out, err := svc.GetAggregate(context.Background(), "XXXX4", nil, nil)
+			out, err := svc.GetAggregate(context.Background(), "XXXX4", nil, nil, storage.AggregateOptions{})
 			if tc.wantErr {
 				if err == nil || out != nil {
 					t.Fatalf("expected error, got out=%+v err=%v", out, err)
@@ -56,3 +125,311 @@ func TestAggregateService_TableDriven(t *testing.T) {
 		})
 	}
 }
+
+func TestAggregateService_GetDailyAggregate(t *testing.T) {
+	day := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+	repo := &stubRepo{daily: &models.DailyAggregate{Ticker: "PETR4", Date: day, MaxPrice: 10, TotalVolume: 100, TradeCount: 3}}
+	out, err := NewAggregateService(repo).GetDailyAggregate(context.Background(), "PETR4", day)
+	if err != nil || out == nil || out.TradeCount != 3 {
+		t.Fatalf("unexpected: out=%+v err=%v", out, err)
+	}
+
+	out, err = NewAggregateService(&stubRepo{}).GetDailyAggregate(context.Background(), "PETR4", day)
+	if out != nil || !errors.Is(err, ErrNoData) {
+		t.Fatalf("expected ErrNoData, got out=%+v err=%v", out, err)
+	}
+}
+
+func TestAggregateService_GetAggregateBySession(t *testing.T) {
+	repo := &stubRepo{sessions: map[string]*models.Aggregate{"1": {Ticker: "PETR4", TradeCount: 5}}}
+	out, err := NewAggregateService(repo).GetAggregateBySession(context.Background(), "PETR4", nil, nil)
+	if err != nil || len(out) != 1 || out["1"].TradeCount != 5 {
+		t.Fatalf("unexpected: out=%+v err=%v", out, err)
+	}
+}
+
+func TestAggregateService_ExplainAggregate(t *testing.T) {
+	repo := &stubRepo{plan: json.RawMessage(`[{"Plan":{}}]`)}
+	out, err := NewAggregateService(repo).ExplainAggregate(context.Background(), "PETR4", nil, nil, storage.AggregateOptions{})
+	if err != nil || string(out) != `[{"Plan":{}}]` {
+		t.Fatalf("unexpected: out=%s err=%v", out, err)
+	}
+}
+
+func TestAggregateService_GetPriceSpread(t *testing.T) {
+	want := &models.PriceSpread{Ticker: "PETR4", MaxPrice: 22, MinPrice: 20, SpreadAbs: 2, SpreadPct: 10}
+	out, err := NewAggregateService(&stubRepo{spread: want}).GetPriceSpread(context.Background(), "PETR4", nil, nil)
+	if err != nil || out != want {
+		t.Fatalf("unexpected: out=%+v err=%v", out, err)
+	}
+
+	if _, err := NewAggregateService(&stubRepo{}).GetPriceSpread(context.Background(), "PETR4", nil, nil); !errors.Is(err, ErrNoData) {
+		t.Fatalf("expected ErrNoData, got %v", err)
+	}
+}
+
+func TestAggregateService_GetNotional(t *testing.T) {
+	want := &models.Notional{Ticker: "PETR4", Notional: 4680, TradeCount: 4}
+	out, err := NewAggregateService(&stubRepo{notional: want}).GetNotional(context.Background(), "PETR4", nil, nil)
+	if err != nil || out != want {
+		t.Fatalf("unexpected: out=%+v err=%v", out, err)
+	}
+
+	if _, err := NewAggregateService(&stubRepo{}).GetNotional(context.Background(), "PETR4", nil, nil); !errors.Is(err, ErrNoData) {
+		t.Fatalf("expected ErrNoData, got %v", err)
+	}
+}
+
+func TestAggregateService_GetLatestPrice(t *testing.T) {
+	want := &models.LatestPrice{Ticker: "PETR4", Price: 21.5, Quantity: 100}
+	out, err := NewAggregateService(&stubRepo{price: want}).GetLatestPrice(context.Background(), "PETR4")
+	if err != nil || out != want {
+		t.Fatalf("unexpected: out=%+v err=%v", out, err)
+	}
+
+	if _, err := NewAggregateService(&stubRepo{}).GetLatestPrice(context.Background(), "PETR4"); !errors.Is(err, ErrNoData) {
+		t.Fatalf("expected ErrNoData, got %v", err)
+	}
+}
+
+func TestAggregateService_GetInstrument(t *testing.T) {
+	want := &models.Instrument{Code: "PETR4", ISIN: "BRPETRACNPR6", Name: "PETROBRAS"}
+	out, err := NewAggregateService(&stubRepo{instr: want}).GetInstrument(context.Background(), "PETR4")
+	if err != nil || out != want {
+		t.Fatalf("unexpected: out=%+v err=%v", out, err)
+	}
+
+	if _, err := NewAggregateService(&stubRepo{}).GetInstrument(context.Background(), "PETR4"); !errors.Is(err, ErrNoData) {
+		t.Fatalf("expected ErrNoData, got %v", err)
+	}
+}
+
+func TestAggregateService_GetCrossTrades(t *testing.T) {
+	rows := []models.CrossActivity{{Ticker: "PETR4", Volume: 300}, {Ticker: "VALE3", Volume: 200}, {Ticker: "ITUB4", Volume: 100}}
+	svc := NewAggregateService(&stubRepo{cross: rows})
+
+	page, err := svc.GetCrossTrades(context.Background(), "3", "", nil, nil, 2, 0)
+	if err != nil || len(page.Tickers) != 2 || !page.HasMore || page.Buyer != "3" {
+		t.Fatalf("want first page of 2 with more, got %+v err=%v", page, err)
+	}
+	page, err = svc.GetCrossTrades(context.Background(), "3", "", nil, nil, 5, 0)
+	if err != nil || len(page.Tickers) != 3 || page.HasMore {
+		t.Fatalf("want single full page, got %+v err=%v", page, err)
+	}
+
+	empty := NewAggregateService(&stubRepo{})
+	if _, err := empty.GetCrossTrades(context.Background(), "3", "4", nil, nil, 5, 0); !errors.Is(err, ErrNoData) {
+		t.Fatalf("expected ErrNoData, got %v", err)
+	}
+	page, err = empty.GetCrossTrades(context.Background(), "3", "4", nil, nil, 5, 10)
+	if err != nil || len(page.Tickers) != 0 || page.HasMore {
+		t.Fatalf("want empty page past the end, got %+v err=%v", page, err)
+	}
+}
+
+func TestAggregateService_GetSMA(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 9, d, 0, 0, 0, 0, time.UTC) }
+	repo := &stubRepo{series: []models.DailyAggregate{
+		{Date: day(10), ClosePrice: 10}, // before the range, inside the lookback
+		{Date: day(11), ClosePrice: 12},
+		{Date: day(12), ClosePrice: 14},
+		{Date: day(16), ClosePrice: 20}, // 15 had no trades: skipped, not averaged as zero
+	}}
+
+	out, err := NewAggregateService(repo).GetSMA(context.Background(), "PETR4", day(11), day(16), 3)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	// Lookback of window-1 = 2 business days before the 11th.
+	if !repo.gotFrom.Equal(day(9)) {
+		t.Fatalf("expected lookback from 2025-09-09, got %s", repo.gotFrom)
+	}
+	if len(out) != 3 || !out[0].Date.Equal(day(11)) || out[0].Close != 12 {
+		t.Fatalf("unexpected points: %+v", out)
+	}
+	if out[0].SMA != nil {
+		t.Fatalf("expected nil SMA with 2 of 3 days, got %v", *out[0].SMA)
+	}
+	if out[1].SMA == nil || *out[1].SMA != 12 || out[2].SMA == nil || *out[2].SMA != 46.0/3 {
+		t.Fatalf("unexpected averages: %+v", out)
+	}
+
+	out, err = NewAggregateService(&stubRepo{series: repo.series}).GetSMA(context.Background(), "PETR4", day(10), day(16), 1)
+	if err != nil || len(out) != 4 || out[0].SMA == nil || *out[0].SMA != 10 {
+		t.Fatalf("window 1 should equal the close: %+v err=%v", out, err)
+	}
+
+	if _, err := NewAggregateService(&stubRepo{err: errors.New("db down")}).GetSMA(context.Background(), "PETR4", day(11), day(16), 3); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestAggregateService_GetCalendar(t *testing.T) {
+	start := time.Date(2025, 4, 14, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 4, 22, 0, 0, 0, 0, time.UTC)
+	holiday := time.Date(2025, 4, 21, 0, 0, 0, 0, time.UTC) // Tiradentes
+	repo := &stubRepo{series: []models.DailyAggregate{
+		{Ticker: "PETR4", Date: time.Date(2025, 4, 15, 0, 0, 0, 0, time.UTC), MaxPrice: 30.5, TotalVolume: 500, TradeCount: 3},
+		{Ticker: "PETR4", Date: holiday, MaxPrice: 31, TotalVolume: 10, TradeCount: 1},
+	}}
+
+	out, err := NewAggregateService(repo).GetCalendar(context.Background(), "PETR4", start, end)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	// 14-17 (Good Friday 18 is closed), the traded holiday 21 and 22.
+	want := []string{"2025-04-14", "2025-04-15", "2025-04-16", "2025-04-17", "2025-04-21", "2025-04-22"}
+	if len(out) != len(want) {
+		t.Fatalf("expected %d days, got %+v", len(want), out)
+	}
+	for i, d := range out {
+		if got := d.Date.Format("2006-01-02"); got != want[i] {
+			t.Fatalf("day %d: expected %s, got %s", i, want[i], got)
+		}
+	}
+	if out[0].TotalVolume != 0 || out[0].Ticker != "PETR4" {
+		t.Fatalf("expected zero-filled day, got %+v", out[0])
+	}
+	if out[1].TotalVolume != 500 || out[1].MaxPrice != 30.5 || out[4].TotalVolume != 10 {
+		t.Fatalf("traded days not preserved: %+v", out)
+	}
+
+	if _, err := NewAggregateService(&stubRepo{err: errors.New("db down")}).GetCalendar(context.Background(), "PETR4", start, end); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestAggregateService_Compare(t *testing.T) {
+	petr := &models.Aggregate{Ticker: "PETR4", MaxRangeValue: 30, MaxDailyVolume: 100}
+	vale := &models.Aggregate{Ticker: "VALE3", MaxRangeValue: 60, MaxDailyVolume: 400}
+	zero := &models.Aggregate{Ticker: "ZERO3"}
+
+	cases := []struct {
+		name        string
+		repo        *stubRepo
+		wantNil     bool
+		wantErr     bool
+		wantNoData  bool
+		wantPartial bool
+		wantPrice   *float64
+		wantVolume  *float64
+	}{
+		{name: "both present", repo: &stubRepo{byTicker: map[string]*models.Aggregate{"PETR4": petr, "VALE3": vale}}, wantPrice: ptr(0.5), wantVolume: ptr(0.25)},
+		{name: "b missing", repo: &stubRepo{byTicker: map[string]*models.Aggregate{"PETR4": petr}}, wantPartial: true},
+		{name: "both missing", repo: &stubRepo{byTicker: map[string]*models.Aggregate{}}, wantNil: true, wantErr: true, wantNoData: true},
+		{name: "zero denominator", repo: &stubRepo{byTicker: map[string]*models.Aggregate{"PETR4": petr, "VALE3": zero}}},
+		{name: "repo error", repo: &stubRepo{err: errors.New("boom")}, wantErr: true, wantNil: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := NewAggregateService(tc.repo).Compare(context.Background(), "PETR4", "VALE3", nil, nil)
+			if (err != nil) != tc.wantErr || errors.Is(err, ErrNoData) != tc.wantNoData {
+				t.Fatalf("err=%v, wantErr=%v wantNoData=%v", err, tc.wantErr, tc.wantNoData)
+			}
+			if (out == nil) != tc.wantNil {
+				t.Fatalf("out=%+v, wantNil=%v", out, tc.wantNil)
+			}
+			if out == nil {
+				return
+			}
+			if out.Partial() != tc.wantPartial {
+				t.Fatalf("Partial()=%v, want %v", out.Partial(), tc.wantPartial)
+			}
+			if !floatPtrEqual(out.MaxPriceRatio, tc.wantPrice) || !floatPtrEqual(out.VolumeRatio, tc.wantVolume) {
+				t.Fatalf("ratios price=%v volume=%v", out.MaxPriceRatio, out.VolumeRatio)
+			}
+		})
+	}
+}
+
+func ptr(f float64) *float64 { return &f }
+
+func floatPtrEqual(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func TestAggregateService_GetParticipantActivity(t *testing.T) {
+	many := make([]models.ParticipantActivity, storage.MaxParticipantTickers+1)
+	cases := []struct {
+		name          string
+		repo          *stubRepo
+		wantNil       bool
+		wantLen       int
+		wantTruncated bool
+	}{
+		{name: "no activity", repo: &stubRepo{}, wantNil: true},
+		{name: "within cap", repo: &stubRepo{activity: many[:3]}, wantLen: 3},
+		{name: "over cap", repo: &stubRepo{activity: many}, wantLen: storage.MaxParticipantTickers, wantTruncated: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := NewAggregateService(tc.repo).GetParticipantActivity(context.Background(), "3", nil, nil)
+			if tc.wantNil {
+				if out != nil || !errors.Is(err, ErrNoData) {
+					t.Fatalf("expected ErrNoData, got out=%+v err=%v", out, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if out == nil || out.Code != "3" || len(out.Tickers) != tc.wantLen || out.Truncated != tc.wantTruncated {
+				t.Fatalf("unexpected summary: %+v", out)
+			}
+		})
+	}
+}
+
+func TestAggregateService_GetAggregate_EmptyRange(t *testing.T) {
+	cases := []struct {
+		name        string
+		repo        *stubRepo
+		wantNil     bool
+		wantErr     bool
+		wantOutside bool
+	}{
+		{name: "unknown ticker", repo: &stubRepo{exists: false}, wantNil: true, wantErr: true},
+		{name: "traded outside range", repo: &stubRepo{exists: true}, wantOutside: true},
+		{name: "existence check error", repo: &stubRepo{existsErr: errors.New("boom")}, wantNil: true, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := NewAggregateService(tc.repo).GetAggregate(context.Background(), "PETR4", nil, nil, storage.AggregateOptions{})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err=%v, wantErr=%v", err, tc.wantErr)
+			}
+			if (out == nil) != tc.wantNil {
+				t.Fatalf("out=%+v, wantNil=%v", out, tc.wantNil)
+			}
+			if out != nil && (out.HasDataOutsideRange != tc.wantOutside || out.Ticker != "PETR4" || out.MaxRangeValue != 0 || out.MaxDailyVolume != 0) {
+				t.Fatalf("unexpected aggregate: %+v", out)
+			}
+		})
+	}
+}
+
+func TestAggregateService_StreamTrades(t *testing.T) {
+	repo := &stubRepo{trades: []models.Trade{{InstrumentCode: "PETR4"}, {InstrumentCode: "VALE3"}}}
+	var got []string
+	err := NewAggregateService(repo).StreamTrades(context.Background(), "", time.Time{}, func(tr models.Trade) error {
+		got = append(got, tr.InstrumentCode)
+		return nil
+	})
+	if err != nil || len(got) != 2 || got[1] != "VALE3" {
+		t.Fatalf("unexpected got=%v err=%v", got, err)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = NewAggregateService(repo).StreamTrades(context.Background(), "", time.Time{}, func(models.Trade) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("expected callback error to stop the stream, calls=%d err=%v", calls, err)
+	}
+}
@@ -1,42 +1,245 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/guttosm/b3pulse/internal/calendar"
 	"github.com/guttosm/b3pulse/internal/domain/models"
+	"github.com/guttosm/b3pulse/internal/logger"
 	pq "github.com/lib/pq"
 )
 
-// TradesRepository defines contract for DB operations.
-type TradesRepository interface {
-	InsertTradesBatch(trades []models.Trade) error
-	GetAggregateByTicker(ticker string, startDate *time.Time, endDate *time.Time) (*models.Aggregate, error)
+// TradeReader defines the read-only DB operations, used by the API service.
+type TradeReader interface {
+	GetAggregateByTicker(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time, opts AggregateOptions) (*models.Aggregate, error)
+	GetDailyAggregate(ticker string, date time.Time) (*models.DailyAggregate, error)
+	GetDailyAggregates(ticker string, startDate time.Time, endDate time.Time) ([]models.DailyAggregate, error)
+	GetAggregateBySession(ticker string, startDate *time.Time, endDate *time.Time) (map[string]*models.Aggregate, error)
 	HasIngestionForDate(date time.Time) (bool, error)
+	CountTradesByDate(date time.Time) (int64, error)
+	GetIngestionCheckpoint(date time.Time) (*models.IngestionCheckpoint, error)
+	GetParticipantActivity(code string, startDate *time.Time, endDate *time.Time) ([]models.ParticipantActivity, error)
+	GetCrossTrades(buyer string, seller string, startDate *time.Time, endDate *time.Time, limit int, offset int) ([]models.CrossActivity, error)
+	TickerExists(ticker string) (bool, error)
+	GetLatestIngestion() (*models.IngestionLogEntry, error)
+	FindMissingIngestionDates(start time.Time, end time.Time) ([]time.Time, error)
+	GetPriceSpread(ticker string, startDate *time.Time, endDate *time.Time) (*models.PriceSpread, error)
+	GetNotional(ticker string, startDate *time.Time, endDate *time.Time) (*models.Notional, error)
+	GetLatestPrice(ticker string) (*models.LatestPrice, error)
+	GetInstrument(code string) (*models.Instrument, error)
+	StreamTradesByDate(ctx context.Context, ticker string, date time.Time, fn func(models.Trade) error) error
+	ExplainAggregate(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time, opts AggregateOptions) (json.RawMessage, error)
+}
+
+// TradeWriter defines the DB operations that modify data, used by ingestion.
+type TradeWriter interface {
+	InsertTradesBatch(trades []models.Trade) error
+	InsertTradesBatchWithCheckpoint(trades []models.Trade, cp models.IngestionCheckpoint) error
 	UpsertIngestionLog(date time.Time, filename string, rowCount int) error
 	DeleteTradesByDate(date time.Time) error
+	DeleteIngestionCheckpoint(date time.Time) error
+	RecordIngestionRun(audit models.IngestionAudit) error
+	AnalyzeTrades(ctx context.Context) error
+	UpsertInstruments(instruments []models.Instrument) error
+	EnsurePartitionForDate(date time.Time) error
+}
+
+// TradesRepository defines contract for DB operations: reads and writes.
+type TradesRepository interface {
+	TradeReader
+	TradeWriter
+}
+
+// Date columns GetAggregateByTicker can filter and group by.
+const (
+	DateFieldTradeDate     = "trade_date"     // session date of the trade (default)
+	DateFieldReferenceDate = "reference_date" // DataReferencia of the source file
+)
+
+// AggregateOptions holds the optional filters of GetAggregateByTicker and
+// ExplainAggregate. The zero value aggregates every trade in the range, by
+// trade_date, with endDate included.
+type AggregateOptions struct {
+	MinQty       *int64 // only trades of at least this quantity count towards the max price
+	DateField    string // column the range and daily volumes use (DateField*); empty means trade_date
+	EndExclusive bool   // treat endDate as an exclusive upper bound (column < endDate)
+}
+
+// ValidDateField reports whether field is one of the DateField* columns or
+// empty (meaning DateFieldTradeDate).
+func ValidDateField(field string) bool {
+	_, err := dateColumn(field)
+	return err == nil
+}
+
+// dateColumn maps a date field to the column name interpolated into queries.
+// Only allowlisted literals are returned, never the input itself.
+func dateColumn(field string) (string, error) {
+	switch field {
+	case "", DateFieldTradeDate:
+		return "trade_date", nil
+	case DateFieldReferenceDate:
+		return "reference_date", nil
+	}
+	return "", fmt.Errorf("unsupported date field %q", field)
+}
+
+// RequiredSchemaVersion is the goose version of the newest migration in
+// db/migrations; bump it together with every new migration.
+const RequiredSchemaVersion = 9
+
+// CheckSchemaVersion returns an error unless goose_db_version shows migrations
+// applied up to at least RequiredSchemaVersion, i.e. the schema is not behind
+// the code. It is used as a /health/detail check.
+func CheckSchemaVersion(ctx context.Context, db *sql.DB) error {
+	var version sql.NullInt64
+	err := db.QueryRowContext(ctx, `SELECT MAX(version_id) FROM goose_db_version WHERE is_applied`).Scan(&version)
+	if err != nil {
+		return err
+	}
+	if version.Int64 < RequiredSchemaVersion {
+		return fmt.Errorf("schema version %d is behind required %d", version.Int64, RequiredSchemaVersion)
+	}
+	return nil
 }
 
+// MaxParticipantTickers caps how many tickers are reported for a participant.
+// GetParticipantActivity returns up to MaxParticipantTickers+1 rows so callers
+// can tell whether the result was truncated.
+const MaxParticipantTickers = 100
+
 type tradesRepository struct {
-	db *sql.DB
+	db         *sql.DB
+	txTimeouts TxTimeouts
+	stmts      stmtCache // prepared GetAggregateByTicker queries, one per shape
+	// slowQueryWarn is the WithSlowQueryWarn threshold (0 = off). The index it
+	// suggests is looked up once, on the first slow query, and the answer reused.
+	slowQueryWarn  time.Duration
+	aggIndexOnce   sync.Once
+	aggIndexExists bool
+	aggIndexErr    error
+}
+
+// TxTimeouts bound how long an InsertTradesBatch transaction may misbehave.
+// Each non-zero value (milliseconds) is applied with SET LOCAL at the start of
+// the transaction; zero keeps the server setting.
+type TxTimeouts struct {
+	IdleInTransactionMs int // idle_in_transaction_session_timeout
+	StatementMs         int // statement_timeout
 }
 
-func NewTradesRepository(db *sql.DB) TradesRepository {
-	return &tradesRepository{db: db}
+// Option configures a repository built by NewTradesRepository.
+type Option func(*tradesRepository)
+
+// WithTxTimeouts applies t to every InsertTradesBatch transaction.
+func WithTxTimeouts(t TxTimeouts) Option {
+	return func(r *tradesRepository) { r.txTimeouts = t }
+}
+
+// WithSlowQueryWarn logs a warning when a GetAggregateByTicker query takes
+// longer than d, suggesting the (instrument_code, trade_date) index and saying
+// whether trades has one. Zero disables the check.
+func WithSlowQueryWarn(d time.Duration) Option {
+	return func(r *tradesRepository) { r.slowQueryWarn = d }
+}
+
+func NewTradesRepository(db *sql.DB, opts ...Option) TradesRepository {
+	r := &tradesRepository{db: db}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// routedRepository sends reads to one repository and writes to another.
+type routedRepository struct {
+	TradeReader
+	TradeWriter
+}
+
+// NewReplicaRepository returns a TradesRepository whose TradeReader methods
+// query replica while TradeWriter methods go to primary. With a nil replica it
+// is NewTradesRepository(primary). Reads may lag the primary by the
+// replication delay, so ingestion bookkeeping should use a primary repository.
+func NewReplicaRepository(primary *sql.DB, replica *sql.DB, opts ...Option) TradesRepository {
+	w := NewTradesRepository(primary, opts...)
+	if replica == nil {
+		return w
+	}
+	return routedRepository{TradeReader: NewTradesRepository(replica, opts...), TradeWriter: w}
+}
+
+// Close closes the statements prepared by the repository. The *sql.DB is
+// owned by the caller and stays open.
+func (r *tradesRepository) Close() error {
+	return r.stmts.close()
+}
+
+// Close closes the statements prepared by both repositories.
+func (r routedRepository) Close() error {
+	return errors.Join(closeRepo(r.TradeReader), closeRepo(r.TradeWriter))
+}
+
+// closeRepo closes r when it holds resources of its own (see Close).
+func closeRepo(r any) error {
+	if c, ok := r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// localSettings returns the SET LOCAL statements for r.txTimeouts. Only these
+// fixed setting names are ever interpolated, and values are integers.
+func (r *tradesRepository) localSettings() []string {
+	var stmts []string
+	for _, s := range []struct {
+		name string
+		ms   int
+	}{
+		{"idle_in_transaction_session_timeout", r.txTimeouts.IdleInTransactionMs},
+		{"statement_timeout", r.txTimeouts.StatementMs},
+	} {
+		if s.ms > 0 {
+			stmts = append(stmts, fmt.Sprintf("SET LOCAL %s = %d", s.name, s.ms))
+		}
+	}
+	return stmts
 }
 
 // InsertTradesBatch inserts multiple trades into DB in a single transaction.
 func (r *tradesRepository) InsertTradesBatch(trades []models.Trade) error {
+	return r.insertBatch(trades, nil)
+}
+
+// InsertTradesBatchWithCheckpoint inserts trades like InsertTradesBatch and
+// upserts cp into ingestion_checkpoint in the same transaction, so the
+// checkpoint never points past rows that were not committed.
+func (r *tradesRepository) InsertTradesBatchWithCheckpoint(trades []models.Trade, cp models.IngestionCheckpoint) error {
+	return r.insertBatch(trades, &cp)
+}
+
+// insertBatch copies trades into the trades table and, when cp is non-nil,
+// records it before committing.
+func (r *tradesRepository) insertBatch(trades []models.Trade, cp *models.IngestionCheckpoint) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
 
-	// Small optimization for bulk load
-	if _, err := tx.Exec(`SET LOCAL synchronous_commit = OFF`); err != nil {
-		_ = tx.Rollback()
-		return err
+	// Small optimization for bulk load, plus the configured safety timeouts
+	for _, stmt := range append([]string{`SET LOCAL synchronous_commit = OFF`}, r.localSettings()...) {
+		if _, err := tx.Exec(stmt); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
 	}
 
 	stmt, err := tx.Prepare(pq.CopyIn(
@@ -71,14 +274,27 @@ func (r *tradesRepository) InsertTradesBatch(trades []models.Trade) error {
 		}
 		return t
 	}
+	toNullPrice := func(t *models.Trade) interface{} {
+		if t.IsPriceMissing() {
+			return nil
+		}
+		return t.TradePrice
+	}
+	toNullQuantity := func(t *models.Trade) interface{} {
+		if t.IsQuantityMissing() {
+			return nil
+		}
+		return t.TradeQuantity
+	}
 
-	for _, rec := range trades {
+	for i := range trades {
+		rec := &trades[i]
 		if _, err := stmt.Exec(
 			toNullDate(rec.ReferenceDate),
 			rec.InstrumentCode,
 			rec.UpdateAction,
-			rec.TradePrice,
-			rec.TradeQuantity,
+			toNullPrice(rec),
+			toNullQuantity(rec),
 			toNullTime(rec.ClosingTime),
 			rec.TradeIdentifierCode,
 			rec.SessionType,
@@ -102,9 +318,48 @@ func (r *tradesRepository) InsertTradesBatch(trades []models.Trade) error {
 		return err
 	}
 
+	if cp != nil {
+		if _, err := tx.Exec(`
+			INSERT INTO ingestion_checkpoint (file_date, filename, line_number, row_count)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (file_date)
+			DO UPDATE SET filename = EXCLUDED.filename,
+						  line_number = EXCLUDED.line_number,
+						  row_count = EXCLUDED.row_count,
+						  updated_at = NOW()
+		`, cp.FileDate, cp.Filename, cp.LineNumber, cp.RowCount); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
 	return tx.Commit()
 }
 
+// GetIngestionCheckpoint returns the checkpoint recorded for date, or nil (and
+// no error) when there is none.
+func (r *tradesRepository) GetIngestionCheckpoint(date time.Time) (*models.IngestionCheckpoint, error) {
+	var cp models.IngestionCheckpoint
+	err := r.db.QueryRow(`
+		SELECT file_date, filename, line_number, row_count
+		FROM ingestion_checkpoint
+		WHERE file_date = $1
+	`, date).Scan(&cp.FileDate, &cp.Filename, &cp.LineNumber, &cp.RowCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// DeleteIngestionCheckpoint removes the checkpoint recorded for date, if any.
+func (r *tradesRepository) DeleteIngestionCheckpoint(date time.Time) error {
+	_, err := r.db.Exec(`DELETE FROM ingestion_checkpoint WHERE file_date = $1`, date)
+	return err
+}
+
 // HasIngestionForDate checks if an ingestion was already recorded for a given business day.
 func (r *tradesRepository) HasIngestionForDate(date time.Time) (bool, error) {
 	var exists bool
@@ -116,6 +371,56 @@ func (r *tradesRepository) HasIngestionForDate(date time.Time) (bool, error) {
 	return exists, nil
 }
 
+// GetLatestIngestion returns the ingestion_log entry with the most recent file_date,
+// or (nil, nil) when nothing has been ingested yet.
+func (r *tradesRepository) GetLatestIngestion() (*models.IngestionLogEntry, error) {
+	var e models.IngestionLogEntry
+	err := r.db.QueryRow(`
+		SELECT file_date, filename, row_count, ingested_at
+		FROM ingestion_log
+		ORDER BY file_date DESC
+		LIMIT 1
+	`).Scan(&e.FileDate, &e.Filename, &e.RowCount, &e.IngestedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// FindMissingIngestionDates returns the business days between start and end
+// (inclusive, see calendar.BusinessDaysBetween) that have no ingestion_log
+// entry, in ascending order; the slice is empty (never nil) when there are none.
+func (r *tradesRepository) FindMissingIngestionDates(start time.Time, end time.Time) ([]time.Time, error) {
+	rows, err := r.db.Query(`SELECT file_date FROM ingestion_log WHERE file_date >= $1 AND file_date <= $2`, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ingested := make(map[string]struct{})
+	for rows.Next() {
+		var d time.Time
+		if err := rows.Scan(&d); err != nil {
+			return nil, err
+		}
+		ingested[d.Format("2006-01-02")] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	missing := []time.Time{}
+	for _, day := range calendar.BusinessDaysBetween(start, end) {
+		if _, ok := ingested[day.Format("2006-01-02")]; !ok {
+			missing = append(missing, day)
+		}
+	}
+	return missing, nil
+}
+
 // UpsertIngestionLog records (or updates) an ingestion entry for a given day.
 func (r *tradesRepository) UpsertIngestionLog(date time.Time, filename string, rowCount int) error {
 	_, err := r.db.Exec(`
@@ -129,64 +434,610 @@ func (r *tradesRepository) UpsertIngestionLog(date time.Time, filename string, r
 	return err
 }
 
+// UpsertInstruments inserts or updates instrument reference data in one
+// statement, keyed by instrument_code. Codes must be unique within the slice.
+func (r *tradesRepository) UpsertInstruments(instruments []models.Instrument) error {
+	if len(instruments) == 0 {
+		return nil
+	}
+	codes := make([]string, len(instruments))
+	isins := make([]string, len(instruments))
+	names := make([]string, len(instruments))
+	for i, in := range instruments {
+		codes[i], isins[i], names[i] = in.Code, in.ISIN, in.Name
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO instruments (instrument_code, isin, name)
+		SELECT * FROM unnest($1::text[], $2::text[], $3::text[])
+		ON CONFLICT (instrument_code) DO UPDATE SET isin = EXCLUDED.isin,
+		                                            name = EXCLUDED.name,
+		                                            updated_at = NOW()
+	`, pq.Array(codes), pq.Array(isins), pq.Array(names))
+	return err
+}
+
+// GetInstrument returns the reference data of an instrument, or (nil, nil)
+// when the instruments file never listed it.
+func (r *tradesRepository) GetInstrument(code string) (*models.Instrument, error) {
+	in := models.Instrument{Code: code}
+	err := r.db.QueryRow(`SELECT isin, name FROM instruments WHERE instrument_code = $1`, code).Scan(&in.ISIN, &in.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &in, nil
+}
+
+// CountTradesByDate returns how many trades are stored for a given trade_date.
+func (r *tradesRepository) CountTradesByDate(date time.Time) (int64, error) {
+	var n int64
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM trades WHERE trade_date = $1`, date).Scan(&n)
+	return n, err
+}
+
 // DeleteTradesByDate removes all trades for a given trade_date.
 func (r *tradesRepository) DeleteTradesByDate(date time.Time) error {
 	_, err := r.db.Exec(`DELETE FROM trades WHERE trade_date = $1`, date)
 	return err
 }
 
-// GetAggregateByTicker returns max price and max daily volume for a ticker.
-func (r *tradesRepository) GetAggregateByTicker(ticker string, startDate *time.Time, endDate *time.Time) (*models.Aggregate, error) {
+// EnsurePartitionForDate creates the monthly partition of trades holding date
+// unless it already exists (ensure_trades_partition, migration 0009). It fails
+// when trades has not been partitioned with partition_trades_by_month().
+func (r *tradesRepository) EnsurePartitionForDate(date time.Time) error {
+	_, err := r.db.Exec(`SELECT ensure_trades_partition($1)`, date)
+	return err
+}
+
+// RecordIngestionRun stores an audit row describing a whole ingestion run.
+func (r *tradesRepository) RecordIngestionRun(audit models.IngestionAudit) error {
+	dates := make([]string, 0, len(audit.RequestedDates))
+	for _, d := range audit.RequestedDates {
+		dates = append(dates, d.Format("2006-01-02"))
+	}
+	errs := audit.Errors
+	if errs == nil {
+		errs = []string{}
+	}
+
+	_, err := r.db.Exec(`
+		INSERT INTO ingestion_audit (
+			run_id, mode, requested_dates, files_processed, total_rows,
+			errors, success, force, started_at, finished_at
+		) VALUES ($1, $2, $3::date[], $4, $5, $6, $7, $8, $9, $10)
+	`,
+		audit.RunID,
+		audit.Mode,
+		pq.Array(dates),
+		audit.FilesProcessed,
+		audit.TotalRows,
+		pq.Array(errs),
+		audit.Success,
+		audit.Force,
+		audit.StartedAt,
+		audit.FinishedAt,
+	)
+	return err
+}
+
+// GetAggregateByTicker returns max price, max daily volume and trade count for a ticker,
+// the number of days with trades (Aggregate.TradingDays) and the latest of them
+// (Aggregate.LatestDate).
+//
+// A non-nil opts.MinQty restricts the max price to trades of at least that
+// quantity, so single-share fat-finger prints don't set it; volumes and the
+// trade count still cover every trade. If no trade reaches it the max price is zero.
+//
+// opts.DateField selects the column the range and the daily volumes use (see
+// DateFieldTradeDate, DateFieldReferenceDate); empty means trade_date.
+// endDate is kept in the range (column <= endDate) unless opts.EndExclusive
+// makes it an exclusive upper bound (column < endDate).
+func (r *tradesRepository) GetAggregateByTicker(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time, opts AggregateOptions) (*models.Aggregate, error) {
 	var agg models.Aggregate
 	agg.Ticker = ticker
 
+	query, args, err := aggregateQuery(ticker, startDate, endDate, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The query text only varies with which filters are set, so each shape is
+	// prepared once and reused instead of being parsed on every call.
+	stmt, err := r.stmts.get(ctx, r.db, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var maxPrice sql.NullFloat64
+	var maxVolume, minVolume sql.NullInt64
+	var tradeCount int64
+	var latest sql.NullTime
+	var tradingDays int
+
+	start := time.Now()
+	err = stmt.QueryRowContext(ctx, args...).Scan(&maxPrice, &maxVolume, &minVolume, &tradeCount, &latest, &tradingDays)
+	r.warnIfSlow(ctx, ticker, time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+
+	// If both are NULL, there is no data for this ticker/date range.
+	if !maxPrice.Valid && !maxVolume.Valid {
+		return nil, nil
+	}
+
+	if maxPrice.Valid {
+		agg.MaxRangeValue = maxPrice.Float64
+	}
+	if maxVolume.Valid {
+		agg.MaxDailyVolume = maxVolume.Int64
+	}
+	if minVolume.Valid {
+		agg.MinDailyVolume = minVolume.Int64
+	}
+	agg.TradeCount = tradeCount
+	agg.TradingDays = tradingDays
+	agg.LatestDate = latest.Time
+
+	return &agg, nil
+}
+
+// aggregateIndexHint is the index the slow aggregate warning suggests; migration
+// 0003 creates it as idx_trades_instr_date.
+const aggregateIndexHint = "CREATE INDEX ON trades (instrument_code, trade_date)"
+
+// warnIfSlow logs a Warn through the request logger in ctx when an aggregate
+// query for ticker took longer than the WithSlowQueryWarn threshold, with
+// whether trades has an index leading with (instrument_code, trade_date).
+func (r *tradesRepository) warnIfSlow(ctx context.Context, ticker string, elapsed time.Duration) {
+	if r.slowQueryWarn <= 0 || elapsed < r.slowQueryWarn {
+		return
+	}
+	ev := logger.FromContext(ctx).Warn().
+		Str("ticker", ticker).
+		Int64("duration_ms", elapsed.Milliseconds()).
+		Int64("threshold_ms", r.slowQueryWarn.Milliseconds())
+
+	exists, err := r.hasAggregateIndex()
+	switch {
+	case err != nil:
+		ev.Str("suggested_index", aggregateIndexHint).AnErr("index_check_error", err).Msg("slow aggregate query")
+	case exists:
+		ev.Bool("index_exists", true).Msg("slow aggregate query despite an index on trades (instrument_code, trade_date)")
+	default:
+		ev.Bool("index_exists", false).Str("suggested_index", aggregateIndexHint).Msg("slow aggregate query; trades has no index on (instrument_code, trade_date)")
+	}
+}
+
+// hasAggregateIndex reports whether trades has an index whose leading columns
+// are (instrument_code, trade_date). pg_indexes is queried only the first time;
+// later calls return that answer, so slow requests don't add catalog lookups.
+func (r *tradesRepository) hasAggregateIndex() (bool, error) {
+	r.aggIndexOnce.Do(func() {
+		r.aggIndexErr = r.db.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1 FROM pg_indexes
+				WHERE schemaname = current_schema() AND tablename = 'trades'
+				  AND indexdef LIKE '%(instrument_code, trade_date%'
+			)`).Scan(&r.aggIndexExists)
+	})
+	return r.aggIndexExists, r.aggIndexErr
+}
+
+// aggregateQuery builds the GetAggregateByTicker query and its args.
+func aggregateQuery(ticker string, startDate *time.Time, endDate *time.Time, opts AggregateOptions) (string, []interface{}, error) {
+	column, err := dateColumn(opts.DateField)
+	if err != nil {
+		return "", nil, err
+	}
+
 	// Build dynamic conditions for date range filters.
 	// $1 is always ticker. Subsequent placeholders depend on provided dates.
-	conditions := "instrument_code = $1"
-	var args []interface{}
-	args = append(args, ticker)
-	if startDate != nil {
-		placeholder := len(args) + 1 // next positional param index
-		conditions += fmt.Sprintf(" AND trade_date >= $%d", placeholder)
-		args = append(args, *startDate)
+	inclusiveEnd := endDate
+	if opts.EndExclusive {
+		inclusiveEnd = nil
 	}
-	if endDate != nil {
-		placeholder := len(args) + 1
-		conditions += fmt.Sprintf(" AND trade_date <= $%d", placeholder)
+	conditions, args := withColumnRange(column, "instrument_code = $1", []interface{}{ticker}, startDate, inclusiveEnd)
+	if endDate != nil && opts.EndExclusive {
+		conditions += fmt.Sprintf(" AND %s < $%d", column, len(args)+1)
 		args = append(args, *endDate)
 	}
 
+	// The quantity floor applies to the price subquery only.
+	priceConditions := conditions
+	if opts.MinQty != nil {
+		priceConditions += fmt.Sprintf(" AND trade_quantity >= $%d", len(args)+1)
+		args = append(args, *opts.MinQty)
+	}
+
 	query := fmt.Sprintf(`
 		WITH daily AS (
-			SELECT trade_date, SUM(trade_quantity) AS daily_volume
+			SELECT %s, SUM(trade_quantity) AS daily_volume
 			FROM trades
 			WHERE %s
-			GROUP BY trade_date
+			GROUP BY %s
 		)
 		SELECT 
 			(SELECT MAX(trade_price) FROM trades WHERE %s) AS max_price,
-			(SELECT MAX(daily_volume) FROM daily) AS max_volume
-	`, conditions, conditions)
+			(SELECT MAX(daily_volume) FROM daily) AS max_volume,
+			(SELECT MIN(daily_volume) FROM daily) AS min_volume,
+			(SELECT COUNT(*) FROM trades WHERE %s) AS trade_count,
+			(SELECT MAX(%s) FROM daily) AS latest_date,
+			(SELECT COUNT(*) FROM daily) AS trading_days
+	`, column, conditions, column, priceConditions, conditions, column)
+	return query, args, nil
+}
 
+// ExplainAggregate runs EXPLAIN (ANALYZE, FORMAT JSON) on the GetAggregateByTicker
+// query and returns the plan as reported by Postgres. ANALYZE executes the
+// query, so this costs as much as the aggregate itself; it is meant for debugging.
+func (r *tradesRepository) ExplainAggregate(ctx context.Context, ticker string, startDate *time.Time, endDate *time.Time, opts AggregateOptions) (json.RawMessage, error) {
+	query, args, err := aggregateQuery(ticker, startDate, endDate, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []byte
+	if err := r.db.QueryRowContext(ctx, "EXPLAIN (ANALYZE, FORMAT JSON)"+query, args...).Scan(&plan); err != nil {
+		return nil, err
+	}
+	return json.RawMessage(plan), nil
+}
+
+// GetAggregateBySession computes the GetAggregateByTicker figures separately for
+// each session_type (e.g. regular session vs after-market). Volumes are daily
+// totals within the session. Trades without a session type are grouped under "".
+// The map is empty (never nil) when the ticker has no trades in the period.
+func (r *tradesRepository) GetAggregateBySession(ticker string, startDate *time.Time, endDate *time.Time) (map[string]*models.Aggregate, error) {
+	conditions, args := withDateRange("instrument_code = $1", []interface{}{ticker}, startDate, endDate)
+
+	query := fmt.Sprintf(`
+		WITH daily AS (
+			SELECT COALESCE(session_type, '') AS session_type, trade_date,
+				SUM(trade_quantity) AS daily_volume,
+				MAX(trade_price) AS max_price,
+				COUNT(*) AS trade_count
+			FROM trades
+			WHERE %s
+			GROUP BY 1, trade_date
+		)
+		SELECT session_type, MAX(max_price), MAX(daily_volume), MIN(daily_volume), SUM(trade_count), COUNT(*)
+		FROM daily
+		GROUP BY session_type
+		ORDER BY session_type
+	`, conditions)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]*models.Aggregate{}
+	for rows.Next() {
+		var session string
+		var maxPrice sql.NullFloat64
+		var maxVolume, minVolume sql.NullInt64
+		agg := &models.Aggregate{Ticker: ticker}
+		if err := rows.Scan(&session, &maxPrice, &maxVolume, &minVolume, &agg.TradeCount, &agg.TradingDays); err != nil {
+			return nil, err
+		}
+		agg.MaxRangeValue, agg.MaxDailyVolume, agg.MinDailyVolume = maxPrice.Float64, maxVolume.Int64, minVolume.Int64
+		out[session] = agg
+	}
+	return out, rows.Err()
+}
+
+// GetPriceSpread returns the max and min trade price for a ticker in the period
+// and the spread between them. It returns (nil, nil) when there are no trades.
+func (r *tradesRepository) GetPriceSpread(ticker string, startDate *time.Time, endDate *time.Time) (*models.PriceSpread, error) {
+	conditions, args := withDateRange("instrument_code = $1", []interface{}{ticker}, startDate, endDate)
+
+	var maxPrice, minPrice sql.NullFloat64
+	query := fmt.Sprintf(`SELECT MAX(trade_price), MIN(trade_price) FROM trades WHERE %s`, conditions)
+	if err := r.db.QueryRow(query, args...).Scan(&maxPrice, &minPrice); err != nil {
+		return nil, err
+	}
+	if !maxPrice.Valid || !minPrice.Valid {
+		return nil, nil
+	}
+
+	spread := &models.PriceSpread{
+		Ticker:    ticker,
+		MaxPrice:  maxPrice.Float64,
+		MinPrice:  minPrice.Float64,
+		SpreadAbs: maxPrice.Float64 - minPrice.Float64,
+	}
+	if minPrice.Float64 > 0 {
+		spread.SpreadPct = spread.SpreadAbs / minPrice.Float64 * 100
+	}
+	return spread, nil
+}
+
+// GetNotional returns the financial value traded in a ticker in the period,
+// SUM(trade_price * trade_quantity), computed in NUMERIC so the sum is exact
+// before the final conversion. It returns (nil, nil) when there are no trades.
+func (r *tradesRepository) GetNotional(ticker string, startDate *time.Time, endDate *time.Time) (*models.Notional, error) {
+	conditions, args := withDateRange("instrument_code = $1", []interface{}{ticker}, startDate, endDate)
+
+	n := &models.Notional{Ticker: ticker}
+	var notional sql.NullFloat64
+	query := fmt.Sprintf(`SELECT SUM(trade_price * trade_quantity), COUNT(*) FROM trades WHERE %s`, conditions)
+	if err := r.db.QueryRow(query, args...).Scan(&notional, &n.TradeCount); err != nil {
+		return nil, err
+	}
+	if n.TradeCount == 0 {
+		return nil, nil
+	}
+	n.Notional = notional.Float64
+	return n, nil
+}
+
+// GetLatestPrice returns the most recent trade of a ticker: latest trade_date,
+// then latest closing_time, with NULLs sorted last. Trades without a price
+// (INGEST_EMPTY_PRICE_NULL) are skipped. It returns (nil, nil) when the ticker
+// has no priced trades. The ordering matches idx_trades_instr_latest, so the
+// lookup is a single index probe.
+func (r *tradesRepository) GetLatestPrice(ticker string) (*models.LatestPrice, error) {
+	var (
+		p           = models.LatestPrice{Ticker: ticker}
+		tradeDate   sql.NullTime
+		closingTime sql.NullTime
+	)
+	err := r.db.QueryRow(`
+		SELECT trade_price, trade_quantity, trade_date, closing_time
+		FROM trades
+		WHERE instrument_code = $1 AND trade_price IS NOT NULL
+		ORDER BY trade_date DESC NULLS LAST, closing_time DESC NULLS LAST
+		LIMIT 1
+	`, ticker).Scan(&p.Price, &p.Quantity, &tradeDate, &closingTime)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.TradeDate, p.ClosingTime = tradeDate.Time, closingTime.Time
+	return &p, nil
+}
+
+// TickerExists reports whether any trade was ever recorded for the ticker, regardless of date.
+func (r *tradesRepository) TickerExists(ticker string) (bool, error) {
+	var exists bool
+	// Served by idx_trades_instrument_code; stops at the first matching row.
+	err := r.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM trades WHERE instrument_code = $1)`, ticker).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// GetDailyAggregate returns max price, total volume and trade count for a ticker on a single trade_date.
+// It returns (nil, nil) when the ticker did not trade on that day.
+func (r *tradesRepository) GetDailyAggregate(ticker string, date time.Time) (*models.DailyAggregate, error) {
 	var maxPrice sql.NullFloat64
-	var maxVolume sql.NullInt64
+	var totalVolume sql.NullInt64
+	var tradeCount int64
 
-	err := r.db.QueryRow(query, args...).Scan(&maxPrice, &maxVolume)
+	err := r.db.QueryRow(`
+		SELECT MAX(trade_price), SUM(trade_quantity), COUNT(*)
+		FROM trades
+		WHERE instrument_code = $1 AND trade_date = $2
+	`, ticker, date).Scan(&maxPrice, &totalVolume, &tradeCount)
 	if err != nil {
 		return nil, err
 	}
 
-	// If both are NULL, there is no data for this ticker/date range.
-	if !maxPrice.Valid && !maxVolume.Valid {
+	if tradeCount == 0 {
 		return nil, nil
 	}
 
-	if maxPrice.Valid {
-		agg.MaxRangeValue = maxPrice.Float64
+	return &models.DailyAggregate{
+		Ticker:      ticker,
+		Date:        date,
+		MaxPrice:    maxPrice.Float64,
+		TotalVolume: totalVolume.Int64,
+		TradeCount:  tradeCount,
+	}, nil
+}
+
+// GetDailyAggregates returns one GetDailyAggregate row per trade date with
+// trades between startDate and endDate (inclusive), in date order, plus the
+// close: the price of the day's last trade by closing_time (NULLs last, as in
+// GetLatestPrice). Days without trades are absent.
+func (r *tradesRepository) GetDailyAggregates(ticker string, startDate time.Time, endDate time.Time) ([]models.DailyAggregate, error) {
+	rows, err := r.db.Query(`
+		SELECT trade_date, MAX(trade_price), SUM(trade_quantity), COUNT(*),
+			(ARRAY_AGG(trade_price ORDER BY closing_time DESC NULLS LAST) FILTER (WHERE trade_price IS NOT NULL))[1]
+		FROM trades
+		WHERE instrument_code = $1 AND trade_date >= $2 AND trade_date <= $3
+		GROUP BY trade_date
+		ORDER BY trade_date
+	`, ticker, startDate, endDate)
+	if err != nil {
+		return nil, err
 	}
-	if maxVolume.Valid {
-		agg.MaxDailyVolume = maxVolume.Int64
+	defer rows.Close()
+
+	var out []models.DailyAggregate
+	for rows.Next() {
+		d := models.DailyAggregate{Ticker: ticker}
+		var maxPrice, closePrice sql.NullFloat64
+		var totalVolume sql.NullInt64
+		if err := rows.Scan(&d.Date, &maxPrice, &totalVolume, &d.TradeCount, &closePrice); err != nil {
+			return nil, err
+		}
+		d.MaxPrice, d.TotalVolume, d.ClosePrice = maxPrice.Float64, totalVolume.Int64, closePrice.Float64
+		out = append(out, d)
 	}
+	return out, rows.Err()
+}
 
-	return &agg, nil
+// GetParticipantActivity returns, per ticker, the volume a participant traded as buyer and as seller,
+// ordered by buy plus sell volume descending and capped at MaxParticipantTickers+1 rows.
+// A trade where the participant is on both sides counts on each side, in the order too.
+func (r *tradesRepository) GetParticipantActivity(code string, startDate *time.Time, endDate *time.Time) ([]models.ParticipantActivity, error) {
+	conditions, args := withDateRange("(buyer_participant_code = $1 OR seller_participant_code = $1)", []interface{}{code}, startDate, endDate)
+	args = append(args, MaxParticipantTickers+1)
+
+	query := fmt.Sprintf(`
+		SELECT instrument_code, buy_volume, sell_volume
+		FROM (
+			SELECT instrument_code,
+				COALESCE(SUM(trade_quantity) FILTER (WHERE buyer_participant_code = $1), 0) AS buy_volume,
+				COALESCE(SUM(trade_quantity) FILTER (WHERE seller_participant_code = $1), 0) AS sell_volume
+			FROM trades
+			WHERE %s
+			GROUP BY instrument_code
+		) activity
+		ORDER BY buy_volume + sell_volume DESC, instrument_code
+		LIMIT $%d
+	`, conditions, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.ParticipantActivity
+	for rows.Next() {
+		var a models.ParticipantActivity
+		if err := rows.Scan(&a.Ticker, &a.BuyVolume, &a.SellVolume); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// GetCrossTrades returns volume and trade count per ticker for trades where
+// buyer bought and seller sold, busiest first, skipping offset tickers and
+// returning at most limit. An empty buyer or seller matches any participant on
+// that side; callers must set at least one.
+func (r *tradesRepository) GetCrossTrades(buyer string, seller string, startDate *time.Time, endDate *time.Time, limit int, offset int) ([]models.CrossActivity, error) {
+	var parts []string
+	var args []interface{}
+	if buyer != "" {
+		args = append(args, buyer)
+		parts = append(parts, fmt.Sprintf("buyer_participant_code = $%d", len(args)))
+	}
+	if seller != "" {
+		args = append(args, seller)
+		parts = append(parts, fmt.Sprintf("seller_participant_code = $%d", len(args)))
+	}
+	if len(parts) == 0 {
+		return nil, errors.New("buyer or seller is required")
+	}
+	conditions, args := withDateRange(strings.Join(parts, " AND "), args, startDate, endDate)
+	args = append(args, limit, offset)
+
+	query := fmt.Sprintf(`
+		SELECT instrument_code, SUM(trade_quantity), COUNT(*)
+		FROM trades
+		WHERE %s
+		GROUP BY instrument_code
+		ORDER BY SUM(trade_quantity) DESC, instrument_code
+		LIMIT $%d OFFSET $%d
+	`, conditions, len(args)-1, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.CrossActivity
+	for rows.Next() {
+		var a models.CrossActivity
+		if err := rows.Scan(&a.Ticker, &a.Volume, &a.TradeCount); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// StreamTradesByDate calls fn for every trade on date, in closing-time order,
+// reading rows from the cursor one at a time instead of materializing them.
+// An empty ticker streams all instruments. A NULL price or quantity comes back
+// as models.MissingPrice / models.MissingQuantity. It stops at the first error
+// from fn or when ctx is canceled, which also aborts the query server-side.
+func (r *tradesRepository) StreamTradesByDate(ctx context.Context, ticker string, date time.Time, fn func(models.Trade) error) error {
+	conditions, args := "trade_date = $1", []interface{}{date}
+	if ticker != "" {
+		conditions += " AND instrument_code = $2"
+		args = append(args, ticker)
+	}
+
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT reference_date, instrument_code,
+			COALESCE(update_action, ''), trade_price, trade_quantity,
+			closing_time, COALESCE(trade_identifier_code, ''), COALESCE(session_type, ''), trade_date,
+			COALESCE(buyer_participant_code, ''), COALESCE(seller_participant_code, '')
+		FROM trades
+		WHERE %s
+		ORDER BY closing_time, instrument_code
+	`, conditions), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.Trade
+		var refDate, closing, tradeDate sql.NullTime
+		var price sql.NullFloat64
+		var qty sql.NullInt64
+		if err := rows.Scan(
+			&refDate, &t.InstrumentCode,
+			&t.UpdateAction, &price, &qty,
+			&closing, &t.TradeIdentifierCode, &t.SessionType, &tradeDate,
+			&t.BuyerParticipantCode, &t.SellerParticipantCode,
+		); err != nil {
+			return err
+		}
+		t.ReferenceDate, t.ClosingTime, t.TradeDate = refDate.Time, closing.Time, tradeDate.Time
+		t.TradePrice, t.TradeQuantity = models.MissingPrice(), models.MissingQuantity()
+		if price.Valid {
+			t.TradePrice = price.Float64
+		}
+		if qty.Valid {
+			t.TradeQuantity = qty.Int64
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// AnalyzeTrades refreshes planner statistics for the trades table so aggregate
+// queries pick good plans right after a large ingestion.
+func (r *tradesRepository) AnalyzeTrades(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `ANALYZE trades`)
+	return err
+}
+
+// withDateRange appends optional trade_date bounds to conditions, numbering
+// placeholders after the args already present.
+func withDateRange(conditions string, args []interface{}, startDate *time.Time, endDate *time.Time) (string, []interface{}) {
+	return withColumnRange("trade_date", conditions, args, startDate, endDate)
+}
+
+// withColumnRange is withDateRange on column, which must be a trusted literal.
+func withColumnRange(column string, conditions string, args []interface{}, startDate *time.Time, endDate *time.Time) (string, []interface{}) {
+	if startDate != nil {
+		placeholder := len(args) + 1 // next positional param index
+		conditions += fmt.Sprintf(" AND %s >= $%d", column, placeholder)
+		args = append(args, *startDate)
+	}
+	if endDate != nil {
+		placeholder := len(args) + 1
+		conditions += fmt.Sprintf(" AND %s <= $%d", column, placeholder)
+		args = append(args, *endDate)
+	}
+	return conditions, args
 }
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/docker/go-connections/nat"
+	"github.com/guttosm/b3pulse/internal/domain/models"
 	_ "github.com/lib/pq"
 	goose "github.com/pressly/goose/v3"
 	tc "github.com/testcontainers/testcontainers-go"
@@ -19,7 +20,7 @@ import (
 )
 
 // startPostgres spins up a Postgres container and returns a DSN and terminate func.
-func startPostgres(t *testing.T) (dsn string, terminate func()) {
+func startPostgres(t testing.TB) (dsn string, terminate func()) {
 	t.Helper()
 	ctx := context.Background()
 
@@ -55,7 +56,7 @@ func startPostgres(t *testing.T) (dsn string, terminate func()) {
 	return dsn, terminate
 }
 
-func openDB(t *testing.T, dsn string) *sql.DB {
+func openDB(t testing.TB, dsn string) *sql.DB {
 	t.Helper()
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
@@ -67,7 +68,7 @@ func openDB(t *testing.T, dsn string) *sql.DB {
 	return db
 }
 
-func runMigrations(t *testing.T, db *sql.DB) {
+func runMigrations(t testing.TB, db *sql.DB) {
 	t.Helper()
 	if err := goose.SetDialect("postgres"); err != nil {
 		t.Fatalf("dialect: %v", err)
@@ -79,7 +80,7 @@ func runMigrations(t *testing.T, db *sql.DB) {
 	}
 }
 
-func seedTrades(t *testing.T, db *sql.DB) (dates []time.Time) {
+func seedTrades(t testing.TB, db *sql.DB) (dates []time.Time) {
 	t.Helper()
 	// Insert multiple days for ticker TEST4
 	base := time.Date(2025, 9, 11, 0, 0, 0, 0, time.UTC)
@@ -127,8 +128,11 @@ func TestRepository_Integration_TableDriven(t *testing.T) {
 		name         string
 		start        *time.Time
 		end          *time.Time
+		exclusive    bool // end_inclusive=false
 		wantMaxPrice float64
 		wantMaxDaily int64
+		wantMinDaily int64
+		wantDays     int
 	}{
 		{
 			name:         "all dates",
@@ -136,6 +140,8 @@ func TestRepository_Integration_TableDriven(t *testing.T) {
 			end:          nil,
 			wantMaxPrice: 12.0, // from day3
 			wantMaxDaily: 200,  // from day2 volume
+			wantMinDaily: 100,  // day1 volume
+			wantDays:     3,
 		},
 		{
 			name:         "last 2 days only",
@@ -143,6 +149,8 @@ func TestRepository_Integration_TableDriven(t *testing.T) {
 			end:          nil,
 			wantMaxPrice: 12.0, // still day3
 			wantMaxDaily: 200,  // day2
+			wantMinDaily: 150,  // day3
+			wantDays:     2,
 		},
 		{
 			name:         "last day only",
@@ -150,6 +158,8 @@ func TestRepository_Integration_TableDriven(t *testing.T) {
 			end:          nil,
 			wantMaxPrice: 12.0,
 			wantMaxDaily: 150,
+			wantMinDaily: 150,
+			wantDays:     1,
 		},
 		{
 			name:         "upper-bound excludes day3",
@@ -157,24 +167,133 @@ func TestRepository_Integration_TableDriven(t *testing.T) {
 			end:          &dates[1], // up to day2 only
 			wantMaxPrice: 11.0,      // day1 max price was 11.0
 			wantMaxDaily: 200,       // day2 volume
+			wantMinDaily: 100,       // day1 volume
+			wantDays:     2,
+		},
+		{
+			name:         "exclusive upper bound excludes day2",
+			start:        &dates[0],
+			end:          &dates[1], // before day2 only
+			exclusive:    true,
+			wantMaxPrice: 11.0,
+			wantMaxDaily: 100, // day1 only
+			wantMinDaily: 100,
+			wantDays:     1,
 		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			agg, err := repo.GetAggregateByTicker("TEST4", tc.start, tc.end)
+			agg, err := repo.GetAggregateByTicker(context.Background(), "TEST4", tc.start, tc.end, AggregateOptions{EndExclusive: tc.exclusive})
 			if err != nil {
 				t.Fatalf("GetAggregateByTicker err: %v", err)
 			}
 			if agg == nil {
 				t.Fatalf("nil aggregate")
 			}
-			if agg.MaxRangeValue != tc.wantMaxPrice || agg.MaxDailyVolume != tc.wantMaxDaily {
-				t.Fatalf("got (price=%.2f, vol=%d), want (price=%.2f, vol=%d)", agg.MaxRangeValue, agg.MaxDailyVolume, tc.wantMaxPrice, tc.wantMaxDaily)
+			if agg.MaxRangeValue != tc.wantMaxPrice || agg.MaxDailyVolume != tc.wantMaxDaily || agg.MinDailyVolume != tc.wantMinDaily {
+				t.Fatalf("got (price=%.2f, vol=%d, min=%d), want (price=%.2f, vol=%d, min=%d)", agg.MaxRangeValue, agg.MaxDailyVolume, agg.MinDailyVolume, tc.wantMaxPrice, tc.wantMaxDaily, tc.wantMinDaily)
+			}
+			if agg.TradingDays != tc.wantDays {
+				t.Fatalf("trading days = %d, want %d", agg.TradingDays, tc.wantDays)
 			}
 		})
 	}
 
+	t.Run("daily aggregate", func(t *testing.T) {
+		daily, err := repo.GetDailyAggregate("TEST4", dates[0])
+		if err != nil || daily == nil {
+			t.Fatalf("GetDailyAggregate: daily=%+v err=%v", daily, err)
+		}
+		if daily.MaxPrice != 11.0 || daily.TotalVolume != 100 || daily.TradeCount != 2 {
+			t.Fatalf("unexpected daily aggregate: %+v", daily)
+		}
+		none, err := repo.GetDailyAggregate("TEST4", dates[0].AddDate(0, 0, -1))
+		if err != nil || none != nil {
+			t.Fatalf("expected nil for non-trading day, got %+v err=%v", none, err)
+		}
+	})
+
+	t.Run("ticker exists", func(t *testing.T) {
+		if ok, err := repo.TickerExists("TEST4"); err != nil || !ok {
+			t.Fatalf("TEST4 should exist: ok=%v err=%v", ok, err)
+		}
+		if ok, err := repo.TickerExists("NOPE3"); err != nil || ok {
+			t.Fatalf("NOPE3 should not exist: ok=%v err=%v", ok, err)
+		}
+	})
+
+	t.Run("participant activity", func(t *testing.T) {
+		// Seeded trades use buyer "B" and seller "S" throughout.
+		rows, err := repo.GetParticipantActivity("B", &dates[0], &dates[1])
+		if err != nil {
+			t.Fatalf("GetParticipantActivity: %v", err)
+		}
+		if len(rows) != 1 || rows[0].Ticker != "TEST4" || rows[0].BuyVolume != 300 || rows[0].SellVolume != 0 {
+			t.Fatalf("unexpected activity: %+v", rows)
+		}
+	})
+
+	t.Run("null price excluded from min", func(t *testing.T) {
+		// INGEST_EMPTY_PRICE_NULL: the empty price is stored as NULL, not 0.
+		trade := models.Trade{InstrumentCode: "NULP3", UpdateAction: "I", TradePrice: 8.0, TradeQuantity: 10,
+			TradeIdentifierCode: "N1", SessionType: "REG", TradeDate: dates[0]}
+		missing := trade
+		missing.TradePrice, missing.TradeIdentifierCode = models.MissingPrice(), "N2"
+		if err := repo.InsertTradesBatch([]models.Trade{trade, missing}); err != nil {
+			t.Fatalf("InsertTradesBatch: %v", err)
+		}
+		spread, err := repo.GetPriceSpread("NULP3", nil, nil)
+		if err != nil || spread == nil || spread.MinPrice != 8.0 || spread.MaxPrice != 8.0 {
+			t.Fatalf("want min=max=8, got %+v err=%v", spread, err)
+		}
+		var nulls int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM trades WHERE instrument_code = 'NULP3' AND trade_price IS NULL`).Scan(&nulls); err != nil || nulls != 1 {
+			t.Fatalf("want 1 NULL price, got %d err=%v", nulls, err)
+		}
+	})
+
+	t.Run("daily closes", func(t *testing.T) {
+		days, err := repo.GetDailyAggregates("TEST4", dates[0], dates[2])
+		if err != nil || len(days) != 3 {
+			t.Fatalf("GetDailyAggregates: days=%+v err=%v", days, err)
+		}
+		// Day 1's two trades share a closing_time, so either is its close.
+		if c := days[0].ClosePrice; c != 10.5 && c != 11.0 {
+			t.Fatalf("unexpected day 1 close: %.2f", c)
+		}
+		if days[1].ClosePrice != 9.0 || days[2].ClosePrice != 12.0 {
+			t.Fatalf("unexpected closes: %+v", days)
+		}
+	})
+
+	t.Run("notional across days", func(t *testing.T) {
+		cases := []struct {
+			name       string
+			start, end *time.Time
+			want       float64
+			wantTrades int64
+		}{
+			// 10.5*40 + 11*60 + 9*200 + 12*150
+			{name: "all dates", want: 4680, wantTrades: 4},
+			{name: "first two days", start: &dates[0], end: &dates[1], want: 2880, wantTrades: 3},
+			{name: "last day only", start: &dates[2], end: &dates[2], want: 1800, wantTrades: 1},
+		}
+		for _, tc := range cases {
+			n, err := repo.GetNotional("TEST4", tc.start, tc.end)
+			if err != nil || n == nil {
+				t.Fatalf("%s: GetNotional n=%+v err=%v", tc.name, n, err)
+			}
+			if n.Notional != tc.want || n.TradeCount != tc.wantTrades {
+				t.Fatalf("%s: got (notional=%.2f, trades=%d), want (notional=%.2f, trades=%d)", tc.name, n.Notional, n.TradeCount, tc.want, tc.wantTrades)
+			}
+		}
+		before := dates[0].AddDate(0, 0, -1)
+		if n, err := repo.GetNotional("TEST4", &before, &before); err != nil || n != nil {
+			t.Fatalf("expected nil for a day without trades, got %+v err=%v", n, err)
+		}
+	})
+
 	// Ingestion log upsert + exists
 	t.Run("ingestion log upsert+exists", func(t *testing.T) {
 		day := dates[0]
@@ -185,6 +304,10 @@ func TestRepository_Integration_TableDriven(t *testing.T) {
 		if err != nil || !ok {
 			t.Fatalf("exists want true, got ok=%v err=%v", ok, err)
 		}
+		latest, err := repo.GetLatestIngestion()
+		if err != nil || latest == nil || !latest.FileDate.Equal(day) || latest.RowCount != 123 {
+			t.Fatalf("latest ingestion: entry=%+v err=%v", latest, err)
+		}
 	})
 
 	// Delete by date
@@ -202,3 +325,94 @@ func TestRepository_Integration_TableDriven(t *testing.T) {
 		}
 	})
 }
+
+func TestRepository_Integration_Partitioning(t *testing.T) {
+	dsn, terminate := startPostgres(t)
+	defer terminate()
+	db := openDB(t, dsn)
+	defer db.Close()
+	runMigrations(t, db)
+	dates := seedTrades(t, db)
+	repo := NewTradesRepository(db)
+
+	if err := repo.EnsurePartitionForDate(dates[0]); err == nil {
+		t.Fatalf("expected an error before trades is partitioned")
+	}
+
+	// Converting keeps every row; running it again is a no-op.
+	for i := 0; i < 2; i++ {
+		if _, err := db.Exec(`SELECT partition_trades_by_month()`); err != nil {
+			t.Fatalf("partition_trades_by_month: %v", err)
+		}
+	}
+	exists := func(name string) bool {
+		var ok bool
+		if err := db.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, name).Scan(&ok); err != nil {
+			t.Fatalf("to_regclass: %v", err)
+		}
+		return ok
+	}
+	if !exists("trades_2025_09") || !exists("trades_default") || exists("trades_unpartitioned") {
+		t.Fatalf("unexpected partitions after conversion")
+	}
+	agg, err := repo.GetAggregateByTicker(context.Background(), "TEST4", nil, nil, AggregateOptions{})
+	if err != nil || agg == nil || agg.TradeCount != 4 || agg.TradingDays != 3 || agg.MaxRangeValue != 12.0 {
+		t.Fatalf("unexpected aggregate after conversion: %+v err=%v", agg, err)
+	}
+
+	// A new month gets its partition before its rows are inserted.
+	oct := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		if err := repo.EnsurePartitionForDate(oct); err != nil {
+			t.Fatalf("EnsurePartitionForDate: %v", err)
+		}
+	}
+	if !exists("trades_2025_10") {
+		t.Fatalf("expected partition trades_2025_10")
+	}
+	trade := models.Trade{ReferenceDate: oct, InstrumentCode: "TEST4", TradePrice: 13.0, TradeQuantity: 10, TradeDate: oct}
+	if err := repo.InsertTradesBatch([]models.Trade{trade}); err != nil {
+		t.Fatalf("InsertTradesBatch: %v", err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM trades_2025_10`).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("expected the October trade in its partition, got %d err=%v", n, err)
+	}
+}
+
+// BenchmarkGetAggregateByTicker compares the cached prepared statement with
+// sending the same query text on every call, which Postgres parses and plans
+// each time.
+func BenchmarkGetAggregateByTicker(b *testing.B) {
+	dsn, terminate := startPostgres(b)
+	defer terminate()
+	db := openDB(b, dsn)
+	defer db.Close()
+	runMigrations(b, db)
+	dates := seedTrades(b, db)
+	start, end := dates[0], dates[2]
+
+	b.Run("prepared", func(b *testing.B) {
+		repo := NewTradesRepository(db)
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.GetAggregateByTicker(context.Background(), "TEST4", &start, &end, AggregateOptions{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("unprepared", func(b *testing.B) {
+		query, args, err := aggregateQuery("TEST4", &start, &end, AggregateOptions{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		var maxPrice sql.NullFloat64
+		var maxVolume, minVolume sql.NullInt64
+		var tradeCount int64
+		var latest sql.NullTime
+		for i := 0; i < b.N; i++ {
+			if err := db.QueryRow(query, args...).Scan(&maxPrice, &maxVolume, &minVolume, &tradeCount, &latest); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -1,12 +1,18 @@
 package storage
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/guttosm/b3pulse/internal/domain/models"
+	"github.com/guttosm/b3pulse/internal/logger"
 )
 
 type dummyErr struct{}
@@ -29,7 +35,7 @@ func TestGetAggregateByTicker_SQLMock(t *testing.T) {
 	defer done()
 
 	// Common regex to avoid brittle query matching; focus on the final SELECT shape
-	selectRegex := regexp.MustCompile(`SELECT\s+\(SELECT MAX\(trade_price\) FROM trades WHERE .*\) AS max_price,\s*\(SELECT MAX\(daily_volume\) FROM daily\) AS max_volume`)
+	selectRegex := regexp.MustCompile(`SELECT\s+\(SELECT MAX\(trade_price\) FROM trades WHERE .*\) AS max_price,\s*\(SELECT MAX\(daily_volume\) FROM daily\) AS max_volume,\s*\(SELECT MIN\(daily_volume\) FROM daily\) AS min_volume,\s*\(SELECT COUNT\(\*\) FROM trades WHERE .*\) AS trade_count,\s*\(SELECT MAX\(trade_date\) FROM daily\) AS latest_date`)
 
 	day := time.Date(2025, 9, 12, 0, 0, 0, 0, time.UTC)
 	day2 := time.Date(2025, 9, 13, 0, 0, 0, 0, time.UTC)
@@ -41,19 +47,30 @@ func TestGetAggregateByTicker_SQLMock(t *testing.T) {
 		argsCount int
 		maxPrice  interface{}
 		maxVolume interface{}
+		minVolume interface{}
+		count     int64
+		latest    interface{}
+		days      int
 	}{
-		{name: "no dates", start: nil, end: nil, argsCount: 1, maxPrice: 12.3, maxVolume: int64(200)},
-		{name: "with start", start: &day, end: nil, argsCount: 2, maxPrice: 9.1, maxVolume: int64(100)},
-		{name: "with range", start: &day, end: &day2, argsCount: 3, maxPrice: 10.0, maxVolume: int64(150)},
-		{name: "no data (NULLs)", start: &day, end: &day2, argsCount: 3, maxPrice: nil, maxVolume: nil},
+		{name: "no dates", start: nil, end: nil, argsCount: 1, maxPrice: 12.3, maxVolume: int64(200), minVolume: int64(20), count: 7, latest: day2, days: 2},
+		{name: "with start", start: &day, end: nil, argsCount: 2, maxPrice: 9.1, maxVolume: int64(100), minVolume: int64(100), latest: day, days: 1},
+		{name: "with range", start: &day, end: &day2, argsCount: 3, maxPrice: 10.0, maxVolume: int64(150), minVolume: int64(90), latest: day2, days: 2},
+		{name: "no data (NULLs)", start: &day, end: &day2, argsCount: 3, maxPrice: nil, maxVolume: nil, minVolume: nil},
 	}
 
+	// Each query shape is prepared once; later calls reuse the statement.
+	prepared := map[int]bool{}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			// Build result row; nil,nil means database NULLs
 			price := tc.maxPrice
 			volume := tc.maxVolume
-			rows := sqlmock.NewRows([]string{"max_price", "max_volume"}).AddRow(price, volume)
+			rows := sqlmock.NewRows([]string{"max_price", "max_volume", "min_volume", "trade_count", "latest_date", "trading_days"}).AddRow(price, volume, tc.minVolume, tc.count, tc.latest, tc.days)
+
+			if !prepared[tc.argsCount] {
+				mock.ExpectPrepare(selectRegex.String())
+				prepared[tc.argsCount] = true
+			}
 
 			switch tc.argsCount {
 			case 1:
@@ -70,13 +87,13 @@ func TestGetAggregateByTicker_SQLMock(t *testing.T) {
 					WillReturnRows(rows)
 			}
 
-			out, err := repo.GetAggregateByTicker("TEST4", tc.start, tc.end)
+			out, err := repo.GetAggregateByTicker(context.Background(), "TEST4", tc.start, tc.end, AggregateOptions{})
 			if tc.maxPrice == nil && tc.maxVolume == nil {
 				if err != nil || out != nil {
 					t.Fatalf("want nil,nil got out=%+v err=%v", out, err)
 				}
 			} else {
-				if err != nil || out == nil {
+				if err != nil || out == nil || out.TradeCount != tc.count || out.TradingDays != tc.days || out.MinDailyVolume != tc.minVolume.(int64) || !out.LatestDate.Equal(tc.latest.(time.Time)) {
 					t.Fatalf("unexpected out=%+v err=%v", out, err)
 				}
 			}
@@ -88,6 +105,217 @@ func TestGetAggregateByTicker_SQLMock(t *testing.T) {
 	}
 }
 
+func TestGetAggregateByTicker_MinQty_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	day := time.Date(2025, 9, 12, 0, 0, 0, 0, time.UTC)
+	minQty := int64(100)
+	// Only the price subquery carries the quantity floor.
+	priceRegex := `\(SELECT MAX\(trade_price\) FROM trades WHERE instrument_code = \$1 AND trade_date >= \$2 AND trade_quantity >= \$3\) AS max_price,.*\(SELECT COUNT\(\*\) FROM trades WHERE instrument_code = \$1 AND trade_date >= \$2\) AS trade_count`
+
+	mock.ExpectPrepare(priceRegex)
+	mock.ExpectQuery(priceRegex).WithArgs("TEST4", day, minQty).
+		WillReturnRows(sqlmock.NewRows([]string{"max_price", "max_volume", "min_volume", "trade_count", "latest_date", "trading_days"}).AddRow(20.5, int64(500), int64(10), int64(9), day, 3))
+	out, err := repo.GetAggregateByTicker(context.Background(), "TEST4", &day, nil, AggregateOptions{MinQty: &minQty})
+	if err != nil || out == nil || out.MaxRangeValue != 20.5 || out.TradeCount != 9 || out.TradingDays != 3 {
+		t.Fatalf("unexpected out=%+v err=%v", out, err)
+	}
+
+	// No trade reaches the floor: price is NULL but the ticker still has data.
+	mock.ExpectQuery(priceRegex).WithArgs("TEST4", day, minQty).
+		WillReturnRows(sqlmock.NewRows([]string{"max_price", "max_volume", "min_volume", "trade_count", "latest_date", "trading_days"}).AddRow(nil, int64(50), int64(50), int64(3), day, 1))
+	out, err = repo.GetAggregateByTicker(context.Background(), "TEST4", &day, nil, AggregateOptions{MinQty: &minQty})
+	if err != nil || out == nil || out.MaxRangeValue != 0 || out.MaxDailyVolume != 50 {
+		t.Fatalf("unexpected out=%+v err=%v", out, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetAggregateByTicker_EndInclusive_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	boundary := time.Date(2025, 9, 12, 0, 0, 0, 0, time.UTC)
+	cols := []string{"max_price", "max_volume", "min_volume", "trade_count", "latest_date", "trading_days"}
+
+	// Inclusive (default): the boundary day is in the range.
+	mock.ExpectPrepare(regexp.QuoteMeta("WHERE instrument_code = $1 AND trade_date >= $2 AND trade_date <= $3"))
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE instrument_code = $1 AND trade_date >= $2 AND trade_date <= $3")).
+		WithArgs("TEST4", start, boundary).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(12.0, int64(200), int64(100), int64(5), boundary, 2))
+	if out, err := repo.GetAggregateByTicker(context.Background(), "TEST4", &start, &boundary, AggregateOptions{}); err != nil || out == nil || out.TradeCount != 5 {
+		t.Fatalf("inclusive: out=%+v err=%v", out, err)
+	}
+
+	// Exclusive: the boundary day is left out, minQty still binds after it.
+	minQty := int64(10)
+	mock.ExpectPrepare(regexp.QuoteMeta("WHERE instrument_code = $1 AND trade_date >= $2 AND trade_date < $3 AND trade_quantity >= $4"))
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE instrument_code = $1 AND trade_date >= $2 AND trade_date < $3 AND trade_quantity >= $4")).
+		WithArgs("TEST4", start, boundary, minQty).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(11.0, int64(100), int64(100), int64(2), boundary, 1))
+	if out, err := repo.GetAggregateByTicker(context.Background(), "TEST4", &start, &boundary, AggregateOptions{MinQty: &minQty, EndExclusive: true}); err != nil || out == nil || out.TradeCount != 2 {
+		t.Fatalf("exclusive: out=%+v err=%v", out, err)
+	}
+
+	// Exclusive without an end date: nothing to exclude.
+	mock.ExpectPrepare(`WHERE instrument_code = \$1 AND trade_date >= \$2\s+GROUP BY`)
+	mock.ExpectQuery(`WHERE instrument_code = \$1 AND trade_date >= \$2\s+GROUP BY`).WithArgs("TEST4", start).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(12.0, int64(200), int64(100), int64(5), boundary, 2))
+	if _, err := repo.GetAggregateByTicker(context.Background(), "TEST4", &start, nil, AggregateOptions{EndExclusive: true}); err != nil {
+		t.Fatalf("open end: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetAggregateByTicker_ContextCancel_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	cols := []string{"max_price", "max_volume", "min_volume", "trade_count", "latest_date", "trading_days"}
+	mock.ExpectPrepare("SELECT")
+	mock.ExpectQuery("SELECT").WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(12.0, int64(200), int64(100), int64(5), time.Now(), 2))
+
+	// The request's deadline stops the query instead of letting it run on.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := repo.GetAggregateByTicker(ctx, "TEST4", nil, nil, AggregateOptions{}); err == nil {
+		t.Fatal("want an error once the context is done")
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Fatalf("query ran %s, past the context deadline", elapsed)
+	}
+}
+
+func TestGetAggregateByTicker_SlowQueryWarn_SQLMock(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "info")
+	t.Cleanup(logger.Init)
+	var buf bytes.Buffer
+	logger.InitTo(&buf)
+
+	repo, mock, done := newMockRepo(t)
+	defer done()
+	cols := []string{"max_price", "max_volume", "min_volume", "trade_count", "latest_date", "trading_days"}
+	row := func() *sqlmock.Rows {
+		return sqlmock.NewRows(cols).AddRow(12.0, int64(200), int64(100), int64(5), time.Now(), 2)
+	}
+	indexQuery := regexp.QuoteMeta("FROM pg_indexes")
+
+	// Disabled (the default): a slow query is not reported.
+	mock.ExpectPrepare("SELECT")
+	mock.ExpectQuery("SELECT").WillDelayFor(20 * time.Millisecond).WillReturnRows(row())
+	if _, err := repo.GetAggregateByTicker(context.Background(), "SLOW3", nil, nil, AggregateOptions{}); err != nil {
+		t.Fatalf("disabled: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("want no log with the check disabled, got %s", buf.String())
+	}
+
+	// Under the threshold: no warning and no index lookup.
+	WithSlowQueryWarn(time.Second)(repo)
+	mock.ExpectQuery("SELECT").WillReturnRows(row())
+	if _, err := repo.GetAggregateByTicker(context.Background(), "SLOW3", nil, nil, AggregateOptions{}); err != nil {
+		t.Fatalf("fast: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("want no log under the threshold, got %s", buf.String())
+	}
+
+	// Over the threshold without the index: warn through the request logger
+	// and suggest it. The index is looked up on the first slow query only.
+	WithSlowQueryWarn(10 * time.Millisecond)(repo)
+	reqLog := logger.L().With().Str("request_id", "req-1").Logger()
+	ctx := logger.WithContext(context.Background(), &reqLog)
+	mock.ExpectQuery("SELECT").WillDelayFor(20 * time.Millisecond).WillReturnRows(row())
+	mock.ExpectQuery(indexQuery).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT").WillDelayFor(20 * time.Millisecond).WillReturnRows(row())
+	for i := 0; i < 2; i++ {
+		if _, err := repo.GetAggregateByTicker(ctx, "SLOW3", nil, nil, AggregateOptions{}); err != nil {
+			t.Fatalf("slow: %v", err)
+		}
+	}
+	out := buf.String()
+	for _, want := range []string{`"level":"warn"`, `"request_id":"req-1"`, `"ticker":"SLOW3"`, `"index_exists":false`, `"suggested_index":"CREATE INDEX ON trades (instrument_code, trade_date)"`, `"threshold_ms":10`} {
+		if strings.Count(out, want) != 2 {
+			t.Fatalf("want %s twice in %s", want, out)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+
+	// With the index: warn without a suggestion.
+	repo, mock, done2 := newMockRepo(t)
+	defer done2()
+	WithSlowQueryWarn(10 * time.Millisecond)(repo)
+	mock.ExpectPrepare("SELECT")
+	mock.ExpectQuery("SELECT").WillDelayFor(20 * time.Millisecond).WillReturnRows(row())
+	mock.ExpectQuery(indexQuery).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	buf.Reset()
+	if _, err := repo.GetAggregateByTicker(context.Background(), "SLOW3", nil, nil, AggregateOptions{}); err != nil {
+		t.Fatalf("slow with index: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, `"index_exists":true`) || strings.Contains(out, "suggested_index") {
+		t.Fatalf("unexpected warning with the index: %s", out)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetAggregateByTicker_DateField_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	day := time.Date(2025, 9, 12, 0, 0, 0, 0, time.UTC)
+	// Both the range and the daily grouping switch to reference_date.
+	refRegex := `SELECT reference_date, SUM\(trade_quantity\) AS daily_volume\s+FROM trades\s+WHERE instrument_code = \$1 AND reference_date >= \$2\s+GROUP BY reference_date`
+
+	mock.ExpectPrepare(refRegex)
+	mock.ExpectQuery(refRegex).WithArgs("TEST4", day).
+		WillReturnRows(sqlmock.NewRows([]string{"max_price", "max_volume", "min_volume", "trade_count", "latest_date", "trading_days"}).AddRow(20.5, int64(500), int64(10), int64(9), day, 4))
+	out, err := repo.GetAggregateByTicker(context.Background(), "TEST4", &day, nil, AggregateOptions{DateField: DateFieldReferenceDate})
+	if err != nil || out == nil || out.TradeCount != 9 {
+		t.Fatalf("unexpected out=%+v err=%v", out, err)
+	}
+
+	// Anything outside the allowlist is rejected before reaching the database.
+	if _, err := repo.GetAggregateByTicker(context.Background(), "TEST4", &day, nil, AggregateOptions{DateField: "trade_date; DROP TABLE trades"}); err == nil {
+		t.Fatalf("expected error for unsupported date field")
+	}
+	if _, err := repo.ExplainAggregate(context.Background(), "TEST4", nil, nil, AggregateOptions{DateField: "closing_time"}); err == nil {
+		t.Fatalf("expected error for unsupported date field")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestValidDateField(t *testing.T) {
+	for field, want := range map[string]bool{
+		"":                     true,
+		DateFieldTradeDate:     true,
+		DateFieldReferenceDate: true,
+		"closing_time":         false,
+		"TRADE_DATE":           false,
+	} {
+		if got := ValidDateField(field); got != want {
+			t.Fatalf("ValidDateField(%q)=%v, want %v", field, got, want)
+		}
+	}
+}
+
 func TestIngestionLog_SQLMock(t *testing.T) {
 	repo, mock, done := newMockRepo(t)
 	defer done()
@@ -109,6 +337,13 @@ func TestIngestionLog_SQLMock(t *testing.T) {
 		t.Fatalf("UpsertIngestionLog: %v", err)
 	}
 
+	// CountTradesByDate
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM trades WHERE trade_date = $1")).
+		WithArgs(d).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	if n, err := repo.CountTradesByDate(d); err != nil || n != 3 {
+		t.Fatalf("CountTradesByDate: n=%d err=%v", n, err)
+	}
+
 	// DeleteTradesByDate
 	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM trades WHERE trade_date = $1")).
 		WithArgs(d).WillReturnResult(sqlmock.NewResult(0, 3))
@@ -116,6 +351,13 @@ func TestIngestionLog_SQLMock(t *testing.T) {
 		t.Fatalf("DeleteTradesByDate: %v", err)
 	}
 
+	// EnsurePartitionForDate
+	mock.ExpectExec(regexp.QuoteMeta("SELECT ensure_trades_partition($1)")).
+		WithArgs(d).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := repo.EnsurePartitionForDate(d); err != nil {
+		t.Fatalf("EnsurePartitionForDate: %v", err)
+	}
+
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("unmet expectations: %v", err)
 	}
@@ -176,6 +418,141 @@ func TestInsertTradesBatch_SQLMock(t *testing.T) {
 	}
 }
 
+func TestInsertTradesBatch_MissingPriceIsNull(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	day := time.Date(2025, 9, 11, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SET LOCAL synchronous_commit = OFF")).WillReturnResult(sqlmock.NewResult(0, 0))
+	prep := mock.ExpectPrepare(".*")
+	prep.ExpectExec().WithArgs(day, "TEST4", "I", 10.5, int64(100), nil, "X", "REG", day, "B", "S").WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.ExpectExec().WithArgs(day, "TEST4", "I", nil, int64(100), nil, "Y", "REG", day, "B", "S").WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.ExpectExec().WithArgs(day, "TEST4", "I", 10.5, nil, nil, "Z", "REG", day, "B", "S").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(".*").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	trade := models.Trade{ReferenceDate: day, InstrumentCode: "TEST4", UpdateAction: "I", TradePrice: 10.5, TradeQuantity: 100,
+		TradeIdentifierCode: "X", SessionType: "REG", TradeDate: day, BuyerParticipantCode: "B", SellerParticipantCode: "S"}
+	missing := trade
+	missing.TradePrice, missing.TradeIdentifierCode = models.MissingPrice(), "Y"
+	noQty := trade
+	noQty.TradeQuantity, noQty.TradeIdentifierCode = models.MissingQuantity(), "Z"
+	if err := repo.InsertTradesBatch([]models.Trade{trade, missing, noQty}); err != nil {
+		t.Fatalf("InsertTradesBatch: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestInsertTradesBatch_TxTimeouts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock new: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	repo := NewTradesRepository(db, WithTxTimeouts(TxTimeouts{IdleInTransactionMs: 60000, StatementMs: 300000}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SET LOCAL synchronous_commit = OFF")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("SET LOCAL idle_in_transaction_session_timeout = 60000")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("SET LOCAL statement_timeout = 300000")).WillReturnError(dummyErr{})
+	mock.ExpectRollback()
+
+	if err := repo.InsertTradesBatch([]models.Trade{{}}); err == nil {
+		t.Fatalf("expected SET LOCAL error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLocalSettings(t *testing.T) {
+	if got := (&tradesRepository{}).localSettings(); len(got) != 0 {
+		t.Fatalf("zero timeouts should add no settings, got %v", got)
+	}
+	got := (&tradesRepository{txTimeouts: TxTimeouts{StatementMs: 5}}).localSettings()
+	if len(got) != 1 || got[0] != "SET LOCAL statement_timeout = 5" {
+		t.Fatalf("unexpected settings: %v", got)
+	}
+}
+
+func TestInsertTradesBatchWithCheckpoint_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	day := time.Date(2025, 9, 11, 0, 0, 0, 0, time.UTC)
+	cp := models.IngestionCheckpoint{FileDate: day, Filename: "11-09-2025_NEGOCIOSAVISTA.txt", LineNumber: 5001, RowCount: 5000}
+
+	// Checkpoint upserted inside the batch transaction, before commit
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SET LOCAL synchronous_commit = OFF")).WillReturnResult(sqlmock.NewResult(0, 0))
+	prep := mock.ExpectPrepare(".*")
+	prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(".*").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO ingestion_checkpoint")).
+		WithArgs(day, cp.Filename, 5001, 5000).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := repo.InsertTradesBatchWithCheckpoint([]models.Trade{{InstrumentCode: "TEST4"}}, cp); err != nil {
+		t.Fatalf("InsertTradesBatchWithCheckpoint: %v", err)
+	}
+
+	// A failed checkpoint write rolls back the batch
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SET LOCAL synchronous_commit = OFF")).WillReturnResult(sqlmock.NewResult(0, 0))
+	prep = mock.ExpectPrepare(".*")
+	prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(".*").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO ingestion_checkpoint")).WillReturnError(dummyErr{})
+	mock.ExpectRollback()
+	if err := repo.InsertTradesBatchWithCheckpoint([]models.Trade{{InstrumentCode: "TEST4"}}, cp); err == nil {
+		t.Fatalf("expected checkpoint error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestIngestionCheckpoint_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	day := time.Date(2025, 9, 11, 0, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta("FROM ingestion_checkpoint")
+
+	mock.ExpectQuery(query).WithArgs(day).
+		WillReturnRows(sqlmock.NewRows([]string{"file_date", "filename", "line_number", "row_count"}).
+			AddRow(day, "11-09-2025_NEGOCIOSAVISTA.txt", 10001, 9998))
+	cp, err := repo.GetIngestionCheckpoint(day)
+	if err != nil || cp == nil || cp.LineNumber != 10001 || cp.RowCount != 9998 || !cp.FileDate.Equal(day) {
+		t.Fatalf("unexpected checkpoint=%+v err=%v", cp, err)
+	}
+
+	// No checkpoint
+	mock.ExpectQuery(query).WithArgs(day).WillReturnRows(sqlmock.NewRows([]string{"file_date", "filename", "line_number", "row_count"}))
+	if cp, err := repo.GetIngestionCheckpoint(day); err != nil || cp != nil {
+		t.Fatalf("want nil, got checkpoint=%+v err=%v", cp, err)
+	}
+
+	// Query error
+	mock.ExpectQuery(query).WillReturnError(dummyErr{})
+	if _, err := repo.GetIngestionCheckpoint(day); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM ingestion_checkpoint WHERE file_date = $1")).WithArgs(day).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.DeleteIngestionCheckpoint(day); err != nil {
+		t.Fatalf("DeleteIngestionCheckpoint: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
 func TestInsertTradesBatch_ErrorOnBegin(t *testing.T) {
 	repo, mock, done := newMockRepo(t)
 	defer done()
@@ -223,3 +600,641 @@ func TestInsertTradesBatch_ErrorOnFinalExec(t *testing.T) {
 }
 
 // Note: We intentionally skip simulating stmt.Close() error path because sqlmock cannot intercept Close().
+
+func TestGetDailyAggregate_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	day := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta("SELECT MAX(trade_price), SUM(trade_quantity), COUNT(*)")
+
+	// Traded that day
+	mock.ExpectQuery(query).WithArgs("TEST4", day).
+		WillReturnRows(sqlmock.NewRows([]string{"max", "sum", "count"}).AddRow(10.5, int64(300), int64(7)))
+	out, err := repo.GetDailyAggregate("TEST4", day)
+	if err != nil || out == nil {
+		t.Fatalf("unexpected out=%+v err=%v", out, err)
+	}
+	if out.MaxPrice != 10.5 || out.TotalVolume != 300 || out.TradeCount != 7 || !out.Date.Equal(day) {
+		t.Fatalf("unexpected aggregate: %+v", out)
+	}
+
+	// No trades that day
+	mock.ExpectQuery(query).WithArgs("TEST4", day).
+		WillReturnRows(sqlmock.NewRows([]string{"max", "sum", "count"}).AddRow(nil, nil, int64(0)))
+	out, err = repo.GetDailyAggregate("TEST4", day)
+	if err != nil || out != nil {
+		t.Fatalf("want nil,nil got out=%+v err=%v", out, err)
+	}
+
+	// Query error
+	mock.ExpectQuery(query).WithArgs("TEST4", day).WillReturnError(dummyErr{})
+	if _, err := repo.GetDailyAggregate("TEST4", day); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRecordIngestionRun_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	started := time.Date(2025, 9, 19, 22, 0, 0, 0, time.UTC)
+	audit := models.IngestionAudit{
+		RunID:          "0199a0b2-0000-7000-8000-000000000000",
+		Mode:           "window",
+		RequestedDates: []time.Time{time.Date(2025, 9, 19, 0, 0, 0, 0, time.UTC), time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)},
+		FilesProcessed: 2,
+		TotalRows:      1234,
+		Success:        true,
+		Force:          true,
+		StartedAt:      started,
+		FinishedAt:     started.Add(time.Minute),
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO ingestion_audit")).
+		WithArgs(audit.RunID, "window", sqlmock.AnyArg(), 2, int64(1234), sqlmock.AnyArg(), true, true, audit.StartedAt, audit.FinishedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.RecordIngestionRun(audit); err != nil {
+		t.Fatalf("RecordIngestionRun: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetAggregateBySession_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	day := time.Date(2025, 9, 12, 0, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta("GROUP BY session_type")
+	mock.ExpectQuery(query).WithArgs("PETR4", day).
+		WillReturnRows(sqlmock.NewRows([]string{"session_type", "max", "max_volume", "min_volume", "count", "days"}).
+			AddRow("1", 10.5, int64(900), int64(300), int64(40), 3).
+			AddRow("6", nil, int64(50), int64(50), int64(2), 1))
+	mock.ExpectQuery(query).WithArgs("NOPE3").
+		WillReturnRows(sqlmock.NewRows([]string{"session_type", "max", "max_volume", "min_volume", "count", "days"}))
+
+	out, err := repo.GetAggregateBySession("PETR4", &day, nil)
+	if err != nil {
+		t.Fatalf("GetAggregateBySession: %v", err)
+	}
+	regular, after := out["1"], out["6"]
+	if len(out) != 2 || regular == nil || after == nil {
+		t.Fatalf("unexpected sessions: %+v", out)
+	}
+	if regular.Ticker != "PETR4" || regular.MaxRangeValue != 10.5 || regular.MaxDailyVolume != 900 || regular.MinDailyVolume != 300 || regular.TradeCount != 40 || regular.TradingDays != 3 {
+		t.Fatalf("unexpected regular session: %+v", regular)
+	}
+	if after.MaxRangeValue != 0 || after.TradeCount != 2 {
+		t.Fatalf("NULL price should map to zero: %+v", after)
+	}
+
+	out, err = repo.GetAggregateBySession("NOPE3", nil, nil)
+	if err != nil || out == nil || len(out) != 0 {
+		t.Fatalf("want empty non-nil map, got %v err=%v", out, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAnalyzeTrades_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	mock.ExpectExec(regexp.QuoteMeta("ANALYZE trades")).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := repo.AnalyzeTrades(context.Background()); err != nil {
+		t.Fatalf("AnalyzeTrades: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestExplainAggregate_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	plan := `[{"Plan":{"Node Type":"Result"},"Execution Time":0.1}]`
+	mock.ExpectQuery(`^EXPLAIN \(ANALYZE, FORMAT JSON\)\s+WITH daily AS`).
+		WithArgs("PETR4", start).
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow([]byte(plan)))
+
+	out, err := repo.ExplainAggregate(context.Background(), "PETR4", &start, nil, AggregateOptions{})
+	if err != nil || string(out) != plan {
+		t.Fatalf("unexpected plan %s err=%v", out, err)
+	}
+
+	mock.ExpectQuery("EXPLAIN").WillReturnError(dummyErr{})
+	if _, err := repo.ExplainAggregate(context.Background(), "PETR4", nil, nil, AggregateOptions{}); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetDailyAggregates_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 9, 30, 0, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta("GROUP BY trade_date")
+
+	mock.ExpectQuery(query).WithArgs("PETR4", start, end).
+		WillReturnRows(sqlmock.NewRows([]string{"trade_date", "max", "sum", "count", "close"}).
+			AddRow(start, 30.5, int64(500), int64(3), 29.75).
+			AddRow(start.AddDate(0, 0, 1), nil, nil, int64(0), nil))
+	out, err := repo.GetDailyAggregates("PETR4", start, end)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(out) != 2 || out[0].MaxPrice != 30.5 || out[0].ClosePrice != 29.75 || out[0].TotalVolume != 500 || out[0].TradeCount != 3 || out[1].TotalVolume != 0 || out[1].Ticker != "PETR4" {
+		t.Fatalf("unexpected rows: %+v", out)
+	}
+
+	// Query error
+	mock.ExpectQuery(query).WillReturnError(dummyErr{})
+	if _, err := repo.GetDailyAggregates("PETR4", start, end); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestFindMissingIngestionDates_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	// Mon 2025-09-15 .. Fri 2025-09-19, with Wednesday never ingested.
+	start := time.Date(2025, 9, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 9, 21, 0, 0, 0, 0, time.UTC) // through the weekend
+	query := regexp.QuoteMeta("SELECT file_date FROM ingestion_log WHERE file_date >= $1 AND file_date <= $2")
+	rows := sqlmock.NewRows([]string{"file_date"})
+	for _, d := range []int{15, 16, 18, 19} {
+		rows.AddRow(time.Date(2025, 9, d, 0, 0, 0, 0, time.UTC))
+	}
+	mock.ExpectQuery(query).WithArgs(start, end).WillReturnRows(rows)
+
+	missing, err := repo.FindMissingIngestionDates(start, end)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(missing) != 1 || !missing[0].Equal(time.Date(2025, 9, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected only 2025-09-17 missing, got %v", missing)
+	}
+
+	// Complete range: empty, not nil.
+	mock.ExpectQuery(query).WithArgs(start, start).
+		WillReturnRows(sqlmock.NewRows([]string{"file_date"}).AddRow(start))
+	missing, err = repo.FindMissingIngestionDates(start, start)
+	if err != nil || missing == nil || len(missing) != 0 {
+		t.Fatalf("want empty, got %v err=%v", missing, err)
+	}
+
+	// Query error
+	mock.ExpectQuery(query).WillReturnError(dummyErr{})
+	if _, err := repo.FindMissingIngestionDates(start, end); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetParticipantActivity_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 9, 30, 0, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta("WHERE (buyer_participant_code = $1 OR seller_participant_code = $1) AND trade_date >= $2 AND trade_date <= $3")
+
+	// Ranked by the buy+sell volume returned, not by the raw SUM(trade_quantity).
+	mock.ExpectQuery(query+`[\s\S]*ORDER BY buy_volume \+ sell_volume DESC, instrument_code`).WithArgs("3", start, end, MaxParticipantTickers+1).
+		WillReturnRows(sqlmock.NewRows([]string{"instrument_code", "buy_volume", "sell_volume"}).
+			AddRow("PETR4", int64(300), int64(100)).
+			AddRow("VALE3", int64(0), int64(50)))
+	out, err := repo.GetParticipantActivity("3", &start, &end)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(out) != 2 || out[0].Ticker != "PETR4" || out[0].BuyVolume != 300 || out[0].SellVolume != 100 || out[1].SellVolume != 50 {
+		t.Fatalf("unexpected rows: %+v", out)
+	}
+
+	// No date bounds: only code and limit are bound
+	mock.ExpectQuery(regexp.QuoteMeta("LIMIT $2")).WithArgs("3", MaxParticipantTickers+1).
+		WillReturnRows(sqlmock.NewRows([]string{"instrument_code", "buy_volume", "sell_volume"}))
+	out, err = repo.GetParticipantActivity("3", nil, nil)
+	if err != nil || len(out) != 0 {
+		t.Fatalf("want empty, got out=%+v err=%v", out, err)
+	}
+
+	// Query error
+	mock.ExpectQuery(query).WillReturnError(dummyErr{})
+	if _, err := repo.GetParticipantActivity("3", &start, &end); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetCrossTrades_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 9, 30, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE buyer_participant_code = $1 AND seller_participant_code = $2 AND trade_date >= $3 AND trade_date <= $4")).
+		WithArgs("3", "72", start, end, 10, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"instrument_code", "sum", "count"}).
+			AddRow("PETR4", int64(500), int64(4)).
+			AddRow("VALE3", int64(80), int64(1)))
+	out, err := repo.GetCrossTrades("3", "72", &start, &end, 10, 20)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(out) != 2 || out[0].Ticker != "PETR4" || out[0].Volume != 500 || out[0].TradeCount != 4 || out[1].Ticker != "VALE3" {
+		t.Fatalf("unexpected rows: %+v", out)
+	}
+
+	// Seller only, no date bounds
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE seller_participant_code = $1")).WithArgs("72", 5, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"instrument_code", "sum", "count"}))
+	out, err = repo.GetCrossTrades("", "72", nil, nil, 5, 0)
+	if err != nil || len(out) != 0 {
+		t.Fatalf("want empty, got out=%+v err=%v", out, err)
+	}
+
+	// Neither side set: rejected without querying
+	if _, err := repo.GetCrossTrades("", "", nil, nil, 5, 0); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// Query error
+	mock.ExpectQuery("FROM trades").WillReturnError(dummyErr{})
+	if _, err := repo.GetCrossTrades("3", "", nil, nil, 5, 0); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestTickerExists_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	query := regexp.QuoteMeta("SELECT EXISTS(SELECT 1 FROM trades WHERE instrument_code = $1)")
+	mock.ExpectQuery(query).WithArgs("PETR4").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(query).WithArgs("NOPE3").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(query).WithArgs("PETR4").WillReturnError(dummyErr{})
+
+	if ok, err := repo.TickerExists("PETR4"); err != nil || !ok {
+		t.Fatalf("want true, got ok=%v err=%v", ok, err)
+	}
+	if ok, err := repo.TickerExists("NOPE3"); err != nil || ok {
+		t.Fatalf("want false, got ok=%v err=%v", ok, err)
+	}
+	if _, err := repo.TickerExists("PETR4"); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetLatestIngestion_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	day := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2025, 9, 19, 2, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta("SELECT file_date, filename, row_count, ingested_at")
+
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"file_date", "filename", "row_count", "ingested_at"}).
+		AddRow(day, "18-09-2025_NEGOCIOSAVISTA.txt", int64(1234), at))
+	e, err := repo.GetLatestIngestion()
+	if err != nil || e == nil || !e.FileDate.Equal(day) || e.RowCount != 1234 || !e.IngestedAt.Equal(at) {
+		t.Fatalf("unexpected entry=%+v err=%v", e, err)
+	}
+
+	// Empty log
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"file_date", "filename", "row_count", "ingested_at"}))
+	if e, err := repo.GetLatestIngestion(); err != nil || e != nil {
+		t.Fatalf("want nil,nil got entry=%+v err=%v", e, err)
+	}
+
+	// Query error
+	mock.ExpectQuery(query).WillReturnError(dummyErr{})
+	if _, err := repo.GetLatestIngestion(); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestStreamTradesByDate_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	day := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+	closing := time.Date(0, 1, 1, 10, 15, 30, 0, time.UTC)
+	cols := []string{"reference_date", "instrument_code", "update_action", "trade_price", "trade_quantity",
+		"closing_time", "trade_identifier_code", "session_type", "trade_date", "buyer_participant_code", "seller_participant_code"}
+	query := `FROM trades\s+WHERE trade_date = \$1`
+
+	// Ticker filter, NULL dates/time scan to zero values, NULL price/quantity to the sentinels
+	mock.ExpectQuery(query+` AND instrument_code = \$2`).WithArgs(day, "PETR4").WillReturnRows(
+		sqlmock.NewRows(cols).
+			AddRow(day, "PETR4", "0", 10.5, int64(100), closing, "T1", "1", day, "3", "72").
+			AddRow(nil, "PETR4", "", nil, nil, nil, "", "", nil, "", ""))
+	var got []models.Trade
+	err := repo.StreamTradesByDate(context.Background(), "PETR4", day, func(tr models.Trade) error {
+		got = append(got, tr)
+		return nil
+	})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("unexpected got=%+v err=%v", got, err)
+	}
+	if got[0].TradePrice != 10.5 || !got[0].ClosingTime.Equal(closing) || got[0].SellerParticipantCode != "72" {
+		t.Fatalf("unexpected first row: %+v", got[0])
+	}
+	if !got[1].TradeDate.IsZero() || !got[1].ClosingTime.IsZero() || !got[1].IsPriceMissing() || !got[1].IsQuantityMissing() {
+		t.Fatalf("expected NULLs as zero values and missing price/quantity: %+v", got[1])
+	}
+
+	// All tickers; callback error stops the scan
+	mock.ExpectQuery(query).WithArgs(day).WillReturnRows(
+		sqlmock.NewRows(cols).
+			AddRow(day, "PETR4", "0", 10.5, int64(100), closing, "T1", "1", day, "3", "72").
+			AddRow(day, "VALE3", "0", 60.0, int64(10), closing, "T2", "1", day, "3", "72"))
+	calls := 0
+	err = repo.StreamTradesByDate(context.Background(), "", day, func(models.Trade) error {
+		calls++
+		return dummyErr{}
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("expected stop after first row, calls=%d err=%v", calls, err)
+	}
+
+	// Query error
+	mock.ExpectQuery(query).WillReturnError(dummyErr{})
+	if err := repo.StreamTradesByDate(context.Background(), "", day, func(models.Trade) error { return nil }); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetLatestPrice_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	day := time.Date(2025, 9, 19, 0, 0, 0, 0, time.UTC)
+	closing := time.Date(0, 1, 1, 17, 54, 59, 0, time.UTC)
+	query := `WHERE instrument_code = \$1 AND trade_price IS NOT NULL\s+ORDER BY trade_date DESC NULLS LAST, closing_time DESC NULLS LAST\s+LIMIT 1`
+	cols := []string{"trade_price", "trade_quantity", "trade_date", "closing_time"}
+
+	mock.ExpectQuery(query).WithArgs("PETR4").WillReturnRows(sqlmock.NewRows(cols).AddRow(21.5, int64(300), day, closing))
+	out, err := repo.GetLatestPrice("PETR4")
+	if err != nil || out == nil || out.Ticker != "PETR4" || out.Price != 21.5 || out.Quantity != 300 || !out.TradeDate.Equal(day) || !out.ClosingTime.Equal(closing) {
+		t.Fatalf("unexpected out=%+v err=%v", out, err)
+	}
+
+	// NULL closing time maps to the zero time.
+	mock.ExpectQuery(query).WithArgs("PETR4").WillReturnRows(sqlmock.NewRows(cols).AddRow(21.5, int64(300), day, nil))
+	if out, err := repo.GetLatestPrice("PETR4"); err != nil || out == nil || !out.ClosingTime.IsZero() {
+		t.Fatalf("unexpected out=%+v err=%v", out, err)
+	}
+
+	// No trades.
+	mock.ExpectQuery(query).WithArgs("UNKN3").WillReturnRows(sqlmock.NewRows(cols))
+	if out, err := repo.GetLatestPrice("UNKN3"); err != nil || out != nil {
+		t.Fatalf("want nil,nil got out=%+v err=%v", out, err)
+	}
+
+	mock.ExpectQuery(query).WithArgs("PETR4").WillReturnError(dummyErr{})
+	if _, err := repo.GetLatestPrice("PETR4"); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetPriceSpread_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	day := time.Date(2025, 9, 12, 0, 0, 0, 0, time.UTC)
+	day2 := time.Date(2025, 9, 19, 0, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta("SELECT MAX(trade_price), MIN(trade_price) FROM trades WHERE instrument_code = $1 AND trade_date >= $2 AND trade_date <= $3")
+	cols := []string{"max", "min"}
+
+	mock.ExpectQuery(query).WithArgs("PETR4", day, day2).WillReturnRows(sqlmock.NewRows(cols).AddRow(22.0, 20.0))
+	out, err := repo.GetPriceSpread("PETR4", &day, &day2)
+	if err != nil || out == nil || out.MaxPrice != 22 || out.MinPrice != 20 || out.SpreadAbs != 2 || out.SpreadPct != 10 {
+		t.Fatalf("unexpected out=%+v err=%v", out, err)
+	}
+
+	// A zero min price must not divide by zero.
+	mock.ExpectQuery(query).WithArgs("PETR4", day, day2).WillReturnRows(sqlmock.NewRows(cols).AddRow(5.0, 0.0))
+	out, err = repo.GetPriceSpread("PETR4", &day, &day2)
+	if err != nil || out == nil || out.SpreadAbs != 5 || out.SpreadPct != 0 {
+		t.Fatalf("unexpected out=%+v err=%v", out, err)
+	}
+
+	// No trades in range.
+	mock.ExpectQuery(query).WithArgs("PETR4", day, day2).WillReturnRows(sqlmock.NewRows(cols).AddRow(nil, nil))
+	if out, err := repo.GetPriceSpread("PETR4", &day, &day2); err != nil || out != nil {
+		t.Fatalf("want nil,nil got out=%+v err=%v", out, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT MAX(trade_price), MIN(trade_price) FROM trades WHERE instrument_code = $1")).
+		WithArgs("PETR4").WillReturnError(dummyErr{})
+	if _, err := repo.GetPriceSpread("PETR4", nil, nil); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetNotional_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	day := time.Date(2025, 9, 11, 0, 0, 0, 0, time.UTC)
+	day3 := time.Date(2025, 9, 13, 0, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta("SELECT SUM(trade_price * trade_quantity), COUNT(*) FROM trades WHERE instrument_code = $1 AND trade_date >= $2 AND trade_date <= $3")
+	cols := []string{"sum", "count"}
+
+	mock.ExpectQuery(query).WithArgs("PETR4", day, day3).WillReturnRows(sqlmock.NewRows(cols).AddRow(4680.0, 4))
+	out, err := repo.GetNotional("PETR4", &day, &day3)
+	if err != nil || out == nil || out.Ticker != "PETR4" || out.Notional != 4680 || out.TradeCount != 4 {
+		t.Fatalf("unexpected out=%+v err=%v", out, err)
+	}
+
+	// No trades in range.
+	mock.ExpectQuery(query).WithArgs("PETR4", day, day3).WillReturnRows(sqlmock.NewRows(cols).AddRow(nil, 0))
+	if out, err := repo.GetNotional("PETR4", &day, &day3); err != nil || out != nil {
+		t.Fatalf("want nil,nil got out=%+v err=%v", out, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT SUM(trade_price * trade_quantity), COUNT(*) FROM trades WHERE instrument_code = $1")).
+		WithArgs("PETR4").WillReturnError(dummyErr{})
+	if _, err := repo.GetNotional("PETR4", nil, nil); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestInstruments_SQLMock(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	// Empty batch is a no-op.
+	if err := repo.UpsertInstruments(nil); err != nil {
+		t.Fatalf("empty upsert: %v", err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO instruments (instrument_code, isin, name)")).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	in := []models.Instrument{{Code: "PETR4", ISIN: "BRPETRACNPR6", Name: "PETROBRAS"}, {Code: "VALE3", ISIN: "BRVALEACNOR0", Name: "VALE"}}
+	if err := repo.UpsertInstruments(in); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	query := regexp.QuoteMeta("SELECT isin, name FROM instruments WHERE instrument_code = $1")
+	mock.ExpectQuery(query).WithArgs("PETR4").
+		WillReturnRows(sqlmock.NewRows([]string{"isin", "name"}).AddRow("BRPETRACNPR6", "PETROBRAS"))
+	got, err := repo.GetInstrument("PETR4")
+	if err != nil || got == nil || got.Code != "PETR4" || got.ISIN != "BRPETRACNPR6" || got.Name != "PETROBRAS" {
+		t.Fatalf("unexpected got=%+v err=%v", got, err)
+	}
+
+	// Not listed in the reference file.
+	mock.ExpectQuery(query).WithArgs("XPTO3").WillReturnError(sql.ErrNoRows)
+	if got, err := repo.GetInstrument("XPTO3"); err != nil || got != nil {
+		t.Fatalf("want nil,nil got=%+v err=%v", got, err)
+	}
+
+	mock.ExpectQuery(query).WithArgs("PETR4").WillReturnError(dummyErr{})
+	if _, err := repo.GetInstrument("PETR4"); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestNewReplicaRepository_RoutesReadsToReplica(t *testing.T) {
+	primary, pmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock new: %v", err)
+	}
+	defer func() { _ = primary.Close() }()
+	replica, rmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock new: %v", err)
+	}
+	defer func() { _ = replica.Close() }()
+
+	repo := NewReplicaRepository(primary, replica)
+	d := time.Date(2025, 9, 18, 0, 0, 0, 0, time.UTC)
+
+	// Reads hit the replica only; the primary mock would fail on any query.
+	rmock.ExpectQuery(regexp.QuoteMeta("SELECT file_date, filename, row_count, ingested_at")).
+		WillReturnRows(sqlmock.NewRows([]string{"file_date", "filename", "row_count", "ingested_at"}).AddRow(d, "f.txt", int64(1), d))
+	if e, err := repo.GetLatestIngestion(); err != nil || e == nil {
+		t.Fatalf("GetLatestIngestion: entry=%+v err=%v", e, err)
+	}
+	rmock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS(SELECT 1 FROM trades WHERE instrument_code = $1)")).
+		WithArgs("PETR4").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	if ok, err := repo.TickerExists("PETR4"); err != nil || !ok {
+		t.Fatalf("TickerExists: ok=%v err=%v", ok, err)
+	}
+
+	// Writes hit the primary only.
+	pmock.ExpectExec(regexp.QuoteMeta("DELETE FROM trades WHERE trade_date = $1")).
+		WithArgs(d).WillReturnResult(sqlmock.NewResult(0, 3))
+	if err := repo.DeleteTradesByDate(d); err != nil {
+		t.Fatalf("DeleteTradesByDate: %v", err)
+	}
+
+	if err := rmock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("replica: %v", err)
+	}
+	if err := pmock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("primary: %v", err)
+	}
+
+	if _, ok := NewReplicaRepository(primary, nil).(*tradesRepository); !ok {
+		t.Fatalf("a nil replica should yield the plain primary repository")
+	}
+}
+
+func TestCheckSchemaVersion_SQLMock(t *testing.T) {
+	cases := []struct {
+		name    string
+		version any
+		wantErr bool
+	}{
+		{name: "current", version: int64(RequiredSchemaVersion)},
+		{name: "behind", version: int64(RequiredSchemaVersion - 1), wantErr: true},
+		{name: "no migrations", version: nil, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock, done := newMockRepo(t)
+			defer done()
+			mock.ExpectQuery(`SELECT MAX\(version_id\) FROM goose_db_version`).
+				WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(tc.version))
+			if err := CheckSchemaVersion(context.Background(), repo.db); (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequiredSchemaVersion_MatchesMigrations(t *testing.T) {
+	files, err := filepath.Glob("../../db/migrations/*.sql")
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	if len(files) != RequiredSchemaVersion {
+		t.Fatalf("RequiredSchemaVersion = %d, but db/migrations has %d files", RequiredSchemaVersion, len(files))
+	}
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestStmtCache_PreparesOncePerShape(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	// Concurrent first uses of one shape still prepare it only once.
+	const query = "SELECT 1"
+	mock.ExpectPrepare(query)
+	var wg sync.WaitGroup
+	stmts := make([]any, 8)
+	for i := range stmts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stmt, err := repo.stmts.get(context.Background(), repo.db, query)
+			if err != nil {
+				t.Errorf("get: %v", err)
+			}
+			stmts[i] = stmt
+		}()
+	}
+	wg.Wait()
+	for _, s := range stmts[1:] {
+		if s != stmts[0] {
+			t.Fatalf("expected one shared statement")
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestTradesRepository_Close(t *testing.T) {
+	repo, mock, done := newMockRepo(t)
+	defer done()
+
+	day := time.Date(2025, 9, 12, 0, 0, 0, 0, time.UTC)
+	cols := []string{"max_price", "max_volume", "min_volume", "trade_count", "latest_date", "trading_days"}
+	mock.ExpectPrepare(`AS latest_date`).WillBeClosed()
+	mock.ExpectQuery(`AS latest_date`).WithArgs("TEST4", day).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(10.0, int64(100), int64(100), int64(1), day, 1))
+	if _, err := repo.GetAggregateByTicker(context.Background(), "TEST4", &day, nil, AggregateOptions{}); err != nil {
+		t.Fatalf("GetAggregateByTicker: %v", err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// After Close the shape is prepared again on its next use.
+	mock.ExpectPrepare(`AS latest_date`)
+	mock.ExpectQuery(`AS latest_date`).WithArgs("TEST4", day).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(10.0, int64(100), int64(100), int64(1), day, 1))
+	if _, err := repo.GetAggregateByTicker(context.Background(), "TEST4", &day, nil, AggregateOptions{}); err != nil {
+		t.Fatalf("GetAggregateByTicker after Close: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}